@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// RegisterRequest is the body of a sign-up.
+type RegisterRequest struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	Locale       string `json:"locale,omitempty"`        // Email language; defaults to the Accept-Language match
+	InviteToken  string `json:"invite_token,omitempty"`  // Required in invite-only mode
+	AcceptTerms  bool   `json:"accept_terms,omitempty"`  // Required when the server tracks legal documents
+	CaptchaToken string `json:"captcha_token,omitempty"` // Required when CAPTCHAs are enabled for sign-up
+	FormToken    string `json:"form_token,omitempty"`    // From GET /auth/register/form, when the form time check is enabled
+}
+
+// LoginRequest is the body of a password sign-in.
+type LoginRequest struct {
+	Email        string `json:"email"`
+	Password     string `json:"password"`
+	StepUpCode   string `json:"step_up_code,omitempty"`  // Emailed code completing a flagged login
+	CaptchaToken string `json:"captcha_token,omitempty"` // Required after failed attempts when CAPTCHAs are enabled
+}
+
+// session is the data returned when a session is issued.
+type session struct {
+	Token     string `json:"token"`
+	CSRFToken string `json:"csrf_token"`
+}
+
+// Register creates an account and signs the client in as the new user.
+func (c *Client) Register(ctx context.Context, req RegisterRequest) error {
+	return c.signIn(ctx, "/auth/register", req)
+}
+
+// Login signs the client in with an email and password. A login that needs
+// a CAPTCHA or an emailed code fails with an *Error; retry it with
+// CaptchaToken or StepUpCode set.
+func (c *Client) Login(ctx context.Context, req LoginRequest) error {
+	return c.signIn(ctx, "/auth/login", req)
+}
+
+func (c *Client) signIn(ctx context.Context, path string, body any) error {
+	var s session
+	err := c.send(ctx, request{method: http.MethodPost, path: path, body: body}, &s)
+	if err != nil {
+		return err
+	}
+	c.setToken(s.Token, s.CSRFToken)
+	return nil
+}
+
+// Refresh exchanges the refresh cookie for a new access token. Requests
+// refresh automatically, so this is only needed to refresh ahead of time.
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refresh(ctx, "")
+}
+
+// refresh refreshes the access token unless stale is set and another
+// request has already replaced it, which would revoke the fresh token.
+func (c *Client) refresh(ctx context.Context, stale string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	if stale != "" && c.AccessToken() != stale {
+		return nil
+	}
+
+	var s session
+	err := c.send(ctx, request{method: http.MethodPost, path: "/auth/refresh", csrf: true}, &s)
+	if err != nil {
+		return err
+	}
+	// API v2 rotates the refresh token and with it the CSRF token; v1
+	// returns none and the current one stays valid
+	c.setToken(s.Token, s.CSRFToken)
+	return nil
+}
+
+// Logout revokes the client's session and forgets its tokens.
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.send(ctx, request{method: http.MethodPost, path: "/auth/revoke", csrf: true}, nil)
+	if err != nil {
+		return err
+	}
+	c.clearToken()
+	return nil
+}
+
+// RequestPasswordReset emails a reset link to the account with email, if
+// there is one.
+func (c *Client) RequestPasswordReset(ctx context.Context, email string) error {
+	body := map[string]string{"email": email}
+	return c.send(ctx, request{method: http.MethodPost, path: "/auth/request-password-reset", body: body}, nil)
+}
+
+// ConfirmPasswordReset sets a new password with the token from a reset link.
+// Every session of the account is revoked.
+func (c *Client) ConfirmPasswordReset(ctx context.Context, token, password string) error {
+	body := map[string]string{"token": token, "password": password}
+	return c.send(ctx, request{method: http.MethodPost, path: "/auth/confirm-password-reset", body: body}, nil)
+}
@@ -0,0 +1,276 @@
+// Package client is a Go client for the auth API. It keeps the access token
+// issued at sign-in, refreshes it through the refresh cookie shortly before
+// it expires, and decodes responses into typed values.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshLeeway is how long before its expiry an access token is refreshed.
+const refreshLeeway = 30 * time.Second
+
+// Client calls the auth API. It is safe for concurrent use; all requests
+// share its session.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	tenant  string // Sent in X-Tenant when set
+
+	mu        sync.Mutex
+	token     string    // Current access token
+	expiresAt time.Time // Expiry of token, zero when unknown
+	csrfToken string    // Echoed in X-CSRF-Token on refresh and sign-out
+
+	refreshMu sync.Mutex // Serializes refreshes
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc. A cookie jar is added when hc
+// has none, since the refresh token is kept in a cookie.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithAccessToken starts the client with an access token obtained
+// elsewhere. It cannot be refreshed without the matching refresh cookie.
+func WithAccessToken(token string) Option {
+	return func(c *Client) { c.setToken(token, "") }
+}
+
+// WithTenant addresses the tenant with the given slug through the X-Tenant
+// header. It is not needed when the tenant is chosen by subdomain.
+func WithTenant(slug string) Option {
+	return func(c *Client) { c.tenant = slug }
+}
+
+// New returns a client for the API at baseURL, including the version
+// prefix, e.g. "https://auth.example.com/api/v1".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	c := &Client{baseURL: strings.TrimRight(baseURL, "/")}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.http == nil {
+		c.http = &http.Client{Timeout: 30 * time.Second}
+	}
+	if c.http.Jar == nil {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		hc := *c.http
+		hc.Jar = jar
+		c.http = &hc
+	}
+	return c, nil
+}
+
+// AccessToken returns the current access token, or "" when signed out.
+func (c *Client) AccessToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// setToken stores an access token and, when set, a new CSRF token.
+func (c *Client) setToken(token, csrfToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.expiresAt = tokenExpiry(token)
+	if csrfToken != "" {
+		c.csrfToken = csrfToken
+	}
+}
+
+func (c *Client) clearToken() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.expiresAt, c.csrfToken = "", time.Time{}, ""
+}
+
+// Error is an API error response.
+type Error struct {
+	StatusCode int            // HTTP status, or the code of an error envelope sent with 200
+	Message    string         // Message from the API, possibly localized
+	Reason     string         // Machine-readable reason, when the API defines one
+	Details    map[string]any // The error's data, including the reason
+
+	tokenRejected bool // The JWT middleware rejected the access token
+}
+
+func (e *Error) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("auth api: %d %s (%s)", e.StatusCode, e.Message, e.Reason)
+	}
+	return fmt.Sprintf("auth api: %d %s", e.StatusCode, e.Message)
+}
+
+// IsUnauthorized reports whether err is an API error with status 401.
+func IsUnauthorized(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized
+}
+
+// envelope is the response shape shared by every endpoint.
+type envelope struct {
+	Success bool            `json:"success"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// request describes one API call.
+type request struct {
+	method string
+	path   string
+	body   any               // Encoded as JSON when not nil
+	header map[string]string // Extra headers
+	auth   bool              // Send the access token, refreshing it as needed
+	csrf   bool              // Echo the CSRF token
+}
+
+// do sends req and decodes the response data into out, when not nil. An
+// authenticated request whose access token is rejected is retried once after
+// a refresh.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	if req.auth {
+		if err := c.refreshIfExpiring(ctx); err != nil {
+			return err
+		}
+	}
+
+	sent := c.AccessToken()
+	err := c.send(ctx, req, out)
+	var apiErr *Error
+	if req.auth && errors.As(err, &apiErr) && apiErr.tokenRejected && c.canRefresh() {
+		if rerr := c.refresh(ctx, sent); rerr != nil {
+			return err
+		}
+		err = c.send(ctx, req, out)
+	}
+	return err
+}
+
+func (c *Client) send(ctx context.Context, req request, out any) error {
+	var body io.Reader
+	if req.body != nil {
+		encoded, err := json.Marshal(req.body)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, body)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if c.tenant != "" {
+		httpReq.Header.Set("X-Tenant", c.tenant)
+	}
+	if req.body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range req.header {
+		httpReq.Header.Set(k, v)
+	}
+
+	c.mu.Lock()
+	token, csrfToken := c.token, c.csrfToken
+	c.mu.Unlock()
+	if req.auth && token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	if req.csrf {
+		httpReq.Header.Set("X-CSRF-Token", csrfToken)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var env envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	// The JWT middleware reports rejected tokens as an error envelope with
+	// HTTP status 200
+	if resp.StatusCode >= 400 || !env.Success {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: env.Message}
+		if resp.StatusCode < 400 && env.Code >= 400 {
+			apiErr.StatusCode = env.Code
+			apiErr.tokenRejected = env.Code == http.StatusUnauthorized
+		}
+		if err := json.Unmarshal(env.Data, &apiErr.Details); err == nil {
+			apiErr.Reason, _ = apiErr.Details["reason"].(string)
+		}
+		return apiErr
+	}
+
+	if out != nil && len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, out); err != nil {
+			return fmt.Errorf("auth api: decode %s %s: %w", req.method, req.path, err)
+		}
+	}
+	return nil
+}
+
+// canRefresh reports whether the client holds a session it can refresh.
+func (c *Client) canRefresh() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.csrfToken != ""
+}
+
+// refreshIfExpiring refreshes the access token when it expires within
+// refreshLeeway.
+func (c *Client) refreshIfExpiring(ctx context.Context) error {
+	c.mu.Lock()
+	token, expiresAt := c.token, c.expiresAt
+	c.mu.Unlock()
+
+	if expiresAt.IsZero() || time.Until(expiresAt) > refreshLeeway || !c.canRefresh() {
+		return nil
+	}
+	return c.refresh(ctx, token)
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it. It returns
+// the zero time when the token cannot be read.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// User is the signed-in user's account, as returned by GET /user/@me.
+type User struct {
+	ID                    uint           `json:"id"`
+	Username              string         `json:"username"`
+	Email                 string         `json:"email"`
+	AccountType           string         `json:"account_type"` // "email", "oauth" or "hybrid"
+	Role                  string         `json:"role"`
+	FirstName             string         `json:"first_name"`
+	LastName              string         `json:"last_name"`
+	DisplayName           string         `json:"display_name"`
+	Bio                   string         `json:"bio"`
+	AvatarURL             string         `json:"avatar_url,omitempty"`
+	GravatarURL           string         `json:"gravatar_url,omitempty"`
+	Currency              string         `json:"currency"`
+	Timezone              string         `json:"timezone"`
+	Locale                string         `json:"locale"`
+	Metadata              map[string]any `json:"metadata,omitempty"`
+	EmailVerifiedAt       *time.Time     `json:"email_verified_at,omitempty"`
+	PasswordResetRequired bool           `json:"password_reset_required"`
+	Sessions              []Session      `json:"Sessions,omitempty"`
+	OAuthAccounts         []OAuthAccount `json:"OAuthLinks,omitempty"`
+	CreatedAt             time.Time      `json:"cat"`
+	UpdatedAt             time.Time      `json:"uat"`
+}
+
+// Session is one of the user's sign-ins.
+type Session struct {
+	ID        uint      `json:"id"`
+	JTI       string    `json:"jti"`
+	Revoked   bool      `json:"revoked"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// OAuthAccount is a provider linked to the user's account.
+type OAuthAccount struct {
+	ID         uint       `json:"id"`
+	Provider   string     `json:"provider"`
+	ProviderID string     `json:"provider_id"`
+	Email      string     `json:"email"`
+	Name       string     `json:"name"`
+	AvatarURL  string     `json:"avatar_url,omitempty"`
+	LinkedAt   time.Time  `json:"linked_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// ProfileUpdate holds the profile fields to change. nil fields are left
+// unchanged; an empty string clears a text field.
+type ProfileUpdate struct {
+	Username    string  `json:"username,omitempty"`
+	FirstName   *string `json:"first_name,omitempty"`
+	LastName    *string `json:"last_name,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	Currency    string  `json:"currency,omitempty"`
+	Timezone    string  `json:"timezone,omitempty"`
+	Locale      string  `json:"locale,omitempty"`
+}
+
+// Me returns the signed-in user with their sessions and linked providers.
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/user/@me", auth: true}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateProfile changes the signed-in user's profile and returns the
+// updated user.
+func (c *Client) UpdateProfile(ctx context.Context, update ProfileUpdate) (*User, error) {
+	var user User
+	if err := c.do(ctx, request{method: http.MethodPatch, path: "/user/profile", body: update, auth: true}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Sessions returns the signed-in user's sessions, including revoked ones.
+func (c *Client) Sessions(ctx context.Context) ([]Session, error) {
+	user, err := c.Me(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return user.Sessions, nil
+}
+
+// OAuthAccounts returns the providers linked to the signed-in user.
+func (c *Client) OAuthAccounts(ctx context.Context) ([]OAuthAccount, error) {
+	var accounts []OAuthAccount
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/user/oauth/accounts", auth: true}, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// ChangePassword replaces the signed-in user's password. Every session is
+// revoked and the client continues on the new session the server issues. It
+// returns how many other sessions were revoked.
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) (int64, error) {
+	body := map[string]string{"current_password": currentPassword, "password": newPassword}
+	var data struct {
+		session
+		RevokedSessions int64 `json:"revoked_sessions"`
+	}
+	if err := c.do(ctx, request{method: http.MethodPatch, path: "/user/password", body: body, auth: true}, &data); err != nil {
+		return 0, err
+	}
+	c.setToken(data.Token, data.CSRFToken)
+	return data.RevokedSessions, nil
+}
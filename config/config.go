@@ -0,0 +1,198 @@
+// Package config loads the settings the service cannot start without from
+// the environment and validates them once at startup, reporting every
+// missing or invalid value together. Optional settings with safe defaults
+// are still read by the packages that use them.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// minProductionSecretLength is the shortest JWT_SECRET accepted with
+// ENV=production.
+const minProductionSecretLength = 32
+
+// Config holds the core settings of the service.
+type Config struct {
+	Env       string // ENV, e.g. "development" or "production"
+	Port      string // PORT to listen on, default 5000
+	DBURI     string // DB_URI, a postgres:// connection URL
+	JWTSecret string // JWT_SECRET signing access tokens
+	BaseURL   string // BASE_URL this service is reached at, for OAuth redirects
+	ClientURL string // CLIENT_URL of the frontend, for links in emails
+	SMTP      SMTP
+}
+
+// SMTP holds the settings of the default email provider.
+type SMTP struct {
+	Host     string // SMTP_HOST, default smtp.gmail.com
+	Port     string // SMTP_PORT, default 587
+	Email    string // SMTP_EMAIL, also the default sender address
+	Password string // SMTP_PASSWORD
+}
+
+// Production reports whether ENV is "production".
+func (c *Config) Production() bool { return c.Env == "production" }
+
+// Development reports whether ENV is "development".
+func (c *Config) Development() bool { return c.Env == "development" }
+
+// Error lists every missing or invalid setting found by Load.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Option relaxes what Load requires.
+type Option func(*options)
+
+type options struct {
+	noDatabase bool
+	noSMTP     bool
+}
+
+// WithoutDatabase does not require DB_URI, for runs that open their own
+// database, such as demo mode.
+func WithoutDatabase() Option {
+	return func(o *options) { o.noDatabase = true }
+}
+
+// WithoutSMTP does not require the SMTP settings, for runs that bring their
+// own email sender or send no email.
+func WithoutSMTP() Option {
+	return func(o *options) { o.noSMTP = true }
+}
+
+var current atomic.Pointer[Config]
+
+// Load reads the configuration from the environment and validates it. On
+// success it becomes the one returned by Get; otherwise the returned *Error
+// lists all problems.
+func Load(opts ...Option) (*Config, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cfg := fromEnv()
+	if problems := cfg.validate(o); len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	current.Store(cfg)
+	return cfg, nil
+}
+
+// Get returns the configuration loaded at startup. Before Load has run,
+// e.g. in tests, it reads the environment on every call without validating
+// it.
+func Get() *Config {
+	if cfg := current.Load(); cfg != nil {
+		return cfg
+	}
+	return fromEnv()
+}
+
+func fromEnv() *Config {
+	return &Config{
+		Env:       os.Getenv("ENV"),
+		Port:      envOr("PORT", "5000"),
+		DBURI:     os.Getenv("DB_URI"),
+		JWTSecret: os.Getenv("JWT_SECRET"),
+		BaseURL:   strings.TrimSuffix(os.Getenv("BASE_URL"), "/"),
+		ClientURL: strings.TrimSuffix(os.Getenv("CLIENT_URL"), "/"),
+		SMTP: SMTP{
+			Host:     envOr("SMTP_HOST", "smtp.gmail.com"),
+			Port:     envOr("SMTP_PORT", "587"),
+			Email:    os.Getenv("SMTP_EMAIL"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		},
+	}
+}
+
+func (c *Config) validate(o options) []string {
+	var problems []string
+	problem := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !validPort(c.Port) {
+		problem("PORT must be a port number, got %q", c.Port)
+	}
+
+	switch {
+	case c.JWTSecret == "":
+		problem("JWT_SECRET is required")
+	case c.Production() && len(c.JWTSecret) < minProductionSecretLength:
+		problem("JWT_SECRET must be at least %d characters with ENV=production", minProductionSecretLength)
+	}
+
+	if !o.noDatabase {
+		if c.DBURI == "" {
+			problem("DB_URI is required")
+		} else if u, err := url.Parse(c.DBURI); err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+			// Do not echo the value, which usually holds a password
+			problem("DB_URI must be a postgres:// or postgresql:// URL")
+		}
+	}
+
+	if c.BaseURL == "" {
+		problem("BASE_URL is required")
+	} else if !validHTTPURL(c.BaseURL) {
+		problem("BASE_URL must be an http or https URL, got %q", c.BaseURL)
+	}
+	if c.ClientURL != "" && !validHTTPURL(c.ClientURL) {
+		problem("CLIENT_URL must be an http or https URL, got %q", c.ClientURL)
+	}
+
+	if !o.noSMTP && usesSMTP() {
+		if c.SMTP.Email == "" {
+			problem("SMTP_EMAIL is required with the smtp email provider")
+		}
+		if c.SMTP.Password == "" {
+			problem("SMTP_PASSWORD is required with the smtp email provider")
+		}
+		if !validPort(c.SMTP.Port) {
+			problem("SMTP_PORT must be a port number, got %q", c.SMTP.Port)
+		}
+	}
+
+	return problems
+}
+
+// usesSMTP reports whether emails are sent through SMTP, the default
+// provider, rather than logged or sent through an API provider.
+func usesSMTP() bool {
+	switch strings.ToLower(os.Getenv("EMAIL_MODE")) {
+	case "", "send":
+	default:
+		return false
+	}
+	provider := strings.ToLower(os.Getenv("EMAIL_PROVIDER"))
+	return provider == "" || provider == "smtp"
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func validHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
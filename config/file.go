@@ -0,0 +1,197 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFiles are looked for in the working directory when CONFIG_FILE is
+// not set.
+var defaultFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// fileKeys are the env vars set by LoadFile, with the values it set, which a
+// later call may change or unset. Variables from the process environment, or
+// changed since, e.g. by a secrets manager, are never touched.
+var fileKeys struct {
+	sync.Mutex
+	set map[string]string
+}
+
+// LoadFile applies a YAML or TOML config file to the environment, so every
+// setting can come from a file as well as from env vars. The file is named by
+// CONFIG_FILE, or is the first of config.yaml, config.yml and config.toml in
+// the working directory. A profile file next to it, named after the
+// CONFIG_PROFILE or ENV in effect (config.production.yaml for
+// ENV=production), is applied over it when present.
+//
+// Keys are env var names in any case; nested keys are joined with
+// underscores, so smtp: {host: ...} sets SMTP_HOST, and lists are joined
+// with commas. Variables already set in the environment, including through
+// .env, take precedence over both files. LoadFile returns the files it
+// applied.
+//
+// Calling it again reloads the files: settings it set before follow the
+// edited files, and ones removed from them are unset.
+func LoadFile() ([]string, error) {
+	fileKeys.Lock()
+	defer fileKeys.Unlock()
+
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		for _, name := range defaultFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+		if path == "" {
+			return nil, unsetFileKeys(nil)
+		}
+	}
+
+	settings, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	loaded := []string{path}
+
+	profile := processEnv("CONFIG_PROFILE")
+	if profile == "" {
+		profile = processEnv("ENV")
+	}
+	if profile == "" {
+		profile = settings["ENV"]
+	}
+	if profile != "" {
+		ext := filepath.Ext(path)
+		profilePath := strings.TrimSuffix(path, ext) + "." + profile + ext
+		overrides, err := readFile(profilePath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			for key, value := range overrides {
+				settings[key] = value
+			}
+			loaded = append(loaded, profilePath)
+		}
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if fileKeys.set == nil {
+		fileKeys.set = make(map[string]string)
+	}
+	for _, key := range keys {
+		if _, set := os.LookupEnv(key); set && !fromFile(key) {
+			continue
+		}
+		if err := os.Setenv(key, settings[key]); err != nil {
+			return nil, fmt.Errorf("config: set %s from %s: %w", key, path, err)
+		}
+		fileKeys.set[key] = settings[key]
+	}
+	return loaded, unsetFileKeys(settings)
+}
+
+// fromFile reports whether the env var still holds the value a config file
+// set. The caller holds fileKeys.
+func fromFile(key string) bool {
+	value, ok := fileKeys.set[key]
+	return ok && os.Getenv(key) == value
+}
+
+// processEnv returns the env var unless it was set by a config file.
+func processEnv(key string) string {
+	if fromFile(key) {
+		return ""
+	}
+	return os.Getenv(key)
+}
+
+// unsetFileKeys unsets the variables set by an earlier load that are not in
+// settings. The caller holds fileKeys.
+func unsetFileKeys(settings map[string]string) error {
+	for key := range fileKeys.set {
+		if _, ok := settings[key]; ok {
+			continue
+		}
+		if fromFile(key) {
+			if err := os.Unsetenv(key); err != nil {
+				return fmt.Errorf("config: unset %s: %w", key, err)
+			}
+		}
+		delete(fileKeys.set, key)
+	}
+	return nil
+}
+
+// readFile parses a YAML or TOML file, by extension, into env var settings.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("config: %s: unsupported format %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	settings := make(map[string]string)
+	if err := flatten(settings, "", doc); err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+func flatten(settings map[string]string, prefix string, value any) error {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			name := strings.ToUpper(key)
+			if prefix != "" {
+				name = prefix + "_" + name
+			}
+			if err := flatten(settings, name, child); err != nil {
+				return err
+			}
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				return fmt.Errorf("%s: lists may only hold plain values", prefix)
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		settings[prefix] = strings.Join(items, ",")
+	case nil:
+		settings[prefix] = ""
+	default:
+		settings[prefix] = fmt.Sprint(v)
+	}
+	return nil
+}
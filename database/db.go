@@ -1,10 +1,12 @@
 package database
 
 import (
+	"api/config"
 	"api/database/models"
+	"api/utils"
+	"fmt"
 	"log"
 	"net/url"
-	"os"
 
 	"time"
 
@@ -12,10 +14,10 @@ import (
 	"gorm.io/gorm"
 )
 
-var Database *gorm.DB
-
-func Init() {
-	serviceURI := os.Getenv("DB_URI")
+// Open connects to DB_URI and migrates the schema. Failing to connect is
+// fatal.
+func Open() *gorm.DB {
+	serviceURI := config.Get().DBURI
 
 	conn, _ := url.Parse(serviceURI)
 
@@ -30,18 +32,74 @@ func Init() {
 	}
 	sqlDB.SetConnMaxLifetime(30 * time.Minute)
 
-	db.AutoMigrate(&models.User{}, &models.Session{}, &models.PasswordReset{}, &models.OAuthAccount{}, &models.OAuthState{})
+	if err := Migrate(db); err != nil {
+		log.Printf("database: migration failed: %v", err)
+	}
+
+	return db
+}
+
+// Migrate creates or updates the schema for all models.
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(&models.Tenant{}, &models.User{}, &models.Session{}, &models.RetiredRefreshToken{}, &models.APIClient{}, &models.PasswordReset{}, &models.OAuthAccount{}, &models.OAuthState{}, &models.IPRule{}, &models.AuditLog{}, &models.LoginAttempt{}, &models.Invitation{}, &models.PasswordHistory{}, &models.LoginChallenge{}, &models.AccountUnlock{}, &models.SecurityPolicyOverride{}, &models.EmailOutbox{}, &models.EmailVerification{}, &models.EmailSuppression{}, &models.AccountSecureToken{}, &models.EmailDeliveryEvent{}, &models.UsernameHistory{}, &models.CurrencySetting{}, &models.FeatureFlag{}, &models.MaintenanceMode{}, &models.NotificationPreferences{}, &models.WebhookEndpoint{}, &models.WebhookDelivery{})
+	if err != nil {
+		return err
+	}
 
 	// Add composite unique index for OAuth accounts (user_id + provider)
-	db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_accounts_user_provider ON oauth_accounts(user_id, provider) WHERE deleted_at IS NULL")
+	if err := db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_oauth_accounts_user_provider ON oauth_accounts(user_id, provider) WHERE deleted_at IS NULL").Error; err != nil {
+		return err
+	}
 
-	Database = db
+	return migrateTenants(db)
 }
 
-func GetInstance() *gorm.DB {
-	if Database == nil {
-		log.Fatal("Database not loaded yet")
+// migrateTenants creates the default tenant, which owns every user created
+// before multi-tenancy, and makes usernames and emails unique per tenant
+// instead of globally.
+func migrateTenants(db *gorm.DB) error {
+	var tenant models.Tenant
+	err := db.Where(models.Tenant{Slug: "default"}).
+		Attrs(models.Tenant{Name: "Default", Active: true}).
+		FirstOrCreate(&tenant).Error
+	if err != nil {
+		return err
+	}
+	if tenant.ID != models.DefaultTenantID {
+		return fmt.Errorf("default tenant has id %d, expected %d", tenant.ID, models.DefaultTenantID)
+	}
+
+	statements := []string{
+		"DROP INDEX IF EXISTS idx_users_username",
+		"DROP INDEX IF EXISTS idx_users_email_lower",
+		"DROP INDEX IF EXISTS idx_users_email_hash",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_username ON users(tenant_id, username)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_email_hash ON users(tenant_id, email_hash)",
+	}
+	// Encrypted emails are randomized, so only the blind index can enforce
+	// uniqueness; the index on lower(email) would just be dead weight
+	if utils.EmailEncryptionEnabled() {
+		statements = append(statements, "DROP INDEX IF EXISTS idx_users_tenant_email_lower")
+	} else {
+		statements = append(statements, "CREATE UNIQUE INDEX IF NOT EXISTS idx_users_tenant_email_lower ON users(tenant_id, lower(email))")
+	}
+	// Only Postgres databases predate tenants; SQLite cannot drop constraints
+	if db.Dialector.Name() == "postgres" {
+		statements = append([]string{"ALTER TABLE users DROP CONSTRAINT IF EXISTS uni_users_email"}, statements...)
 	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	return Database
+// Close closes the connection pool of db.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// APIClient is a confidential client that obtains scoped access tokens with
+// the client-credentials grant. Its tokens act as the user who created it
+type APIClient struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID   uint       `gorm:"not null;default:1;index" json:"tenant_id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"` // Owner, the subject of the client's tokens
+	User       User       `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	Name       string     `gorm:"size:100;not null" json:"name"`
+	ClientID   string     `gorm:"uniqueIndex;size:64;not null" json:"client_id"`
+	SecretHash string     `gorm:"size:64;not null" json:"-"` // SHA256 hash of the client secret
+	Scope      string     `gorm:"size:255" json:"scope"`     // Space-separated scopes the client's tokens may carry
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
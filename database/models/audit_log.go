@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditEvent identifies the kind of security-relevant event being recorded
+type AuditEvent string
+
+const (
+	AuditEventCredentialStuffingSuspected AuditEvent = "credential_stuffing.suspected" // CAPTCHA escalation for an IP/ASN
+	AuditEventCredentialStuffingBlocked   AuditEvent = "credential_stuffing.blocked"   // IP temporarily blocked
+	AuditEventImpossibleTravel            AuditEvent = "login.impossible_travel"       // Login location implies implausible travel speed
+	AuditEventLoginRisk                   AuditEvent = "login.risk"                    // Login risk score triggered a CAPTCHA, challenge or denial
+	AuditEventRegistrationBotSuspected    AuditEvent = "registration.bot_suspected"    // Sign-up tripped the honeypot or form-time check
+	AuditEventUserBanned                  AuditEvent = "user.banned"                   // Admin banned a user
+	AuditEventUserUnbanned                AuditEvent = "user.unbanned"                 // Admin lifted a ban
+	AuditEventUserSuspended               AuditEvent = "user.suspended"                // Admin suspended a user until a given time
+	AuditEventUserUnsuspended             AuditEvent = "user.unsuspended"              // Admin lifted a suspension
+	AuditEventUserSessionsRevoked         AuditEvent = "user.sessions_revoked"         // Admin revoked all of a user's sessions
+	AuditEventOAuthUnlinkedByAdmin        AuditEvent = "oauth.unlinked_by_admin"       // Admin removed a provider link from a user
+	AuditEventUserRoleChanged             AuditEvent = "user.role_changed"             // Admin changed a user's role
+	AuditEventPasswordResetForced         AuditEvent = "password.reset_forced"         // Admin invalidated a user's password and sent a reset link
+	AuditEventUserAnonymized              AuditEvent = "user.anonymized"               // Personal data erased on request, by the user or an admin
+	AuditEventUserPurged                  AuditEvent = "user.purged"                   // Deleted account permanently removed after the grace period
+	AuditEventTLSFingerprintMismatch      AuditEvent = "session.tls_mismatch"          // Refresh from a TLS client other than the session's
+	AuditEventRefreshTokenReused          AuditEvent = "session.refresh_token_reused"  // Rotated-out refresh token presented again; the session was revoked
+	AuditEventSessionRevoked              AuditEvent = "session.revoked"               // Internal service revoked a session through the token service
+	AuditEventPersonalTokenCreated        AuditEvent = "personal_token.created"        // User created a personal access token
+	AuditEventPersonalTokenRevoked        AuditEvent = "personal_token.revoked"        // User revoked a personal access token
+	AuditEventAPIClientCreated            AuditEvent = "api_client.created"            // User registered an API client
+	AuditEventAPIClientDeleted            AuditEvent = "api_client.deleted"            // User deleted an API client, revoking its tokens
+	AuditEventSecurityPolicyUpdated       AuditEvent = "security_policy.updated"       // Admin changed the security policy
+	AuditEventSecurityPolicyReset         AuditEvent = "security_policy.reset"         // Admin restored the configured defaults
+	AuditEventEmailSuppressed             AuditEvent = "email.suppressed"              // Provider reported a hard bounce or spam complaint
+	AuditEventEmailUnsuppressed           AuditEvent = "email.unsuppressed"            // Admin lifted an email suppression
+	AuditEventPasswordChanged             AuditEvent = "password.changed"              // User changed or reset their password
+	AuditEventPasswordChangeDisputed      AuditEvent = "password.change_disputed"      // User reported a password change they did not make
+	AuditEventLegalAccepted               AuditEvent = "legal.accepted"                // User accepted the current terms of service or privacy policy
+	AuditEventInvitationCreated           AuditEvent = "invitation.created"            // Admin issued a registration invitation
+	AuditEventCurrencyUpdated             AuditEvent = "currency.updated"              // Admin enabled or disabled a currency
+	AuditEventFeatureFlagUpdated          AuditEvent = "feature_flag.updated"          // Admin overrode a feature flag or reset it to its default
+	AuditEventMaintenanceUpdated          AuditEvent = "maintenance.updated"           // Admin switched maintenance mode or reset it to its default
+	AuditEventLoginSucceeded              AuditEvent = "login.succeeded"               // User signed in with a password or OAuth provider
+	AuditEventOAuthLinked                 AuditEvent = "oauth.linked"                  // User linked an OAuth provider to their account
+	AuditEventOAuthUnlinked               AuditEvent = "oauth.unlinked"                // User removed an OAuth provider link
+	AuditEventUsersImported               AuditEvent = "users.imported"                // Admin bulk-imported users
+	AuditEventProfileUpdated              AuditEvent = "user.profile_updated"          // User changed their profile
+	AuditEventWebhookCreated              AuditEvent = "webhook.created"               // Admin registered a webhook endpoint
+	AuditEventWebhookUpdated              AuditEvent = "webhook.updated"               // Admin changed a webhook endpoint
+	AuditEventWebhookDeleted              AuditEvent = "webhook.deleted"               // Admin removed a webhook endpoint
+	AuditEventTenantCreated               AuditEvent = "tenant.created"                // Admin created a tenant
+	AuditEventTenantUpdated               AuditEvent = "tenant.updated"                // Admin renamed, activated or deactivated a tenant
+	AuditEventSettingsReloaded            AuditEvent = "settings.reloaded"             // Admin reloaded runtime settings
+)
+
+// AuditLog is an append-only record of security-relevant events
+type AuditLog struct {
+	ID        uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Event     AuditEvent `gorm:"type:varchar(100);index" json:"event"`
+	UserID    *uint      `gorm:"index" json:"user_id,omitempty"` // Affected user, if any
+	ActorID   *uint      `json:"actor_id,omitempty"`             // Admin who performed the action, if any
+	IPAddress string     `gorm:"size:45;index" json:"ip_address,omitempty"`
+	UserAgent string     `gorm:"size:500" json:"user_agent,omitempty"`
+	Metadata  string     `gorm:"type:jsonb;default:'{}'" json:"metadata,omitempty"` // Event-specific details as JSON
+	CreatedAt time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// LoginAttempt records every password login attempt, successful or not
+type LoginAttempt struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID      uint      `gorm:"not null;default:1;index" json:"tenant_id"` // Tenant the login was attempted in
+	UserID        *uint     `gorm:"index" json:"user_id,omitempty"`            // Nil when the email matched no user
+	Email         string    `gorm:"serializer:pii" json:"email"`
+	EmailHash     *string   `gorm:"index;size:64" json:"-"` // Blind index of the email, used for lookups when it is encrypted
+	IPAddress     string    `gorm:"size:45;index:idx_login_attempts_ip_created" json:"ip_address"`
+	ASN           string    `gorm:"size:20;index" json:"asn,omitempty"`
+	UserAgent     string    `gorm:"size:500" json:"user_agent,omitempty"`
+	Latitude      *float64  `json:"latitude,omitempty"` // Approximate client location, if known
+	Longitude     *float64  `json:"longitude,omitempty"`
+	Country       string    `gorm:"size:100" json:"country,omitempty"`
+	City          string    `gorm:"size:100" json:"city,omitempty"`
+	Success       bool      `gorm:"default:false" json:"success"`
+	FailureReason string    `gorm:"size:50" json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `gorm:"autoCreateTime;index:idx_login_attempts_ip_created" json:"created_at"`
+}
+
+// LoginChallenge is a one-time code emailed to a user whose login needs
+// step-up verification, e.g. after an impossible-travel detection
+type LoginChallenge struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Code      string    `gorm:"size:64" json:"-"` // SHA256 hash of the code
+	IPAddress string    `gorm:"size:45" json:"ip_address"`
+	Used      bool      `gorm:"default:false" json:"used"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// CurrencySetting stores an admin's choice to enable or disable a currency
+// for user profiles, overriding CURRENCIES_ENABLED
+type CurrencySetting struct {
+	Code      string    `gorm:"primaryKey;size:3" json:"code"` // Lower-case ISO 4217 code
+	Enabled   bool      `json:"enabled"`
+	UpdatedBy uint      `json:"updated_by"` // Admin user ID
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// Email outbox statuses.
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusDead    = "dead"
+
+	// EmailStatusSuppressed marks entries that were not sent because every
+	// recipient is suppressed.
+	EmailStatusSuppressed = "suppressed"
+
+	// EmailStatusFailed marks delivery events for attempts that will be
+	// retried.
+	EmailStatusFailed = "failed"
+)
+
+// EmailOutbox is an outgoing email waiting to be delivered by the outbox
+// worker. Rows that keep failing are dead-lettered instead of dropped.
+type EmailOutbox struct {
+	ID            uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Type          string     `gorm:"size:50;index" json:"type"` // Template name, e.g. password_reset
+	UserID        *uint      `gorm:"index" json:"user_id,omitempty"`
+	Recipients    string     `gorm:"serializer:pii;not null" json:"recipients"` // Comma-separated addresses
+	RecipientHash *string    `gorm:"index;size:64" json:"-"`                    // Blind index of the recipient, for single-recipient emails
+	Subject       string     `gorm:"size:255" json:"subject"`
+	Body          string     `gorm:"type:text" json:"-"` // Plain text; encrypted when a key is configured and cleared once sent
+	HTMLBody      string     `gorm:"type:text" json:"-"` // Optional HTML alternative, stored like Body
+	Attachments   string     `gorm:"type:text" json:"-"` // JSON-encoded attachments, stored like Body
+	Status        string     `gorm:"size:16;index;default:pending" json:"status"`
+	Attempts      int        `gorm:"default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"`
+	LastError     string     `gorm:"type:text" json:"last_error,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (EmailOutbox) TableName() string {
+	return "email_outbox"
+}
+
+// EmailDeliveryEvent records the outcome of one send attempt for one
+// recipient, so support can tell whether and when an email went out.
+type EmailDeliveryEvent struct {
+	ID                uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OutboxID          uint      `gorm:"index" json:"outbox_id"`
+	Type              string    `gorm:"size:50;index" json:"type"`
+	UserID            *uint     `gorm:"index" json:"user_id,omitempty"`
+	Email             string    `gorm:"serializer:pii" json:"email"`
+	EmailHash         *string   `gorm:"index;size:64" json:"-"` // Blind index of the email, used for lookups when it is encrypted
+	Provider          string    `gorm:"size:20" json:"provider"`
+	ProviderMessageID string    `gorm:"size:255;index" json:"provider_message_id,omitempty"`
+	Status            string    `gorm:"size:16;index" json:"status"` // sent, failed, dead or suppressed
+	Attempt           int       `json:"attempt"`
+	Error             string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt         time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+// EmailSuppression blocks sending to an address after a provider reported a
+// permanent bounce or a spam complaint for it.
+type EmailSuppression struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Email     string    `gorm:"serializer:pii" json:"email"`
+	EmailHash *string   `gorm:"index;size:64" json:"-"` // Blind index of the email, used for lookups when it is encrypted
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	Reason    string    `gorm:"size:16" json:"reason"`   // bounce or complaint
+	Provider  string    `gorm:"size:20" json:"provider"` // ses or sendgrid
+	Detail    string    `gorm:"size:500" json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
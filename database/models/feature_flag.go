@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// FeatureFlag stores an admin's override of a feature flag, taking
+// precedence over its default from FEATURE_FLAGS
+type FeatureFlag struct {
+	Name      string    `gorm:"primaryKey;size:64" json:"name"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedBy uint      `json:"updated_by"` // Admin user ID
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Invitation grants the holder of its token the right to register while
+// registration is invite-only, with the role chosen by the inviter
+type Invitation struct {
+	ID         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID   uint       `gorm:"not null;default:1;index" json:"tenant_id"`   // Tenant the invited user joins
+	Email      string     `gorm:"serializer:pii" json:"email,omitempty"`       // If set, only this email may accept
+	Token      string     `gorm:"uniqueIndex;size:64" json:"-"`                // SHA256 hash of the invitation token
+	Role       Role       `gorm:"type:varchar(20);default:'user'" json:"role"` // Assigned to the user on registration
+	InvitedBy  uint       `json:"invited_by"`                                  // Admin user ID
+	ExpiresAt  time.Time  `json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	AcceptedBy *uint      `json:"accepted_by,omitempty"` // User created from this invitation
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
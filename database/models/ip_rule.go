@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// IPRuleAction represents what happens to requests matching an IP rule
+type IPRuleAction string
+
+const (
+	IPRuleAllow IPRuleAction = "allow" // Explicitly permit the range (overrides broader deny rules)
+	IPRuleDeny  IPRuleAction = "deny"  // Block the range
+)
+
+// IPRule allows or blocks a CIDR range. When several rules match a client IP
+// the most specific (longest prefix) wins, and allow beats deny on a tie.
+type IPRule struct {
+	ID        uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	CIDR      string       `gorm:"size:50;index" json:"cidr"`
+	Action    IPRuleAction `gorm:"type:varchar(10)" json:"action"`
+	Reason    string       `gorm:"size:500" json:"reason,omitempty"`
+	CreatedBy uint         `json:"created_by"`                        // Admin user ID
+	ExpiresAt *time.Time   `gorm:"index" json:"expires_at,omitempty"` // Nil means permanent
+	CreatedAt time.Time    `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (IPRule) TableName() string {
+	return "ip_rules"
+}
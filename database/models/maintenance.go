@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// MaintenanceMode stores maintenance mode as set through the admin API.
+// There is at most one row; when present it replaces the MAINTENANCE_*
+// environment variables.
+type MaintenanceMode struct {
+	ID          uint       `gorm:"primaryKey" json:"-"`
+	Enabled     bool       `json:"enabled"`
+	Message     string     `gorm:"size:500" json:"message,omitempty"`
+	Until       *time.Time `json:"until,omitempty"` // Expected end, sent as Retry-After
+	AllowAdmins bool       `json:"allow_admins"`    // Keep the admin API available
+	UpdatedBy   uint       `json:"updated_by"`      // Admin user ID
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (MaintenanceMode) TableName() string {
+	return "maintenance_mode"
+}
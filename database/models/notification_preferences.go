@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// NotificationPreferences stores which optional emails a user wants. Users
+// without a row get DefaultNotificationPreferences. Emails that carry a code
+// or link the user asked for, such as password resets, are always sent.
+type NotificationPreferences struct {
+	UserID             uint      `gorm:"primaryKey" json:"-"`
+	SecurityAlerts     bool      `json:"security_alerts"`     // OAuth accounts linked or unlinked
+	LoginNotifications bool      `json:"login_notifications"` // Sign-ins from unusual locations
+	ProductEmails      bool      `json:"product_emails"`      // Announcements and other non-account emails
+	UpdatedAt          time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// DefaultNotificationPreferences returns the preferences of a user who has not
+// changed them: account notices on, product emails off.
+func DefaultNotificationPreferences(userID uint) NotificationPreferences {
+	return NotificationPreferences{
+		UserID:             userID,
+		SecurityAlerts:     true,
+		LoginNotifications: true,
+		ProductEmails:      false,
+	}
+}
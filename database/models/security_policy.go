@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// SecurityPolicyOverride stores the security policy saved through the admin
+// API. There is at most one row; when present it replaces the defaults from
+// environment variables.
+type SecurityPolicyOverride struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	Policy    string    `gorm:"type:jsonb" json:"policy"` // Encoded utils.SecurityPolicy
+	UpdatedBy uint      `json:"updated_by"`               // Admin user ID
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (SecurityPolicyOverride) TableName() string {
+	return "security_policy_overrides"
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultTenantID is the tenant of requests that name no tenant, and of every
+// user created before multi-tenancy.
+const DefaultTenantID = 1
+
+// Tenant is an isolated set of users. Usernames and emails are unique per
+// tenant, so the same person can hold separate accounts in several tenants.
+type Tenant struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Slug      string    `gorm:"uniqueIndex;size:63" json:"slug"` // Subdomain or X-Tenant header value
+	Name      string    `gorm:"size:255" json:"name"`
+	Active    bool      `gorm:"default:true" json:"active"` // Requests to inactive tenants are refused
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
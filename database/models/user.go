@@ -15,6 +15,23 @@ const (
 	AccountTypeHybrid AccountType = "hybrid" // Email account with OAuth providers linked
 )
 
+// SessionKind tells how a session was created
+type SessionKind string
+
+const (
+	SessionKindLogin             SessionKind = "login"                 // Password or OAuth sign-in, renewed with its refresh token
+	SessionKindPersonalToken     SessionKind = "personal_access_token" // Long-lived scoped token created by the user
+	SessionKindClientCredentials SessionKind = "client_credentials"    // Scoped token issued to an API client
+)
+
+// Role represents the authorization level of a user
+type Role string
+
+const (
+	RoleUser  Role = "user"  // Regular user
+	RoleAdmin Role = "admin" // Administrator with access to /admin routes
+)
+
 // OAuthProvider represents supported OAuth providers
 type OAuthProvider string
 
@@ -23,7 +40,8 @@ const (
 	OAuthProviderGithub OAuthProvider = "github"
 )
 
-// Currency represents supported currencies
+// Currency is a lower-case ISO 4217 code from the catalog in utils. The
+// constants below are the default enabled currencies
 type Currency string
 
 const (
@@ -33,7 +51,8 @@ const (
 	CurrencyUSD Currency = "usd" // US Dollar
 )
 
-// Timezone represents supported timezones
+// Timezone is an IANA time zone name, validated against the embedded tzdata.
+// The constants below are common values, not an exhaustive list
 type Timezone string
 
 const (
@@ -54,16 +73,63 @@ const (
 	TimezoneAustraliaSydney   Timezone = "Australia/Sydney"
 )
 
+// Locale represents supported languages for outgoing emails
+type Locale string
+
+const (
+	LocaleEN Locale = "en" // English
+	LocaleRO Locale = "ro" // Romanian
+	LocaleDE Locale = "de" // German
+)
+
+// SupportedLocales lists the locales with email and API message
+// translations, in order of preference when negotiating Accept-Language
+var SupportedLocales = []Locale{LocaleEN, LocaleRO, LocaleDE}
+
 type User struct {
 	ID          uint        `gorm:"primaryKey;autoIncrement" json:"id"`
-	Username    string      `gorm:"uniqueIndex;size:255" json:"username"`
-	Email       string      `gorm:"unique" json:"email"`
-	Password    string      `json:"-"` // Nullable for OAuth-only accounts
+	TenantID    uint        `gorm:"not null;default:1;index" json:"tenant_id"` // Username and email are unique per tenant
+	Username    string      `gorm:"size:255" json:"username"`
+	Email       string      `gorm:"serializer:pii" json:"email"`
+	EmailHash   *string     `gorm:"size:64" json:"-"` // Blind index of the email, used for lookups when it is encrypted
+	Password    string      `json:"-"`                // Nullable for OAuth-only accounts
 	AccountType AccountType `gorm:"type:varchar(20);default:'email'" json:"account_type"`
+	Role        Role        `gorm:"type:varchar(20);default:'user'" json:"role"`
 
 	// Profile fields
-	Currency Currency `gorm:"type:varchar(3);default:'usd'" json:"currency"`
-	Timezone Timezone `gorm:"type:varchar(50);default:'UTC'" json:"timezone"`
+	FirstName   string   `gorm:"size:100" json:"first_name"`
+	LastName    string   `gorm:"size:100" json:"last_name"`
+	DisplayName string   `gorm:"size:100" json:"display_name"`
+	Bio         string   `gorm:"size:500" json:"bio"`
+	AvatarURL   string   `gorm:"size:500" json:"avatar_url,omitempty"` // Uploaded avatar
+	AvatarKey   string   `gorm:"size:255" json:"-"`                    // Object storage key of the uploaded avatar
+	GravatarURL string   `gorm:"-" json:"gravatar_url,omitempty"`      // Computed for users without any avatar when enabled
+	Currency    Currency `gorm:"type:varchar(3);default:'usd'" json:"currency"`
+	Timezone    Timezone `gorm:"type:varchar(50);default:'UTC'" json:"timezone"`
+	Locale      Locale   `gorm:"type:varchar(10);default:'en'" json:"locale"`
+
+	Metadata map[string]any `gorm:"type:jsonb;serializer:json" json:"metadata,omitempty"` // Application-defined attributes
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"` // Set once the user follows a verification link
+
+	// Lockout state
+	FailedLoginAttempts int        `gorm:"default:0" json:"failed_login_attempts"`
+	LockedUntil         *time.Time `gorm:"index" json:"locked_until,omitempty"` // Login is refused until this time
+
+	// Moderation state, set by admins
+	Banned            bool       `gorm:"default:false;index" json:"banned"`
+	SuspendedUntil    *time.Time `gorm:"index" json:"suspended_until,omitempty"`       // Authentication is refused until this time
+	RestrictionReason string     `gorm:"size:500" json:"restriction_reason,omitempty"` // Admin-provided reason for the ban or suspension
+
+	// Accepted legal document versions, compared with TERMS_VERSION and PRIVACY_VERSION
+	TermsVersion      string     `gorm:"size:50" json:"terms_version,omitempty"`
+	TermsAcceptedAt   *time.Time `json:"terms_accepted_at,omitempty"`
+	PrivacyVersion    string     `gorm:"size:50" json:"privacy_version,omitempty"`
+	PrivacyAcceptedAt *time.Time `json:"privacy_accepted_at,omitempty"`
+
+	PasswordResetRequired bool `gorm:"default:false" json:"password_reset_required"` // Set by an admin; password login is refused until the user resets it
+
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"` // Set when personal data was erased; the row is kept for references
 
 	Sessions   []Session      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
 	OAuthLinks []OAuthAccount `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
@@ -79,7 +145,7 @@ type OAuthAccount struct {
 	User         User           `gorm:"foreignKey:UserID;references:ID" json:"-"`
 	Provider     OAuthProvider  `gorm:"type:varchar(20);index" json:"provider"`
 	ProviderID   string         `gorm:"size:255;index" json:"provider_id"`    // OAuth provider's user ID
-	Email        string         `gorm:"size:255;index" json:"email"`          // Email from OAuth provider
+	Email        string         `gorm:"serializer:pii" json:"email"`          // Email from OAuth provider
 	Name         string         `gorm:"size:255" json:"name"`                 // Display name from provider
 	AvatarURL    string         `gorm:"size:500" json:"avatar_url,omitempty"` // Profile picture URL
 	AccessToken  string         `gorm:"type:text" json:"-"`                   // Encrypted OAuth access token
@@ -98,6 +164,7 @@ type OAuthState struct {
 	ID          uint          `gorm:"primaryKey;autoIncrement" json:"id"`
 	State       string        `gorm:"uniqueIndex;size:64" json:"state"`       // Random state parameter
 	Provider    OAuthProvider `gorm:"type:varchar(20)" json:"provider"`       // OAuth provider
+	TenantID    uint          `gorm:"not null;default:1" json:"tenant_id"`    // Tenant the sign-in started in
 	Nonce       string        `gorm:"size:64" json:"nonce"`                   // Additional CSRF protection
 	RedirectURL string        `gorm:"size:500" json:"redirect_url,omitempty"` // Post-auth redirect
 	UserAgent   string        `gorm:"size:500" json:"user_agent,omitempty"`   // Security: track requesting UA
@@ -112,21 +179,91 @@ func (OAuthAccount) TableName() string {
 }
 
 type Session struct {
-	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	JTI          string    `gorm:"unique" json:"jti"`
-	UserID       uint      `json:"uid"`
-	User         User      `gorm:"foreignKey:UserID;references:ID" json:"-"`
-	RefreshToken string    `json:"-"`
-	Revoked      bool      `gorm:"default:false" json:"revoked"`
-	IssuedAt     time.Time `gorm:"autoCreateTime" json:"iat"`
-	ExpiresAt    time.Time `json:"exp"`
+	ID             uint        `gorm:"primaryKey;autoIncrement" json:"id"`
+	JTI            string      `gorm:"unique" json:"jti"`
+	UserID         uint        `json:"uid"`
+	User           User        `gorm:"foreignKey:UserID;references:ID" json:"-"`
+	Kind           SessionKind `gorm:"type:varchar(32);default:'login';index" json:"kind"`
+	Name           string      `gorm:"size:100" json:"name,omitempty"`  // Label of a personal access token
+	Scope          string      `gorm:"size:255" json:"scope,omitempty"` // Space-separated scopes of the access token; empty for login sessions
+	APIClientID    *uint       `gorm:"index" json:"api_client_id,omitempty"`
+	RefreshToken   string      `json:"-"`
+	Revoked        bool        `gorm:"default:false;index" json:"revoked"`
+	IPAddress      string      `gorm:"size:45" json:"ip_address,omitempty"`  // Client IP at session creation
+	UserAgent      string      `gorm:"size:500" json:"user_agent,omitempty"` // Client UA at session creation
+	TLSFingerprint string      `gorm:"size:32" json:"-"`                     // JA3 of the client at login, when TLS is terminated in-app
+	IssuedAt       time.Time   `gorm:"autoCreateTime;index" json:"iat"`
+	ExpiresAt      time.Time   `json:"exp"`
+}
+
+// RetiredRefreshToken is a refresh token replaced by rotation. Presenting one
+// again means it was copied, so the session it belonged to is revoked.
+type RetiredRefreshToken struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	SessionID uint      `gorm:"index" json:"session_id"`
+	Token     string    `gorm:"uniqueIndex;size:64" json:"-"` // SHA256 hash of the refresh token
+	RetiredAt time.Time `gorm:"autoCreateTime" json:"retired_at"`
 }
 
 type PasswordReset struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TenantID       uint      `gorm:"not null;default:1" json:"tenant_id"`
+	Email          string    `gorm:"serializer:pii" json:"email"`
+	EmailHash      *string   `gorm:"index;size:64" json:"-"` // Blind index of the email, used for lookups when it is encrypted
+	Token          string    `gorm:"unique" json:"-"`
+	Used           bool      `gorm:"default:false" json:"used"`
+	FailedAttempts int       `gorm:"default:0" json:"failed_attempts"` // Rejected confirmations; the token is invalidated at the limit
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+// AccountUnlock is a single-use token emailed to a locked-out user that lifts
+// the lockout early
+type AccountUnlock struct {
 	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	Email     string    `gorm:"index" json:"email"`
-	Token     string    `gorm:"unique" json:"-"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Token     string    `gorm:"unique" json:"-"` // SHA256 hash of the unlock token
 	Used      bool      `gorm:"default:false" json:"used"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"` // Matches the end of the lockout
+}
+
+// EmailVerification stores a single-use token confirming that a user owns
+// their email address. Rows double as the send history for resend limits.
+type EmailVerification struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Token     string    `gorm:"unique" json:"-"` // SHA256 hash of the verification token
+	Used      bool      `gorm:"default:false" json:"used"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
 }
+
+// AccountSecureToken is a single-use token in the password changed email.
+// If the user did not make the change, following it revokes all sessions.
+type AccountSecureToken struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Token     string    `gorm:"unique" json:"-"` // SHA256 hash of the token
+	Used      bool      `gorm:"default:false" json:"used"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// PasswordHistory keeps hashes of a user's previous passwords so they cannot
+// be reused
+type PasswordHistory struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// UsernameHistory records a username a user gave up, for the change cooldown
+// and so lookups of the old name can be redirected for a while
+type UsernameHistory struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Username  string    `gorm:"size:255;index" json:"username"` // The previous username
+	ChangedAt time.Time `gorm:"autoCreateTime;index" json:"changed_at"`
+}
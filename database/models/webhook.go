@@ -0,0 +1,69 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// WebhookEvent names an auth event delivered to webhook endpoints
+type WebhookEvent string
+
+const (
+	WebhookEventUserCreated    WebhookEvent = "user.created"    // Account registered with a password or OAuth provider
+	WebhookEventUserDeleted    WebhookEvent = "user.deleted"    // Account anonymized or permanently purged
+	WebhookEventLoginFailed    WebhookEvent = "login.failed"    // Password login rejected
+	WebhookEventSessionRevoked WebhookEvent = "session.revoked" // One or more of a user's sessions were revoked
+	WebhookEventOAuthLinked    WebhookEvent = "oauth.linked"    // Provider linked to an existing account
+)
+
+// WebhookEvents lists every event an endpoint can subscribe to
+var WebhookEvents = []WebhookEvent{
+	WebhookEventUserCreated,
+	WebhookEventUserDeleted,
+	WebhookEventLoginFailed,
+	WebhookEventSessionRevoked,
+	WebhookEventOAuthLinked,
+}
+
+// Webhook delivery statuses.
+const (
+	WebhookStatusPending   = "pending"   // Waiting for its first or next attempt
+	WebhookStatusDelivered = "delivered" // The endpoint answered with a 2xx status
+	WebhookStatusDead      = "dead"      // Gave up after the maximum number of attempts
+)
+
+// WebhookEndpoint is a URL registered by an admin to receive auth events
+type WebhookEndpoint struct {
+	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	URL         string         `gorm:"size:500;not null" json:"url"`
+	Secret      string         `gorm:"type:text;not null" json:"-"` // HMAC signing secret, encrypted when a key is configured
+	Events      []WebhookEvent `gorm:"type:jsonb;serializer:json" json:"events"`
+	Description string         `gorm:"size:255" json:"description,omitempty"`
+	Active      bool           `gorm:"default:true" json:"active"`
+	CreatedBy   *uint          `json:"created_by,omitempty"` // Admin who registered the endpoint
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Subscribes reports whether the endpoint receives event
+func (e WebhookEndpoint) Subscribes(event WebhookEvent) bool {
+	return slices.Contains(e.Events, event)
+}
+
+// WebhookDelivery is one event queued for one endpoint, with the outcome of
+// its latest attempt. It doubles as the delivery log.
+type WebhookDelivery struct {
+	ID             uint         `gorm:"primaryKey;autoIncrement" json:"id"`
+	EndpointID     uint         `gorm:"index" json:"endpoint_id"`
+	EventID        string       `gorm:"size:36;index" json:"event_id"` // Shared by the deliveries of one event to several endpoints
+	Event          WebhookEvent `gorm:"size:50;index" json:"event"`
+	Payload        string       `gorm:"type:text" json:"payload"` // JSON request body
+	Status         string       `gorm:"size:16;index;default:pending" json:"status"`
+	Attempts       int          `gorm:"default:0" json:"attempts"`
+	NextAttemptAt  time.Time    `gorm:"index" json:"next_attempt_at"`
+	LastStatusCode int          `json:"last_status_code,omitempty"` // HTTP status of the latest attempt, 0 if none was received
+	LastError      string       `gorm:"type:text" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time   `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time    `gorm:"autoCreateTime;index" json:"created_at"`
+	UpdatedAt      time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// OpenSQLite opens an embedded SQLite database at path, or an in-memory one
+// when path is empty, for demos that run without Postgres. The driver is
+// pure Go, so no C toolchain is needed. A few admin features that rely on
+// Postgres, such as revoking sessions by IP range, fail on it.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	dsn := "file:" + path
+	if path == "" {
+		// Shared cache keeps one in-memory database across connections
+		dsn = "file::memory:?cache=shared"
+	}
+	if strings.Contains(dsn, "?") {
+		dsn += "&"
+	} else {
+		dsn += "?"
+	}
+	dsn += "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+
+	db, err := gorm.Open(sqlite.Open(dsn))
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// An in-memory database is dropped when its last connection closes
+	sqlDB.SetMaxIdleConns(1)
+	sqlDB.SetConnMaxLifetime(0)
+
+	return db, nil
+}
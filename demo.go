@@ -0,0 +1,63 @@
+package main
+
+import (
+	"api/config"
+	"api/database"
+	"api/seed"
+	"api/server"
+	"api/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+)
+
+// demoEmailLimit is how many captured emails demo mode keeps.
+const demoEmailLimit = 100
+
+// demoMode configures the service to run without Postgres or a mail server:
+// data lives in SQLite (in memory unless DEMO_DB names a file), emails are
+// captured and listed at /api/v1/dev/emails, and the dev routes are mounted.
+// The returned function seeds the demo accounts once the app is built. The
+// accounts have a well-known password and the captured emails are served
+// without authentication, so it refuses to run with ENV=production.
+func demoMode() ([]server.Option, func()) {
+	if config.Get().Production() {
+		log.Fatal("demo: refusing to run demo mode with ENV=production")
+	}
+
+	db, err := database.OpenSQLite(os.Getenv("DEMO_DB"))
+	if err != nil {
+		log.Fatalf("demo: open database: %v", err)
+	}
+
+	if os.Getenv("PORT") == "" {
+		os.Setenv("PORT", "8080")
+	}
+	if os.Getenv("BASE_URL") == "" {
+		os.Setenv("BASE_URL", "http://localhost:"+os.Getenv("PORT"))
+	}
+	if os.Getenv("JWT_SECRET") == "" {
+		// Tokens only need to outlive this process
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		os.Setenv("JWT_SECRET", hex.EncodeToString(secret))
+	}
+
+	opts := []server.Option{
+		server.WithDB(db),
+		server.WithEmailSender(utils.NewMemorySender(demoEmailLimit)),
+		server.WithDevRoutes(true),
+	}
+
+	seedAccounts := func() {
+		if err := seed.Demo(db); err != nil {
+			log.Fatalf("demo: %v", err)
+		}
+		log.Printf("demo: running on SQLite; emails are listed at /api/v1/dev/emails")
+		for _, account := range seed.DemoAccounts {
+			log.Printf("demo: %s account %s, password %q", account.Role, account.Email, seed.Password)
+		}
+	}
+	return opts, seedAccounts
+}
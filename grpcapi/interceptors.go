@@ -0,0 +1,71 @@
+package grpcapi
+
+import (
+	"api/service"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requireToken rejects calls whose authorization metadata does not hold
+// "Bearer <token>".
+func requireToken(token string) grpc.UnaryServerInterceptor {
+	want := []byte(token)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			scheme, credential, _ := strings.Cut(value, " ")
+			if strings.EqualFold(scheme, "Bearer") && subtle.ConstantTimeCompare([]byte(credential), want) == 1 {
+				return handler(ctx, req)
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid authorization")
+	}
+}
+
+// mapErrors converts errors returned by the handlers to gRPC statuses.
+// Internal errors are logged and reported without their details.
+func mapErrors(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return nil, err
+	}
+
+	var serviceErr *service.Error
+	if errors.As(err, &serviceErr) {
+		return nil, status.Error(statusCode(serviceErr.Kind), serviceErr.Message)
+	}
+
+	log.Printf("grpc: %s: %v", info.FullMethod, err)
+	return nil, status.Error(codes.Internal, "internal error")
+}
+
+// statusCode maps an error kind to the gRPC code with the same meaning.
+func statusCode(kind service.Kind) codes.Code {
+	switch kind {
+	case service.KindInvalid:
+		return codes.InvalidArgument
+	case service.KindUnauthenticated:
+		return codes.Unauthenticated
+	case service.KindForbidden:
+		return codes.PermissionDenied
+	case service.KindNotFound:
+		return codes.NotFound
+	case service.KindConflict:
+		return codes.AlreadyExists
+	case service.KindLocked:
+		return codes.FailedPrecondition
+	default:
+		return codes.Unknown
+	}
+}
@@ -0,0 +1,134 @@
+// Package grpcapi serves the AuthService API of proto/auth/v1 for internal
+// services. It is a thin transport over service.Tokens: requests are
+// translated into calls on it and returned *service.Error values are mapped
+// to gRPC status codes.
+package grpcapi
+
+import (
+	"api/database/models"
+	authv1 "api/proto/auth/v1"
+	"api/service"
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// NewServer returns a gRPC server with AuthService registered on tokens.
+// Callers authenticate with GRPC_AUTH_TOKEN as a bearer token in the
+// authorization metadata; the server refuses to start without it. With
+// GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE the server uses TLS.
+func NewServer(tokens *service.Tokens) (*grpc.Server, error) {
+	authToken := os.Getenv("GRPC_AUTH_TOKEN")
+	if authToken == "" {
+		return nil, errors.New("GRPC_AUTH_TOKEN is required to serve gRPC")
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(requireToken(authToken), mapErrors),
+	}
+
+	certFile, keyFile := os.Getenv("GRPC_TLS_CERT_FILE"), os.Getenv("GRPC_TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("set both GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE, or neither")
+	}
+	if certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	srv := grpc.NewServer(opts...)
+	authv1.RegisterAuthServiceServer(srv, &authService{tokens: tokens})
+	return srv, nil
+}
+
+// authService implements authv1.AuthServiceServer.
+type authService struct {
+	authv1.UnimplementedAuthServiceServer
+	tokens *service.Tokens
+}
+
+func (s *authService) ValidateToken(_ context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	claims, _, err := s.tokens.Validate(req.GetToken())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &authv1.ValidateTokenResponse{
+		UserId:    uint64(claims.Subject),
+		SessionId: claims.ID,
+		Scopes:    claims.Scopes(),
+	}
+	if claims.ExpiresAt != nil {
+		resp.ExpiresAt = timestamppb.New(claims.ExpiresAt.Time)
+	}
+	return resp, nil
+}
+
+func (s *authService) IntrospectToken(_ context.Context, req *authv1.IntrospectTokenRequest) (*authv1.IntrospectTokenResponse, error) {
+	info, err := s.tokens.Introspect(req.GetToken())
+	if err != nil {
+		return nil, err
+	}
+	if !info.Active {
+		return &authv1.IntrospectTokenResponse{}, nil
+	}
+
+	return &authv1.IntrospectTokenResponse{
+		Active:    true,
+		UserId:    uint64(info.Subject),
+		Scopes:    info.Scopes,
+		Jti:       info.JTI,
+		IssuedAt:  optionalTimestamp(info.IssuedAt),
+		ExpiresAt: optionalTimestamp(info.ExpiresAt),
+		Issuer:    info.Issuer,
+		Audience:  info.Audience,
+	}, nil
+}
+
+func (s *authService) GetUser(_ context.Context, req *authv1.GetUserRequest) (*authv1.User, error) {
+	id := req.GetUserId()
+	if id == 0 || id != uint64(uint(id)) {
+		return nil, service.ErrUserNotFound
+	}
+
+	user, err := s.tokens.User(uint(id))
+	if err != nil {
+		return nil, err
+	}
+	return userMessage(user), nil
+}
+
+func (s *authService) RevokeSession(_ context.Context, req *authv1.RevokeSessionRequest) (*authv1.RevokeSessionResponse, error) {
+	if err := s.tokens.RevokeSession(req.GetSessionId()); err != nil {
+		return nil, err
+	}
+	return &authv1.RevokeSessionResponse{}, nil
+}
+
+func userMessage(user *models.User) *authv1.User {
+	return &authv1.User{
+		Id:            uint64(user.ID),
+		Username:      user.Username,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Role:          string(user.Role),
+		Locale:        string(user.Locale),
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+	}
+}
+
+// optionalTimestamp converts t, leaving unset times unset.
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
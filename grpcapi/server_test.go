@@ -0,0 +1,258 @@
+package grpcapi
+
+import (
+	"api/database"
+	"api/database/models"
+	authv1 "api/proto/auth/v1"
+	"api/service"
+	"api/store"
+	"api/utils"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testAuthToken = "grpc-test-token"
+
+// testEnv is a running server on a fresh database with one signed-in user.
+type testEnv struct {
+	client authv1.AuthServiceClient
+	store  store.Store
+	user   *models.User
+	token  string // Access token of the user's session
+	jti    string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "grpc-test-secret")
+	t.Setenv("GRPC_AUTH_TOKEN", testAuthToken)
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close(db) })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	st := store.NewGorm(db)
+
+	verifiedAt := time.Now()
+	user := &models.User{
+		TenantID:        models.DefaultTenantID,
+		Username:        "alice",
+		Email:           "alice@example.com",
+		Role:            models.RoleAdmin,
+		Locale:          models.LocaleDE,
+		EmailVerifiedAt: &verifiedAt,
+	}
+	if err := st.Users().Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	jti, token, err := utils.GetSignedKey(user)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	session := &models.Session{JTI: jti, UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := st.Sessions().Create(session); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	srv, err := NewServer(&service.Tokens{Store: st})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	ln := bufconn.Listen(1 << 20)
+	go srv.Serve(ln)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &testEnv{client: authv1.NewAuthServiceClient(conn), store: st, user: user, token: token, jti: jti}
+}
+
+// authorized returns a context carrying the bearer token.
+func authorized(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func assertCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("got code %s (%v), want %s", got, err, want)
+	}
+}
+
+func TestNewServerRequiresAuthToken(t *testing.T) {
+	t.Setenv("GRPC_AUTH_TOKEN", "")
+	if _, err := NewServer(&service.Tokens{}); err == nil {
+		t.Fatal("NewServer succeeded without GRPC_AUTH_TOKEN")
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"no metadata", context.Background(), codes.Unauthenticated},
+		{"wrong token", authorized("not-the-token"), codes.Unauthenticated},
+		{"wrong scheme", metadata.AppendToOutgoingContext(context.Background(), "authorization", "Basic "+testAuthToken), codes.Unauthenticated},
+		{"valid token", authorized(testAuthToken), codes.OK},
+		{"scheme is case-insensitive", metadata.AppendToOutgoingContext(context.Background(), "authorization", "bearer "+testAuthToken), codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.client.GetUser(tt.ctx, &authv1.GetUserRequest{UserId: uint64(env.user.ID)})
+			assertCode(t, err, tt.want)
+		})
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := authorized(testAuthToken)
+
+	t.Run("valid", func(t *testing.T) {
+		resp, err := env.client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: env.token})
+		assertCode(t, err, codes.OK)
+		if resp.GetUserId() != uint64(env.user.ID) || resp.GetSessionId() != env.jti {
+			t.Errorf("got user %d session %q, want %d %q", resp.GetUserId(), resp.GetSessionId(), env.user.ID, env.jti)
+		}
+		if resp.GetExpiresAt().AsTime().Before(time.Now()) {
+			t.Errorf("expires at %v, in the past", resp.GetExpiresAt().AsTime())
+		}
+	})
+
+	for _, token := range []string{"", "not-a-jwt", env.token + "x"} {
+		t.Run("invalid "+token, func(t *testing.T) {
+			_, err := env.client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: token})
+			assertCode(t, err, codes.Unauthenticated)
+		})
+	}
+}
+
+func TestIntrospectToken(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := authorized(testAuthToken)
+
+	resp, err := env.client.IntrospectToken(ctx, &authv1.IntrospectTokenRequest{Token: env.token})
+	assertCode(t, err, codes.OK)
+	if !resp.GetActive() || resp.GetUserId() != uint64(env.user.ID) || resp.GetJti() != env.jti {
+		t.Errorf("unexpected introspection %v", resp)
+	}
+
+	// Inactive tokens are not errors
+	resp, err = env.client.IntrospectToken(ctx, &authv1.IntrospectTokenRequest{Token: "not-a-jwt"})
+	assertCode(t, err, codes.OK)
+	if resp.GetActive() || resp.GetUserId() != 0 {
+		t.Errorf("unexpected introspection of an invalid token %v", resp)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := authorized(testAuthToken)
+
+	tests := []struct {
+		name string
+		id   uint64
+		want codes.Code
+	}{
+		{"existing", uint64(env.user.ID), codes.OK},
+		{"unknown", uint64(env.user.ID) + 1, codes.NotFound},
+		{"zero", 0, codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := env.client.GetUser(ctx, &authv1.GetUserRequest{UserId: tt.id})
+			assertCode(t, err, tt.want)
+			if err != nil {
+				return
+			}
+			if user.GetUsername() != "alice" || user.GetEmail() != "alice@example.com" || !user.GetEmailVerified() ||
+				user.GetRole() != "admin" || user.GetLocale() != "de" || user.GetCreatedAt() == nil {
+				t.Errorf("unexpected user %v", user)
+			}
+		})
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := authorized(testAuthToken)
+
+	tests := []struct {
+		name string
+		jti  string
+		want codes.Code
+	}{
+		{"missing", " ", codes.InvalidArgument},
+		{"unknown", "no-such-session", codes.NotFound},
+		{"existing", env.jti, codes.OK},
+		{"already revoked", env.jti, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := env.client.RevokeSession(ctx, &authv1.RevokeSessionRequest{SessionId: tt.jti})
+			assertCode(t, err, tt.want)
+		})
+	}
+
+	_, err := env.client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: env.token})
+	assertCode(t, err, codes.Unauthenticated)
+}
+
+func TestMapErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		code    codes.Code
+		message string
+	}{
+		{"invalid", &service.Error{Kind: service.KindInvalid, Message: "bad"}, codes.InvalidArgument, "bad"},
+		{"unauthenticated", service.ErrInvalidToken, codes.Unauthenticated, service.ErrInvalidToken.Message},
+		{"forbidden", &service.Error{Kind: service.KindForbidden, Message: "no"}, codes.PermissionDenied, "no"},
+		{"not found", service.ErrSessionNotFound, codes.NotFound, service.ErrSessionNotFound.Message},
+		{"conflict", &service.Error{Kind: service.KindConflict, Message: "taken"}, codes.AlreadyExists, "taken"},
+		{"locked", service.ErrAccountLocked, codes.FailedPrecondition, service.ErrAccountLocked.Message},
+		{"status passes through", status.Error(codes.Unavailable, "later"), codes.Unavailable, "later"},
+		{"internal details are hidden", errors.New("connection refused"), codes.Internal, "internal error"},
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: authv1.AuthService_GetUser_FullMethodName}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := mapErrors(context.Background(), nil, info, func(context.Context, any) (any, error) {
+				return nil, tt.err
+			})
+			st, _ := status.FromError(err)
+			if st.Code() != tt.code || st.Message() != tt.message {
+				t.Errorf("got %s %q, want %s %q", st.Code(), st.Message(), tt.code, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/service"
+	"api/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// defaultPersonalTokenDays is how long personal access tokens are valid when
+// the request does not say.
+const defaultPersonalTokenDays = 90
+
+type CreatePersonalTokenProps struct {
+	Name          string   `json:"name" validate:"required"`
+	Scopes        []string `json:"scopes" validate:"required"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty" validate:"omitempty,min=1"` // Defaults to 90, at most 365
+}
+
+type CreateAPIClientProps struct {
+	Name   string   `json:"name" validate:"required"`
+	Scopes []string `json:"scopes" validate:"required"`
+}
+
+// ClientCredentialsProps is a client-credentials grant (RFC 6749 section
+// 4.4), sent as a form or as JSON.
+type ClientCredentialsProps struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	Scope        string `json:"scope,omitempty" form:"scope"` // Space-separated; defaults to every scope of the client
+}
+
+// CreatePersonalToken issues a personal access token limited to the
+// requested scopes. The token is only returned in this response.
+func (h *Handler) CreatePersonalToken(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var body CreatePersonalTokenProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+	days := body.ExpiresInDays
+	if days == 0 {
+		days = defaultPersonalTokenDays
+	}
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	accessToken, session, err := h.tokens.CreatePersonalToken(user, service.PersonalTokenInput{
+		Name:      body.Name,
+		Scopes:    body.Scopes,
+		TTL:       time.Duration(days) * 24 * time.Hour,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	})
+	if err != nil {
+		return serviceError(err)
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventPersonalTokenCreated,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"token_id": session.ID, "name": session.Name, "scope": session.Scope})
+
+	return c.Status(201).JSON(utils.Response{
+		Success: true,
+		Code:    201,
+		Message: "Token created",
+		Data: fiber.Map{
+			"token":        session,
+			"access_token": accessToken,
+		},
+	})
+}
+
+// ListPersonalTokens returns the user's active personal access tokens.
+func (h *Handler) ListPersonalTokens(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	sessions, err := h.tokens.PersonalTokens(claims.Subject)
+	if err != nil {
+		return fiber.NewError(500, "Failed to fetch tokens")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    sessions,
+	})
+}
+
+// RevokePersonalToken revokes one of the user's personal access tokens.
+func (h *Handler) RevokePersonalToken(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid token id")
+	}
+
+	session, err := h.tokens.RevokePersonalToken(claims.Subject, uint(id))
+	if err != nil {
+		return serviceError(err)
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventPersonalTokenRevoked,
+		UserID:    &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"token_id": session.ID, "name": session.Name})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Token revoked",
+	})
+}
+
+// CreateAPIClient registers an API client that obtains tokens limited to the
+// requested scopes from POST /auth/token. The client secret is only
+// returned in this response.
+func (h *Handler) CreateAPIClient(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var body CreateAPIClientProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	client, secret, err := h.tokens.CreateClient(user, body.Name, body.Scopes)
+	if err != nil {
+		return serviceError(err)
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventAPIClientCreated,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"client_id": client.ClientID, "name": client.Name, "scope": client.Scope})
+
+	return c.Status(201).JSON(utils.Response{
+		Success: true,
+		Code:    201,
+		Message: "API client created",
+		Data: fiber.Map{
+			"client":        client,
+			"client_secret": secret,
+		},
+	})
+}
+
+// ListAPIClients returns the user's API clients.
+func (h *Handler) ListAPIClients(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	clients, err := h.tokens.Clients(claims.Subject)
+	if err != nil {
+		return fiber.NewError(500, "Failed to fetch API clients")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    clients,
+	})
+}
+
+// DeleteAPIClient deletes one of the user's API clients and revokes the
+// tokens issued to it.
+func (h *Handler) DeleteAPIClient(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid API client id")
+	}
+
+	client, revoked, err := h.tokens.DeleteClient(claims.Subject, uint(id))
+	if err != nil {
+		return serviceError(err)
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventAPIClientDeleted,
+		UserID:    &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"client_id": client.ClientID, "name": client.Name, "revoked_tokens": revoked})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "API client deleted",
+		Data:    fiber.Map{"revoked_tokens": revoked},
+	})
+}
+
+// ClientCredentialsToken issues an access token to an API client of the
+// tenant that authenticates with its client ID and secret. The response
+// follows RFC 6749; there is no refresh token.
+func (h *Handler) ClientCredentialsToken(c *fiber.Ctx) error {
+	var body ClientCredentialsProps
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEApplicationForm) {
+		if err := c.BodyParser(&body); err != nil {
+			return fiber.NewError(400, "Invalid form body")
+		}
+	} else if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+	if body.GrantType != "client_credentials" {
+		return utils.NewReasonError(400, "unsupported_grant_type", "grant_type must be client_credentials")
+	}
+
+	accessToken, session, err := h.tokens.ClientCredentials(middleware.TenantID(c), service.ClientCredentialsInput{
+		ClientID:     body.ClientID,
+		ClientSecret: body.ClientSecret,
+		Scopes:       strings.Fields(body.Scope),
+		IPAddress:    c.IP(),
+		UserAgent:    c.Get("User-Agent"),
+	})
+	if err != nil {
+		return serviceError(err)
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"access_token": accessToken,
+			"token_type":   "Bearer",
+			"expires_in":   int(service.ClientTokenTTL.Seconds()),
+			"scope":        session.Scope,
+		},
+	})
+}
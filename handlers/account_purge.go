@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// accountPurgeConfig controls the permanent removal of deleted accounts.
+type accountPurgeConfig struct {
+	gracePeriod time.Duration // How long a soft-deleted account can still be restored
+	interval    time.Duration
+	batchSize   int
+}
+
+// setupAccountPurge reads ACCOUNT_DELETION_GRACE_PERIOD (default 720h) and
+// ACCOUNT_PURGE_INTERVAL (default 1h) and starts the purge worker.
+func (h *Handler) setupAccountPurge() {
+	h.accountPurge = accountPurgeConfig{
+		gracePeriod: envDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+		interval:    envDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+		batchSize:   100,
+	}
+
+	h.startWorker(h.runAccountPurge)
+}
+
+// runAccountPurge purges expired accounts every interval.
+func (h *Handler) runAccountPurge(ctx context.Context) {
+	ticker := time.NewTicker(h.accountPurge.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			purged, err := h.purgeDeletedUsers()
+			if err != nil {
+				log.Printf("account purge: %v", err)
+				break
+			}
+			if purged < h.accountPurge.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// purgeDeletedUsers permanently deletes up to batchSize users whose soft
+// deletion is older than the grace period. It returns how many candidates it
+// processed.
+func (h *Handler) purgeDeletedUsers() (int, error) {
+	ids, err := h.stores.Users().DeletedBefore(time.Now().Add(-h.accountPurge.gracePeriod), h.accountPurge.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("find deleted users: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := h.purgeUser(id); err != nil {
+			log.Printf("account purge: user %d: %v", id, err)
+		}
+	}
+	return len(ids), nil
+}
+
+// purgeUser removes one user with their sessions, OAuth accounts and
+// outstanding tokens, then records a user.purged audit event. The row is
+// locked with SKIP LOCKED so concurrent workers never purge it twice.
+func (h *Handler) purgeUser(id uint) error {
+	var user models.User
+	var removed fiber.Map
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			First(&user).Error
+		if err != nil {
+			return err
+		}
+
+		removed, err = deleteUserCredentials(tx, &user)
+		if err != nil {
+			return err
+		}
+		if err := deleteUserSettings(tx, user.ID); err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&user).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Restored, or being purged by another instance
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	h.deleteStoredAvatar(user.AvatarKey)
+
+	removed["deleted_at"] = user.DeletedAt.Time
+	h.recordAuditEvent(models.AuditLog{
+		Event:  models.AuditEventUserPurged,
+		UserID: &id,
+	}, removed)
+	h.publishEvent(models.WebhookEventUserDeleted, fiber.Map{
+		"user_id": id,
+		"reason":  "purged",
+	})
+	return nil
+}
+
+// deleteUserCredentials deletes everything that lets anyone act as the user:
+// sessions, API clients, OAuth accounts, password history and outstanding
+// reset, unlock, verification and challenge tokens. It returns the rows removed per table.
+func deleteUserCredentials(tx *gorm.DB, user *models.User) (fiber.Map, error) {
+	dependents := []struct {
+		name  string
+		query *gorm.DB
+		model any
+	}{
+		{"retired_refresh_tokens", tx.Where("session_id IN (?)", tx.Model(&models.Session{}).Select("id").Where("user_id = ?", user.ID)), &models.RetiredRefreshToken{}},
+		{"sessions", tx.Where("user_id = ?", user.ID), &models.Session{}},
+		{"api_clients", tx.Where("user_id = ?", user.ID), &models.APIClient{}},
+		{"oauth_accounts", tx.Unscoped().Where("user_id = ?", user.ID), &models.OAuthAccount{}},
+		{"password_resets", tx.Where("tenant_id = ?", user.TenantID).Scopes(whereEmail(utils.NormalizeEmail(user.Email))), &models.PasswordReset{}},
+		{"email_verifications", tx.Where("user_id = ?", user.ID), &models.EmailVerification{}},
+		{"account_unlocks", tx.Where("user_id = ?", user.ID), &models.AccountUnlock{}},
+		{"account_secure_tokens", tx.Where("user_id = ?", user.ID), &models.AccountSecureToken{}},
+		{"login_challenges", tx.Where("user_id = ?", user.ID), &models.LoginChallenge{}},
+		{"password_history", tx.Where("user_id = ?", user.ID), &models.PasswordHistory{}},
+	}
+
+	removed := fiber.Map{}
+	for _, d := range dependents {
+		result := d.query.Delete(d.model)
+		if result.Error != nil {
+			return nil, fmt.Errorf("delete %s: %w", d.name, result.Error)
+		}
+		removed[d.name] = result.RowsAffected
+	}
+	return removed, nil
+}
+
+// deleteUserSettings deletes the user's username history and notification
+// preferences.
+func deleteUserSettings(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.UsernameHistory{}).Error; err != nil {
+		return fmt.Errorf("delete username_history: %w", err)
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.NotificationPreferences{}).Error; err != nil {
+		return fmt.Errorf("delete notification_preferences: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type UnlockAccountProps struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// sendUnlockEmail issues a single-use unlock token valid until lockedUntil and
+// emails the link to the user. Earlier unused tokens are invalidated.
+func (h *Handler) sendUnlockEmail(user *models.User, lockedUntil time.Time) {
+	token, hashedToken := utils.GenerateSecureToken()
+	if token == "" {
+		log.Printf("unlock: failed to generate token for user %d", user.ID)
+		return
+	}
+
+	h.db.Model(&models.AccountUnlock{}).Where("user_id = ? AND used = false", user.ID).Update("used", true)
+
+	unlock := models.AccountUnlock{
+		UserID:    user.ID,
+		Token:     hashedToken,
+		ExpiresAt: lockedUntil,
+	}
+	if err := h.db.Create(&unlock).Error; err != nil {
+		log.Printf("unlock: failed to store token for user %d: %v", user.ID, err)
+		return
+	}
+
+	err := h.queueUserEmail(user, utils.EmailTemplateAccountLocked, utils.AccountLockedEmail{
+		UnlockURL:   fmt.Sprintf("%s/unlock-account?token=%s", config.Get().ClientURL, token),
+		LockedUntil: lockedUntil.UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		log.Printf("unlock: %v", err)
+	}
+}
+
+// UnlockAccount lifts a lockout using the token from the unlock email.
+func (h *Handler) UnlockAccount(c *fiber.Ctx) error {
+	var body UnlockAccountProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	var unlock models.AccountUnlock
+	err := h.db.Where("token = ? AND used = false AND expires_at > ?",
+		utils.HashTokenSHA256(body.Token), time.Now()).First(&unlock).Error
+	if err != nil {
+		return fiber.NewError(400, "Invalid or expired unlock token")
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// The conditional update makes concurrent uses of one token fail
+		result := tx.Model(&models.AccountUnlock{}).
+			Where("id = ? AND used = false", unlock.ID).
+			Update("used", true)
+		if result.Error != nil {
+			return fmt.Errorf("failed to mark unlock token as used: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(400, "Invalid or expired unlock token")
+		}
+
+		_, err := store.NewGorm(tx).Users().UpdateByID(unlock.UserID, map[string]any{
+			"failed_login_attempts": 0,
+			"locked_until":          nil,
+		})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Account unlocked. You can now sign in.",
+		Data:    nil,
+	})
+}
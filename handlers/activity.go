@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"encoding/json"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// activityEvents are the audit events shown in a user's own activity feed.
+// Internal signals such as risk scores and bot detection are left out.
+var activityEvents = []models.AuditEvent{
+	models.AuditEventLoginSucceeded,
+	models.AuditEventImpossibleTravel,
+	models.AuditEventTLSFingerprintMismatch,
+	models.AuditEventPasswordChanged,
+	models.AuditEventPasswordChangeDisputed,
+	models.AuditEventPasswordResetForced,
+	models.AuditEventOAuthLinked,
+	models.AuditEventOAuthUnlinked,
+	models.AuditEventOAuthUnlinkedByAdmin,
+	models.AuditEventProfileUpdated,
+	models.AuditEventLegalAccepted,
+	models.AuditEventPersonalTokenCreated,
+	models.AuditEventPersonalTokenRevoked,
+	models.AuditEventAPIClientCreated,
+	models.AuditEventAPIClientDeleted,
+	models.AuditEventUserSessionsRevoked,
+	models.AuditEventUserRoleChanged,
+	models.AuditEventUserBanned,
+	models.AuditEventUserUnbanned,
+	models.AuditEventUserSuspended,
+	models.AuditEventUserUnsuspended,
+}
+
+// ActivityEntry is an audit log entry as shown to the affected user. The
+// acting admin is reduced to a flag.
+type ActivityEntry struct {
+	ID        uint              `json:"id"`
+	Event     models.AuditEvent `json:"event"`
+	ByAdmin   bool              `json:"by_admin"`
+	IPAddress string            `json:"ip_address,omitempty"`
+	UserAgent string            `json:"user_agent,omitempty"`
+	Metadata  json.RawMessage   `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// GetMyActivity returns a page of the authenticated user's recent account
+// activity, newest first.
+func (h *Handler) GetMyActivity(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	params, page, err := parsePageNumber(c, 50, 200)
+	if err != nil {
+		return err
+	}
+
+	query := h.db.Model(&models.AuditLog{}).
+		Where("user_id = ? AND event IN ?", claims.Subject, activityEvents)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return fiber.NewError(500, "Failed to count activity")
+	}
+
+	var entries []models.AuditLog
+	if err := params.apply(query, "created_at", "id").Find(&entries).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch activity")
+	}
+	entries, pageInfo := pageOf(params, entries, auditLogPosition)
+
+	activity := make([]ActivityEntry, len(entries))
+	for i, entry := range entries {
+		activity[i] = ActivityEntry{
+			ID:        entry.ID,
+			Event:     entry.Event,
+			ByAdmin:   entry.ActorID != nil,
+			IPAddress: entry.IPAddress,
+			UserAgent: entry.UserAgent,
+			CreatedAt: entry.CreatedAt,
+		}
+		if entry.Metadata != "" && entry.Metadata != "{}" {
+			activity[i].Metadata = json.RawMessage(entry.Metadata)
+		}
+	}
+
+	data := fiber.Map{
+		"activity": activity,
+		"total":    total,
+		"limit":    params.limit,
+	}
+	if page > 0 {
+		data["page"] = page
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    data,
+		Meta:    pageInfo,
+	})
+}
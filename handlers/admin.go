@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/store"
+	"api/utils"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RevokeSessionsRequest represents the filters for a bulk session revocation.
+// At least one filter must be provided; all provided filters are combined
+// with AND.
+type RevokeSessionsRequest struct {
+	UserID       uint   `json:"user_id,omitempty"`
+	IPRange      string `json:"ip_range,omitempty"`      // CIDR (e.g. 10.0.0.0/8) or a single IP
+	IssuedBefore string `json:"issued_before,omitempty"` // RFC3339 timestamp
+}
+
+// AdminRevokeSessions revokes every active session matching the given
+// filters in a single batched UPDATE. Intended for incident response.
+func (h *Handler) AdminRevokeSessions(c *fiber.Ctx) error {
+	var req RevokeSessionsRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	if req.UserID == 0 && req.IPRange == "" && req.IssuedBefore == "" {
+		return fiber.NewError(400, "At least one filter is required: user_id, ip_range or issued_before")
+	}
+
+	filter := store.SessionFilter{TenantID: middleware.TenantID(c), UserID: req.UserID}
+
+	if req.IPRange != "" {
+		cidr, err := parseIPRange(req.IPRange)
+		if err != nil {
+			return fiber.NewError(400, "Invalid ip_range. Expected CIDR notation or a single IP address")
+		}
+		filter.IPRange = cidr
+	}
+
+	if req.IssuedBefore != "" {
+		before, err := time.Parse(time.RFC3339, req.IssuedBefore)
+		if err != nil {
+			return fiber.NewError(400, "Invalid issued_before. Expected RFC3339 timestamp")
+		}
+		filter.IssuedBefore = &before
+	}
+
+	perUser, err := h.stores.Sessions().RevokeMatching(filter)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	var total int64
+	for userID, revoked := range perUser {
+		h.sessionsRevoked(userID, revoked, "admin_revoked")
+		total += revoked
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Sessions revoked",
+		Data: fiber.Map{
+			"revoked": total,
+		},
+	})
+}
+
+// AdminRevokeUserSessions revokes every session of the user in :id, which
+// invalidates their refresh tokens and access tokens at once.
+func (h *Handler) AdminRevokeUserSessions(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	user, err := h.stores.Users().ByID(uint(userID))
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+
+	revoked, err := revokeUserSessions(h.stores, user.ID)
+	if err != nil {
+		return err
+	}
+	h.sessionsRevoked(user.ID, revoked, "admin_revoked")
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventUserSessionsRevoked,
+		UserID:    &user.ID,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"revoked_sessions": revoked})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Sessions revoked",
+		Data: fiber.Map{
+			"revoked": revoked,
+		},
+	})
+}
+
+// parseIPRange normalizes a CIDR or bare IP into CIDR notation.
+func parseIPRange(value string) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network.String(), nil
+	}
+
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return "", fmt.Errorf("invalid ip range %q", value)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
+
+// AdminListLockouts returns the tenant's users that are currently locked out.
+func (h *Handler) AdminListLockouts(c *fiber.Ctx) error {
+	users, err := h.stores.Users().Locked(middleware.TenantID(c))
+	if err != nil {
+		return fiber.NewError(500, "Failed to fetch locked accounts")
+	}
+
+	lockouts := make([]fiber.Map, 0, len(users))
+	for _, user := range users {
+		lockouts = append(lockouts, fiber.Map{
+			"user_id":               user.ID,
+			"username":              user.Username,
+			"email":                 user.Email,
+			"failed_login_attempts": user.FailedLoginAttempts,
+			"locked_until":          user.LockedUntil,
+		})
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    lockouts,
+	})
+}
+
+// AdminClearLockout unlocks a user's account and resets the failure counter.
+func (h *Handler) AdminClearLockout(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	found, err := h.stores.Users().UpdateByID(uint(userID), map[string]any{
+		"failed_login_attempts": 0,
+		"locked_until":          nil,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear lockout: %w", err)
+	}
+	if !found {
+		return fiber.NewError(404, "User not found")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Account unlocked",
+		Data:    nil,
+	})
+}
+
+// UpdateRoleRequest represents the request body for changing a user's role
+type UpdateRoleRequest struct {
+	Role models.Role `json:"role" validate:"required,oneof=user admin"`
+}
+
+// AdminUpdateUserRole changes a user's role and revokes all of their
+// sessions, so tokens issued under the previous role cannot be reused.
+func (h *Handler) AdminUpdateUserRole(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+	if uint(userID) == claims.Subject {
+		return fiber.NewError(400, "You cannot change your own role")
+	}
+
+	var req UpdateRoleRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(uint(userID))
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	if user.Role == req.Role {
+		return fiber.NewError(409, "User already has this role")
+	}
+
+	previousRole := user.Role
+
+	var revoked int64
+	err = h.stores.Transaction(func(tx store.Store) error {
+		if err := tx.Users().Update(user, map[string]any{"role": req.Role}); err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+		revoked, err = revokeUserSessions(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	h.sessionsRevoked(user.ID, revoked, "role_changed")
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventUserRoleChanged,
+		UserID:    &user.ID,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"previous_role":    previousRole,
+		"role":             req.Role,
+		"revoked_sessions": revoked,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Role updated",
+		Data: fiber.Map{
+			"role":             req.Role,
+			"revoked_sessions": revoked,
+		},
+	})
+}
+
+// CreateIPRuleRequest represents the request body for creating an IP rule
+type CreateIPRuleRequest struct {
+	CIDR      string              `json:"cidr" validate:"required"` // CIDR or a single IP
+	Action    models.IPRuleAction `json:"action" validate:"required,oneof=allow deny"`
+	Reason    string              `json:"reason,omitempty"`
+	ExpiresAt string              `json:"expires_at,omitempty"` // RFC3339; omit for a permanent rule
+}
+
+// AdminListIPRules returns all IP allow/deny rules
+func (h *Handler) AdminListIPRules(c *fiber.Ctx) error {
+	var rules []models.IPRule
+	if err := h.db.Order("created_at DESC").Find(&rules).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch IP rules")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    rules,
+	})
+}
+
+// AdminCreateIPRule adds an IP allow/deny rule
+func (h *Handler) AdminCreateIPRule(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var req CreateIPRuleRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	cidr, err := parseIPRange(req.CIDR)
+	if err != nil {
+		return fiber.NewError(400, "Invalid cidr. Expected CIDR notation or a single IP address")
+	}
+
+	rule := models.IPRule{
+		CIDR:      cidr,
+		Action:    req.Action,
+		Reason:    req.Reason,
+		CreatedBy: claims.Subject,
+	}
+
+	if req.ExpiresAt != "" {
+		expiresAt, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil {
+			return fiber.NewError(400, "Invalid expires_at. Expected RFC3339 timestamp")
+		}
+		if !expiresAt.After(time.Now()) {
+			return fiber.NewError(400, "expires_at must be in the future")
+		}
+		rule.ExpiresAt = &expiresAt
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		return fmt.Errorf("failed to create ip rule: %w", err)
+	}
+
+	h.caches.IPRules.Invalidate()
+
+	return c.Status(201).JSON(utils.Response{
+		Success: true,
+		Code:    201,
+		Message: "IP rule created",
+		Data:    rule,
+	})
+}
+
+// AdminDeleteIPRule removes an IP allow/deny rule
+func (h *Handler) AdminDeleteIPRule(c *fiber.Ctx) error {
+	ruleID, err := c.ParamsInt("id")
+	if err != nil || ruleID <= 0 {
+		return fiber.NewError(400, "Invalid rule id")
+	}
+
+	result := h.db.Delete(&models.IPRule{}, ruleID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete ip rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fiber.NewError(404, "IP rule not found")
+	}
+
+	h.caches.IPRules.Invalidate()
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "IP rule deleted",
+		Data:    nil,
+	})
+}
+
+// CreateInvitationRequest represents the request body for creating an invitation
+type CreateInvitationRequest struct {
+	Email          string      `json:"email,omitempty" validate:"omitempty,email"`           // Restrict the invitation to this email and send it there
+	Role           models.Role `json:"role,omitempty" validate:"omitempty,oneof=user admin"` // Role given to the invitee; defaults to user
+	Locale         string      `json:"locale,omitempty"`                                     // Language of the invitation email; defaults to en
+	ExpiresInHours int         `json:"expires_in_hours,omitempty" validate:"gte=0"`          // Defaults to 7 days
+}
+
+// AdminCreateInvitation issues an invitation token. When an email is given,
+// the invitation is also sent there with a link to the registration page.
+func (h *Handler) AdminCreateInvitation(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var req CreateInvitationRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	expiresIn := 7 * 24 * time.Hour
+	if req.ExpiresInHours > 0 {
+		expiresIn = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+
+	if req.Role == "" {
+		req.Role = models.RoleUser
+	}
+
+	locale := models.Locale(req.Locale)
+	if locale == "" {
+		locale = models.LocaleEN
+	} else if !isValidLocale(locale) {
+		return fiber.NewError(400, "Invalid locale. Supported locales: en, ro, de")
+	}
+
+	inviteToken, hashedToken := utils.GenerateSecureToken()
+
+	invitation := models.Invitation{
+		TenantID:  middleware.TenantID(c),
+		Email:     utils.NormalizeEmail(req.Email),
+		Token:     hashedToken,
+		Role:      req.Role,
+		InvitedBy: claims.Subject,
+		ExpiresAt: time.Now().Add(expiresIn),
+	}
+
+	if err := h.db.Create(&invitation).Error; err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	emailQueued := false
+	if invitation.Email != "" {
+		if err := h.sendInvitationEmail(&invitation, inviteToken, claims.Subject, locale, expiresIn); err != nil {
+			log.Printf("create invitation %d: %v", invitation.ID, err)
+		} else {
+			emailQueued = true
+		}
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventInvitationCreated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"invitation_id": invitation.ID,
+		"role":          invitation.Role,
+		"email_queued":  emailQueued,
+	})
+
+	return c.Status(201).JSON(utils.Response{
+		Success: true,
+		Code:    201,
+		Message: "Invitation created",
+		Data: fiber.Map{
+			"invitation":   invitation,
+			"invite_token": inviteToken,
+			"email_queued": emailQueued,
+		},
+	})
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/store"
+	"api/utils"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AdminListUsers returns one page of users, newest first. Filters: email
+// (exact), username (substring), account_type, role, status (active, locked,
+// banned, suspended, unverified, anonymized or deleted), created_after and
+// created_before (RFC3339). Pages are selected with limit and cursor, or
+// with the older page parameter.
+func (h *Handler) AdminListUsers(c *fiber.Ctx) error {
+	params, page, err := parsePageNumber(c, 50, 200)
+	if err != nil {
+		return err
+	}
+
+	filter := store.UserFilter{
+		TenantID:    middleware.TenantID(c),
+		Email:       utils.NormalizeEmail(c.Query("email")),
+		Username:    c.Query("username"),
+		AccountType: models.AccountType(c.Query("account_type")),
+		Role:        models.Role(c.Query("role")),
+		Status:      store.UserStatus(c.Query("status")),
+	}
+	if filter.Status != "" && !slices.Contains(store.UserStatuses, filter.Status) {
+		return fiber.NewError(400, "Invalid status. Supported statuses: active, locked, banned, suspended, unverified, anonymized, deleted")
+	}
+
+	for param, bound := range map[string]**time.Time{"created_after": &filter.CreatedAfter, "created_before": &filter.CreatedBefore} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return fiber.NewError(400, "Invalid "+param+". Expected RFC3339 timestamp")
+		}
+		*bound = &t
+	}
+
+	users, total, err := h.stores.Users().List(filter, params.page())
+	if err != nil {
+		return fiber.NewError(500, "Failed to fetch users")
+	}
+	users, pageInfo := pageOf(params, users, func(u models.User) utils.Cursor {
+		return utils.Cursor{Time: u.CreatedAt, ID: u.ID}
+	})
+
+	data := fiber.Map{
+		"users": users,
+		"total": total,
+		"limit": params.limit,
+	}
+	if page > 0 {
+		data["page"] = page
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    data,
+		Meta:    pageInfo,
+	})
+}
+
+// AdminGetUser returns one user with their sessions, newest first, and linked
+// OAuth accounts. Soft-deleted users are included so they can be inspected
+// during the deletion grace period.
+func (h *Handler) AdminGetUser(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	user, err := h.stores.Users().WithDetails(uint(userID), true)
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+
+	// Sanitize sensitive fields
+	for i := range user.OAuthLinks {
+		user.OAuthLinks[i].AccessToken = ""
+		user.OAuthLinks[i].RefreshToken = ""
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    user,
+	})
+}
+
+// AdminListUserOAuthAccounts returns the OAuth accounts linked to the user in
+// :id, without provider tokens.
+func (h *Handler) AdminListUserOAuthAccounts(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	user, err := h.stores.Users().ByID(uint(userID))
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+
+	oauthAccounts, err := h.stores.OAuth().ListByUser(user.ID)
+	if err != nil {
+		return fiber.NewError(500, "Failed to fetch OAuth accounts")
+	}
+
+	// Sanitize sensitive fields
+	for i := range oauthAccounts {
+		oauthAccounts[i].AccessToken = ""
+		oauthAccounts[i].RefreshToken = ""
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    oauthAccounts,
+	})
+}
+
+// AdminUnlinkUserOAuthAccount removes a provider link from the user in :id,
+// e.g. when they lost access to the provider account. The same rules as a
+// self-service unlink apply, and the user is notified by email.
+func (h *Handler) AdminUnlinkUserOAuthAccount(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	provider := models.OAuthProvider(c.Params("provider"))
+	if provider != models.OAuthProviderGoogle && provider != models.OAuthProviderGithub {
+		return fiber.NewError(400, "Invalid OAuth provider")
+	}
+
+	user, err := h.unlinkOAuthAccount(uint(userID), provider)
+	if err != nil {
+		return err
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventOAuthUnlinkedByAdmin,
+		UserID:    &user.ID,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"provider": provider})
+
+	h.sendOAuthLinkEmail(user, provider, c.IP(), false)
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: fmt.Sprintf("%s account unlinked", provider),
+		Data:    nil,
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+type AnonymizeAccountProps struct {
+	Password string `json:"password"` // Required when the account has a password
+}
+
+// AnonymizeMe irreversibly anonymizes the authenticated user's account, for
+// erasure requests. Accounts with a password must confirm it.
+func (h *Handler) AnonymizeMe(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var body AnonymizeAccountProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	if user.AnonymizedAt != nil {
+		return fiber.NewError(409, "Account is already anonymized")
+	}
+	if user.Password != "" && !utils.ComparePassword(body.Password, user.Password) {
+		return fiber.NewError(401, "Invalid password")
+	}
+
+	removed, err := h.anonymizeUser(user)
+	if err != nil {
+		return err
+	}
+
+	// The request's IP and user agent are personal data too, so leave them out
+	h.recordAuditEvent(models.AuditLog{
+		Event:  models.AuditEventUserAnonymized,
+		UserID: &user.ID,
+	}, removed)
+	h.publishEvent(models.WebhookEventUserDeleted, fiber.Map{
+		"user_id":      user.ID,
+		"reason":       "anonymized",
+		"initiated_by": "user",
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Your account has been anonymized",
+		Data:    nil,
+	})
+}
+
+// AdminAnonymizeUser irreversibly anonymizes the user in :id on their behalf.
+func (h *Handler) AdminAnonymizeUser(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+	if uint(userID) == claims.Subject {
+		return fiber.NewError(400, "You cannot anonymize your own account here")
+	}
+
+	user, err := h.stores.Users().ByID(uint(userID))
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	if user.AnonymizedAt != nil {
+		return fiber.NewError(409, "User is already anonymized")
+	}
+
+	removed, err := h.anonymizeUser(user)
+	if err != nil {
+		return err
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventUserAnonymized,
+		UserID:    &user.ID,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, removed)
+	h.publishEvent(models.WebhookEventUserDeleted, fiber.Map{
+		"user_id":      user.ID,
+		"reason":       "anonymized",
+		"initiated_by": "admin",
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "User anonymized",
+		Data:    nil,
+	})
+}
+
+// anonymizeUser replaces the user's email and username with random values,
+// deletes their credentials and tokens, and strips personal data from the
+// rows that reference them. The user row and its ID are kept, so audit
+// entries and other references stay valid. It returns the rows removed per
+// table.
+func (h *Handler) anonymizeUser(user *models.User) (fiber.Map, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("failed to generate anonymous identity: %w", err)
+	}
+	alias := "deleted-" + hex.EncodeToString(suffix)
+	email := alias + "@anonymized.invalid"
+	matchEmail, emailArg := emailCondition("email", "email_hash", utils.NormalizeEmail(user.Email))
+	avatarKey := user.AvatarKey
+
+	var removed fiber.Map
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if removed, err = deleteUserCredentials(tx, user); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		err = tx.Model(user).Select("*").Omit("id", "created_at", "deleted_at").Updates(models.User{
+			Username:     alias,
+			Email:        email,
+			EmailHash:    utils.EmailBlindIndex(email),
+			AccountType:  models.AccountTypeEmail,
+			Role:         models.RoleUser,
+			Currency:     user.Currency,
+			Timezone:     models.TimezoneUTC,
+			Locale:       models.LocaleEN,
+			AnonymizedAt: &now,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to anonymize user: %w", err)
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.EmailSuppression{}).Error; err != nil {
+			return fmt.Errorf("failed to delete email suppressions: %w", err)
+		}
+		if err := deleteUserSettings(tx, user.ID); err != nil {
+			return err
+		}
+
+		scrubs := []struct {
+			name    string
+			query   *gorm.DB
+			model   any
+			updates map[string]interface{}
+		}{
+			{"login attempts", tx.Where("user_id = ? OR (tenant_id = ? AND "+matchEmail+")", user.ID, user.TenantID, emailArg), &models.LoginAttempt{}, map[string]interface{}{
+				"email": "", "email_hash": nil, "ip_address": "", "asn": "", "user_agent": "",
+				"latitude": nil, "longitude": nil, "country": "", "city": "",
+			}},
+			{"audit logs", tx.Where("user_id = ? OR actor_id = ?", user.ID, user.ID), &models.AuditLog{}, map[string]interface{}{
+				"ip_address": "", "user_agent": "", "metadata": "{}",
+			}},
+			{"queued emails", tx.Where("user_id = ?", user.ID), &models.EmailOutbox{}, map[string]interface{}{
+				"recipients": "", "recipient_hash": nil, "body": "", "html_body": "", "attachments": "",
+			}},
+			{"email delivery events", tx.Where("user_id = ?", user.ID), &models.EmailDeliveryEvent{}, map[string]interface{}{
+				"email": "", "email_hash": nil,
+			}},
+		}
+		for _, s := range scrubs {
+			if err := s.query.Model(s.model).Updates(s.updates).Error; err != nil {
+				return fmt.Errorf("failed to anonymize %s: %w", s.name, err)
+			}
+		}
+
+		// Nothing is left to deliver to
+		return tx.Model(&models.EmailOutbox{}).
+			Where("user_id = ? AND status = ?", user.ID, models.EmailStatusPending).
+			Update("status", models.EmailStatusSuppressed).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.deleteStoredAvatar(avatarKey)
+	return removed, nil
+}
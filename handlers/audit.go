@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/utils"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// setupSIEM configures event streaming (see utils.NewSIEMStreamer).
+func (h *Handler) setupSIEM() {
+	streamer, err := utils.NewSIEMStreamer()
+	if err != nil {
+		log.Fatalf("siem: %v", err)
+	}
+	h.siem = streamer
+}
+
+// recordAuditEvent appends an entry to the audit log and streams it to the
+// SIEM, if configured. Audit failures are logged but never fail the request
+// that triggered them.
+func (h *Handler) recordAuditEvent(entry models.AuditLog, metadata fiber.Map) {
+	if len(metadata) > 0 {
+		encoded, err := json.Marshal(metadata)
+		if err != nil {
+			log.Printf("audit: failed to encode metadata for %s: %v", entry.Event, err)
+		} else {
+			entry.Metadata = string(encoded)
+		}
+	}
+
+	if err := h.db.Create(&entry).Error; err != nil {
+		log.Printf("audit: failed to record %s: %v", entry.Event, err)
+	}
+
+	// Stream even when the insert failed, so the SIEM still sees the event
+	if h.siem != nil {
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = time.Now()
+		}
+		h.siem.Publish(entry)
+	}
+}
+
+// AdminListAuditLogs returns one page of audit log entries, newest first,
+// optionally filtered by event, user_id and ip query parameters.
+func (h *Handler) AdminListAuditLogs(c *fiber.Ctx) error {
+	params, err := parsePage(c, 100, 1000)
+	if err != nil {
+		return err
+	}
+
+	// Entries about the tenant's users, plus deployment-wide entries such as
+	// IP blocks for the default tenant
+	tenantID := middleware.TenantID(c)
+	users := h.tenantUsers(tenantID)
+	scope := h.db.Where("user_id IN (?) OR actor_id IN (?)", users, users)
+	if tenantID == models.DefaultTenantID {
+		scope = scope.Or("user_id IS NULL AND actor_id IS NULL")
+	}
+	query := h.db.Model(&models.AuditLog{}).Where(scope)
+
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+
+	var entries []models.AuditLog
+	if err := params.apply(query, "created_at", "id").Find(&entries).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch audit logs")
+	}
+	entries, pageInfo := pageOf(params, entries, auditLogPosition)
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    entries,
+		Meta:    pageInfo,
+	})
+}
+
+// auditLogPosition is the pagination cursor of an audit log entry.
+func auditLogPosition(entry models.AuditLog) utils.Cursor {
+	return utils.Cursor{Time: entry.CreatedAt, ID: entry.ID}
+}
@@ -1,183 +1,211 @@
 package handlers
 
 import (
-	"api/database"
+	"api/config"
 	"api/database/models"
+	"api/middleware"
+	"api/service"
+	"api/store"
 	"api/utils"
-	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"gorm.io/gorm"
 )
 
-var db *gorm.DB
-
 type RegisterProps struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Username    string `json:"username" validate:"required"`
+	Email       string `json:"email" validate:"required,email"`
+	Password    string `json:"password" validate:"required"`
+	Locale      string `json:"locale,omitempty"`       // Email language; defaults to the Accept-Language match
+	InviteToken string `json:"invite_token,omitempty"` // Required in invite-only mode
+	AcceptTerms bool   `json:"accept_terms,omitempty"` // Required when TERMS_VERSION or PRIVACY_VERSION is set
+	CaptchaProps
+	Website   string `json:"website,omitempty"`    // Honeypot: hidden from humans, must stay empty
+	FormToken string `json:"form_token,omitempty"` // From GET /auth/register/form, checks the minimum form time
 }
 
 type LoginProps struct {
-	Email    string
-	Password string
+	Email      string `json:"email" validate:"required"`
+	Password   string `json:"password" validate:"required"`
+	StepUpCode string `json:"step_up_code,omitempty"` // Emailed code completing a flagged or risky login
+	CaptchaProps
 }
 
-func Register(c *fiber.Ctx) error {
+func (h *Handler) Register(c *fiber.Ctx) error {
 	var body RegisterProps
 
-	err := c.BodyParser(&body)
-
-	if err != nil {
-		return fiber.NewError(400, "Malformed request")
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
 	}
 
-	// Validate required fields
-	if body.Username == "" {
-		return fiber.NewError(400, "Username is required")
-	}
-	if body.Email == "" {
-		return fiber.NewError(400, "Email is required")
-	}
-	if body.Password == "" {
-		return fiber.NewError(400, "Password is required")
+	// Turn away automated sign-ups before doing any real work
+	if err := h.checkRegistrationBot(c, &body); err != nil {
+		return err
 	}
 
-	// Basic username validation
-	if len(body.Username) < 3 {
-		return fiber.NewError(400, "Username must be at least 3 characters long")
-	}
-	if len(body.Username) > 255 {
-		return fiber.NewError(400, "Username must be less than 255 characters")
+	locale := models.Locale(body.Locale)
+	if locale == "" {
+		locale = requestLocale(c)
+	} else if !isValidLocale(locale) {
+		return fiber.NewError(400, "Invalid locale. Supported locales: en, ro, de")
 	}
 
-	hash, err := utils.HashPassword(body.Password)
-
+	tenantID := middleware.TenantID(c)
+	invitation, err := h.checkRegistrationWithInvitation(tenantID, utils.NormalizeEmail(body.Email), body.InviteToken)
 	if err != nil {
-		// return the original error so the global error handler will log it
-		// and return a generic 500 to the client
 		return err
 	}
 
-	user := models.User{
-		Username: body.Username,
-		Email:    body.Email,
-		Password: hash,
+	legal := utils.CurrentLegalDocuments()
+	if legal.Tracked() && !body.AcceptTerms {
+		return fiber.NewError(400, "You must accept the terms of service and privacy policy")
 	}
 
-	err = db.Create(&user).Error
+	if err := h.verifyCaptcha(c, captchaActionRegister); err != nil {
+		return err
+	}
 
+	user, err := h.accounts.Register(service.RegisterInput{
+		TenantID:   tenantID,
+		Username:   body.Username,
+		Email:      body.Email,
+		Password:   body.Password,
+		Locale:     locale,
+		Invitation: invitation,
+		Legal:      legal,
+	})
 	if err != nil {
-		return fiber.NewError(400, "User with this email or username already exists")
+		return serviceError(err)
 	}
 
-	jti, jwt, err := utils.GetSignedKey(user.ID)
-
+	jwt, csrfToken, err := h.issueSession(c, h.stores, user.ID)
 	if err != nil {
 		return err
 	}
 
-	refreshToken, hashedToken := utils.GenerateRefreshToken()
-
-	session := models.Session{
-		JTI:          jti,
-		UserID:       user.ID,
-		RefreshToken: hashedToken,
-		Revoked:      false,
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour),
+	// Queue a welcome email. Do not block registration on email delivery.
+	verifyURL, err := h.issueEmailVerification(user)
+	if err != nil {
+		log.Printf("register: %v", err)
 	}
-	db.Create(&session)
-
-	c.Cookie(&fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		HTTPOnly: true,
-		SameSite: "Lax",
-		Secure:   os.Getenv("ENV") == "production",
+	if err := h.queueUserEmail(user, utils.EmailTemplateWelcome, utils.WelcomeEmail{Username: user.Username, VerifyURL: verifyURL}); err != nil {
+		log.Printf("register: %v", err)
+	}
+	h.publishEvent(models.WebhookEventUserCreated, fiber.Map{
+		"user_id":  user.ID,
+		"username": user.Username,
+		"method":   "password",
 	})
 
-	// Send a welcome email asynchronously. Do not block registration on email delivery.
-	go func(email string) {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		client := utils.NewSMTPClient()
-		subject := "Welcome to Asuna Labs"
-		body := "Welcome! Your account has been created successfully.\n\nThanks for joining."
-		if err := client.Send(ctx, []string{email}, subject, body); err != nil {
-			// Best-effort logging via standard error output; keep registration successful.
-			// In an enterprise setup, replace with structured logger/metrics.
-			_ = err
-		}
-	}(user.Email)
-
 	return c.JSON(utils.Response{
 		Success: true,
 		Code:    200,
 		Message: "Registered Successfully",
 		Data: struct {
-			Token string `json:"token"`
+			Token     string `json:"token"`
+			CSRFToken string `json:"csrf_token"`
 		}{
-			Token: jwt,
+			Token:     jwt,
+			CSRFToken: csrfToken,
 		},
 	})
 }
 
-func Login(c *fiber.Ctx) error {
+func (h *Handler) Login(c *fiber.Ctx) error {
 	var body LoginProps
-	err := c.BodyParser(&body)
-	if err != nil {
-		return fiber.NewError(400, "Malformed request")
-	}
-
-	var user models.User
-	err = db.Where(&models.User{Email: body.Email}).First(&user).Error
-	if err != nil {
-		return fiber.NewError(404, "User not found")
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
 	}
 
-	// Verify the password against the stored hash
-	if !utils.ComparePassword(body.Password, user.Password) {
-		return fiber.NewError(401, "Invalid credentials")
+	// Escalate (CAPTCHA, IP block) on bursts of failures across accounts
+	if err := h.checkCredentialStuffing(c); err != nil {
+		return err
 	}
 
-	jti, jwt, err := utils.GetSignedKey(user.ID)
+	body.Email = utils.NormalizeEmail(body.Email)
 
+	user, err := h.accounts.Login(middleware.TenantID(c), body.Email, body.Password, service.LoginChecks{
+		// Require a CAPTCHA once the account has accumulated failed logins
+		BeforePassword: func(user *models.User) error {
+			if user.FailedLoginAttempts >= h.captcha.loginAfterFailures {
+				return h.verifyCaptcha(c, captchaActionLogin)
+			}
+			return nil
+		},
+		AfterPassword: func(user *models.User) error {
+			// Admins may always have to confirm password logins by email
+			if err := h.checkAdminStepUp(c, user, body.StepUpCode); err != nil {
+				return err
+			}
+			// Score the login and require a CAPTCHA or code, or deny it, when risky
+			if err := h.assessLoginRisk(c, user, body.StepUpCode); err != nil {
+				return err
+			}
+			// Flag logins from implausibly distant locations
+			return h.checkImpossibleTravel(c, user, body.StepUpCode)
+		},
+	})
 	if err != nil {
-		return err
+		var userID *uint
+		if user != nil {
+			userID = &user.ID
+		}
+		if reason := loginFailureReason(err); reason != "" {
+			h.recordLoginAttempt(c, body.Email, userID, false, reason)
+		}
+		if errors.Is(err, service.ErrAccountLocked) {
+			retryAfter := int(math.Ceil(time.Until(*user.LockedUntil).Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		}
+		return serviceError(err)
 	}
 
-	refreshToken, hashedToken := utils.GenerateRefreshToken()
+	h.recordLoginAttempt(c, body.Email, &user.ID, true, "")
 
-	session := models.Session{
-		JTI:          jti,
-		UserID:       user.ID,
-		RefreshToken: hashedToken,
-		Revoked:      false,
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour),
+	jwt, csrfToken, err := h.issueSession(c, h.stores, user.ID)
+	if err != nil {
+		return err
 	}
-	db.Create(&session)
 
-	c.Cookie(&fiber.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		HTTPOnly: true,
-		SameSite: "Lax",
-		Secure:   os.Getenv("ENV") == "production",
-	})
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventLoginSucceeded,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"method": "password"})
 
 	return c.JSON(utils.Response{
 		Success: true,
 		Code:    200,
 		Message: "Signed in successfully",
 		Data: fiber.Map{
-			"token": jwt,
+			"token":      jwt,
+			"csrf_token": csrfToken,
 		},
 	})
 }
 
-func RefreshToken(c *fiber.Ctx) error {
+// RefreshToken issues a new access token for the session of the
+// refresh_token cookie. The refresh token itself stays valid (API v1).
+func (h *Handler) RefreshToken(c *fiber.Ctx) error {
+	return h.refreshSession(c, false)
+}
+
+// RotateRefreshToken issues a new access token and replaces the refresh
+// token, so each refresh token can be used only once (API v2). A new CSRF
+// token is returned with it.
+func (h *Handler) RotateRefreshToken(c *fiber.Ctx) error {
+	return h.refreshSession(c, true)
+}
+
+func (h *Handler) refreshSession(c *fiber.Ctx, rotate bool) error {
 	refreshToken := c.Cookies("refresh_token")
 
 	if refreshToken == "" {
@@ -186,10 +214,10 @@ func RefreshToken(c *fiber.Ctx) error {
 
 	hash := utils.HashTokenSHA256(refreshToken)
 
-	var session models.Session
-
-	err := db.Where(&models.Session{RefreshToken: hash}).First(&session).Error
-
+	session, err := h.stores.Sessions().ByRefreshToken(hash)
+	if errors.Is(err, store.ErrNotFound) {
+		return h.rejectUnknownRefreshToken(c, hash)
+	}
 	if err != nil {
 		return fiber.NewError(401, "Unauthorized")
 	}
@@ -200,46 +228,82 @@ func RefreshToken(c *fiber.Ctx) error {
 
 	if session.ExpiresAt.Before(time.Now()) {
 		session.Revoked = true
-		db.Save(&session)
+		h.stores.Sessions().Save(session)
 
 		return fiber.NewError(401, "Unauthorized: Refresh token expired")
 	}
 
-	jti, jwt, err := utils.GetSignedKey(session.UserID)
+	// Optionally bind the refresh token to the TLS client that signed in
+	if err := h.checkTLSBinding(c, session); err != nil {
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(session.UserID)
+	if err != nil {
+		return fiber.NewError(401, "Unauthorized")
+	}
+	if err := checkAccountRestriction(user); err != nil {
+		session.Revoked = true
+		h.stores.Sessions().Save(session)
+		h.sessionsRevoked(user.ID, 1, "account_restricted")
+		return err
+	}
+
+	jti, jwt, err := utils.GetSignedKey(user)
 
 	if err != nil {
 		return err
 	}
 
 	session.JTI = jti
-	db.Save(&session)
+	var newRefreshToken string
+	if rotate {
+		newRefreshToken, session.RefreshToken = utils.GenerateRefreshToken()
+	}
+	err = h.stores.Transaction(func(tx store.Store) error {
+		if rotate {
+			if err := tx.Sessions().Retire(session.ID, hash); err != nil {
+				return err
+			}
+		}
+		return tx.Sessions().Save(session)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+
+	data := fiber.Map{"token": jwt}
+	if rotate {
+		data["csrf_token"] = setAuthCookies(c, newRefreshToken)
+	}
 
 	return c.JSON(utils.Response{
 		Success: true,
 		Code:    200,
 		Message: "Success",
-		Data: fiber.Map{
-			"token": jwt,
-		},
+		Data:    data,
 	})
 }
 
-func RevokeToken(c *fiber.Ctx) error {
+func (h *Handler) RevokeToken(c *fiber.Ctx) error {
 	refreshToken := c.Cookies("refresh_token")
 	if refreshToken == "" {
 		return fiber.NewError(400, "Missing refresh_token")
 	}
 
-	var session models.Session
-	err := db.Where(&models.Session{RefreshToken: utils.HashTokenSHA256(refreshToken)}).First(&session).Error
+	session, err := h.stores.Sessions().ByRefreshToken(utils.HashTokenSHA256(refreshToken))
 	if err != nil {
 		return fiber.NewError(404, "Invalid token")
 	}
 
+	wasActive := !session.Revoked
 	session.Revoked = true
-	db.Save(&session)
+	h.stores.Sessions().Save(session)
+	if wasActive {
+		h.sessionsRevoked(session.UserID, 1, "logout")
+	}
 
-	c.ClearCookie("refresh_token")
+	c.ClearCookie("refresh_token", middleware.CSRFCookieName)
 
 	return c.Status(fiber.StatusOK).JSON(utils.Response{
 		Success: true,
@@ -249,6 +313,86 @@ func RevokeToken(c *fiber.Ctx) error {
 	})
 }
 
-func SetupAuth() {
-	db = database.GetInstance()
+// setAuthCookies stores the refresh token in an HttpOnly cookie and issues a
+// fresh double-submit CSRF token. The CSRF token is also returned so clients
+// on another origin, which cannot read the cookie, can still send it back.
+func setAuthCookies(c *fiber.Ctx, refreshToken string) string {
+	secure := config.Get().Production()
+
+	// Cross-origin SPAs need SameSite=None for the browser to send the
+	// cookie, and browsers only accept SameSite=None on secure cookies.
+	sameSite := os.Getenv("COOKIE_SAME_SITE")
+	if sameSite == "" {
+		sameSite = fiber.CookieSameSiteLaxMode
+	}
+	if strings.EqualFold(sameSite, fiber.CookieSameSiteNoneMode) {
+		secure = true
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		HTTPOnly: true,
+		SameSite: sameSite,
+		Secure:   secure,
+	})
+
+	csrfToken, _ := utils.GenerateSecureToken()
+	c.Cookie(&fiber.Cookie{
+		Name:     middleware.CSRFCookieName,
+		Value:    csrfToken,
+		HTTPOnly: false,
+		SameSite: sameSite,
+		Secure:   secure,
+	})
+
+	return csrfToken
+}
+
+// loginFailureReason classifies a failed login for the login attempt log.
+// It returns "" for failures the checks have already recorded.
+func loginFailureReason(err error) string {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return loginFailureUnknownUser
+	case errors.Is(err, service.ErrAccountLocked):
+		return loginFailureLocked
+	case errors.Is(err, service.ErrPasswordResetRequired):
+		return loginFailureResetRequired
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return loginFailureInvalidPassword
+	case errors.Is(err, service.ErrAccountRestricted):
+		return loginFailureRestricted
+	}
+	return ""
+}
+
+// setupAuth configures the auth handlers. sender delivers account emails;
+// nil uses SMTP (see utils.NewSMTPClient).
+func (h *Handler) setupAuth(sender utils.EmailSender) {
+	h.setMailer(sender)
+	h.setupSecurityPolicy()
+	h.setupSIEM()
+	h.setupEventBus()
+	h.usernamePolicy = utils.NewUsernamePolicy()
+	h.setupCaptcha()
+	h.setupCredentialStuffing()
+	h.setupRegistration()
+	h.setupFeatureFlags()
+	h.setupBotDetection()
+	h.setupEmailVerification()
+	h.setupEmailWebhooks()
+	h.setupEventWebhooks()
+	h.setupPasswordHistory()
+	h.setupGeoAnomaly()
+	h.setupRiskScoring()
+	h.setupRequestParsing()
+	h.setupEmailProtection()
+	h.setupTLSBinding()
+	h.setupAccountPurge()
+	h.setupUserMetadata()
+	h.setupUsernameChanges()
+	h.setupCurrencies()
+	h.setupUserImport()
+	h.setupServices()
 }
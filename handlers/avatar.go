@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// avatarConfig holds the avatar upload limits and Gravatar fallback settings.
+type avatarConfig struct {
+	maxBytes int // Largest accepted upload
+	size     int // Width and height of the stored image in pixels
+
+	gravatar        bool   // Expose a Gravatar URL for users without an avatar
+	gravatarDefault string // Gravatar "d" parameter, used when no Gravatar exists
+}
+
+// setupAvatars installs the avatar storage backend and reads AVATAR_MAX_BYTES
+// (default 2 MiB), AVATAR_SIZE (default 256), GRAVATAR_FALLBACK (default
+// false) and GRAVATAR_DEFAULT (default identicon).
+func (h *Handler) setupAvatars(storage utils.ObjectStorage) {
+	h.avatarStorage = storage
+	h.avatars.maxBytes = envInt("AVATAR_MAX_BYTES", 2<<20)
+	h.avatars.size = envInt("AVATAR_SIZE", 256)
+	if h.avatars.size <= 0 || h.avatars.size > 1024 {
+		log.Fatalf("AVATAR_SIZE must be between 1 and 1024")
+	}
+
+	h.avatars.gravatar, _ = strconv.ParseBool(os.Getenv("GRAVATAR_FALLBACK"))
+	h.avatars.gravatarDefault = os.Getenv("GRAVATAR_DEFAULT")
+	if h.avatars.gravatarDefault == "" {
+		h.avatars.gravatarDefault = "identicon"
+	}
+}
+
+// applyGravatarFallback sets user.GravatarURL when the fallback is enabled
+// and the user has neither an uploaded avatar nor one from a linked OAuth
+// account. OAuthLinks must be loaded.
+func (h *Handler) applyGravatarFallback(user *models.User) {
+	if !h.avatars.gravatar || user.AvatarURL != "" || user.AnonymizedAt != nil {
+		return
+	}
+	for _, link := range user.OAuthLinks {
+		if link.AvatarURL != "" {
+			return
+		}
+	}
+
+	// Gravatar accepts the SHA-256 of the trimmed, lower-cased address
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(user.Email))))
+	query := url.Values{"s": {strconv.Itoa(h.avatars.size)}, "d": {h.avatars.gravatarDefault}}
+	user.GravatarURL = "https://www.gravatar.com/avatar/" + hex.EncodeToString(sum[:]) + "?" + query.Encode()
+}
+
+// UploadAvatar accepts a JPEG, PNG or GIF image in the "avatar" form field,
+// crops and scales it to a square PNG and stores it as the user's avatar.
+func (h *Handler) UploadAvatar(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	if h.avatarStorage == nil {
+		return fiber.NewError(503, "Avatar uploads are not configured")
+	}
+
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		return fiber.NewError(400, "An image is required in the avatar field")
+	}
+	if file.Size > int64(h.avatars.maxBytes) {
+		return fiber.NewError(413, fmt.Sprintf("Avatar must be at most %d bytes", h.avatars.maxBytes))
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open avatar upload: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, int64(h.avatars.maxBytes)+1))
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read avatar upload: %w", err)
+	}
+	if len(data) > h.avatars.maxBytes {
+		return fiber.NewError(413, fmt.Sprintf("Avatar must be at most %d bytes", h.avatars.maxBytes))
+	}
+
+	// Check the actual content, not the client-supplied type or name
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return fiber.NewError(415, "Avatar must be a JPEG, PNG or GIF image")
+	}
+
+	processed, err := utils.ProcessAvatar(data, h.avatars.size)
+	if errors.Is(err, utils.ErrInvalidImage) {
+		return fiber.NewError(400, "Avatar could not be read as an image")
+	}
+	if err != nil {
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil || user.AnonymizedAt != nil {
+		return fiber.NewError(404, "User not found")
+	}
+
+	// A new key per upload keeps caches from serving the previous image
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to generate avatar key: %w", err)
+	}
+	key := fmt.Sprintf("avatars/%d/%s.png", user.ID, hex.EncodeToString(suffix))
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), 30*time.Second)
+	defer cancel()
+	url, err := h.avatarStorage.Put(ctx, key, processed, "image/png")
+	if err != nil {
+		return fmt.Errorf("failed to store avatar: %w", err)
+	}
+
+	previousKey := user.AvatarKey
+	err = h.stores.Users().Update(user, map[string]any{
+		"avatar_url": url,
+		"avatar_key": key,
+	})
+	if err != nil {
+		h.deleteStoredAvatar(key)
+		return fmt.Errorf("failed to save avatar: %w", err)
+	}
+	h.deleteStoredAvatar(previousKey)
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Avatar updated",
+		Data:    fiber.Map{"avatar_url": url},
+	})
+}
+
+// DeleteAvatar removes the user's uploaded avatar.
+func (h *Handler) DeleteAvatar(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	if user.AvatarKey == "" {
+		return fiber.NewError(404, "No avatar uploaded")
+	}
+
+	err = h.stores.Users().Update(user, map[string]any{
+		"avatar_url": "",
+		"avatar_key": "",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove avatar: %w", err)
+	}
+	h.deleteStoredAvatar(user.AvatarKey)
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Avatar removed",
+		Data:    nil,
+	})
+}
+
+// deleteStoredAvatar removes an avatar object in the background. Failures
+// only leave an orphaned object behind, so they are logged.
+func (h *Handler) deleteStoredAvatar(key string) {
+	if key == "" || h.avatarStorage == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := h.avatarStorage.Delete(ctx, key); err != nil {
+			log.Printf("avatar: delete %s: %v", key, err)
+		}
+	}()
+}
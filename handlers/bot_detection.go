@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/utils"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// reasonRegistrationRejected is returned when a registration looks automated.
+// The message is deliberately vague so bots learn nothing from it.
+const reasonRegistrationRejected = "registration_rejected"
+
+// Bot signals recorded in the audit log.
+const (
+	botSignalHoneypot    = "honeypot"
+	botSignalMissingForm = "missing_form_token"
+	botSignalInvalidForm = "invalid_form_token"
+	botSignalTooFast     = "submitted_too_fast"
+)
+
+// botDetectionConfig holds the registration bot checks.
+type botDetectionConfig struct {
+	honeypot     bool          // reject registrations that fill the hidden "website" field
+	minFormTime  time.Duration // minimum time between fetching the form token and submitting; 0 disables
+	formTokenTTL time.Duration // how long a form token stays valid
+	// captchaFallback requires a CAPTCHA from suspected bots instead of
+	// rejecting them, so humans caught by a false positive can still sign up.
+	captchaFallback bool
+	formTokenKey    []byte // HMAC key for form tokens, derived from JWT_SECRET
+}
+
+// setupBotDetection configures registration bot checks from environment
+// variables: REGISTRATION_HONEYPOT (default true), REGISTRATION_MIN_FORM_TIME
+// (default 0, disabled), REGISTRATION_FORM_TOKEN_TTL (default 1h) and
+// REGISTRATION_BOT_ACTION (reject or captcha, default reject).
+func (h *Handler) setupBotDetection() {
+	h.botDetection.honeypot = true
+	if v, err := strconv.ParseBool(os.Getenv("REGISTRATION_HONEYPOT")); err == nil {
+		h.botDetection.honeypot = v
+	}
+	h.botDetection.minFormTime = envDuration("REGISTRATION_MIN_FORM_TIME", 0)
+	h.botDetection.formTokenTTL = envDuration("REGISTRATION_FORM_TOKEN_TTL", time.Hour)
+	h.botDetection.captchaFallback = strings.EqualFold(os.Getenv("REGISTRATION_BOT_ACTION"), "captcha")
+
+	// Derived once, like the JWT signing key, so a secrets refresh does not
+	// invalidate forms already handed out
+	key := hmac.New(sha256.New, []byte(config.Get().JWTSecret))
+	key.Write([]byte("registration-form"))
+	h.botDetection.formTokenKey = key.Sum(nil)
+}
+
+// RegistrationForm issues a form token to embed in the sign-up form. With
+// REGISTRATION_MIN_FORM_TIME set, /register requires the token and rejects
+// submissions that arrive sooner than that after it was issued.
+func (h *Handler) RegistrationForm(c *fiber.Ctx) error {
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"form_token":    h.signFormToken(time.Now()),
+			"min_form_time": h.botDetection.minFormTime.Seconds(),
+		},
+	})
+}
+
+// checkRegistrationBot runs the honeypot and form-time checks. Suspected bots
+// are rejected, or asked for a CAPTCHA when the captcha fallback is enabled
+// and a provider is configured.
+func (h *Handler) checkRegistrationBot(c *fiber.Ctx, body *RegisterProps) error {
+	var signals []string
+	if h.botDetection.honeypot && body.Website != "" {
+		signals = append(signals, botSignalHoneypot)
+	}
+	if h.botDetection.minFormTime > 0 {
+		if signal := h.checkFormToken(body.FormToken); signal != "" {
+			signals = append(signals, signal)
+		}
+	}
+	if len(signals) == 0 {
+		return nil
+	}
+
+	fallback := h.botDetection.captchaFallback && h.captcha.verifier != nil
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventRegistrationBotSuspected,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"email_hash": utils.EmailBlindIndex(body.Email), // Never the address itself
+		"username":   body.Username,
+		"signals":    signals,
+		"captcha":    fallback,
+	})
+
+	if fallback {
+		return h.requireCaptcha(c, captchaActionRegister)
+	}
+	return utils.NewReasonError(400, reasonRegistrationRejected, "Registration could not be completed")
+}
+
+// checkFormToken returns the bot signal raised by the form token, or "" if
+// it is valid and old enough.
+func (h *Handler) checkFormToken(token string) string {
+	if token == "" {
+		return botSignalMissingForm
+	}
+
+	issuedAt, ok := h.parseFormToken(token)
+	if !ok {
+		return botSignalInvalidForm
+	}
+
+	age := time.Since(issuedAt)
+	if age > h.botDetection.formTokenTTL || age < -time.Minute {
+		return botSignalInvalidForm
+	}
+	if age < h.botDetection.minFormTime {
+		return botSignalTooFast
+	}
+	return ""
+}
+
+// signFormToken encodes the issue time with an HMAC keyed by a key derived
+// from JWT_SECRET, so clients cannot backdate it.
+func (h *Handler) signFormToken(issuedAt time.Time) string {
+	payload := strconv.FormatInt(issuedAt.Unix(), 10)
+	return payload + "." + h.formTokenMAC(payload)
+}
+
+func (h *Handler) parseFormToken(token string) (time.Time, bool) {
+	payload, mac, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(mac), []byte(h.formTokenMAC(payload))) {
+		return time.Time{}, false
+	}
+
+	unix, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unix, 0), true
+}
+
+func (h *Handler) formTokenMAC(payload string) string {
+	mac := hmac.New(sha256.New, h.botDetection.formTokenKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"api/utils"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Actions on which a CAPTCHA can be enforced.
+const (
+	captchaActionRegister      = "register"
+	captchaActionLogin         = "login"
+	captchaActionPasswordReset = "password_reset"
+)
+
+// captchaVerifiedLocal marks a request whose CAPTCHA token was already
+// accepted, since providers reject a token that is verified twice.
+const captchaVerifiedLocal = "captcha_verified"
+
+// captchaTokenLocal holds the captcha_token field of a body decoded by
+// parseJSONBody.
+const captchaTokenLocal = "captcha_token"
+
+// CaptchaProps is embedded in the bodies of requests that can require a
+// CAPTCHA. Clients may send the token in the X-Captcha-Token header instead.
+type CaptchaProps struct {
+	CaptchaToken string `json:"captcha_token,omitempty"`
+}
+
+// captchaToken lets parseJSONBody find the token in any body embedding
+// CaptchaProps.
+func (p CaptchaProps) captchaToken() string {
+	return p.CaptchaToken
+}
+
+type captchaConfig struct {
+	verifier utils.CaptchaVerifier
+	// registerVerifier, if set, replaces verifier for registrations
+	registerVerifier utils.CaptchaVerifier
+	actions          map[string]bool
+	// loginAfterFailures is the number of consecutive failed logins after
+	// which a CAPTCHA is required to log in.
+	loginAfterFailures int
+}
+
+// setupCaptcha configures CAPTCHA enforcement from environment variables.
+// CAPTCHA_ACTIONS is a comma-separated list of actions to protect (default:
+// register,login,password_reset) and CAPTCHA_LOGIN_AFTER_FAILURES controls
+// when login starts requiring a CAPTCHA (default 3, 0 = always).
+func (h *Handler) setupCaptcha() {
+	verifier, err := utils.NewCaptchaVerifier()
+	if err != nil {
+		log.Fatalf("captcha: %v", err)
+	}
+	h.captcha.verifier = verifier
+
+	registerVerifier, err := utils.NewRegistrationCaptchaVerifier()
+	if err != nil {
+		log.Fatalf("captcha: %v", err)
+	}
+	h.captcha.registerVerifier = registerVerifier
+
+	actions := os.Getenv("CAPTCHA_ACTIONS")
+	if actions == "" {
+		actions = strings.Join([]string{captchaActionRegister, captchaActionLogin, captchaActionPasswordReset}, ",")
+	}
+	h.captcha.actions = make(map[string]bool)
+	for _, action := range strings.Split(actions, ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			h.captcha.actions[action] = true
+		}
+	}
+
+	h.captcha.loginAfterFailures = 3
+	if v, err := strconv.Atoi(os.Getenv("CAPTCHA_LOGIN_AFTER_FAILURES")); err == nil && v >= 0 {
+		h.captcha.loginAfterFailures = v
+	}
+}
+
+// captchaEnabled reports whether a CAPTCHA must be checked for the action.
+func (h *Handler) captchaEnabled(action string) bool {
+	return h.captcha.verifier != nil && h.captcha.actions[action]
+}
+
+// verifyCaptcha checks the CAPTCHA token sent with the request, either in the
+// X-Captcha-Token header or the captcha_token field of a body already decoded
+// by parseJSONBody. It is a no-op when CAPTCHA is not enabled for the action.
+func (h *Handler) verifyCaptcha(c *fiber.Ctx, action string) error {
+	if !h.captchaEnabled(action) {
+		return nil
+	}
+	return h.requireCaptcha(c, action)
+}
+
+// requireCaptcha checks the CAPTCHA token like verifyCaptcha, even when
+// CAPTCHA_ACTIONS does not include the action. It is a no-op only when no
+// CAPTCHA provider is configured.
+func (h *Handler) requireCaptcha(c *fiber.Ctx, action string) error {
+	if h.captcha.verifier == nil || c.Locals(captchaVerifiedLocal) == true {
+		return nil
+	}
+
+	token := c.Get("X-Captcha-Token")
+	if token == "" {
+		token, _ = c.Locals(captchaTokenLocal).(string)
+	}
+
+	if token == "" {
+		return fiber.NewError(400, "CAPTCHA verification required")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	verifier := h.captcha.verifier
+	if action == captchaActionRegister && h.captcha.registerVerifier != nil {
+		verifier = h.captcha.registerVerifier
+	}
+
+	if err := verifier.Verify(ctx, token, c.IP()); err != nil {
+		if errors.Is(err, utils.ErrCaptchaRejected) {
+			return fiber.NewError(400, "CAPTCHA verification failed")
+		}
+		log.Printf("captcha verification error: %v", err)
+		return fiber.NewError(503, "CAPTCHA verification unavailable")
+	}
+
+	c.Locals(captchaVerifiedLocal, true)
+	return nil
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/utils"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Login failure reasons recorded on LoginAttempt.
+const (
+	loginFailureUnknownUser     = "unknown_user"
+	loginFailureInvalidPassword = "invalid_password"
+	loginFailureLocked          = "locked"
+	loginFailureRestricted      = "restricted"
+	loginFailureResetRequired   = "password_reset_required"
+	loginFailureRiskDenied      = "risk_denied"
+	loginFailureInvalidCode     = "invalid_verification_code"
+)
+
+// stuffingConfig holds the deployment settings of credential-stuffing
+// detection. Its thresholds are part of the security policy (see
+// utils.StuffingPolicy).
+type stuffingConfig struct {
+	asnHeader string // header carrying the client ASN, set by an edge proxy
+}
+
+// setupCredentialStuffing reads STUFFING_ASN_HEADER (optional).
+func (h *Handler) setupCredentialStuffing() {
+	h.stuffing.asnHeader = os.Getenv("STUFFING_ASN_HEADER")
+}
+
+// clientASN returns the client's ASN as reported by the edge proxy, if any.
+func (h *Handler) clientASN(c *fiber.Ctx) string {
+	if h.stuffing.asnHeader == "" {
+		return ""
+	}
+	return c.Get(h.stuffing.asnHeader)
+}
+
+// recordLoginAttempt stores a login attempt for history and burst detection.
+func (h *Handler) recordLoginAttempt(c *fiber.Ctx, email string, userID *uint, success bool, reason string) {
+	attempt := models.LoginAttempt{
+		TenantID:      middleware.TenantID(c),
+		UserID:        userID,
+		Email:         email,
+		EmailHash:     utils.EmailBlindIndex(email),
+		IPAddress:     c.IP(),
+		ASN:           h.clientASN(c),
+		UserAgent:     c.Get("User-Agent"),
+		Success:       success,
+		FailureReason: reason,
+	}
+
+	// Successful logins are the baseline for impossible-travel detection
+	if success {
+		if loc := h.clientLocation(c); loc != nil {
+			attempt.Latitude = &loc.Latitude
+			attempt.Longitude = &loc.Longitude
+			attempt.Country = loc.Country
+			attempt.City = loc.City
+		}
+	}
+
+	if err := h.db.Create(&attempt).Error; err != nil {
+		log.Printf("failed to record login attempt: %v", err)
+	}
+
+	if !success {
+		data := fiber.Map{
+			"user_id":    nil,
+			"reason":     reason,
+			"ip_address": attempt.IPAddress,
+			"user_agent": attempt.UserAgent,
+		}
+		if userID != nil {
+			data["user_id"] = *userID
+		} else {
+			// Lets receivers correlate attempts on unknown accounts without
+			// the address itself
+			data["email_hash"] = attempt.EmailHash
+		}
+		h.publishEvent(models.WebhookEventLoginFailed, data)
+	}
+}
+
+// failedAccountsSince counts distinct emails with failed logins matching the
+// given column/value within window.
+func (h *Handler) failedAccountsSince(column, value string, window time.Duration) int64 {
+	var count int64
+	// Encrypted emails differ per row, so count accounts by blind index
+	account := "email"
+	if utils.EmailBlindIndexEnabled() {
+		account = "email_hash"
+	}
+
+	err := h.db.Model(&models.LoginAttempt{}).
+		Where(column+" = ? AND success = ? AND created_at > ?", value, false, time.Now().Add(-window)).
+		Distinct(account).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("credential stuffing: failed to count attempts: %v", err)
+		return 0
+	}
+	return count
+}
+
+// checkCredentialStuffing escalates when the client's IP or ASN shows a burst
+// of failed logins across many accounts: first by requiring a CAPTCHA, then
+// by temporarily blocking the IP. It is called before credentials are checked.
+func (h *Handler) checkCredentialStuffing(c *fiber.Ctx) error {
+	policy := h.policy().CredentialStuffing
+	window := time.Duration(policy.Window)
+
+	ip := c.IP()
+	ipAccounts := h.failedAccountsSince("ip_address", ip, window)
+
+	if policy.BlockAccounts > 0 && ipAccounts >= int64(policy.BlockAccounts) {
+		h.blockStuffingIP(c, ipAccounts, policy)
+		return fiber.NewError(403, "Access denied")
+	}
+
+	suspected := policy.CaptchaAccounts > 0 && ipAccounts >= int64(policy.CaptchaAccounts)
+
+	var asnAccounts int64
+	if asn := h.clientASN(c); asn != "" && policy.ASNAccounts > 0 {
+		asnAccounts = h.failedAccountsSince("asn", asn, window)
+		suspected = suspected || asnAccounts >= int64(policy.ASNAccounts)
+	}
+
+	if !suspected {
+		return nil
+	}
+
+	if err := h.verifyCaptcha(c, captchaActionLogin); err != nil {
+		h.recordAuditEvent(models.AuditLog{
+			Event:     models.AuditEventCredentialStuffingSuspected,
+			IPAddress: ip,
+			UserAgent: c.Get("User-Agent"),
+		}, fiber.Map{
+			"asn":             h.clientASN(c),
+			"ip_accounts":     ipAccounts,
+			"asn_accounts":    asnAccounts,
+			"window_seconds":  int(window.Seconds()),
+			"captcha_enabled": h.captchaEnabled(captchaActionLogin),
+		})
+		return err
+	}
+
+	return nil
+}
+
+// blockStuffingIP adds a temporary deny rule for the client IP.
+func (h *Handler) blockStuffingIP(c *fiber.Ctx, accounts int64, policy utils.StuffingPolicy) {
+	ip := c.IP()
+	cidr, err := parseIPRange(ip)
+	if err != nil {
+		return
+	}
+
+	window := time.Duration(policy.Window)
+	expiresAt := time.Now().Add(time.Duration(policy.BlockDuration))
+	rule := models.IPRule{
+		CIDR:      cidr,
+		Action:    models.IPRuleDeny,
+		Reason:    fmt.Sprintf("credential stuffing: %d accounts failed within %s", accounts, window),
+		ExpiresAt: &expiresAt,
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		log.Printf("credential stuffing: failed to block %s: %v", ip, err)
+		return
+	}
+	h.caches.IPRules.Invalidate()
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventCredentialStuffingBlocked,
+		IPAddress: ip,
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"asn":            h.clientASN(c),
+		"ip_accounts":    accounts,
+		"window_seconds": int(window.Seconds()),
+		"ip_rule_id":     rule.ID,
+		"expires_at":     expiresAt,
+	})
+}
+
+func envInt(key string, fallback int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v >= 0 {
+		return v
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
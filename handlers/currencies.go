@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm/clause"
+)
+
+// currencySettingsRefreshInterval bounds how stale the cached settings can
+// get when they are changed by another instance.
+const currencySettingsRefreshInterval = 30 * time.Second
+
+// currencyCache caches which catalog currencies users may pick: the
+// configured defaults with the admin settings applied.
+type currencyCache struct {
+	sync.RWMutex
+	defaults map[string]bool
+	enabled  map[string]bool
+	loadedAt time.Time
+}
+
+type UpdateCurrencyRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// setupCurrencies reads CURRENCIES_ENABLED, a comma-separated list of ISO
+// 4217 codes or "all" (default "ron,eur,gbp,usd"). Admin settings are read
+// from the database on first use.
+func (h *Handler) setupCurrencies() {
+	value := os.Getenv("CURRENCIES_ENABLED")
+	if value == "" {
+		value = "ron,eur,gbp,usd"
+	}
+
+	defaults := map[string]bool{}
+	if strings.EqualFold(strings.TrimSpace(value), "all") {
+		for _, c := range utils.CurrencyCatalog() {
+			defaults[c.Code] = true
+		}
+	} else {
+		for _, code := range strings.Split(value, ",") {
+			if strings.TrimSpace(code) == "" {
+				continue
+			}
+			c, ok := utils.LookupCurrency(code)
+			if !ok {
+				log.Fatalf("CURRENCIES_ENABLED: unknown currency %q", strings.TrimSpace(code))
+			}
+			defaults[c.Code] = true
+		}
+	}
+
+	h.currencies.Lock()
+	h.currencies.defaults = defaults
+	h.currencies.enabled = defaults
+	h.currencies.loadedAt = time.Time{}
+	h.currencies.Unlock()
+}
+
+// enabledCurrencies returns the set of enabled currency codes, reloading the
+// admin settings when the cached copy is stale.
+func (h *Handler) enabledCurrencies() map[string]bool {
+	h.currencies.RLock()
+	if time.Since(h.currencies.loadedAt) < currencySettingsRefreshInterval {
+		enabled := h.currencies.enabled
+		h.currencies.RUnlock()
+		return enabled
+	}
+	h.currencies.RUnlock()
+
+	h.currencies.Lock()
+	defer h.currencies.Unlock()
+
+	if time.Since(h.currencies.loadedAt) < currencySettingsRefreshInterval {
+		return h.currencies.enabled
+	}
+
+	var settings []models.CurrencySetting
+	if err := h.db.Find(&settings).Error; err != nil {
+		// Keep the previous set rather than failing open or closed
+		log.Printf("currencies: failed to load settings: %v", err)
+		return h.currencies.enabled
+	}
+
+	enabled := make(map[string]bool, len(h.currencies.defaults))
+	for code := range h.currencies.defaults {
+		enabled[code] = true
+	}
+	for _, s := range settings {
+		if s.Enabled {
+			enabled[s.Code] = true
+		} else {
+			delete(enabled, s.Code)
+		}
+	}
+
+	h.currencies.enabled = enabled
+	h.currencies.loadedAt = time.Now()
+	return enabled
+}
+
+// currencyEnabled reports whether users may pick the currency.
+func (h *Handler) currencyEnabled(code string) bool {
+	c, ok := utils.LookupCurrency(code)
+	return ok && h.enabledCurrencies()[c.Code]
+}
+
+// enabledCurrencyList returns the enabled currencies, sorted by code.
+func (h *Handler) enabledCurrencyList() []utils.Currency {
+	enabled := h.enabledCurrencies()
+	list := []utils.Currency{}
+	for _, c := range utils.CurrencyCatalog() {
+		if enabled[c.Code] {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// AdminListCurrencies returns the whole currency catalog with whether each
+// currency is enabled.
+func (h *Handler) AdminListCurrencies(c *fiber.Ctx) error {
+	enabled := h.enabledCurrencies()
+
+	type currencyStatus struct {
+		utils.Currency
+		Enabled bool `json:"enabled"`
+	}
+	list := []currencyStatus{}
+	for _, currency := range utils.CurrencyCatalog() {
+		list = append(list, currencyStatus{Currency: currency, Enabled: enabled[currency.Code]})
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    list,
+	})
+}
+
+// AdminUpdateCurrency enables or disables the currency in :code. Users who
+// already picked a disabled currency keep it until they change it.
+func (h *Handler) AdminUpdateCurrency(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	currency, ok := utils.LookupCurrency(c.Params("code"))
+	if !ok {
+		return fiber.NewError(404, "Unknown currency")
+	}
+
+	var req UpdateCurrencyRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+	setting := models.CurrencySetting{
+		Code:      currency.Code,
+		Enabled:   *req.Enabled,
+		UpdatedBy: claims.Subject,
+	}
+	if err := h.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&setting).Error; err != nil {
+		return fmt.Errorf("failed to save currency setting: %w", err)
+	}
+
+	// Reload on next use so this instance sees the change right away
+	h.currencies.Lock()
+	h.currencies.loadedAt = time.Time{}
+	h.currencies.Unlock()
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventCurrencyUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"currency": currency.Code,
+		"enabled":  setting.Enabled,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Currency updated",
+		Data:    setting,
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of rows loaded at a time. Batches are read in
+// primary key order, so exports list the oldest rows first.
+const exportBatchSize = 500
+
+// ExportMySessionsCSV sends all of the authenticated user's sessions,
+// including revoked and expired ones, as a CSV download.
+func (h *Handler) ExportMySessionsCSV(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	header := []string{"issued_at", "expires_at", "revoked", "ip_address", "user_agent", "kind"}
+	return sendCSV(c, "sessions.csv", header, func(w *csv.Writer) error {
+		return h.stores.Sessions().EachBatch(claims.Subject, exportBatchSize, func(sessions []models.Session) error {
+			for _, session := range sessions {
+				w.Write([]string{
+					session.IssuedAt.UTC().Format(time.RFC3339),
+					session.ExpiresAt.UTC().Format(time.RFC3339),
+					strconv.FormatBool(session.Revoked),
+					session.IPAddress,
+					csvSafe(session.UserAgent),
+					string(session.Kind),
+				})
+			}
+			return nil
+		})
+	})
+}
+
+// ExportMyLoginHistoryCSV sends all password login attempts against the
+// authenticated user's account as a CSV download.
+func (h *Handler) ExportMyLoginHistoryCSV(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	header := []string{"created_at", "success", "failure_reason", "ip_address", "country", "city", "user_agent"}
+	var attempts []models.LoginAttempt
+	query := h.db.Where("user_id = ?", claims.Subject)
+
+	return sendCSV(c, "login-history.csv", header, func(w *csv.Writer) error {
+		return query.FindInBatches(&attempts, exportBatchSize, func(_ *gorm.DB, _ int) error {
+			for _, attempt := range attempts {
+				w.Write([]string{
+					attempt.CreatedAt.UTC().Format(time.RFC3339),
+					strconv.FormatBool(attempt.Success),
+					attempt.FailureReason,
+					attempt.IPAddress,
+					csvSafe(attempt.Country),
+					csvSafe(attempt.City),
+					csvSafe(attempt.UserAgent),
+				})
+			}
+			return nil
+		}).Error
+	})
+}
+
+// sendCSV writes the header and the rows produced by fill, then sends the
+// result as an attachment named filename.
+func sendCSV(c *fiber.Ctx, filename string, header []string, fill func(w *csv.Writer) error) error {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(header)
+	if err := fill(w); err != nil {
+		return fiber.NewError(500, "Failed to export data")
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fiber.NewError(500, "Failed to export data")
+	}
+
+	c.Attachment(filename)
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
+
+// csvSafe keeps client-supplied text such as user agents from being
+// evaluated as a formula when the file is opened in a spreadsheet.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
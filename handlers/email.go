@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"api/utils"
+)
+
+// setMailer installs the email sender, falling back to SMTP when nil, and
+// starts the outbox worker that uses it.
+func (h *Handler) setMailer(sender utils.EmailSender) {
+	if sender == nil {
+		sender = utils.NewSMTPClient()
+	}
+	h.mailer = sender
+	h.mailerProvider = emailProviderName(sender)
+	h.setupEmailOutbox()
+}
+
+func emailProviderName(sender utils.EmailSender) string {
+	switch sender.(type) {
+	case *utils.SMTPClient:
+		return "smtp"
+	case *utils.SendGridSender:
+		return "sendgrid"
+	case *utils.SESSender:
+		return "ses"
+	case *utils.MailgunSender:
+		return "mailgun"
+	case *utils.LogSender:
+		return "log"
+	case *utils.MemorySender:
+		return "memory"
+	default:
+		return "custom"
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailSendLimit caps how many emails of one type an address receives.
+type emailSendLimit struct {
+	max    int
+	window time.Duration // Zero counts every email ever sent to the address
+}
+
+// errEmailSendLimited is returned by queueEmail when an address has already
+// received its allowance of an email type.
+var errEmailSendLimited = errors.New("email send limit reached")
+
+// setupEmailSendLimits reads EMAIL_SEND_LIMIT_<TYPE> settings, e.g.
+// EMAIL_SEND_LIMIT_PASSWORD_RESET=3/1h or EMAIL_SEND_LIMIT_WELCOME=1 for a
+// lifetime cap. A max of 0 removes the limit. verify_email defaults to
+// EMAIL_VERIFICATION_DAILY_LIMIT per 24 hours.
+func (h *Handler) setupEmailSendLimits() {
+	h.emailSendLimits = map[string]emailSendLimit{
+		utils.EmailTemplateWelcome:       {max: 1},
+		utils.EmailTemplatePasswordReset: {max: 3, window: time.Hour},
+		utils.EmailTemplateVerifyEmail:   {max: envInt("EMAIL_VERIFICATION_DAILY_LIMIT", 5), window: 24 * time.Hour},
+	}
+
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		kind, ok := strings.CutPrefix(key, "EMAIL_SEND_LIMIT_")
+		if !ok || kind == "" {
+			continue
+		}
+		limit, err := parseEmailSendLimit(value)
+		if err != nil {
+			log.Fatalf("email send limits: %s: %v", key, err)
+		}
+		h.emailSendLimits[strings.ToLower(kind)] = limit
+	}
+}
+
+// parseEmailSendLimit parses "<max>" or "<max>/<window>".
+func parseEmailSendLimit(value string) (emailSendLimit, error) {
+	maxPart, windowPart, hasWindow := strings.Cut(strings.TrimSpace(value), "/")
+	max, err := strconv.Atoi(maxPart)
+	if err != nil || max < 0 {
+		return emailSendLimit{}, fmt.Errorf("invalid limit %q, expected <max> or <max>/<window>", value)
+	}
+
+	limit := emailSendLimit{max: max}
+	if hasWindow {
+		window, err := time.ParseDuration(windowPart)
+		if err != nil || window <= 0 {
+			return emailSendLimit{}, fmt.Errorf("invalid window in %q", value)
+		}
+		limit.window = window
+	}
+	return limit, nil
+}
+
+// checkEmailSendLimit returns errEmailSendLimited if any recipient has
+// already been sent its allowance of kind. Handlers that issue a token for
+// the email call it first so a limited request leaves earlier tokens valid.
+func (h *Handler) checkEmailSendLimit(kind string, recipients ...string) error {
+	limit, ok := h.emailSendLimits[kind]
+	if !ok || limit.max <= 0 {
+		return nil
+	}
+
+	for _, recipient := range recipients {
+		query := h.db.Model(&models.EmailOutbox{}).Where("type = ?", kind).
+			Scopes(whereEmailColumn("recipients", "recipient_hash", utils.NormalizeEmail(recipient)))
+		if limit.window > 0 {
+			query = query.Where("created_at > ?", time.Now().Add(-limit.window))
+		}
+
+		var sent int64
+		if err := query.Count(&sent).Error; err != nil {
+			return fmt.Errorf("count %s emails: %w", kind, err)
+		}
+		if sent >= int64(limit.max) {
+			return fmt.Errorf("%w: %s", errEmailSendLimited, kind)
+		}
+	}
+	return nil
+}
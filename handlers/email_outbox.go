@@ -0,0 +1,504 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// emailOutboxConfig controls delivery of queued emails.
+type emailOutboxConfig struct {
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	backoff      time.Duration // Delay after the first failure, doubled per attempt
+	maxBackoff   time.Duration
+	lease        time.Duration // How long a claimed row is hidden from other workers
+}
+
+// setupEmailOutbox reads EMAIL_OUTBOX_* settings and starts the delivery
+// worker for the handler's database and mailer.
+func (h *Handler) setupEmailOutbox() {
+	h.emailOutbox = emailOutboxConfig{
+		pollInterval: envDuration("EMAIL_OUTBOX_POLL_INTERVAL", 5*time.Second),
+		batchSize:    envInt("EMAIL_OUTBOX_BATCH_SIZE", 20),
+		maxAttempts:  envInt("EMAIL_OUTBOX_MAX_ATTEMPTS", 8),
+		backoff:      envDuration("EMAIL_OUTBOX_BACKOFF", 30*time.Second),
+		maxBackoff:   envDuration("EMAIL_OUTBOX_MAX_BACKOFF", time.Hour),
+		lease:        2 * time.Minute,
+	}
+	if h.emailOutbox.batchSize == 0 {
+		h.emailOutbox.batchSize = 20
+	}
+	if h.emailOutbox.maxAttempts == 0 {
+		h.emailOutbox.maxAttempts = 1
+	}
+	h.setupEmailSendLimits()
+
+	h.startWorker(h.runEmailOutbox)
+}
+
+// maxEmailAttachmentBytes caps the total attachment size of one email,
+// within every provider's message size limit.
+const maxEmailAttachmentBytes = 10 << 20
+
+// queueEmail stores an email in the outbox for the worker to deliver. kind
+// and userID label the delivery events, and kind selects the send limit. Bodies and attachments are
+// encrypted at rest when TOKEN_ENCRYPTION_KEY is configured, since they
+// usually carry a reset, unlock or verification secret or personal data.
+func (h *Handler) queueEmail(kind string, userID *uint, msg utils.EmailMessage) error {
+	if len(msg.To) == 0 {
+		return fmt.Errorf("no recipients provided")
+	}
+	if err := h.checkEmailSendLimit(kind, msg.To...); err != nil {
+		return err
+	}
+
+	var attachments string
+	if len(msg.Attachments) > 0 {
+		size := 0
+		for _, a := range msg.Attachments {
+			size += len(a.Data)
+		}
+		if size > maxEmailAttachmentBytes {
+			return fmt.Errorf("email attachments exceed %d bytes", maxEmailAttachmentBytes)
+		}
+
+		encoded, err := json.Marshal(msg.Attachments)
+		if err != nil {
+			return fmt.Errorf("encode email attachments: %w", err)
+		}
+		if attachments, err = encryptEmailBody(string(encoded)); err != nil {
+			return err
+		}
+	}
+
+	text, err := encryptEmailBody(msg.Text)
+	if err != nil {
+		return err
+	}
+	html, err := encryptEmailBody(msg.HTML)
+	if err != nil {
+		return err
+	}
+
+	entry := models.EmailOutbox{
+		Type:          kind,
+		UserID:        userID,
+		Recipients:    strings.Join(msg.To, ","),
+		RecipientHash: recipientHash(msg.To),
+		Subject:       msg.Subject,
+		Body:          text,
+		HTMLBody:      html,
+		Attachments:   attachments,
+		Status:        models.EmailStatusPending,
+		NextAttemptAt: time.Now(),
+	}
+	if err := h.db.Create(&entry).Error; err != nil {
+		return fmt.Errorf("queue email: %w", err)
+	}
+	return nil
+}
+
+// recipientHash returns the blind index of the only recipient, or nil for
+// emails with several.
+func recipientHash(recipients []string) *string {
+	if len(recipients) != 1 {
+		return nil
+	}
+	return utils.EmailBlindIndex(recipients[0])
+}
+
+// queueUserEmail renders a template in the user's locale and queues it for
+// delivery to the user's address, with any attachments. Optional emails the
+// user turned off in their notification preferences are skipped.
+func (h *Handler) queueUserEmail(user *models.User, template string, data any, attachments ...utils.EmailAttachment) error {
+	if category, ok := emailNotificationCategories[template]; ok {
+		enabled, err := h.notificationEnabled(user.ID, category)
+		if err != nil {
+			return err
+		}
+		if !enabled {
+			return nil
+		}
+	}
+
+	msg, err := utils.RenderEmail(template, string(user.Locale), data)
+	if err != nil {
+		return err
+	}
+
+	msg.To = []string{user.Email}
+	msg.Attachments = attachments
+	return h.queueEmail(template, &user.ID, msg)
+}
+
+// encryptEmailBody encrypts a body for storage, keeping it as plaintext when
+// no encryption key is configured.
+func encryptEmailBody(body string) (string, error) {
+	if body == "" {
+		return "", nil
+	}
+
+	stored, err := utils.EncryptValue(body)
+	if errors.Is(err, utils.ErrEncryptionKeyMissing) {
+		return body, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("encrypt email body: %w", err)
+	}
+	return stored, nil
+}
+
+// runEmailOutbox delivers due emails every poll interval.
+func (h *Handler) runEmailOutbox(ctx context.Context) {
+	ticker := time.NewTicker(h.emailOutbox.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Finish the batch in progress, but start no new one once stopped
+		for ctx.Err() == nil {
+			delivered, err := h.deliverEmailBatch()
+			if err != nil {
+				log.Printf("email outbox: %v", err)
+				break
+			}
+			if delivered < h.emailOutbox.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliverEmailBatch claims up to batchSize due emails and sends them. Rows
+// are claimed with SKIP LOCKED and leased by pushing next_attempt_at forward,
+// so several instances can run the worker without sending twice.
+func (h *Handler) deliverEmailBatch() (int, error) {
+	var batch []models.EmailOutbox
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.EmailStatusPending, now).
+			Order("next_attempt_at").
+			Limit(h.emailOutbox.batchSize).
+			Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(batch))
+		for i, entry := range batch {
+			ids[i] = entry.ID
+		}
+		return tx.Model(&models.EmailOutbox{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(h.emailOutbox.lease)).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("claim emails: %w", err)
+	}
+
+	for i := range batch {
+		h.deliverEmail(&batch[i])
+	}
+	return len(batch), nil
+}
+
+// deliverEmail sends one outbox entry and records the outcome, both on the
+// entry and in the delivery event log. Failures are retried with exponential
+// backoff until maxAttempts, then dead-lettered. Suppressed recipients are
+// skipped.
+func (h *Handler) deliverEmail(entry *models.EmailOutbox) {
+	attempts := entry.Attempts + 1
+	all := strings.Split(entry.Recipients, ",")
+
+	recipients, err := h.deliverableRecipients(all)
+	if err == nil {
+		for _, recipient := range all {
+			if !slices.Contains(recipients, recipient) {
+				h.recordDeliveryEvent(entry, recipient, models.EmailStatusSuppressed, attempts, "", nil)
+			}
+		}
+	}
+	if err == nil && len(recipients) == 0 {
+		h.db.Model(entry).Updates(map[string]interface{}{
+			"status":      models.EmailStatusSuppressed,
+			"body":        "",
+			"html_body":   "",
+			"attachments": "",
+		})
+		return
+	}
+
+	var messageID string
+	if err == nil {
+		messageID, err = h.sendOutboxEntry(entry, recipients)
+	}
+
+	if err == nil {
+		for _, recipient := range recipients {
+			h.recordDeliveryEvent(entry, recipient, models.EmailStatusSent, attempts, messageID, nil)
+		}
+
+		now := time.Now()
+		h.db.Model(entry).Updates(map[string]interface{}{
+			"status":      models.EmailStatusSent,
+			"attempts":    attempts,
+			"sent_at":     now,
+			"body":        "",
+			"html_body":   "",
+			"attachments": "",
+			"last_error":  "",
+		})
+		return
+	}
+
+	status := models.EmailStatusFailed
+	updates := map[string]interface{}{
+		"attempts":   attempts,
+		"last_error": err.Error(),
+	}
+	if attempts >= h.emailOutbox.maxAttempts {
+		status = models.EmailStatusDead
+		updates["status"] = models.EmailStatusDead
+		log.Printf("email outbox: giving up on email %d after %d attempts: %v", entry.ID, attempts, err)
+	} else {
+		updates["next_attempt_at"] = time.Now().Add(h.emailBackoff(attempts))
+	}
+
+	if recipients == nil {
+		recipients = all
+	}
+	for _, recipient := range recipients {
+		h.recordDeliveryEvent(entry, recipient, status, attempts, "", err)
+	}
+
+	if err := h.db.Model(entry).Updates(updates).Error; err != nil {
+		log.Printf("email outbox: failed to record attempt for email %d: %v", entry.ID, err)
+	}
+}
+
+// recordDeliveryEvent logs one send attempt for one recipient. Failures are
+// logged; they never affect delivery.
+func (h *Handler) recordDeliveryEvent(entry *models.EmailOutbox, recipient, status string, attempt int, messageID string, sendErr error) {
+	email := utils.NormalizeEmail(recipient)
+	event := models.EmailDeliveryEvent{
+		OutboxID:          entry.ID,
+		Type:              entry.Type,
+		UserID:            entry.UserID,
+		Email:             email,
+		EmailHash:         utils.EmailBlindIndex(email),
+		Provider:          h.mailerProvider,
+		ProviderMessageID: messageID,
+		Status:            status,
+		Attempt:           attempt,
+	}
+	if sendErr != nil {
+		event.Error = sendErr.Error()
+	}
+
+	if err := h.db.Create(&event).Error; err != nil {
+		log.Printf("email outbox: failed to record delivery event for email %d: %v", entry.ID, err)
+	}
+}
+
+func (h *Handler) sendOutboxEntry(entry *models.EmailOutbox, recipients []string) (string, error) {
+	text, err := utils.DecryptValue(entry.Body)
+	if err != nil {
+		return "", fmt.Errorf("decrypt email body: %w", err)
+	}
+	html, err := utils.DecryptValue(entry.HTMLBody)
+	if err != nil {
+		return "", fmt.Errorf("decrypt email body: %w", err)
+	}
+
+	var attachments []utils.EmailAttachment
+	if entry.Attachments != "" {
+		encoded, err := utils.DecryptValue(entry.Attachments)
+		if err != nil {
+			return "", fmt.Errorf("decrypt email attachments: %w", err)
+		}
+		if err := json.Unmarshal([]byte(encoded), &attachments); err != nil {
+			return "", fmt.Errorf("decode email attachments: %w", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return h.mailer.Send(ctx, utils.EmailMessage{
+		To:          recipients,
+		Subject:     entry.Subject,
+		Text:        text,
+		HTML:        html,
+		Attachments: attachments,
+	})
+}
+
+// emailBackoff returns the delay before the next attempt after the given
+// number of failed attempts.
+func (h *Handler) emailBackoff(attempts int) time.Duration {
+	delay := h.emailOutbox.backoff
+	for i := 1; i < attempts && delay < h.emailOutbox.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, h.emailOutbox.maxBackoff)
+}
+
+// AdminListEmailOutbox returns queued emails, newest first, optionally
+// filtered by the status (pending, sent, dead or suppressed), type and
+// user_id query parameters.
+func (h *Handler) AdminListEmailOutbox(c *fiber.Ctx) error {
+	params, err := parsePage(c, 100, 1000)
+	if err != nil {
+		return err
+	}
+
+	query := h.db.Model(&models.EmailOutbox{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if kind := c.Query("type"); kind != "" {
+		query = query.Where("type = ?", kind)
+	}
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var emails []models.EmailOutbox
+	if err := params.apply(query, "created_at", "id").Find(&emails).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch email outbox")
+	}
+	emails, pageInfo := pageOf(params, emails, func(e models.EmailOutbox) utils.Cursor {
+		return utils.Cursor{Time: e.CreatedAt, ID: e.ID}
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    emails,
+		Meta:    pageInfo,
+	})
+}
+
+// AdminListEmailDeliveryEvents returns send attempts, newest first. Filters:
+// email, user_id, type, status, outbox_id and provider_message_id.
+func (h *Handler) AdminListEmailDeliveryEvents(c *fiber.Ctx) error {
+	params, err := parsePage(c, 100, 1000)
+	if err != nil {
+		return err
+	}
+
+	query := h.db.Model(&models.EmailDeliveryEvent{})
+	if email := utils.NormalizeEmail(c.Query("email")); email != "" {
+		query = query.Scopes(whereEmail(email))
+	}
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if kind := c.Query("type"); kind != "" {
+		query = query.Where("type = ?", kind)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if outboxID := c.QueryInt("outbox_id"); outboxID > 0 {
+		query = query.Where("outbox_id = ?", outboxID)
+	}
+	if messageID := c.Query("provider_message_id"); messageID != "" {
+		query = query.Where("provider_message_id = ?", messageID)
+	}
+
+	var events []models.EmailDeliveryEvent
+	if err := params.apply(query, "created_at", "id").Find(&events).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch email delivery events")
+	}
+	events, pageInfo := pageOf(params, events, func(e models.EmailDeliveryEvent) utils.Cursor {
+		return utils.Cursor{Time: e.CreatedAt, ID: e.ID}
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    events,
+		Meta:    pageInfo,
+	})
+}
+
+// AdminGetEmailDeliveryEvents returns every send attempt for one outbox
+// entry, oldest first.
+func (h *Handler) AdminGetEmailDeliveryEvents(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid email id")
+	}
+
+	var entry models.EmailOutbox
+	if err := h.db.First(&entry, id).Error; err != nil {
+		return fiber.NewError(404, "Email not found")
+	}
+
+	var events []models.EmailDeliveryEvent
+	if err := h.db.Where("outbox_id = ?", id).Order("created_at, id").Find(&events).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch email delivery events")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"email":  entry,
+			"events": events,
+		},
+	})
+}
+
+// AdminRetryEmail moves a dead-lettered email back into the queue with a
+// fresh attempt budget.
+func (h *Handler) AdminRetryEmail(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid email id")
+	}
+
+	result := h.db.Model(&models.EmailOutbox{}).
+		Where("id = ? AND status = ?", id, models.EmailStatusDead).
+		Updates(map[string]interface{}{
+			"status":          models.EmailStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to requeue email: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fiber.NewError(404, "Dead-lettered email not found")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Email requeued",
+		Data:    nil,
+	})
+}
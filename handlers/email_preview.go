@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"api/utils"
+	"errors"
+	"mime"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ListEmailPreviews returns the templates and locales that can be previewed.
+func (h *Handler) ListEmailPreviews(c *fiber.Ctx) error {
+	templates, locales := utils.EmailTemplatePreviews()
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"templates": templates,
+			"locales":   locales,
+		},
+	})
+}
+
+// PreviewEmailTemplate renders a template with sample data for viewing in a
+// browser. The locale query parameter picks the language and format=text
+// returns the plain-text variant instead of HTML. The subject is sent in
+// the X-Email-Subject header, RFC 2047 encoded.
+func (h *Handler) PreviewEmailTemplate(c *fiber.Ctx) error {
+	locale := c.Query("locale", utils.DefaultEmailLocale)
+
+	msg, err := utils.PreviewEmail(c.Params("name"), locale)
+	if err != nil {
+		if errors.Is(err, utils.ErrUnknownEmailTemplate) {
+			return fiber.NewError(404, err.Error())
+		}
+		return fiber.NewError(500, err.Error())
+	}
+
+	c.Set("X-Email-Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	if c.Query("format") == "text" {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString("Subject: " + msg.Subject + "\n\n" + msg.Text)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.SendString(msg.HTML)
+}
+
+// ListCapturedEmails returns the emails kept by the in-memory sender used in
+// demo mode, newest first, so links in them can be followed without a mail
+// server. It answers 404 with any other sender.
+func (h *Handler) ListCapturedEmails(c *fiber.Ctx) error {
+	sender, ok := h.mailer.(*utils.MemorySender)
+	if !ok {
+		return fiber.NewError(404, "Emails are only captured in demo mode")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    sender.Messages(),
+	})
+}
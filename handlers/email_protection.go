@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"api/utils"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// whereEmail scopes a users query to a normalized email. With a blind index
+// key configured the lookup goes through email_hash, which also works when
+// emails are encrypted; otherwise it compares lower(email).
+func whereEmail(email string) func(*gorm.DB) *gorm.DB {
+	return whereEmailColumn("email", "email_hash", email)
+}
+
+// whereEmailColumn is whereEmail for tables whose email and blind index
+// columns are named differently.
+func whereEmailColumn(column, hashColumn, email string) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		condition, arg := emailCondition(column, hashColumn, email)
+		return tx.Where(condition, arg)
+	}
+}
+
+// emailCondition returns the condition whereEmailColumn applies, for
+// combining with other conditions.
+func emailCondition(column, hashColumn, email string) (string, any) {
+	if index := utils.EmailBlindIndex(email); index != nil {
+		return hashColumn + " = ?", *index
+	}
+	return "lower(" + column + ") = ?", email
+}
+
+// protectedEmailColumns are the columns holding email addresses, with the
+// blind index column used to look them up, if any. Recipients hold
+// comma-separated addresses, of which only single ones are indexed.
+var protectedEmailColumns = []struct {
+	table, column, hashColumn string
+	multiple                  bool
+}{
+	{table: "users", column: "email", hashColumn: "email_hash"},
+	{table: "login_attempts", column: "email", hashColumn: "email_hash"},
+	{table: "password_resets", column: "email", hashColumn: "email_hash"},
+	{table: "email_outbox", column: "recipients", hashColumn: "recipient_hash", multiple: true},
+	{table: "invitations", column: "email"},
+	{table: "oauth_accounts", column: "email"},
+}
+
+// setupEmailProtection backfills blind indexes, and encrypts plaintext
+// emails when encryption is enabled, for rows written before either was
+// configured.
+func (h *Handler) setupEmailProtection() {
+	if !utils.EmailBlindIndexEnabled() {
+		return
+	}
+
+	for _, t := range protectedEmailColumns {
+		updated, err := h.protectEmailColumn(t.table, t.column, t.hashColumn, t.multiple)
+		if updated > 0 {
+			log.Printf("email protection: updated %d %s", updated, t.table)
+		}
+		if err != nil {
+			log.Printf("email protection: %s: %v", t.table, err)
+		}
+	}
+}
+
+// protectEmailColumn rewrites the rows of table whose email is missing its
+// blind index or, with encryption enabled, is still plaintext. Rows are read
+// and written raw, in batches, since map updates bypass the pii serializer.
+func (h *Handler) protectEmailColumn(table, column, hashColumn string, multiple bool) (int, error) {
+	var pending []string
+	if hashColumn != "" {
+		unindexed := fmt.Sprintf("%s IS NULL AND %s <> ''", hashColumn, column)
+		if multiple {
+			unindexed += fmt.Sprintf(" AND %s NOT LIKE '%%,%%'", column)
+		}
+		pending = append(pending, unindexed)
+	}
+	if utils.EmailEncryptionEnabled() {
+		pending = append(pending, fmt.Sprintf("%[1]s <> '' AND %[1]s NOT LIKE 'enc:%%' AND %[1]s NOT LIKE 'env:%%'", column))
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	const batchSize = 500
+
+	var updated int
+	for {
+		// Read raw column values so encrypted and plaintext rows can be told apart
+		var rows []struct {
+			ID    uint
+			Email string
+		}
+
+		err := h.db.Table(table).Select("id", column+" AS email").
+			Where("(" + strings.Join(pending, ") OR (") + ")").
+			Order("id").Limit(batchSize).Find(&rows).Error
+		if err != nil {
+			return updated, fmt.Errorf("load rows: %w", err)
+		}
+		if len(rows) == 0 {
+			return updated, nil
+		}
+
+		for _, row := range rows {
+			email, err := utils.DecryptValue(row.Email)
+			if err != nil {
+				return updated, fmt.Errorf("decrypt row %d: %w", row.ID, err)
+			}
+			if !multiple {
+				email = utils.NormalizeEmail(email)
+			}
+
+			stored, err := utils.EncryptPII(email)
+			if err != nil {
+				return updated, fmt.Errorf("encrypt row %d: %w", row.ID, err)
+			}
+			updates := map[string]any{column: stored}
+			if hashColumn != "" && !strings.Contains(email, ",") {
+				updates[hashColumn] = utils.EmailBlindIndex(email)
+			}
+
+			if err := h.db.Table(table).Where("id = ?", row.ID).Updates(updates).Error; err != nil {
+				return updated, fmt.Errorf("update row %d: %w", row.ID, err)
+			}
+			updated++
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// emailWebhookConfig holds the credentials for provider feedback webhooks.
+type emailWebhookConfig struct {
+	sesTopicARNs      []string         // Accepted SNS topics; empty accepts any correctly signed message
+	sendgridPublicKey *ecdsa.PublicKey // Nil disables the SendGrid webhook
+}
+
+// setupEmailWebhooks reads SES_SNS_TOPIC_ARNS (comma-separated) and
+// SENDGRID_WEBHOOK_PUBLIC_KEY.
+func (h *Handler) setupEmailWebhooks() {
+	h.emailWebhooks = emailWebhookConfig{}
+
+	for _, arn := range strings.Split(os.Getenv("SES_SNS_TOPIC_ARNS"), ",") {
+		if arn = strings.TrimSpace(arn); arn != "" {
+			h.emailWebhooks.sesTopicARNs = append(h.emailWebhooks.sesTopicARNs, arn)
+		}
+	}
+
+	if encoded := os.Getenv("SENDGRID_WEBHOOK_PUBLIC_KEY"); encoded != "" {
+		key, err := utils.ParseSendGridPublicKey(encoded)
+		if err != nil {
+			log.Fatalf("email webhooks: %v", err)
+		}
+		h.emailWebhooks.sendgridPublicKey = key
+	}
+}
+
+// SESWebhook receives SES bounce and complaint notifications delivered by
+// Amazon SNS. Subscription confirmations are answered automatically.
+func (h *Handler) SESWebhook(c *fiber.Ctx) error {
+	// SNS posts JSON with a text/plain content type, so decode the raw body
+	var msg utils.SNSMessage
+	if err := json.Unmarshal(c.Body(), &msg); err != nil {
+		return fiber.NewError(400, "Invalid SNS message")
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), 10*time.Second)
+	defer cancel()
+
+	if err := utils.VerifySNSMessage(ctx, &msg); err != nil {
+		log.Printf("email webhooks: rejected SNS message: %v", err)
+		return fiber.NewError(401, "Invalid SNS signature")
+	}
+	if len(h.emailWebhooks.sesTopicARNs) > 0 && !slices.Contains(h.emailWebhooks.sesTopicARNs, msg.TopicArn) {
+		return fiber.NewError(403, "Unknown SNS topic")
+	}
+
+	switch msg.Type {
+	case "SubscriptionConfirmation":
+		if err := utils.ConfirmSNSSubscription(ctx, &msg); err != nil {
+			return fmt.Errorf("failed to confirm SNS subscription: %w", err)
+		}
+	case "Notification":
+		feedback, err := utils.ParseSESNotification(msg.Message)
+		if err != nil {
+			return fiber.NewError(400, err.Error())
+		}
+		h.suppressEmails(feedback, "ses")
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// SendGridWebhook receives a batch of signed SendGrid Event Webhook events.
+func (h *Handler) SendGridWebhook(c *fiber.Ctx) error {
+	if h.emailWebhooks.sendgridPublicKey == nil {
+		return fiber.NewError(404, "SendGrid webhook is not configured")
+	}
+
+	err := utils.VerifySendGridSignature(h.emailWebhooks.sendgridPublicKey,
+		c.Get("X-Twilio-Email-Event-Webhook-Signature"),
+		c.Get("X-Twilio-Email-Event-Webhook-Timestamp"),
+		c.Body())
+	if err != nil {
+		return fiber.NewError(401, "Invalid webhook signature")
+	}
+
+	feedback, err := utils.ParseSendGridEvents(c.Body())
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	h.suppressEmails(feedback, "sendgrid")
+
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// suppressEmails records a suppression for each reported address, linked to
+// the matching user if there is one. Repeat reports refresh the existing row.
+func (h *Handler) suppressEmails(feedback []utils.EmailFeedback, provider string) {
+	for _, f := range feedback {
+		email := utils.NormalizeEmail(f.Email)
+		if email == "" {
+			continue
+		}
+
+		var userID *uint
+		if user, err := h.stores.Users().AnyByEmail(email); err == nil {
+			userID = &user.ID
+		}
+
+		detail := f.Detail
+		if len(detail) > 500 {
+			detail = detail[:500]
+		}
+
+		var suppression models.EmailSuppression
+		err := h.db.Scopes(whereEmail(email)).First(&suppression).Error
+		suppression.Email = email
+		suppression.EmailHash = utils.EmailBlindIndex(email)
+		suppression.UserID = userID
+		suppression.Reason = f.Type
+		suppression.Provider = provider
+		suppression.Detail = detail
+		if err == nil {
+			err = h.db.Save(&suppression).Error
+		} else {
+			err = h.db.Create(&suppression).Error
+		}
+		if err != nil {
+			log.Printf("email webhooks: failed to suppress address: %v", err)
+			continue
+		}
+
+		h.recordAuditEvent(models.AuditLog{
+			Event:  models.AuditEventEmailSuppressed,
+			UserID: userID,
+		}, fiber.Map{
+			"reason":   f.Type,
+			"provider": provider,
+			"detail":   detail,
+		})
+	}
+}
+
+// deliverableRecipients drops suppressed addresses from a recipient list.
+func (h *Handler) deliverableRecipients(recipients []string) ([]string, error) {
+	deliverable := make([]string, 0, len(recipients))
+	for _, recipient := range recipients {
+		var count int64
+		if err := h.db.Model(&models.EmailSuppression{}).Scopes(whereEmail(utils.NormalizeEmail(recipient))).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("check email suppression: %w", err)
+		}
+		if count == 0 {
+			deliverable = append(deliverable, recipient)
+		}
+	}
+	return deliverable, nil
+}
+
+// AdminListEmailSuppressions returns suppressed addresses, newest first,
+// optionally filtered by the reason (bounce or complaint) and user_id query
+// parameters.
+func (h *Handler) AdminListEmailSuppressions(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := h.db.Order("updated_at DESC").Limit(limit)
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if userID := c.QueryInt("user_id"); userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var suppressions []models.EmailSuppression
+	if err := query.Find(&suppressions).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch email suppressions")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    suppressions,
+	})
+}
+
+// AdminDeleteEmailSuppression lifts a suppression, e.g. after the user fixed
+// their mailbox, so emails to the address are sent again.
+func (h *Handler) AdminDeleteEmailSuppression(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid suppression id")
+	}
+
+	var suppression models.EmailSuppression
+	if err := h.db.First(&suppression, id).Error; err != nil {
+		return fiber.NewError(404, "Email suppression not found")
+	}
+	if err := h.db.Delete(&suppression).Error; err != nil {
+		return fmt.Errorf("failed to delete email suppression: %w", err)
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventEmailUnsuppressed,
+		UserID:    suppression.UserID,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"reason": suppression.Reason})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Email suppression removed",
+		Data:    nil,
+	})
+}
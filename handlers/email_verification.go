@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/middleware"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type VerifyEmailProps struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationProps struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// emailVerificationConfig limits how verification emails are issued.
+type emailVerificationConfig struct {
+	ttl      time.Duration
+	cooldown time.Duration // Minimum time between resends for one account
+}
+
+// setupEmailVerification reads EMAIL_VERIFICATION_TTL (default 24h) and
+// EMAIL_VERIFICATION_RESEND_COOLDOWN (default 2m). The daily cap is a send
+// limit on verify_email; see setupEmailSendLimits.
+func (h *Handler) setupEmailVerification() {
+	h.emailVerification = emailVerificationConfig{
+		ttl:      envDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+		cooldown: envDuration("EMAIL_VERIFICATION_RESEND_COOLDOWN", 2*time.Minute),
+	}
+}
+
+// issueEmailVerification creates a verification token for the user and
+// returns the link to put in the email. Earlier unused tokens are
+// invalidated.
+func (h *Handler) issueEmailVerification(user *models.User) (string, error) {
+	token, hashedToken := utils.GenerateSecureToken()
+	if token == "" {
+		return "", fmt.Errorf("failed to generate verification token")
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EmailVerification{}).
+			Where("user_id = ? AND used = false", user.ID).
+			Update("used", true).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.EmailVerification{
+			UserID:    user.ID,
+			Token:     hashedToken,
+			ExpiresAt: time.Now().Add(h.emailVerification.ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	return fmt.Sprintf("%s/verify-email?token=%s", config.Get().ClientURL, token), nil
+}
+
+// VerifyEmail marks the user's email as verified using the token from a
+// verification email.
+func (h *Handler) VerifyEmail(c *fiber.Ctx) error {
+	var body VerifyEmailProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	var verification models.EmailVerification
+	err := h.db.Where("token = ? AND used = false AND expires_at > ?",
+		utils.HashTokenSHA256(body.Token), time.Now()).First(&verification).Error
+	if err != nil {
+		return fiber.NewError(400, "Invalid or expired verification token")
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// The conditional update makes concurrent uses of one token fail
+		result := tx.Model(&models.EmailVerification{}).
+			Where("id = ? AND used = false", verification.ID).
+			Update("used", true)
+		if result.Error != nil {
+			return fmt.Errorf("failed to mark verification token as used: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(400, "Invalid or expired verification token")
+		}
+
+		return store.NewGorm(tx).Users().MarkEmailVerified(verification.UserID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Email verified successfully",
+		Data:    nil,
+	})
+}
+
+// ResendVerification sends a fresh verification email. Each account may
+// request one every EMAIL_VERIFICATION_RESEND_COOLDOWN and at most
+// EMAIL_VERIFICATION_DAILY_LIMIT per 24 hours.
+func (h *Handler) ResendVerification(c *fiber.Ctx) error {
+	var body ResendVerificationProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	body.Email = utils.NormalizeEmail(body.Email)
+
+	response := utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "If an unverified account with that email exists, a verification link has been sent.",
+		Data:    nil,
+	}
+
+	// Don't reveal whether the account exists or is already verified
+	user, err := h.stores.Users().ByEmail(middleware.TenantID(c), body.Email)
+	if err != nil || user.EmailVerifiedAt != nil {
+		return c.JSON(response)
+	}
+
+	var last models.EmailVerification
+	err = h.db.Where("user_id = ?", user.ID).Order("created_at DESC").First(&last).Error
+	if err == nil {
+		if wait := time.Until(last.CreatedAt.Add(h.emailVerification.cooldown)); wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return fiber.NewError(429, "A verification email was sent recently. Please check your email or try again shortly.")
+		}
+	}
+
+	if err := h.checkEmailSendLimit(utils.EmailTemplateVerifyEmail, user.Email); err != nil {
+		if errors.Is(err, errEmailSendLimited) {
+			return fiber.NewError(429, "Too many verification emails requested today. Please try again tomorrow.")
+		}
+		return err
+	}
+
+	verifyURL, err := h.issueEmailVerification(user)
+	if err != nil {
+		return err
+	}
+
+	err = h.queueUserEmail(user, utils.EmailTemplateVerifyEmail, utils.VerifyEmail{
+		VerifyURL: verifyURL,
+		ExpiresIn: h.emailVerification.ttl,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response)
+}
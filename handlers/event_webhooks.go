@@ -0,0 +1,571 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/utils"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Headers sent with every webhook delivery.
+const (
+	webhookHeaderEvent     = "X-Webhook-Event"
+	webhookHeaderID        = "X-Webhook-ID"
+	webhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// webhookConfig controls delivery of auth events to webhook endpoints.
+type webhookConfig struct {
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	backoff      time.Duration // Delay after the first failure, doubled per attempt
+	maxBackoff   time.Duration
+	timeout      time.Duration // Per request
+	lease        time.Duration // How long a claimed row is hidden from other workers
+}
+
+// setupEventWebhooks reads WEBHOOK_* settings and starts the delivery worker.
+func (h *Handler) setupEventWebhooks() {
+	h.webhooks = webhookConfig{
+		pollInterval: envDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		batchSize:    20,
+		maxAttempts:  envInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		backoff:      envDuration("WEBHOOK_BACKOFF", 30*time.Second),
+		maxBackoff:   envDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+		timeout:      envDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		lease:        2 * time.Minute,
+	}
+	if h.webhooks.maxAttempts <= 0 {
+		h.webhooks.maxAttempts = 1
+	}
+
+	h.webhookClient = &http.Client{
+		Timeout: h.webhooks.timeout,
+		// A redirect could point the signed request at an internal address
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	h.startWorker(h.runWebhookDeliveries)
+}
+
+// queueWebhookDeliveries queues an encoded event for every active endpoint
+// subscribed to it. Failures are logged and never fail the request that
+// triggered the event.
+func (h *Handler) queueWebhookDeliveries(event models.WebhookEvent, eventID string, payload []byte) {
+	var endpoints []models.WebhookEndpoint
+	if err := h.db.Where("active = ?", true).Find(&endpoints).Error; err != nil {
+		log.Printf("webhooks: failed to load endpoints for %s: %v", event, err)
+		return
+	}
+
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event) {
+			continue
+		}
+		delivery := models.WebhookDelivery{
+			EndpointID:    endpoint.ID,
+			EventID:       eventID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.WebhookStatusPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := h.db.Create(&delivery).Error; err != nil {
+			log.Printf("webhooks: failed to queue %s for endpoint %d: %v", event, endpoint.ID, err)
+		}
+	}
+}
+
+// runWebhookDeliveries delivers due webhook events every poll interval.
+func (h *Handler) runWebhookDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(h.webhooks.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			delivered, err := h.deliverWebhookBatch()
+			if err != nil {
+				log.Printf("webhooks: %v", err)
+				break
+			}
+			if delivered < h.webhooks.batchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliverWebhookBatch claims up to batchSize due deliveries and sends them,
+// leasing them like the email outbox so several instances can run the worker.
+func (h *Handler) deliverWebhookBatch() (int, error) {
+	var batch []models.WebhookDelivery
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookStatusPending, now).
+			Order("next_attempt_at").
+			Limit(h.webhooks.batchSize).
+			Find(&batch).Error
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(batch))
+		for i, delivery := range batch {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(h.webhooks.lease)).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("claim deliveries: %w", err)
+	}
+
+	for i := range batch {
+		h.deliverWebhook(&batch[i])
+	}
+	return len(batch), nil
+}
+
+// deliverWebhook sends one delivery and records the outcome. Failures are
+// retried with exponential backoff until maxAttempts, then dead-lettered.
+func (h *Handler) deliverWebhook(delivery *models.WebhookDelivery) {
+	attempts := delivery.Attempts + 1
+
+	var endpoint models.WebhookEndpoint
+	err := h.db.First(&endpoint, delivery.EndpointID).Error
+	statusCode := 0
+	if err == nil {
+		statusCode, err = h.sendWebhook(&endpoint, delivery)
+	}
+
+	if err == nil {
+		now := time.Now()
+		h.db.Model(delivery).Updates(map[string]interface{}{
+			"status":           models.WebhookStatusDelivered,
+			"attempts":         attempts,
+			"last_status_code": statusCode,
+			"last_error":       "",
+			"delivered_at":     now,
+		})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"attempts":         attempts,
+		"last_status_code": statusCode,
+		"last_error":       err.Error(),
+	}
+	if attempts >= h.webhooks.maxAttempts {
+		updates["status"] = models.WebhookStatusDead
+		log.Printf("webhooks: giving up on delivery %d after %d attempts: %v", delivery.ID, attempts, err)
+	} else {
+		updates["next_attempt_at"] = time.Now().Add(h.webhookBackoff(attempts))
+	}
+
+	if err := h.db.Model(delivery).Updates(updates).Error; err != nil {
+		log.Printf("webhooks: failed to record attempt for delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// sendWebhook posts the delivery's payload to the endpoint, signed with its
+// secret. It returns the response status, which must be 2xx.
+func (h *Handler) sendWebhook(endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) (int, error) {
+	secret, err := utils.DecryptValue(endpoint.Secret)
+	if err != nil {
+		return 0, fmt.Errorf("decrypt webhook secret: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.webhooks.timeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-auth-webhooks/1.0")
+	req.Header.Set(webhookHeaderEvent, string(delivery.Event))
+	req.Header.Set(webhookHeaderID, delivery.EventID)
+	req.Header.Set(webhookHeaderSignature, webhookSignature(secret, time.Now(), body))
+
+	resp, err := h.webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookSignature returns the signature header value "t=<unix>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix>.<body>" keyed with the secret.
+// Receivers should reject timestamps that are too old to prevent replays.
+func webhookSignature(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// webhookBackoff returns the delay before the next attempt after the given
+// number of failed attempts.
+func (h *Handler) webhookBackoff(attempts int) time.Duration {
+	delay := h.webhooks.backoff
+	for i := 1; i < attempts && delay < h.webhooks.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, h.webhooks.maxBackoff)
+}
+
+// WebhookEndpointRequest represents the request body for registering or
+// updating a webhook endpoint. On update, omitted fields are left unchanged.
+type WebhookEndpointRequest struct {
+	URL         string                `json:"url,omitempty"`
+	Secret      string                `json:"secret,omitempty" validate:"omitempty,min=16"` // Generated when omitted on create
+	Events      []models.WebhookEvent `json:"events,omitempty"`
+	Description *string               `json:"description,omitempty"`
+	Active      *bool                 `json:"active,omitempty"`
+}
+
+// encryptWebhookSecret encrypts a signing secret for storage, keeping it as
+// plaintext when no encryption key is configured.
+func encryptWebhookSecret(secret string) (string, error) {
+	stored, err := utils.EncryptValue(secret)
+	if errors.Is(err, utils.ErrEncryptionKeyMissing) {
+		return secret, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("encrypt webhook secret: %w", err)
+	}
+	return stored, nil
+}
+
+// validateWebhookURL requires an absolute HTTPS URL. Plain HTTP is accepted
+// in development.
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return fiber.NewError(400, "Invalid url")
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && config.Get().Development()) {
+		return fiber.NewError(400, "Webhook url must use https")
+	}
+	if len(raw) > 500 {
+		return fiber.NewError(400, "Webhook url must be at most 500 characters")
+	}
+	return nil
+}
+
+// validateWebhookEvents requires a non-empty list of known events.
+func validateWebhookEvents(events []models.WebhookEvent) error {
+	if len(events) == 0 {
+		return fiber.NewError(400, "At least one event is required")
+	}
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			names := make([]string, len(models.WebhookEvents))
+			for i, e := range models.WebhookEvents {
+				names[i] = string(e)
+			}
+			return fiber.NewError(400, fmt.Sprintf("Unknown event %q. Supported events: %s", event, strings.Join(names, ", ")))
+		}
+	}
+	return nil
+}
+
+// AdminListWebhooks returns the registered webhook endpoints.
+func (h *Handler) AdminListWebhooks(c *fiber.Ctx) error {
+	var endpoints []models.WebhookEndpoint
+	if err := h.db.Order("id").Find(&endpoints).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch webhooks")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    endpoints,
+	})
+}
+
+// AdminCreateWebhook registers a webhook endpoint. The signing secret is
+// only returned in this response.
+func (h *Handler) AdminCreateWebhook(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var req WebhookEndpointRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+	if err := validateWebhookURL(req.URL); err != nil {
+		return err
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		return err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret, _ = utils.GenerateSecureToken()
+		if secret == "" {
+			return fmt.Errorf("failed to generate webhook secret")
+		}
+	}
+	encrypted, err := encryptWebhookSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL:       req.URL,
+		Secret:    encrypted,
+		Events:    req.Events,
+		Active:    true,
+		CreatedBy: &claims.Subject,
+	}
+	if req.Description != nil {
+		endpoint.Description = *req.Description
+	}
+	if req.Active != nil {
+		endpoint.Active = *req.Active
+	}
+	// Select Active so an explicit false is not replaced by the column default
+	if err := h.db.Select("*").Create(&endpoint).Error; err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventWebhookCreated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"webhook_id": endpoint.ID, "url": endpoint.URL, "events": endpoint.Events})
+
+	return c.Status(201).JSON(utils.Response{
+		Success: true,
+		Code:    201,
+		Message: "Webhook created",
+		Data: fiber.Map{
+			"webhook": endpoint,
+			"secret":  secret,
+		},
+	})
+}
+
+// AdminUpdateWebhook changes a webhook endpoint's URL, events, description,
+// active flag or secret.
+func (h *Handler) AdminUpdateWebhook(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid webhook id")
+	}
+
+	var req WebhookEndpointRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	var endpoint models.WebhookEndpoint
+	if err := h.db.First(&endpoint, id).Error; err != nil {
+		return fiber.NewError(404, "Webhook not found")
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != "" {
+		if err := validateWebhookURL(req.URL); err != nil {
+			return err
+		}
+		updates["url"] = req.URL
+	}
+	if req.Events != nil {
+		if err := validateWebhookEvents(req.Events); err != nil {
+			return err
+		}
+		endpoint.Events = req.Events
+		updates["events"] = endpoint.Events
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	if req.Secret != "" {
+		encrypted, err := encryptWebhookSecret(req.Secret)
+		if err != nil {
+			return err
+		}
+		updates["secret"] = encrypted
+	}
+	if len(updates) == 0 {
+		return fiber.NewError(400, "No changes provided")
+	}
+
+	if err := h.db.Model(&endpoint).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	changed := make([]string, 0, len(updates))
+	for field := range updates {
+		changed = append(changed, field)
+	}
+	slices.Sort(changed)
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventWebhookUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"webhook_id": endpoint.ID, "fields": changed})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Webhook updated",
+		Data:    endpoint,
+	})
+}
+
+// AdminDeleteWebhook removes a webhook endpoint and its delivery log.
+func (h *Handler) AdminDeleteWebhook(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid webhook id")
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.WebhookEndpoint{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete webhook: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(404, "Webhook not found")
+		}
+		return tx.Where("endpoint_id = ?", id).Delete(&models.WebhookDelivery{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventWebhookDeleted,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"webhook_id": id})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Webhook deleted",
+		Data:    nil,
+	})
+}
+
+// AdminListWebhookDeliveries returns webhook deliveries, newest first.
+// Filters: webhook_id, event, event_id and status.
+func (h *Handler) AdminListWebhookDeliveries(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	query := h.db.Order("created_at DESC, id DESC").Limit(limit)
+	if endpointID := c.QueryInt("webhook_id"); endpointID > 0 {
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	if eventID := c.Query("event_id"); eventID != "" {
+		query = query.Where("event_id = ?", eventID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Find(&deliveries).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch webhook deliveries")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    deliveries,
+	})
+}
+
+// AdminRetryWebhookDelivery moves a dead-lettered delivery back into the
+// queue with a fresh attempt budget.
+func (h *Handler) AdminRetryWebhookDelivery(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return fiber.NewError(400, "Invalid delivery id")
+	}
+
+	result := h.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ?", id, models.WebhookStatusDead).
+		Updates(map[string]interface{}{
+			"status":          models.WebhookStatusPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to requeue webhook delivery: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fiber.NewError(404, "Dead-lettered delivery not found")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Webhook delivery requeued",
+		Data:    nil,
+	})
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// setupEventBus configures event publishing (see utils.NewEventBus).
+func (h *Handler) setupEventBus() {
+	bus, err := utils.NewEventBus()
+	if err != nil {
+		log.Fatalf("event bus: %v", err)
+	}
+	h.eventBus = bus
+}
+
+// publishEvent publishes an account lifecycle event to the event bus, if
+// configured, and queues it for subscribed webhook endpoints. Both receive
+// the same envelope. data must not carry secrets or email addresses; it is
+// stored in the webhook delivery log and leaves the service.
+func (h *Handler) publishEvent(event models.WebhookEvent, data fiber.Map) {
+	eventID := uuid.NewString()
+	payload, err := json.Marshal(fiber.Map{
+		"id":         eventID,
+		"event":      event,
+		"created_at": time.Now().UTC(),
+		"data":       data,
+	})
+	if err != nil {
+		log.Printf("events: failed to encode %s: %v", event, err)
+		return
+	}
+
+	if h.eventBus != nil {
+		// Keying by user keeps each user's events in order on one partition
+		var key string
+		if userID, ok := data["user_id"]; ok && userID != nil {
+			key = fmt.Sprint(userID)
+		}
+		h.eventBus.Publish(string(event), key, payload)
+	}
+	h.queueWebhookDeliveries(event, eventID, payload)
+}
+
+// sessionsRevoked publishes a session.revoked event when any were revoked.
+func (h *Handler) sessionsRevoked(userID uint, revoked int64, reason string) {
+	if revoked <= 0 {
+		return
+	}
+	h.publishEvent(models.WebhookEventSessionRevoked, fiber.Map{
+		"user_id":          userID,
+		"revoked_sessions": revoked,
+		"reason":           reason,
+	})
+}
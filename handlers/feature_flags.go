@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm/clause"
+)
+
+// Feature flags gating optional features.
+const (
+	// FlagOpenRegistration allows sign-up without an invitation, through
+	// /register and OAuth.
+	FlagOpenRegistration = "open_registration"
+	// FlagOAuthGoogle and FlagOAuthGithub allow sign-in with that provider.
+	FlagOAuthGoogle = "oauth_google"
+	FlagOAuthGithub = "oauth_github"
+)
+
+// featureFlagNames lists the known flags, in the order they are shown.
+var featureFlagNames = []string{FlagOpenRegistration, FlagOAuthGoogle, FlagOAuthGithub}
+
+// featureFlagsRefreshInterval bounds how stale the cached flags can get
+// when they are changed by another instance.
+const featureFlagsRefreshInterval = 30 * time.Second
+
+// featureFlagCache caches the flag values: the configured defaults with the
+// admin overrides applied.
+type featureFlagCache struct {
+	sync.RWMutex
+	defaults map[string]bool
+	enabled  map[string]bool
+	loadedAt time.Time
+}
+
+type UpdateFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// FeatureFlagStatus is a flag as shown to admins.
+type FeatureFlagStatus struct {
+	Name     string              `json:"name"`
+	Enabled  bool                `json:"enabled"`
+	Default  bool                `json:"default"`
+	Override *models.FeatureFlag `json:"override,omitempty"`
+}
+
+// setupFeatureFlags reads the flag defaults. Every flag is on unless
+// FEATURE_FLAGS, a comma-separated list such as
+// "oauth_github=false,open_registration=true", says otherwise.
+// REGISTRATION_INVITE_ONLY=true still turns open_registration off by default.
+// Admin overrides are read from the database on first use.
+func (h *Handler) setupFeatureFlags() {
+	if err := h.reloadFeatureFlagDefaults(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// reloadFeatureFlagDefaults applies the current FEATURE_FLAGS and
+// REGISTRATION_INVITE_ONLY. Invalid values leave the previous defaults in
+// place.
+func (h *Handler) reloadFeatureFlagDefaults() error {
+	defaults := make(map[string]bool, len(featureFlagNames))
+	for _, name := range featureFlagNames {
+		defaults[name] = true
+	}
+	if inviteOnly, _ := strconv.ParseBool(os.Getenv("REGISTRATION_INVITE_ONLY")); inviteOnly {
+		defaults[FlagOpenRegistration] = false
+	}
+
+	for _, entry := range strings.Split(os.Getenv("FEATURE_FLAGS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := defaults[name]; !ok {
+			return fmt.Errorf("FEATURE_FLAGS: unknown flag %q", name)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("FEATURE_FLAGS: invalid value for %s: %q", name, value)
+		}
+		defaults[name] = enabled
+	}
+
+	h.featureFlags.Lock()
+	h.featureFlags.defaults = defaults
+	h.featureFlags.enabled = defaults
+	h.featureFlags.loadedAt = time.Time{}
+	h.featureFlags.Unlock()
+	return nil
+}
+
+// enabledFeatureFlags returns the current flag values, reloading the admin
+// overrides when the cached copy is stale.
+func (h *Handler) enabledFeatureFlags() map[string]bool {
+	h.featureFlags.RLock()
+	if time.Since(h.featureFlags.loadedAt) < featureFlagsRefreshInterval {
+		enabled := h.featureFlags.enabled
+		h.featureFlags.RUnlock()
+		return enabled
+	}
+	h.featureFlags.RUnlock()
+
+	h.featureFlags.Lock()
+	defer h.featureFlags.Unlock()
+
+	if time.Since(h.featureFlags.loadedAt) < featureFlagsRefreshInterval {
+		return h.featureFlags.enabled
+	}
+
+	var overrides []models.FeatureFlag
+	if err := h.db.Find(&overrides).Error; err != nil {
+		// Keep the previous values rather than failing open or closed
+		log.Printf("feature flags: failed to load overrides: %v", err)
+		return h.featureFlags.enabled
+	}
+
+	enabled := make(map[string]bool, len(h.featureFlags.defaults))
+	for name, value := range h.featureFlags.defaults {
+		enabled[name] = value
+	}
+	for _, o := range overrides {
+		if _, ok := enabled[o.Name]; ok {
+			enabled[o.Name] = o.Enabled
+		}
+	}
+
+	h.featureFlags.enabled = enabled
+	h.featureFlags.loadedAt = time.Now()
+	return enabled
+}
+
+// flagEnabled reports whether the named feature flag is on.
+func (h *Handler) flagEnabled(name string) bool {
+	return h.enabledFeatureFlags()[name]
+}
+
+// oauthProviderEnabled reports whether sign-in with provider is allowed.
+func (h *Handler) oauthProviderEnabled(provider models.OAuthProvider) bool {
+	return h.flagEnabled("oauth_" + string(provider))
+}
+
+// reloadFeatureFlags makes the next lookup read the overrides again, so this
+// instance sees an admin change right away.
+func (h *Handler) reloadFeatureFlags() {
+	h.featureFlags.Lock()
+	h.featureFlags.loadedAt = time.Time{}
+	h.featureFlags.Unlock()
+}
+
+// GetClientConfig returns the public configuration frontends need to decide
+// what to show, such as which sign-up and sign-in options are available.
+func (h *Handler) GetClientConfig(c *fiber.Ctx) error {
+	flags := h.enabledFeatureFlags()
+
+	oauthProviders := []models.OAuthProvider{}
+	for _, provider := range []models.OAuthProvider{models.OAuthProviderGoogle, models.OAuthProviderGithub} {
+		if _, err := utils.GetOAuthConfig(provider); err == nil && flags["oauth_"+string(provider)] {
+			oauthProviders = append(oauthProviders, provider)
+		}
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"features":        flags,
+			"oauth_providers": oauthProviders,
+		},
+	})
+}
+
+// AdminListFeatureFlags returns every feature flag with its current value,
+// its default and the admin override, if any.
+func (h *Handler) AdminListFeatureFlags(c *fiber.Ctx) error {
+	var overrides []models.FeatureFlag
+	if err := h.db.Find(&overrides).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch feature flags")
+	}
+	byName := make(map[string]*models.FeatureFlag, len(overrides))
+	for i := range overrides {
+		byName[overrides[i].Name] = &overrides[i]
+	}
+
+	h.featureFlags.RLock()
+	defaults := h.featureFlags.defaults
+	h.featureFlags.RUnlock()
+
+	flags := make([]FeatureFlagStatus, 0, len(featureFlagNames))
+	for _, name := range featureFlagNames {
+		status := FeatureFlagStatus{Name: name, Enabled: defaults[name], Default: defaults[name]}
+		if override, ok := byName[name]; ok {
+			status.Enabled = override.Enabled
+			status.Override = override
+		}
+		flags = append(flags, status)
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    flags,
+	})
+}
+
+// AdminUpdateFeatureFlag overrides the flag in :name.
+func (h *Handler) AdminUpdateFeatureFlag(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	name, err := featureFlagParam(c)
+	if err != nil {
+		return err
+	}
+
+	var req UpdateFeatureFlagRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	flag := models.FeatureFlag{
+		Name:      name,
+		Enabled:   *req.Enabled,
+		UpdatedBy: claims.Subject,
+	}
+	if err := h.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&flag).Error; err != nil {
+		return fmt.Errorf("failed to save feature flag: %w", err)
+	}
+	h.reloadFeatureFlags()
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventFeatureFlagUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"flag":    name,
+		"enabled": flag.Enabled,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Feature flag updated",
+		Data:    flag,
+	})
+}
+
+// AdminResetFeatureFlag removes the override of the flag in :name, so its
+// default applies again.
+func (h *Handler) AdminResetFeatureFlag(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	name, err := featureFlagParam(c)
+	if err != nil {
+		return err
+	}
+
+	result := h.db.Delete(&models.FeatureFlag{}, "name = ?", name)
+	if result.Error != nil {
+		return fmt.Errorf("failed to reset feature flag: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fiber.NewError(404, "Feature flag is not overridden")
+	}
+	h.reloadFeatureFlags()
+
+	h.featureFlags.RLock()
+	enabled := h.featureFlags.defaults[name]
+	h.featureFlags.RUnlock()
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventFeatureFlagUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"flag":    name,
+		"enabled": enabled,
+		"reset":   true,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Feature flag reset to its default",
+		Data:    fiber.Map{"name": name, "enabled": enabled},
+	})
+}
+
+// featureFlagParam returns the known flag named in :name.
+func featureFlagParam(c *fiber.Ctx) (string, error) {
+	name := strings.ToLower(c.Params("name"))
+	for _, known := range featureFlagNames {
+		if name == known {
+			return name, nil
+		}
+	}
+	return "", fiber.NewError(404, "Unknown feature flag")
+}
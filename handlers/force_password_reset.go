@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// reasonPasswordResetRequired is returned when a password login is refused
+// because an admin forced a reset.
+const reasonPasswordResetRequired = "password_reset_required"
+
+// AdminForcePasswordReset invalidates the password of the user in :id,
+// revokes all of their sessions and emails them a reset link, for incident
+// response after a suspected compromise. Password logins fail until the user
+// completes the reset.
+func (h *Handler) AdminForcePasswordReset(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+	if uint(userID) == claims.Subject {
+		return fiber.NewError(400, "You cannot force a reset of your own password")
+	}
+
+	user, err := h.stores.Users().ByID(uint(userID))
+	if err != nil || user.AnonymizedAt != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	if user.Password == "" {
+		return fiber.NewError(400, "User has no password to reset")
+	}
+
+	var revoked int64
+	err = h.stores.Transaction(func(tx store.Store) error {
+		if err := tx.Users().Update(user, map[string]any{"password_reset_required": true}); err != nil {
+			return fmt.Errorf("failed to invalidate password: %w", err)
+		}
+		revoked, err = revokeUserSessions(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	h.sessionsRevoked(user.ID, revoked, "password_reset_forced")
+
+	// The password stays invalidated even if the email cannot be queued; the
+	// user can still request a reset themselves
+	emailQueued := true
+	if err := h.passwordResets.Issue(user); err != nil {
+		emailQueued = false
+		if !errors.Is(err, errEmailSendLimited) {
+			log.Printf("force password reset: user %d: %v", user.ID, err)
+		}
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventPasswordResetForced,
+		UserID:    &user.ID,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"revoked_sessions": revoked,
+		"email_queued":     emailQueued,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Password invalidated and sessions revoked",
+		Data: fiber.Map{
+			"revoked_sessions": revoked,
+			"email_queued":     emailQueued,
+		},
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// geoLocationLocal caches the resolved client location for the request.
+const geoLocationLocal = "geo_location"
+
+// loginChallengeLocal marks a request whose step-up code was already
+// accepted, so several checks can share one code.
+const loginChallengeLocal = "login_challenge_verified"
+
+// geoConfig holds the client geolocation used by impossible-travel detection.
+// Detection is disabled when no locator is configured; its thresholds are
+// part of the security policy (see utils.TravelPolicy).
+type geoConfig struct {
+	locator utils.GeoLocator
+}
+
+// setupGeoAnomaly configures the locator from GEO_SOURCE (see
+// utils.NewGeoLocator).
+func (h *Handler) setupGeoAnomaly() {
+	locator, err := utils.NewGeoLocator()
+	if err != nil {
+		log.Printf("impossible travel detection disabled: %v", err)
+	}
+	h.geo.locator = locator
+}
+
+// clientLocation returns the approximate location of the client, or nil if
+// detection is disabled or the location is unknown.
+func (h *Handler) clientLocation(c *fiber.Ctx) *utils.GeoLocation {
+	if h.geo.locator == nil {
+		return nil
+	}
+	if loc, ok := c.Locals(geoLocationLocal).(*utils.GeoLocation); ok {
+		return loc
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), 3*time.Second)
+	defer cancel()
+
+	loc, err := h.geo.locator.Locate(ctx, c.IP(), func(key string) string { return c.Get(key) })
+	if err != nil {
+		log.Printf("geo: failed to locate %s: %v", c.IP(), err)
+	}
+	c.Locals(geoLocationLocal, loc)
+	return loc
+}
+
+// checkImpossibleTravel compares the client location with the user's last
+// successful login. When the implied speed is implausible the user is
+// alerted by email and, if the step-up policy asks for it, the login must be
+// completed with the code sent in that email.
+func (h *Handler) checkImpossibleTravel(c *fiber.Ctx, user *models.User, stepUpCode string) error {
+	loc := h.clientLocation(c)
+	if loc == nil {
+		return nil
+	}
+	policy := h.policy()
+	stepUp := policy.StepUp.ImpossibleTravel
+
+	var last models.LoginAttempt
+	err := h.db.Where("user_id = ? AND success = ? AND latitude IS NOT NULL AND longitude IS NOT NULL", user.ID, true).
+		Order("created_at DESC").
+		First(&last).Error
+	if err != nil {
+		return nil
+	}
+
+	previous := utils.GeoLocation{Latitude: *last.Latitude, Longitude: *last.Longitude, Country: last.Country, City: last.City}
+	distance := utils.DistanceKm(previous, *loc)
+	if distance < float64(policy.ImpossibleTravel.MinDistanceKm) {
+		return nil
+	}
+
+	// Clamp to one minute so back-to-back logins don't divide by zero
+	hours := max(time.Since(last.CreatedAt).Hours(), 1.0/60)
+	speed := distance / hours
+	if speed <= float64(policy.ImpossibleTravel.MaxSpeedKmh) {
+		return nil
+	}
+
+	if stepUp && stepUpCode != "" {
+		if h.consumeLoginChallenge(c, user.ID, stepUpCode) {
+			return nil
+		}
+		h.recordLoginAttempt(c, user.Email, &user.ID, false, loginFailureInvalidCode)
+		return fiber.NewError(401, "Invalid or expired verification code")
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventImpossibleTravel,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"previous_ip":       last.IPAddress,
+		"previous_location": previous,
+		"location":          loc,
+		"distance_km":       int(distance),
+		"speed_kmh":         int(speed),
+		"step_up":           stepUp,
+	})
+
+	var code string
+	if stepUp {
+		code, err = h.issueLoginChallenge(c, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	h.sendImpossibleTravelAlert(user, describeLocation(previous), describeLocation(*loc), c.IP(), code)
+
+	if stepUp {
+		return fiber.NewError(403, "Additional verification required. A verification code has been sent to your email")
+	}
+	return nil
+}
+
+// issueLoginChallenge replaces any pending step-up codes for the user with a
+// new one bound to the client IP and returns the plaintext code.
+func (h *Handler) issueLoginChallenge(c *fiber.Ctx, userID uint) (string, error) {
+	code, hash := utils.GenerateNumericCode(6)
+	if code == "" {
+		return "", fmt.Errorf("failed to generate verification code")
+	}
+
+	h.db.Model(&models.LoginChallenge{}).Where("user_id = ? AND used = false", userID).Update("used", true)
+
+	challenge := models.LoginChallenge{
+		UserID:    userID,
+		Code:      hash,
+		IPAddress: c.IP(),
+		ExpiresAt: time.Now().Add(time.Duration(h.policy().StepUp.ChallengeTTL)),
+	}
+	if err := h.db.Create(&challenge).Error; err != nil {
+		return "", fmt.Errorf("failed to create login challenge: %w", err)
+	}
+
+	return code, nil
+}
+
+// consumeLoginChallenge marks a matching, unexpired code issued to the same
+// IP as used. The conditional update makes each code single-use.
+func (h *Handler) consumeLoginChallenge(c *fiber.Ctx, userID uint, code string) bool {
+	if c.Locals(loginChallengeLocal) == true {
+		return true
+	}
+
+	result := h.db.Model(&models.LoginChallenge{}).
+		Where("user_id = ? AND code = ? AND ip_address = ? AND used = false AND expires_at > ?",
+			userID, utils.HashTokenSHA256(code), c.IP(), time.Now()).
+		Update("used", true)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false
+	}
+
+	c.Locals(loginChallengeLocal, true)
+	return true
+}
+
+func describeLocation(loc utils.GeoLocation) string {
+	switch {
+	case loc.City != "" && loc.Country != "":
+		return loc.City + ", " + loc.Country
+	case loc.Country != "":
+		return loc.Country
+	default:
+		return fmt.Sprintf("%.2f, %.2f", loc.Latitude, loc.Longitude)
+	}
+}
+
+func (h *Handler) sendImpossibleTravelAlert(user *models.User, previous, current, ip, code string) {
+	err := h.queueUserEmail(user, utils.EmailTemplateImpossibleTravel, utils.ImpossibleTravelEmail{
+		Location:         current,
+		PreviousLocation: previous,
+		IPAddress:        ip,
+		Code:             code,
+		ExpiresIn:        time.Duration(h.policy().StepUp.ChallengeTTL),
+	})
+	if err != nil {
+		log.Printf("geo: failed to queue impossible travel alert: %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"api/middleware"
+	"api/service"
+	"api/store"
+	"api/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Handler serves the API routes. Its database, stores, services, mailer and
+// avatar storage are injected through New, and each instance reads its own
+// settings, so several instances can run side by side.
+type Handler struct {
+	db     *gorm.DB
+	stores store.Store       // Users, sessions, OAuth links and password resets
+	caches middleware.Caches // Invalidated when admins change what they cache
+
+	// The services behind the registration, login, OAuth and password reset
+	// handlers
+	accounts       *service.Accounts
+	oauthLogins    *service.OAuth
+	passwordResets *service.PasswordResets
+	tokens         *service.Tokens // Scoped token issuance, and validation for internal services over gRPC
+
+	mailer         utils.EmailSender
+	mailerProvider string              // Names the mailer in the delivery event log
+	avatarStorage  utils.ObjectStorage // nil disables uploads
+
+	riskRules []RiskRule // Built-in rules, then those added with RegisterRiskRule
+
+	// Settings read from the environment by New, and the caches of settings
+	// stored in the database
+	securityPolicy           securityPolicyCache
+	featureFlags             featureFlagCache
+	currencies               currencyCache
+	registration             registrationConfig
+	usernamePolicy           utils.UsernamePolicy
+	usernameChanges          usernameChangeConfig
+	captcha                  captchaConfig
+	botDetection             botDetectionConfig
+	stuffing                 stuffingConfig
+	geo                      geoConfig
+	avatars                  avatarConfig
+	emailVerification        emailVerificationConfig
+	emailOutbox              emailOutboxConfig
+	emailSendLimits          map[string]emailSendLimit
+	emailWebhooks            emailWebhookConfig
+	webhooks                 webhookConfig
+	webhookClient            *http.Client
+	accountPurge             accountPurgeConfig
+	userImport               userImportConfig
+	passwordHistorySize      int           // Previous passwords that may not be reused
+	passwordResetMaxAttempts int           // Rejected confirmations a reset token survives
+	secureAccountTTL         time.Duration // Validity of the "this wasn't me" link
+	requireTLSBinding        bool
+	maxJSONBodyBytes         int
+	maxUserMetadataBytes     int
+
+	siem     *utils.SIEMStreamer // nil unless SIEM_SINK is set
+	eventBus *utils.EventBus     // nil unless EVENT_BUS is set
+
+	// Background workers run until stop is done; Close waits for them
+	stop        context.Context
+	stopWorkers context.CancelFunc
+	workers     sync.WaitGroup
+}
+
+// New builds the handlers on db and reads their settings from the
+// environment. caches must be the ones the app's middleware reads from db.
+// mailer delivers account emails; nil uses SMTP. storage holds
+// uploaded avatars; nil disables uploads. It starts the email outbox,
+// webhook delivery and account purge workers, which run until Close.
+func New(db *gorm.DB, caches middleware.Caches, mailer utils.EmailSender, storage utils.ObjectStorage) *Handler {
+	h := &Handler{db: db, stores: store.NewGorm(db), caches: caches}
+	h.stop, h.stopWorkers = context.WithCancel(context.Background())
+	h.setupAuth(mailer)
+	h.setupPasswordReset()
+	h.setupAvatars(storage)
+	return h
+}
+
+// multipartOverhead is the room left around an upload for its multipart
+// boundaries and headers.
+const multipartOverhead = 64 << 10
+
+// BodyLimit returns the largest request body a route accepts, for
+// fiber.Config.BodyLimit: the largest of the JSON body, user import and
+// avatar upload limits, and at least Fiber's default of 4 MiB. Without it
+// Fiber rejects bodies above its default before the handlers see them.
+func (h *Handler) BodyLimit() int {
+	return max(fiber.DefaultBodyLimit, h.maxJSONBodyBytes, h.userImport.maxBytes, h.avatars.maxBytes+multipartOverhead)
+}
+
+// startWorker runs a background worker until Close.
+func (h *Handler) startWorker(run func(ctx context.Context)) {
+	h.workers.Add(1)
+	go func() {
+		defer h.workers.Done()
+		run(h.stop)
+	}()
+}
+
+// Close stops the background workers, letting each finish the batch in
+// progress, then sends the audit and bus events still queued. It gives up
+// waiting when ctx is done.
+func (h *Handler) Close(ctx context.Context) error {
+	h.stopWorkers()
+
+	var errs []error
+	stopped := make(chan struct{})
+	go func() {
+		h.workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("workers: %w", ctx.Err()))
+	}
+
+	// Workers record audit events and publish events, so close these last
+	if h.siem != nil {
+		errs = append(errs, h.siem.Close(ctx))
+	}
+	if h.eventBus != nil {
+		errs = append(errs, h.eventBus.Close(ctx))
+	}
+	return errors.Join(errs...)
+}
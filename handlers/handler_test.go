@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name        string
+		jsonBytes   int
+		importBytes int
+		avatarBytes int
+		want        int
+	}{
+		{"defaults", 16 << 10, 4 << 20, 2 << 20, fiber.DefaultBodyLimit},
+		{"large import", 16 << 10, 10 << 20, 2 << 20, 10 << 20},
+		{"large avatar", 16 << 10, 4 << 20, 8 << 20, 8<<20 + multipartOverhead},
+		{"large json", 6 << 20, 4 << 20, 2 << 20, 6 << 20},
+		{"small limits", 1 << 10, 1 << 10, 1 << 10, fiber.DefaultBodyLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				maxJSONBodyBytes: tt.jsonBytes,
+				userImport:       userImportConfig{maxBytes: tt.importBytes},
+				avatars:          avatarConfig{maxBytes: tt.avatarBytes},
+			}
+			if got := h.BodyLimit(); got != tt.want {
+				t.Errorf("BodyLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
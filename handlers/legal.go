@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/utils"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type AcceptLegalProps struct {
+	TermsVersion   string `json:"terms_version" validate:"required"`
+	PrivacyVersion string `json:"privacy_version" validate:"required"`
+}
+
+// GetLegalDocuments returns the current terms of service and privacy policy
+// versions and URLs.
+func (h *Handler) GetLegalDocuments(c *fiber.Ctx) error {
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    utils.CurrentLegalDocuments(),
+	})
+}
+
+// GetMyLegalAcceptance returns the versions the user accepted and whether
+// they must accept newer ones.
+func (h *Handler) GetMyLegalAcceptance(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+
+	documents := utils.CurrentLegalDocuments()
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"current":             documents,
+			"terms_version":       user.TermsVersion,
+			"terms_accepted_at":   user.TermsAcceptedAt,
+			"privacy_version":     user.PrivacyVersion,
+			"privacy_accepted_at": user.PrivacyAcceptedAt,
+			"acceptance_required": !documents.AcceptedBy(user),
+		},
+	})
+}
+
+// AcceptLegalDocuments records that the user accepted the current terms of
+// service and privacy policy. The versions the user saw must be sent back,
+// so an acceptance of a page rendered before a version change is refused.
+func (h *Handler) AcceptLegalDocuments(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var body AcceptLegalProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	documents := utils.CurrentLegalDocuments()
+	if !documents.Tracked() {
+		return fiber.NewError(400, "No terms of service or privacy policy are configured")
+	}
+	if body.TermsVersion != documents.TermsVersion || body.PrivacyVersion != documents.PrivacyVersion {
+		err := utils.NewReasonError(409, "legal_version_mismatch", "The terms of service or privacy policy have changed. Review the current versions and try again.")
+		err.Details = map[string]any{"documents": documents}
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil {
+		return fiber.NewError(404, "User not found")
+	}
+
+	if err := h.stores.Users().Update(user, legalAcceptanceUpdates(documents, time.Now())); err != nil {
+		return fmt.Errorf("failed to record legal acceptance: %w", err)
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventLegalAccepted,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"terms_version":   documents.TermsVersion,
+		"privacy_version": documents.PrivacyVersion,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Acceptance recorded",
+		Data:    documents,
+	})
+}
+
+// legalAcceptanceUpdates returns the user columns recording acceptance of the
+// tracked documents at now.
+func legalAcceptanceUpdates(documents utils.LegalDocuments, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{}
+	if documents.TermsVersion != "" {
+		updates["terms_version"] = documents.TermsVersion
+		updates["terms_accepted_at"] = now
+	}
+	if documents.PrivacyVersion != "" {
+		updates["privacy_version"] = documents.PrivacyVersion
+		updates["privacy_accepted_at"] = now
+	}
+	return updates
+}
+
+// RequireLegalAcceptance returns middleware.RequireLegalAcceptance for the
+// handler's database, or nil when no versions are configured.
+func (h *Handler) RequireLegalAcceptance() fiber.Handler {
+	return middleware.RequireLegalAcceptance(h.db)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LoginHistoryEntry is a login attempt as shown to the account owner.
+// Coordinates and the attempted email are left out.
+type LoginHistoryEntry struct {
+	ID            uint      `json:"id"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent,omitempty"`
+	Country       string    `json:"country,omitempty"`
+	City          string    `json:"city,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// GetMyLoginHistory returns a page of password login attempts against the
+// authenticated user's account, newest first. The status query parameter
+// (success or failed) narrows the result.
+func (h *Handler) GetMyLoginHistory(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	page := c.QueryInt("page", 1)
+	if page <= 0 {
+		page = 1
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	query := h.db.Model(&models.LoginAttempt{}).Where("user_id = ?", claims.Subject)
+	switch c.Query("status") {
+	case "":
+	case "success":
+		query = query.Where("success = ?", true)
+	case "failed":
+		query = query.Where("success = ?", false)
+	default:
+		return fiber.NewError(400, "Invalid status. Expected success or failed")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return fiber.NewError(500, "Failed to count login attempts")
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("created_at DESC, id DESC").Offset((page - 1) * limit).Limit(limit).Find(&attempts).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch login history")
+	}
+
+	history := make([]LoginHistoryEntry, len(attempts))
+	for i, attempt := range attempts {
+		history[i] = LoginHistoryEntry{
+			ID:            attempt.ID,
+			Success:       attempt.Success,
+			FailureReason: attempt.FailureReason,
+			IPAddress:     attempt.IPAddress,
+			UserAgent:     attempt.UserAgent,
+			Country:       attempt.Country,
+			City:          attempt.City,
+			CreatedAt:     attempt.CreatedAt,
+		}
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"history": history,
+			"total":   total,
+			"page":    page,
+			"limit":   limit,
+		},
+	})
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UpdateMaintenanceRequest switches maintenance mode on or off.
+type UpdateMaintenanceRequest struct {
+	Enabled     *bool  `json:"enabled" validate:"required"`
+	Message     string `json:"message,omitempty" validate:"max=500"`
+	Until       string `json:"until,omitempty"` // RFC3339; expected end, sent as Retry-After
+	AllowAdmins bool   `json:"allow_admins,omitempty"`
+}
+
+// Health reports that the process is up. It is registered before the
+// maintenance middleware, so load balancers keep routing to the instance
+// while the API answers 503.
+func (h *Handler) Health(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// AdminGetMaintenance returns the maintenance mode in effect and whether it
+// comes from the admin API or the environment.
+func (h *Handler) AdminGetMaintenance(c *fiber.Ctx) error {
+	mode := h.caches.Maintenance.Defaults()
+	source := "env"
+
+	var stored models.MaintenanceMode
+	err := h.db.First(&stored, middleware.MaintenanceModeID).Error
+	switch {
+	case err == nil:
+		mode, source = stored, "admin"
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.NewError(500, "Failed to fetch maintenance mode")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"maintenance": mode,
+			"source":      source,
+		},
+	})
+}
+
+// AdminUpdateMaintenance switches maintenance mode, overriding the
+// MAINTENANCE_* environment variables.
+func (h *Handler) AdminUpdateMaintenance(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var req UpdateMaintenanceRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	mode := models.MaintenanceMode{
+		ID:          middleware.MaintenanceModeID,
+		Enabled:     *req.Enabled,
+		Message:     req.Message,
+		AllowAdmins: req.AllowAdmins,
+		UpdatedBy:   claims.Subject,
+	}
+	if req.Until != "" {
+		until, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			return fiber.NewError(400, "Invalid until. Expected RFC3339 timestamp")
+		}
+		mode.Until = &until
+	}
+
+	if err := h.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&mode).Error; err != nil {
+		return fmt.Errorf("failed to save maintenance mode: %w", err)
+	}
+	h.caches.Maintenance.Invalidate()
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventMaintenanceUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"enabled":      mode.Enabled,
+		"until":        mode.Until,
+		"allow_admins": mode.AllowAdmins,
+	})
+
+	message := "Maintenance mode disabled"
+	if mode.Enabled {
+		message = "Maintenance mode enabled"
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: message,
+		Data:    mode,
+	})
+}
+
+// AdminResetMaintenance deletes the stored setting so the MAINTENANCE_*
+// environment variables apply again.
+func (h *Handler) AdminResetMaintenance(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	if err := h.db.Delete(&models.MaintenanceMode{}, middleware.MaintenanceModeID).Error; err != nil {
+		return fmt.Errorf("failed to reset maintenance mode: %w", err)
+	}
+	h.caches.Maintenance.Invalidate()
+
+	defaults := h.caches.Maintenance.Defaults()
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventMaintenanceUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"enabled": defaults.Enabled,
+		"reset":   true,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Maintenance mode reset to its default",
+		Data:    defaults,
+	})
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
-	"api/database"
 	"api/database/models"
+	"api/store"
 	"api/utils"
 	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -13,14 +17,12 @@ import (
 // GetMe returns the authenticated user's public profile. It expects the JWT
 // middleware to have populated c.Locals("user") with a *jwt.Token whose
 // claims are *utils.JWTClaims.
-func GetMe(c *fiber.Ctx) error {
+func (h *Handler) GetMe(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
 	claims := token.Claims.(*utils.JWTClaims)
 
-	db := database.GetInstance()
-
-	var user models.User
-	if err := db.Preload("Sessions").Preload("OAuthLinks").First(&user, claims.Subject).Error; err != nil {
+	user, err := h.stores.Users().WithDetails(claims.Subject, false)
+	if err != nil {
 		return fiber.NewError(404, "User not found")
 	}
 
@@ -30,6 +32,7 @@ func GetMe(c *fiber.Ctx) error {
 		user.OAuthLinks[i].AccessToken = ""
 		user.OAuthLinks[i].RefreshToken = ""
 	}
+	h.applyGravatarFallback(user)
 
 	return c.JSON(utils.Response{
 		Success: true,
@@ -40,14 +43,12 @@ func GetMe(c *fiber.Ctx) error {
 }
 
 // GetOAuthAccounts returns the user's linked OAuth accounts
-func GetOAuthAccounts(c *fiber.Ctx) error {
+func (h *Handler) GetOAuthAccounts(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
 	claims := token.Claims.(*utils.JWTClaims)
 
-	db := database.GetInstance()
-
-	var oauthAccounts []models.OAuthAccount
-	if err := db.Where("user_id = ?", claims.Subject).Find(&oauthAccounts).Error; err != nil {
+	oauthAccounts, err := h.stores.OAuth().ListByUser(claims.Subject)
+	if err != nil {
 		return fiber.NewError(500, "Failed to fetch OAuth accounts")
 	}
 
@@ -66,7 +67,7 @@ func GetOAuthAccounts(c *fiber.Ctx) error {
 }
 
 // UnlinkOAuthAccount removes an OAuth provider link from the user's account
-func UnlinkOAuthAccount(c *fiber.Ctx) error {
+func (h *Handler) UnlinkOAuthAccount(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
 	claims := token.Claims.(*utils.JWTClaims)
 	provider := c.Params("provider")
@@ -80,101 +81,127 @@ func UnlinkOAuthAccount(c *fiber.Ctx) error {
 		return fiber.NewError(400, "Invalid OAuth provider")
 	}
 
-	db := database.GetInstance()
+	user, err := h.unlinkOAuthAccount(claims.Subject, oauthProvider)
+	if err != nil {
+		return err
+	}
 
-	// Start transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventOAuthUnlinked,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"method": provider})
 
-	// Check if user exists and get their account type
-	var user models.User
-	if err := tx.First(&user, claims.Subject).Error; err != nil {
-		tx.Rollback()
-		return fiber.NewError(404, "User not found")
-	}
+	h.sendOAuthLinkEmail(user, oauthProvider, c.IP(), false)
 
-	// Find the OAuth account to unlink
-	var oauthAccount models.OAuthAccount
-	err := tx.Where("user_id = ? AND provider = ?", claims.Subject, oauthProvider).First(&oauthAccount).Error
-	if err != nil {
-		tx.Rollback()
-		return fiber.NewError(404, "OAuth account not linked")
-	}
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: fmt.Sprintf("%s account unlinked successfully", provider),
+		Data:    nil,
+	})
+}
 
-	// Check business rules for unlinking
-	if user.AccountType == models.AccountTypeOAuth {
-		// Count remaining OAuth links
-		var oauthCount int64
-		tx.Model(&models.OAuthAccount{}).Where("user_id = ?", claims.Subject).Count(&oauthCount)
+// unlinkOAuthAccount deletes the user's link to provider, refusing to remove
+// the last way an OAuth-only account can sign in. Hybrid accounts left with
+// no links become email accounts.
+func (h *Handler) unlinkOAuthAccount(userID uint, provider models.OAuthProvider) (*models.User, error) {
+	var user *models.User
+
+	err := h.stores.Transaction(func(tx store.Store) error {
+		// Check if user exists and get their account type
+		var err error
+		if user, err = tx.Users().ByID(userID); err != nil {
+			return fiber.NewError(404, "User not found")
+		}
 
-		if oauthCount <= 1 {
-			tx.Rollback()
-			return fiber.NewError(400, "Cannot unlink the only authentication method. Please set a password first or link another OAuth account.")
+		// Find the OAuth account to unlink
+		oauthAccount, err := tx.OAuth().ByUser(userID, provider)
+		if err != nil {
+			return fiber.NewError(404, "OAuth account not linked")
 		}
-	}
 
-	// Delete the OAuth account
-	if err := tx.Delete(&oauthAccount).Error; err != nil {
-		tx.Rollback()
-		return fiber.NewError(500, "Failed to unlink OAuth account")
-	}
+		// Check business rules for unlinking
+		if user.AccountType == models.AccountTypeOAuth {
+			oauthCount, _ := tx.OAuth().CountByUser(userID)
 
-	// Update user account type if necessary
-	if user.AccountType == models.AccountTypeHybrid {
-		var remainingOAuthCount int64
-		tx.Model(&models.OAuthAccount{}).Where("user_id = ?", claims.Subject).Count(&remainingOAuthCount)
+			if oauthCount <= 1 {
+				return fiber.NewError(400, "Cannot unlink the only authentication method. Please set a password first or link another OAuth account.")
+			}
+		}
 
-		if remainingOAuthCount == 0 && user.Password != "" {
-			// No more OAuth accounts but has password - revert to email type
-			user.AccountType = models.AccountTypeEmail
-			tx.Save(&user)
+		if err := tx.OAuth().Delete(oauthAccount); err != nil {
+			return fiber.NewError(500, "Failed to unlink OAuth account")
 		}
-	}
 
-	tx.Commit()
+		// Update user account type if necessary
+		if user.AccountType == models.AccountTypeHybrid {
+			remainingOAuthCount, _ := tx.OAuth().CountByUser(userID)
 
-	return c.JSON(utils.Response{
-		Success: true,
-		Code:    200,
-		Message: fmt.Sprintf("%s account unlinked successfully", provider),
-		Data:    nil,
+			if remainingOAuthCount == 0 && user.Password != "" {
+				// No more OAuth accounts but has password - revert to email type
+				return tx.Users().Update(user, map[string]interface{}{"account_type": models.AccountTypeEmail})
+			}
+		}
+
+		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
 }
 
 // UpdateProfileRequest represents the request body for updating user profile
 type UpdateProfileRequest struct {
-	Username string          `json:"username,omitempty"`
-	Currency models.Currency `json:"currency,omitempty"`
-	Timezone models.Timezone `json:"timezone,omitempty"`
+	Username    string          `json:"username,omitempty"`
+	FirstName   *string         `json:"first_name,omitempty"` // An empty string clears the field
+	LastName    *string         `json:"last_name,omitempty"`
+	DisplayName *string         `json:"display_name,omitempty"`
+	Bio         *string         `json:"bio,omitempty"`
+	Currency    models.Currency `json:"currency,omitempty"`
+	Timezone    models.Timezone `json:"timezone,omitempty"`
+	Locale      models.Locale   `json:"locale,omitempty"`
+}
+
+// profileTextFields lists the free-text profile fields with their maximum
+// length in characters.
+var profileTextFields = []struct {
+	column    string
+	label     string
+	maxLength int
+	multiline bool
+}{
+	{"first_name", "First name", 100, false},
+	{"last_name", "Last name", 100, false},
+	{"display_name", "Display name", 100, false},
+	{"bio", "Bio", 500, true},
 }
 
 // UpdateProfile updates the authenticated user's profile information
-func UpdateProfile(c *fiber.Ctx) error {
+func (h *Handler) UpdateProfile(c *fiber.Ctx) error {
 	token := c.Locals("user").(*jwt.Token)
 	claims := token.Claims.(*utils.JWTClaims)
 
 	var req UpdateProfileRequest
-	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(400, "Invalid request body")
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
 	}
 
-	db := database.GetInstance()
-
 	// Start transaction
-	tx := db.Begin()
+	tx := h.db.Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
+	st := store.NewGorm(tx)
+
 	// Get current user
-	var user models.User
-	if err := tx.First(&user, claims.Subject).Error; err != nil {
+	user, err := st.Users().ByID(claims.Subject)
+	if err != nil {
 		tx.Rollback()
 		return fiber.NewError(404, "User not found")
 	}
@@ -184,34 +211,63 @@ func UpdateProfile(c *fiber.Ctx) error {
 
 	// Username validation and update
 	if req.Username != "" {
-		if len(req.Username) < 3 {
+		req.Username = h.usernamePolicy.Normalize(req.Username)
+	}
+	if req.Username != "" && req.Username == user.Username {
+		updates["username"] = req.Username
+	} else if req.Username != "" {
+		if err := h.usernamePolicy.Validate(req.Username); err != nil {
 			tx.Rollback()
-			return fiber.NewError(400, "Username must be at least 3 characters long")
+			return fiber.NewError(400, err.Error())
 		}
-		if len(req.Username) > 255 {
+		if err := h.checkUsernameChangeAllowed(tx, user.ID); err != nil {
 			tx.Rollback()
-			return fiber.NewError(400, "Username must be less than 255 characters")
+			return err
 		}
 
 		// Check if username is already taken by another user
-		var count int64
-		if err := tx.Model(&models.User{}).Where("username = ? AND id != ?", req.Username, user.ID).Count(&count).Error; err != nil {
+		taken, err := st.Users().UsernameTaken(user.TenantID, req.Username)
+		if err != nil {
 			tx.Rollback()
 			return fiber.NewError(500, "Database error checking username")
 		}
-		if count > 0 {
+		reserved, err := h.usernameReserved(st.Users(), user.TenantID, req.Username, user.ID)
+		if err != nil {
+			tx.Rollback()
+			return fiber.NewError(500, "Database error checking username")
+		}
+		if taken || reserved {
 			tx.Rollback()
 			return fiber.NewError(409, "Username already taken")
 		}
 
+		if err := recordUsernameChange(tx, user.ID, user.Username); err != nil {
+			tx.Rollback()
+			return fiber.NewError(500, "Failed to record username change")
+		}
 		updates["username"] = req.Username
 	}
 
+	// Free-text fields; nil leaves the field unchanged
+	for i, value := range []*string{req.FirstName, req.LastName, req.DisplayName, req.Bio} {
+		if value == nil {
+			continue
+		}
+		field := profileTextFields[i]
+		text, err := normalizeProfileText(*value, field.maxLength, field.multiline)
+		if err != nil {
+			tx.Rollback()
+			return fiber.NewError(400, field.label+" "+err.Error())
+		}
+		updates[field.column] = text
+	}
+
 	// Currency validation and update
 	if req.Currency != "" {
-		if !isValidCurrency(req.Currency) {
+		req.Currency = models.Currency(strings.ToLower(strings.TrimSpace(string(req.Currency))))
+		if !h.isValidCurrency(req.Currency) {
 			tx.Rollback()
-			return fiber.NewError(400, "Invalid currency. Supported currencies: ron, eur, gbp, usd")
+			return fiber.NewError(400, "Invalid currency. See GET /user/profile/options for the supported currencies")
 		}
 		updates["currency"] = req.Currency
 	}
@@ -225,6 +281,15 @@ func UpdateProfile(c *fiber.Ctx) error {
 		updates["timezone"] = req.Timezone
 	}
 
+	// Locale validation and update
+	if req.Locale != "" {
+		if !isValidLocale(req.Locale) {
+			tx.Rollback()
+			return fiber.NewError(400, "Invalid locale. Supported locales: en, ro, de")
+		}
+		updates["locale"] = req.Locale
+	}
+
 	// Check if there are any updates to apply
 	if len(updates) == 0 {
 		tx.Rollback()
@@ -232,25 +297,42 @@ func UpdateProfile(c *fiber.Ctx) error {
 	}
 
 	// Apply updates
-	if err := tx.Model(&user).Updates(updates).Error; err != nil {
+	if err := st.Users().Update(user, updates); err != nil {
 		tx.Rollback()
 		return fiber.NewError(500, "Failed to update profile")
 	}
 
 	// Reload user with updated data
-	if err := tx.Preload("OAuthLinks").First(&user, claims.Subject).Error; err != nil {
+	if user, err = st.Users().ByID(claims.Subject); err != nil {
+		tx.Rollback()
+		return fiber.NewError(500, "Failed to reload user data")
+	}
+	if user.OAuthLinks, err = st.OAuth().ListByUser(user.ID); err != nil {
 		tx.Rollback()
 		return fiber.NewError(500, "Failed to reload user data")
 	}
 
 	tx.Commit()
 
+	fields := make([]string, 0, len(updates))
+	for field := range updates {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventProfileUpdated,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"fields": fields})
+
 	// Sanitize sensitive fields
 	user.Password = ""
 	for i := range user.OAuthLinks {
 		user.OAuthLinks[i].AccessToken = ""
 		user.OAuthLinks[i].RefreshToken = ""
 	}
+	h.applyGravatarFallback(user)
 
 	return c.JSON(utils.Response{
 		Success: true,
@@ -260,85 +342,64 @@ func UpdateProfile(c *fiber.Ctx) error {
 	})
 }
 
-// GetProfileOptions returns available currencies and timezones
-func GetProfileOptions(c *fiber.Ctx) error {
-	currencies := []models.Currency{
-		models.CurrencyRON,
-		models.CurrencyEUR,
-		models.CurrencyGBP,
-		models.CurrencyUSD,
-	}
-
-	timezones := []models.Timezone{
-		models.TimezoneUTC,
-		models.TimezoneEuropeAmsterdam,
-		models.TimezoneEuropeBerlin,
-		models.TimezoneEuropeBucharest,
-		models.TimezoneEuropeLondon,
-		models.TimezoneEuropeParis,
-		models.TimezoneEuropeRome,
-		models.TimezoneAmericaNewYork,
-		models.TimezoneAmericaChicago,
-		models.TimezoneAmericaDenver,
-		models.TimezoneAmericaLosAngeles,
-		models.TimezoneAsiaShanghai,
-		models.TimezoneAsiaTokyo,
-		models.TimezoneAsiaKolkata,
-		models.TimezoneAustraliaSydney,
-	}
-
+// GetProfileOptions returns available currencies, timezones grouped by
+// region and locales
+func (h *Handler) GetProfileOptions(c *fiber.Ctx) error {
 	return c.JSON(utils.Response{
 		Success: true,
 		Code:    200,
 		Message: "Profile options retrieved successfully",
 		Data: fiber.Map{
-			"currencies": currencies,
-			"timezones":  timezones,
+			"currencies": h.enabledCurrencyList(),
+			"timezones":  utils.TimezonesByRegion(),
+			"locales":    supportedLocales,
 		},
 	})
 }
 
 // Helper functions for validation
 
-func isValidCurrency(currency models.Currency) bool {
-	validCurrencies := []models.Currency{
-		models.CurrencyRON,
-		models.CurrencyEUR,
-		models.CurrencyGBP,
-		models.CurrencyUSD,
+// normalizeProfileText trims value and checks that it is valid UTF-8 of at
+// most maxLength characters without control characters. Line breaks are
+// allowed when multiline is set.
+func normalizeProfileText(value string, maxLength int, multiline bool) (string, error) {
+	value = strings.TrimSpace(value)
+	if !utf8.ValidString(value) {
+		return "", fmt.Errorf("must be valid UTF-8")
 	}
-
-	for _, valid := range validCurrencies {
-		if currency == valid {
-			return true
+	if utf8.RuneCountInString(value) > maxLength {
+		return "", fmt.Errorf("must be at most %d characters", maxLength)
+	}
+	for _, r := range value {
+		if multiline && (r == '\n' || r == '\r') {
+			continue
+		}
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("must not contain control characters")
 		}
 	}
-	return false
+	return value, nil
+}
+
+// isValidCurrency accepts the enabled currencies of the catalog.
+func (h *Handler) isValidCurrency(currency models.Currency) bool {
+	return h.currencyEnabled(string(currency))
 }
 
+// isValidTimezone accepts any IANA zone in the embedded tzdata.
 func isValidTimezone(timezone models.Timezone) bool {
-	validTimezones := []models.Timezone{
-		models.TimezoneUTC,
-		models.TimezoneEuropeAmsterdam,
-		models.TimezoneEuropeBerlin,
-		models.TimezoneEuropeBucharest,
-		models.TimezoneEuropeLondon,
-		models.TimezoneEuropeParis,
-		models.TimezoneEuropeRome,
-		models.TimezoneAmericaNewYork,
-		models.TimezoneAmericaChicago,
-		models.TimezoneAmericaDenver,
-		models.TimezoneAmericaLosAngeles,
-		models.TimezoneAsiaShanghai,
-		models.TimezoneAsiaTokyo,
-		models.TimezoneAsiaKolkata,
-		models.TimezoneAustraliaSydney,
-	}
+	return utils.ValidTimezone(string(timezone))
+}
 
-	for _, valid := range validTimezones {
-		if timezone == valid {
-			return true
-		}
-	}
-	return false
+// supportedLocales lists the locales users can pick.
+var supportedLocales = models.SupportedLocales
+
+func isValidLocale(locale models.Locale) bool {
+	return slices.Contains(supportedLocales, locale)
+}
+
+// requestLocale picks the supported locale that best matches the request's
+// Accept-Language header, defaulting to English.
+func requestLocale(c *fiber.Ctx) models.Locale {
+	return utils.NegotiateLocale(c.Get(fiber.HeaderAcceptLanguage))
 }
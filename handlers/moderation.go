@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/service"
+	"api/store"
+	"api/utils"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// checkAccountRestriction returns a 403 ReasonError if the user is banned or
+// currently suspended.
+func checkAccountRestriction(user *models.User) error {
+	return serviceError(service.CheckRestriction(user))
+}
+
+// BanUserRequest represents the request body for banning a user
+type BanUserRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// SuspendUserRequest represents the request body for suspending a user.
+// Exactly one of Until and Duration must be provided.
+type SuspendUserRequest struct {
+	Until    string `json:"until,omitempty"`    // RFC3339 timestamp
+	Duration string `json:"duration,omitempty"` // Go duration, e.g. "72h"
+	Reason   string `json:"reason,omitempty"`
+}
+
+// AdminBanUser bans a user indefinitely and revokes all of their sessions.
+func (h *Handler) AdminBanUser(c *fiber.Ctx) error {
+	var req BanUserRequest
+	// The body is optional
+	if len(c.Body()) > 0 {
+		if err := h.parseJSONBody(c, &req); err != nil {
+			return err
+		}
+	}
+
+	return h.restrictUser(c, models.AuditEventUserBanned, map[string]interface{}{
+		"banned":             true,
+		"restriction_reason": req.Reason,
+	}, fiber.Map{"reason": req.Reason})
+}
+
+// AdminSuspendUser suspends a user until a given time and revokes all of
+// their sessions.
+func (h *Handler) AdminSuspendUser(c *fiber.Ctx) error {
+	var req SuspendUserRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	var until time.Time
+	switch {
+	case req.Until != "" && req.Duration != "":
+		return fiber.NewError(400, "Provide either until or duration, not both")
+	case req.Until != "":
+		t, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			return fiber.NewError(400, "Invalid until. Expected RFC3339 timestamp")
+		}
+		until = t
+	case req.Duration != "":
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			return fiber.NewError(400, "Invalid duration. Expected a positive duration such as 72h")
+		}
+		until = time.Now().Add(d)
+	default:
+		return fiber.NewError(400, "Either until or duration is required")
+	}
+
+	if !until.After(time.Now()) {
+		return fiber.NewError(400, "Suspension must end in the future")
+	}
+
+	return h.restrictUser(c, models.AuditEventUserSuspended, map[string]interface{}{
+		"suspended_until":    until,
+		"restriction_reason": req.Reason,
+	}, fiber.Map{"reason": req.Reason, "suspended_until": until})
+}
+
+// AdminUnbanUser lifts a user's ban.
+func (h *Handler) AdminUnbanUser(c *fiber.Ctx) error {
+	return h.liftRestriction(c, models.AuditEventUserUnbanned, map[string]interface{}{
+		"banned": false,
+	})
+}
+
+// AdminUnsuspendUser lifts a user's suspension.
+func (h *Handler) AdminUnsuspendUser(c *fiber.Ctx) error {
+	return h.liftRestriction(c, models.AuditEventUserUnsuspended, map[string]interface{}{
+		"suspended_until": nil,
+	})
+}
+
+// restrictUser applies the moderation updates to the user in :id and revokes
+// their sessions in the same transaction so the restriction is immediate.
+func (h *Handler) restrictUser(c *fiber.Ctx, event models.AuditEvent, updates map[string]interface{}, metadata fiber.Map) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+	if uint(userID) == claims.Subject {
+		return fiber.NewError(400, "You cannot restrict your own account")
+	}
+
+	var revoked int64
+	err = h.stores.Transaction(func(tx store.Store) error {
+		found, err := tx.Users().UpdateByID(uint(userID), updates)
+		if err != nil {
+			return fmt.Errorf("failed to restrict user: %w", err)
+		}
+		if !found {
+			return fiber.NewError(404, "User not found")
+		}
+
+		revoked, err = revokeUserSessions(tx, uint(userID))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	target := uint(userID)
+	h.sessionsRevoked(target, revoked, "account_restricted")
+	metadata["revoked_sessions"] = revoked
+	h.recordAuditEvent(models.AuditLog{
+		Event:     event,
+		UserID:    &target,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, metadata)
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "User restricted",
+		Data: fiber.Map{
+			"revoked_sessions": revoked,
+		},
+	})
+}
+
+// liftRestriction applies the moderation updates to the user in :id. The
+// restriction reason is cleared once neither a ban nor a suspension remains.
+func (h *Handler) liftRestriction(c *fiber.Ctx, event models.AuditEvent, updates map[string]interface{}) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	found, err := h.stores.Users().UpdateByID(uint(userID), updates)
+	if err != nil {
+		return fmt.Errorf("failed to lift restriction: %w", err)
+	}
+	if !found {
+		return fiber.NewError(404, "User not found")
+	}
+
+	h.stores.Users().ClearLiftedRestriction(uint(userID))
+
+	target := uint(userID)
+	h.recordAuditEvent(models.AuditLog{
+		Event:     event,
+		UserID:    &target,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, nil)
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Restriction lifted",
+		Data:    nil,
+	})
+}
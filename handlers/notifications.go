@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Notification categories users can turn off.
+const (
+	notificationSecurityAlerts     = "security_alerts"
+	notificationLoginNotifications = "login_notifications"
+	notificationProductEmails      = "product_emails"
+)
+
+// emailNotificationCategories maps optional email templates to the preference
+// that controls them. Templates not listed are always sent.
+var emailNotificationCategories = map[string]string{
+	utils.EmailTemplateOAuthLinked:      notificationSecurityAlerts,
+	utils.EmailTemplateOAuthUnlinked:    notificationSecurityAlerts,
+	utils.EmailTemplateImpossibleTravel: notificationLoginNotifications,
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	SecurityAlerts     *bool `json:"security_alerts,omitempty"`
+	LoginNotifications *bool `json:"login_notifications,omitempty"`
+	ProductEmails      *bool `json:"product_emails,omitempty"`
+}
+
+// loadNotificationPreferences returns the user's preferences, or the
+// defaults when they never changed them.
+func loadNotificationPreferences(tx *gorm.DB, userID uint) (models.NotificationPreferences, error) {
+	var prefs models.NotificationPreferences
+	err := tx.First(&prefs, userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultNotificationPreferences(userID), nil
+	}
+	return prefs, err
+}
+
+// notificationEnabled reports whether the user wants emails in category.
+func (h *Handler) notificationEnabled(userID uint, category string) (bool, error) {
+	prefs, err := loadNotificationPreferences(h.db, userID)
+	if err != nil {
+		return false, fmt.Errorf("load notification preferences: %w", err)
+	}
+
+	switch category {
+	case notificationSecurityAlerts:
+		return prefs.SecurityAlerts, nil
+	case notificationLoginNotifications:
+		return prefs.LoginNotifications, nil
+	case notificationProductEmails:
+		return prefs.ProductEmails, nil
+	default:
+		return true, nil
+	}
+}
+
+// GetNotificationPreferences returns the authenticated user's notification
+// preferences.
+func (h *Handler) GetNotificationPreferences(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	prefs, err := loadNotificationPreferences(h.db, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("failed to load notification preferences: %w", err)
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    prefs,
+	})
+}
+
+// UpdateNotificationPreferences changes the given preferences; omitted ones
+// keep their current values.
+func (h *Handler) UpdateNotificationPreferences(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var req UpdateNotificationPreferencesRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+	if req.SecurityAlerts == nil && req.LoginNotifications == nil && req.ProductEmails == nil {
+		return fiber.NewError(400, "No valid fields to update")
+	}
+
+	var prefs models.NotificationPreferences
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Lock the user row so concurrent updates apply one after the other
+		user, err := store.NewGorm(tx).Users().LockByID(claims.Subject)
+		if err != nil {
+			return fiber.NewError(404, "User not found")
+		}
+
+		if prefs, err = loadNotificationPreferences(tx, user.ID); err != nil {
+			return err
+		}
+		if req.SecurityAlerts != nil {
+			prefs.SecurityAlerts = *req.SecurityAlerts
+		}
+		if req.LoginNotifications != nil {
+			prefs.LoginNotifications = *req.LoginNotifications
+		}
+		if req.ProductEmails != nil {
+			prefs.ProductEmails = *req.ProductEmails
+		}
+		return tx.Save(&prefs).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Notification preferences updated",
+		Data:    prefs,
+	})
+}
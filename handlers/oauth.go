@@ -2,20 +2,23 @@ package handlers
 
 import (
 	"api/database/models"
+	"api/middleware"
+	"api/service"
 	"api/utils"
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/oauth2"
-	"gorm.io/gorm"
 )
 
 // OAuthInitiateRequest represents the request to initiate OAuth flow
 type OAuthInitiateRequest struct {
-	Provider    string `json:"provider"`
+	Provider    string `json:"provider" validate:"required"`
 	RedirectURL string `json:"redirect_url,omitempty"`
 }
 
@@ -27,10 +30,10 @@ type OAuthCallbackQuery struct {
 }
 
 // OAuthInitiate starts the OAuth flow for a given provider
-func OAuthInitiate(c *fiber.Ctx) error {
+func (h *Handler) OAuthInitiate(c *fiber.Ctx) error {
 	var req OAuthInitiateRequest
-	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(400, "Invalid request body")
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
 	}
 
 	// Validate provider
@@ -38,6 +41,9 @@ func OAuthInitiate(c *fiber.Ctx) error {
 	if provider != models.OAuthProviderGoogle && provider != models.OAuthProviderGithub {
 		return fiber.NewError(400, "Unsupported OAuth provider")
 	}
+	if !h.oauthProviderEnabled(provider) {
+		return fiber.NewError(403, "Sign-in with this provider is disabled")
+	}
 
 	// Get OAuth config
 	config, err := utils.GetOAuthConfig(provider)
@@ -60,6 +66,7 @@ func OAuthInitiate(c *fiber.Ctx) error {
 	oauthState := models.OAuthState{
 		State:       state,
 		Provider:    provider,
+		TenantID:    middleware.TenantID(c),
 		Nonce:       nonce,
 		RedirectURL: req.RedirectURL,
 		UserAgent:   c.Get("User-Agent"),
@@ -67,7 +74,7 @@ func OAuthInitiate(c *fiber.Ctx) error {
 		ExpiresAt:   time.Now().Add(10 * time.Minute), // 10-minute expiry
 	}
 
-	if err := db.Create(&oauthState).Error; err != nil {
+	if err := h.stores.OAuth().CreateState(&oauthState); err != nil {
 		return fiber.NewError(500, "Failed to store OAuth state")
 	}
 
@@ -86,11 +93,14 @@ func OAuthInitiate(c *fiber.Ctx) error {
 }
 
 // OAuthCallback handles OAuth provider callbacks
-func OAuthCallback(c *fiber.Ctx) error {
+func (h *Handler) OAuthCallback(c *fiber.Ctx) error {
 	provider := models.OAuthProvider(c.Params("provider"))
 	if provider != models.OAuthProviderGoogle && provider != models.OAuthProviderGithub {
 		return fiber.NewError(400, "Invalid OAuth provider")
 	}
+	if !h.oauthProviderEnabled(provider) {
+		return fiber.NewError(403, "Sign-in with this provider is disabled")
+	}
 
 	var query OAuthCallbackQuery
 	if err := c.QueryParser(&query); err != nil {
@@ -107,9 +117,7 @@ func OAuthCallback(c *fiber.Ctx) error {
 	}
 
 	// Validate and retrieve OAuth state
-	var oauthState models.OAuthState
-	err := db.Where("state = ? AND provider = ? AND expires_at > ?",
-		query.State, provider, time.Now()).First(&oauthState).Error
+	oauthState, err := h.stores.OAuth().ValidState(query.State, provider)
 	if err != nil {
 		return fiber.NewError(400, "Invalid or expired OAuth state")
 	}
@@ -121,7 +129,7 @@ func OAuthCallback(c *fiber.Ctx) error {
 	}
 
 	// Clean up used state
-	db.Delete(&oauthState)
+	h.stores.OAuth().DeleteState(oauthState)
 
 	// Exchange code for token
 	config, err := utils.GetOAuthConfig(provider)
@@ -138,14 +146,14 @@ func OAuthCallback(c *fiber.Ctx) error {
 	}
 
 	// Fetch user info from OAuth provider
-	var userInfo OAuthUserInfo
+	var userInfo service.OAuthUserInfo
 	switch provider {
 	case models.OAuthProviderGoogle:
 		googleInfo, err := utils.FetchGoogleUserInfo(ctx, token)
 		if err != nil {
 			return fiber.NewError(400, fmt.Sprintf("Failed to fetch Google user info: %v", err))
 		}
-		userInfo = OAuthUserInfo{
+		userInfo = service.OAuthUserInfo{
 			ID:        googleInfo.ID,
 			Email:     googleInfo.Email,
 			Name:      googleInfo.Name,
@@ -156,7 +164,7 @@ func OAuthCallback(c *fiber.Ctx) error {
 		if err != nil {
 			return fiber.NewError(400, fmt.Sprintf("Failed to fetch GitHub user info: %v", err))
 		}
-		userInfo = OAuthUserInfo{
+		userInfo = service.OAuthUserInfo{
 			ID:        fmt.Sprintf("%d", githubInfo.ID),
 			Email:     githubInfo.Email,
 			Name:      githubInfo.Name,
@@ -164,394 +172,126 @@ func OAuthCallback(c *fiber.Ctx) error {
 		}
 	}
 
-	// Process OAuth login/registration
-	result, err := processOAuthLogin(provider, userInfo, token)
-	if err != nil {
-		return err
-	}
-
-	return c.JSON(result)
-}
-
-// OAuthUserInfo represents normalized user information from OAuth providers
-type OAuthUserInfo struct {
-	ID        string
-	Email     string
-	Name      string
-	AvatarURL string
-}
-
-// OAuthLoginResult represents the result of OAuth login processing
-type OAuthLoginResult struct {
-	Success     bool   `json:"success"`
-	Code        int    `json:"code"`
-	Message     string `json:"message"`
-	Action      string `json:"action"` // "login", "register", "link_required"
-	Token       string `json:"token,omitempty"`
-	RedirectURL string `json:"redirect_url,omitempty"`
-}
-
-// processOAuthLogin implements the enterprise OAuth flow logic
-func processOAuthLogin(provider models.OAuthProvider, userInfo OAuthUserInfo, token *oauth2.Token) (*utils.Response, error) {
-	// Start database transaction for consistency
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Check if OAuth account already exists
-	var existingOAuth models.OAuthAccount
-	err := tx.Where("provider = ? AND provider_id = ?", provider, userInfo.ID).First(&existingOAuth).Error
-
-	if err == nil {
-		// OAuth account exists - proceed with login
-		return handleExistingOAuthLogin(tx, &existingOAuth, userInfo, token)
-	}
-
-	if err != gorm.ErrRecordNotFound {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Database error during OAuth lookup")
-	}
-
-	// OAuth account doesn't exist - check if email user exists
-	var existingUser models.User
-	err = tx.Where("email = ?", userInfo.Email).First(&existingUser).Error
-
-	if err == gorm.ErrRecordNotFound {
-		// No user with this email - create new OAuth user
-		return handleNewOAuthUser(tx, provider, userInfo, token)
-	}
+	// Providers may return mixed-case addresses; match and store them normalized
+	userInfo.Email = utils.NormalizeEmail(userInfo.Email)
 
-	if err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Database error during user lookup")
-	}
-
-	// User exists with this email
-	switch existingUser.AccountType {
-	case models.AccountTypeEmail:
-		// Email account exists - require explicit linking
-		tx.Rollback()
-		return &utils.Response{
+	// Process OAuth login/registration
+	// The provider redirects back without the tenant header, so sign in to
+	// the tenant the flow was started in
+	client := service.Client{TenantID: oauthState.TenantID, IPAddress: c.IP(), UserAgent: c.Get("User-Agent"), Locale: requestLocale(c)}
+	login, err := h.oauthLogins.Login(provider, userInfo, token, client)
+	if errors.Is(err, service.ErrLinkRequired) {
+		return c.JSON(utils.Response{
 			Success: false,
 			Code:    409,
-			Message: "Account with this email already exists. Please log in with your email and link your OAuth account in settings.",
+			Message: service.ErrLinkRequired.Message,
 			Data: fiber.Map{
 				"action":           "link_required",
 				"existing_account": "email",
 				"provider":         string(provider),
 				"email":            userInfo.Email,
 			},
-		}, nil
-
-	case models.AccountTypeOAuth:
-		// OAuth-only account exists - link new provider
-		return handleOAuthAccountLinking(tx, &existingUser, provider, userInfo, token)
-
-	case models.AccountTypeHybrid:
-		// Hybrid account exists - link new provider
-		return handleOAuthAccountLinking(tx, &existingUser, provider, userInfo, token)
-
-	default:
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Unknown account type")
-	}
-}
-
-// handleExistingOAuthLogin processes login for existing OAuth accounts
-func handleExistingOAuthLogin(tx *gorm.DB, oauthAccount *models.OAuthAccount, userInfo OAuthUserInfo, token *oauth2.Token) (*utils.Response, error) {
-	// Load the associated user
-	var user models.User
-	if err := tx.First(&user, oauthAccount.UserID).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to load user")
-	}
-
-	// Update OAuth account with latest info
-	encryptedAccess, _ := utils.EncryptToken(token.AccessToken)
-	encryptedRefresh, _ := utils.EncryptToken(token.RefreshToken)
-
-	updates := map[string]interface{}{
-		"email":         userInfo.Email,
-		"name":          userInfo.Name,
-		"avatar_url":    userInfo.AvatarURL,
-		"access_token":  encryptedAccess,
-		"refresh_token": encryptedRefresh,
-		"token_expiry":  token.Expiry,
-		"last_used_at":  time.Now(),
-	}
-
-	if err := tx.Model(oauthAccount).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to update OAuth account")
+		})
 	}
-
-	// Create JWT session
-	jti, jwt, err := utils.GetSignedKey(user.ID)
 	if err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to generate JWT")
-	}
-
-	_, hashedToken := utils.GenerateRefreshToken()
-	session := models.Session{
-		JTI:          jti,
-		UserID:       user.ID,
-		RefreshToken: hashedToken,
-		Revoked:      false,
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour),
+		return serviceError(err)
 	}
 
-	if err := tx.Create(&session).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to create session")
-	}
-
-	tx.Commit()
-
-	return &utils.Response{
-		Success: true,
-		Code:    200,
-		Message: fmt.Sprintf("Logged in successfully with %s", string(oauthAccount.Provider)),
-		Data: fiber.Map{
-			"action": "login",
-			"token":  jwt,
-			"user": fiber.Map{
-				"id":           user.ID,
-				"username":     user.Username,
-				"email":        user.Email,
-				"account_type": user.AccountType,
-			},
-		},
-	}, nil
-}
-
-// handleNewOAuthUser creates a new OAuth-only user account
-func handleNewOAuthUser(tx *gorm.DB, provider models.OAuthProvider, userInfo OAuthUserInfo, token *oauth2.Token) (*utils.Response, error) {
-	// Generate username from email or name
-	username := generateUsernameFromOAuth(userInfo)
-
-	// Ensure username uniqueness
-	username, err := ensureUniqueUsername(tx, username)
+	// The session is issued like after a password login, under the session
+	// policy's lifetime and cap and bound to the TLS client
+	accessToken, csrfToken, err := h.issueSession(c, h.stores, login.User.ID)
 	if err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to generate unique username")
-	}
-
-	// Create new OAuth user
-	user := models.User{
-		Username:    username,
-		Email:       userInfo.Email,
-		AccountType: models.AccountTypeOAuth,
-		// Password is null for OAuth-only accounts
-	}
-
-	if err := tx.Create(&user).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(400, "Failed to create user account")
-	}
-
-	// Create OAuth account record
-	encryptedAccess, _ := utils.EncryptToken(token.AccessToken)
-	encryptedRefresh, _ := utils.EncryptToken(token.RefreshToken)
-
-	// Extract scopes safely
-	scopes := ""
-	if scopeValue := token.Extra("scope"); scopeValue != nil {
-		if scopeStr, ok := scopeValue.(string); ok {
-			scopes = scopeStr
-		}
-	}
-
-	oauthAccount := models.OAuthAccount{
-		UserID:       user.ID,
-		Provider:     provider,
-		ProviderID:   userInfo.ID,
-		Email:        userInfo.Email,
-		Name:         userInfo.Name,
-		AvatarURL:    userInfo.AvatarURL,
-		AccessToken:  encryptedAccess,
-		RefreshToken: encryptedRefresh,
-		TokenExpiry:  &token.Expiry,
-		Scopes:       scopes,
-		LinkedAt:     time.Now(),
-		LastUsedAt:   &[]time.Time{time.Now()}[0],
+		return err
 	}
 
-	if err := tx.Create(&oauthAccount).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to create OAuth account")
+	// Record and notify only once the sign-in has been committed
+	if login.Linked {
+		h.recordOAuthAuditEvent(models.AuditEventOAuthLinked, login.User.ID, provider, client)
+		h.publishEvent(models.WebhookEventOAuthLinked, fiber.Map{
+			"user_id":  login.User.ID,
+			"provider": provider,
+		})
 	}
-
-	// Create JWT session
-	jti, jwt, err := utils.GetSignedKey(user.ID)
-	if err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to generate JWT")
+	if login.Action == "register" {
+		h.publishEvent(models.WebhookEventUserCreated, fiber.Map{
+			"user_id":  login.User.ID,
+			"username": login.User.Username,
+			"method":   string(provider),
+		})
 	}
-
-	_, hashedToken := utils.GenerateRefreshToken()
-	session := models.Session{
-		JTI:          jti,
-		UserID:       user.ID,
-		RefreshToken: hashedToken,
-		Revoked:      false,
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour),
+	h.recordOAuthAuditEvent(models.AuditEventLoginSucceeded, login.User.ID, provider, client)
+	if login.Linked {
+		h.sendOAuthLinkEmail(login.User, provider, client.IPAddress, true)
 	}
 
-	if err := tx.Create(&session).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to create session")
+	code, message := uint(200), fmt.Sprintf("Logged in successfully with %s", string(provider))
+	switch {
+	case login.Action == "register":
+		code, message = 201, fmt.Sprintf("Account created successfully with %s", string(provider))
+	case login.Linked:
+		message = fmt.Sprintf("%s account linked and logged in successfully", string(provider))
 	}
 
-	tx.Commit()
-
-	return &utils.Response{
+	return c.JSON(utils.Response{
 		Success: true,
-		Code:    201,
-		Message: fmt.Sprintf("Account created successfully with %s", string(provider)),
+		Code:    code,
+		Message: message,
 		Data: fiber.Map{
-			"action": "register",
-			"token":  jwt,
+			"action":     login.Action,
+			"token":      accessToken,
+			"csrf_token": csrfToken,
 			"user": fiber.Map{
-				"id":           user.ID,
-				"username":     user.Username,
-				"email":        user.Email,
-				"account_type": user.AccountType,
+				"id":           login.User.ID,
+				"username":     login.User.Username,
+				"email":        login.User.Email,
+				"account_type": login.User.AccountType,
 			},
 		},
-	}, nil
+	})
 }
 
-// handleOAuthAccountLinking links a new OAuth provider to existing user
-func handleOAuthAccountLinking(tx *gorm.DB, user *models.User, provider models.OAuthProvider, userInfo OAuthUserInfo, token *oauth2.Token) (*utils.Response, error) {
-	// Check if this provider is already linked
-	var existingLink models.OAuthAccount
-	err := tx.Where("user_id = ? AND provider = ?", user.ID, provider).First(&existingLink).Error
-	if err == nil {
-		tx.Rollback()
-		return nil, fiber.NewError(409, fmt.Sprintf("%s account already linked to this user", string(provider)))
-	}
-
-	if err != gorm.ErrRecordNotFound {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Database error checking existing OAuth links")
-	}
-
-	// Create new OAuth account link
-	encryptedAccess, _ := utils.EncryptToken(token.AccessToken)
-	encryptedRefresh, _ := utils.EncryptToken(token.RefreshToken)
-
-	oauthAccount := models.OAuthAccount{
-		UserID:       user.ID,
-		Provider:     provider,
-		ProviderID:   userInfo.ID,
-		Email:        userInfo.Email,
-		Name:         userInfo.Name,
-		AvatarURL:    userInfo.AvatarURL,
-		AccessToken:  encryptedAccess,
-		RefreshToken: encryptedRefresh,
-		TokenExpiry:  &token.Expiry,
-		LinkedAt:     time.Now(),
-		LastUsedAt:   &[]time.Time{time.Now()}[0],
-	}
-
-	if err := tx.Create(&oauthAccount).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to link OAuth account")
-	}
-
-	// Update user account type to hybrid if it was OAuth-only
-	if user.AccountType == models.AccountTypeOAuth {
-		user.AccountType = models.AccountTypeHybrid
-		if err := tx.Save(user).Error; err != nil {
-			tx.Rollback()
-			return nil, fiber.NewError(500, "Failed to update account type")
-		}
-	}
-
-	// Create JWT session
-	jti, jwt, err := utils.GetSignedKey(user.ID)
-	if err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to generate JWT")
-	}
-
-	_, hashedToken := utils.GenerateRefreshToken()
-	session := models.Session{
-		JTI:          jti,
-		UserID:       user.ID,
-		RefreshToken: hashedToken,
-		Revoked:      false,
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour),
-	}
-
-	if err := tx.Create(&session).Error; err != nil {
-		tx.Rollback()
-		return nil, fiber.NewError(500, "Failed to create session")
-	}
-
-	tx.Commit()
-
-	return &utils.Response{
-		Success: true,
-		Code:    200,
-		Message: fmt.Sprintf("%s account linked and logged in successfully", string(provider)),
-		Data: fiber.Map{
-			"action": "login",
-			"token":  jwt,
-			"user": fiber.Map{
-				"id":           user.ID,
-				"username":     user.Username,
-				"email":        user.Email,
-				"account_type": user.AccountType,
-			},
-		},
-	}, nil
+// OAuthLoginResult represents the result of OAuth login processing
+type OAuthLoginResult struct {
+	Success     bool   `json:"success"`
+	Code        int    `json:"code"`
+	Message     string `json:"message"`
+	Action      string `json:"action"` // "login", "register", "link_required"
+	Token       string `json:"token,omitempty"`
+	RedirectURL string `json:"redirect_url,omitempty"`
 }
 
-// Helper functions
-
-func generateUsernameFromOAuth(userInfo OAuthUserInfo) string {
-	// Try to use the part before @ in email
-	if userInfo.Email != "" {
-		parts := strings.Split(userInfo.Email, "@")
-		if len(parts) > 0 && parts[0] != "" {
-			return strings.ToLower(parts[0])
-		}
-	}
-
-	// Fallback to name
-	if userInfo.Name != "" {
-		return strings.ToLower(strings.ReplaceAll(userInfo.Name, " ", "_"))
-	}
-
-	// Final fallback
-	return "user"
+// oauthProviderNames are the provider names shown in emails.
+var oauthProviderNames = map[models.OAuthProvider]string{
+	models.OAuthProviderGoogle: "Google",
+	models.OAuthProviderGithub: "GitHub",
 }
 
-func ensureUniqueUsername(tx *gorm.DB, baseUsername string) (string, error) {
-	username := baseUsername
-	suffix := 1
-
-	for {
-		var count int64
-		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
-			return "", err
-		}
-
-		if count == 0 {
-			return username, nil
-		}
+// recordOAuthAuditEvent records an OAuth sign-in or link for the user's
+// activity feed
+func (h *Handler) recordOAuthAuditEvent(event models.AuditEvent, userID uint, provider models.OAuthProvider, client service.Client) {
+	h.recordAuditEvent(models.AuditLog{
+		Event:     event,
+		UserID:    &userID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	}, fiber.Map{"method": string(provider)})
+}
 
-		username = fmt.Sprintf("%s_%d", baseUsername, suffix)
-		suffix++
+// sendOAuthLinkEmail tells the user a provider was linked to or unlinked
+// from their account, since an unexpected change can mean a takeover.
+// Failures are logged.
+func (h *Handler) sendOAuthLinkEmail(user *models.User, provider models.OAuthProvider, ip string, linked bool) {
+	template := utils.EmailTemplateOAuthUnlinked
+	if linked {
+		template = utils.EmailTemplateOAuthLinked
+	}
 
-		// Prevent infinite loops
-		if suffix > 1000 {
-			return "", fmt.Errorf("unable to generate unique username")
-		}
+	err := h.queueUserEmail(user, template, utils.OAuthLinkEmail{
+		Provider:  oauthProviderNames[provider],
+		IPAddress: ip,
+		ChangedAt: time.Now().UTC().Format(time.RFC1123),
+	})
+	if err != nil {
+		log.Printf("oauth: failed to queue %s email for user %d: %v", template, user.ID, err)
 	}
 }
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"api/store"
+	"api/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const reasonInvalidCursor = "invalid_cursor"
+
+// pageParams are the pagination parameters of a list request: limit and
+// either cursor, from the previous response's meta.next_cursor, or the
+// older page number, which lists that still accept it pass as page.
+type pageParams struct {
+	limit  int
+	after  *utils.Cursor
+	offset int
+}
+
+// parsePage reads the limit and cursor query parameters. A limit outside
+// 1..maxLimit falls back to defaultLimit.
+func parsePage(c *fiber.Ctx, defaultLimit, maxLimit int) (pageParams, error) {
+	p := pageParams{limit: c.QueryInt("limit", defaultLimit)}
+	if p.limit <= 0 || p.limit > maxLimit {
+		p.limit = defaultLimit
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := utils.DecodeCursor(raw)
+		if err != nil {
+			return pageParams{}, utils.NewReasonError(400, reasonInvalidCursor, "Invalid cursor")
+		}
+		p.after = &cursor
+	}
+	return p, nil
+}
+
+// parsePageNumber is parsePage for lists that also accept page, a 1-based
+// page number. It returns the page number, or 0 when a cursor is used or
+// page is not given.
+func parsePageNumber(c *fiber.Ctx, defaultLimit, maxLimit int) (pageParams, int, error) {
+	p, err := parsePage(c, defaultLimit, maxLimit)
+	if err != nil || p.after != nil {
+		return p, 0, err
+	}
+
+	page := c.QueryInt("page")
+	if page <= 0 {
+		return p, 0, nil
+	}
+	p.offset = (page - 1) * p.limit
+	return p, page, nil
+}
+
+// page returns the store page for the parameters. It fetches one row more
+// than the limit, which pageOf uses to tell whether another page follows.
+func (p pageParams) page() store.Page {
+	return store.Page{Limit: p.limit + 1, After: p.after, Offset: p.offset}
+}
+
+// apply orders query newest first by timeColumn and then idColumn and
+// selects the page, for lists of tables without a store.
+func (p pageParams) apply(query *gorm.DB, timeColumn, idColumn string) *gorm.DB {
+	return p.page().Apply(query, timeColumn, idColumn)
+}
+
+// pageOf drops the extra row fetched for the page and returns the page with
+// its metadata. position returns an item's timestamp and ID.
+func pageOf[T any](p pageParams, items []T, position func(T) utils.Cursor) ([]T, *utils.PageInfo) {
+	info := &utils.PageInfo{Limit: p.limit}
+	if len(items) > p.limit {
+		items = items[:p.limit]
+		info.HasMore = true
+		info.NextCursor = position(items[len(items)-1]).Encode()
+	}
+	return items, info
+}
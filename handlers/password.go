@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type SetPasswordProps struct {
+	Password   string `json:"password" validate:"required"`
+	StepUpCode string `json:"step_up_code,omitempty"` // Emailed code, required unless the session signed in recently
+}
+
+type ChangePasswordProps struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	Password        string `json:"password" validate:"required"`
+}
+
+// SetPassword lets an OAuth-only user add a password, turning the account
+// into a hybrid one that can also sign in with email and password. Sessions
+// that did not sign in recently must confirm with an emailed code. Like a
+// password change, every session is revoked and the caller gets a new one.
+func (h *Handler) SetPassword(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var body SetPasswordProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil || user.AnonymizedAt != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	if user.Password != "" {
+		return fiber.NewError(409, "A password is already set. Use PATCH /user/password to change it.")
+	}
+
+	if err := h.policy().Password.Validate(body.Password, user.Username, user.Email); err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	if err := h.checkPasswordReuse(h.stores.Users(), user, body.Password); err != nil {
+		return err
+	}
+
+	err = h.requireRecentLogin(c, claims, body.StepUpCode, func(code string) {
+		h.sendPasswordSetChallenge(user, c.IP(), code)
+	})
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(body.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var accessToken, csrfToken string
+	var revoked int64
+	err = h.stores.Transaction(func(tx store.Store) error {
+		set, err := tx.Users().SetInitialPassword(user.ID, hashedPassword)
+		if err != nil {
+			return fmt.Errorf("failed to set password: %w", err)
+		}
+		if !set {
+			return fiber.NewError(409, "A password is already set. Use PATCH /user/password to change it.")
+		}
+		accessToken, csrfToken, revoked, err = h.rotateSessions(c, tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	h.sessionsRevoked(user.ID, revoked, "password_set")
+
+	h.passwordChanged(c, user, "set")
+
+	// The caller's own session was replaced rather than signed out
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Password set. You can now also log in with your email and password.",
+		Data: fiber.Map{
+			"account_type":     models.AccountTypeHybrid,
+			"token":            accessToken,
+			"csrf_token":       csrfToken,
+			"revoked_sessions": revoked - 1,
+		},
+	})
+}
+
+// sendPasswordSetChallenge emails the code confirming that a password is
+// added to the user's account.
+func (h *Handler) sendPasswordSetChallenge(user *models.User, ip, code string) {
+	err := h.queueUserEmail(user, utils.EmailTemplatePasswordSetChallenge, utils.PasswordSetChallengeEmail{
+		IPAddress: ip,
+		Code:      code,
+		ExpiresIn: time.Duration(h.policy().StepUp.ChallengeTTL),
+	})
+	if err != nil {
+		log.Printf("failed to queue password set verification code: %v", err)
+	}
+}
+
+// ChangePassword replaces the user's password after verifying the current
+// one. Every session is revoked, which also invalidates their access tokens,
+// and the caller gets a new session, so a token or refresh cookie captured
+// before the change is useless afterwards.
+func (h *Handler) ChangePassword(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var body ChangePasswordProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	user, err := h.stores.Users().ByID(claims.Subject)
+	if err != nil || user.AnonymizedAt != nil {
+		return fiber.NewError(404, "User not found")
+	}
+	if user.Password == "" {
+		return fiber.NewError(400, "No password is set. Use POST /user/password to add one.")
+	}
+	if user.PasswordResetRequired {
+		return utils.NewReasonError(403, reasonPasswordResetRequired, "A password reset is required. Check your email for a reset link.")
+	}
+	if !utils.ComparePassword(body.CurrentPassword, user.Password) {
+		return fiber.NewError(401, "Current password is incorrect")
+	}
+
+	if err := h.policy().Password.Validate(body.Password, user.Username, user.Email); err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	if err := h.checkPasswordReuse(h.stores.Users(), user, body.Password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(body.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var accessToken, csrfToken string
+	var revoked int64
+	err = h.stores.Transaction(func(tx store.Store) error {
+		if err := h.rememberPreviousPassword(tx.Users(), user); err != nil {
+			return fmt.Errorf("failed to record password history: %w", err)
+		}
+		if err := tx.Users().Update(user, map[string]any{"password": hashedPassword}); err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+		accessToken, csrfToken, revoked, err = h.rotateSessions(c, tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	h.sessionsRevoked(user.ID, revoked, "password_changed")
+
+	h.passwordChanged(c, user, "change")
+
+	// The caller's own session was replaced rather than signed out
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Password changed. You have been signed out everywhere else.",
+		Data: fiber.Map{
+			"token":            accessToken,
+			"csrf_token":       csrfToken,
+			"revoked_sessions": revoked - 1,
+		},
+	})
+}
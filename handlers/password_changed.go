@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type SecureAccountProps struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// setupPasswordChanged reads PASSWORD_CHANGED_LINK_TTL (default 72h), how
+// long the "this wasn't me" link in the password changed email stays valid.
+func (h *Handler) setupPasswordChanged() {
+	h.secureAccountTTL = envDuration("PASSWORD_CHANGED_LINK_TTL", 72*time.Hour)
+}
+
+// passwordChanged audits a password change and emails the user a notice with
+// the IP and time of the change and a link that revokes every session if the
+// change was not theirs. Failures are logged; the change itself stands.
+func (h *Handler) passwordChanged(c *fiber.Ctx, user *models.User, method string) {
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventPasswordChanged,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"method": method})
+
+	token, hashedToken := utils.GenerateSecureToken()
+	if token == "" {
+		log.Printf("password changed: failed to generate token for user %d", user.ID)
+		return
+	}
+
+	secure := models.AccountSecureToken{
+		UserID:    user.ID,
+		Token:     hashedToken,
+		ExpiresAt: time.Now().Add(h.secureAccountTTL),
+	}
+	if err := h.db.Create(&secure).Error; err != nil {
+		log.Printf("password changed: failed to store token for user %d: %v", user.ID, err)
+		return
+	}
+
+	err := h.queueUserEmail(user, utils.EmailTemplatePasswordChanged, utils.PasswordChangedEmail{
+		IPAddress: c.IP(),
+		ChangedAt: time.Now().UTC().Format(time.RFC1123),
+		SecureURL: fmt.Sprintf("%s/secure-account?token=%s", config.Get().ClientURL, token),
+	})
+	if err != nil {
+		log.Printf("password changed: %v", err)
+	}
+}
+
+// SecureAccount revokes all of the user's sessions using the token from a
+// password changed email, for when the user did not make the change.
+func (h *Handler) SecureAccount(c *fiber.Ctx) error {
+	var body SecureAccountProps
+	if err := h.parseJSONBody(c, &body); err != nil {
+		return err
+	}
+
+	var secure models.AccountSecureToken
+	err := h.db.Where("token = ? AND used = false AND expires_at > ?",
+		utils.HashTokenSHA256(body.Token), time.Now()).First(&secure).Error
+	if err != nil {
+		return fiber.NewError(400, "Invalid or expired token")
+	}
+
+	var revoked int64
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// The conditional update makes concurrent uses of one token fail
+		result := tx.Model(&models.AccountSecureToken{}).
+			Where("id = ? AND used = false", secure.ID).
+			Update("used", true)
+		if result.Error != nil {
+			return fmt.Errorf("failed to mark token as used: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(400, "Invalid or expired token")
+		}
+
+		revoked, err = revokeUserSessions(store.NewGorm(tx), secure.UserID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	h.sessionsRevoked(secure.UserID, revoked, "password_change_disputed")
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventPasswordChangeDisputed,
+		UserID:    &secure.UserID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"sessions_revoked": revoked})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "All sessions have been signed out. Reset your password to regain control of your account.",
+		Data:    nil,
+	})
+}
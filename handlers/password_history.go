@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/service"
+	"api/store"
+)
+
+// setupPasswordHistory reads PASSWORD_HISTORY_SIZE (default 5), how many
+// previous passwords, in addition to the current one, a user may not reuse;
+// 0 only prevents reusing the current password.
+func (h *Handler) setupPasswordHistory() {
+	h.passwordHistorySize = envInt("PASSWORD_HISTORY_SIZE", 5)
+}
+
+// checkPasswordReuse rejects password if it matches the user's current
+// password or one of their remembered previous passwords.
+func (h *Handler) checkPasswordReuse(users store.UserStore, user *models.User, password string) error {
+	return serviceError(service.CheckPasswordReuse(users, user, password, h.passwordHistorySize))
+}
+
+// rememberPreviousPassword moves the user's current hash into the history
+// before it is replaced and prunes entries beyond passwordHistorySize.
+func (h *Handler) rememberPreviousPassword(users store.UserStore, user *models.User) error {
+	return service.RememberPassword(users, user, h.passwordHistorySize)
+}
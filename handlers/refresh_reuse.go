@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// reasonRefreshTokenReused is returned when a refresh token that was already
+// rotated out is presented again.
+const reasonRefreshTokenReused = "refresh_token_reused"
+
+// rejectUnknownRefreshToken answers a refresh with a token matching no
+// session. A token retired by rotation (API v2) can only be presented again
+// if it was copied, and either the copy or the legitimate client already
+// holds its replacement, so the session is revoked for both.
+func (h *Handler) rejectUnknownRefreshToken(c *fiber.Ctx, hash string) error {
+	session, err := h.stores.Sessions().ByRetiredRefreshToken(hash)
+	if err != nil {
+		return fiber.NewError(401, "Unauthorized")
+	}
+
+	if !session.Revoked {
+		session.Revoked = true
+		if err := h.stores.Sessions().Save(session); err != nil {
+			log.Printf("refresh: failed to revoke session %d after token reuse: %v", session.ID, err)
+		}
+		h.sessionsRevoked(session.UserID, 1, "refresh_token_reused")
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventRefreshTokenReused,
+		UserID:    &session.UserID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{"session_id": session.ID})
+
+	return utils.NewReasonError(401, reasonRefreshTokenReused, "Unauthorized: Refresh token already used")
+}
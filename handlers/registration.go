@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/middleware"
+	"api/utils"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// registrationConfig holds the self-registration policy. It applies to
+// /register and to accounts created through OAuth sign-up, but not to users
+// created by admins.
+type registrationConfig struct {
+	// allowedDomains restricts self-registration to these email domains. An
+	// entry starting with "*." also matches any subdomain. Empty means any.
+	allowedDomains []string
+}
+
+// setupRegistration configures self-registration from environment variables:
+// REGISTRATION_ALLOWED_DOMAINS is a comma-separated list such as
+// "company.com,*.company.io". Without the open_registration feature flag
+// (see setupFeatureFlags) an invitation is required to register.
+func (h *Handler) setupRegistration() {
+	h.registration.allowedDomains = nil
+	for _, domain := range strings.Split(os.Getenv("REGISTRATION_ALLOWED_DOMAINS"), ",") {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" {
+			h.registration.allowedDomains = append(h.registration.allowedDomains, domain)
+		}
+	}
+}
+
+// emailDomainAllowed reports whether the email's domain may self-register.
+func (h *Handler) emailDomainAllowed(email string) bool {
+	if len(h.registration.allowedDomains) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(strings.TrimSpace(email[at+1:]))
+
+	for _, allowed := range h.registration.allowedDomains {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(domain, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if domain == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkRegistrationAllowed enforces the self-registration policy for
+// sign-ups that cannot carry an invitation (OAuth).
+func (h *Handler) checkRegistrationAllowed(email string) error {
+	if !h.flagEnabled(FlagOpenRegistration) {
+		return fiber.NewError(403, "Registration is by invitation only")
+	}
+	if !h.emailDomainAllowed(email) {
+		return fiber.NewError(403, "Registration is restricted to approved email domains")
+	}
+	return nil
+}
+
+// checkRegistrationWithInvitation enforces the self-registration policy for
+// /register. It returns the tenant's invitation matching inviteToken, which is
+// required in invite-only mode and must be accepted in the same transaction
+// that creates the user. A valid invitation bypasses the email domain
+// allowlist.
+func (h *Handler) checkRegistrationWithInvitation(tenantID uint, email, inviteToken string) (*models.Invitation, error) {
+	if inviteToken == "" {
+		return nil, h.checkRegistrationAllowed(email)
+	}
+
+	invitation, err := h.findPendingInvitation(tenantID, inviteToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if invitation.Email != "" && !strings.EqualFold(invitation.Email, strings.TrimSpace(email)) {
+		return nil, fiber.NewError(403, "This invitation was issued for a different email address")
+	}
+
+	return invitation, nil
+}
+
+// findPendingInvitation returns the tenant's unaccepted, unexpired
+// invitation for inviteToken.
+func (h *Handler) findPendingInvitation(tenantID uint, inviteToken string) (*models.Invitation, error) {
+	var invitation models.Invitation
+	err := h.db.Where("tenant_id = ? AND token = ? AND accepted_at IS NULL AND expires_at > ?",
+		tenantID, utils.HashTokenSHA256(inviteToken), time.Now()).First(&invitation).Error
+	if err != nil {
+		return nil, fiber.NewError(403, "Invalid or expired invitation")
+	}
+	return &invitation, nil
+}
+
+// GetInvitation returns the email and role of a pending invitation so the
+// registration page can pre-fill the form.
+func (h *Handler) GetInvitation(c *fiber.Ctx) error {
+	invitation, err := h.findPendingInvitation(middleware.TenantID(c), c.Params("token"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"email":      invitation.Email,
+			"role":       invitation.Role,
+			"expires_at": invitation.ExpiresAt,
+		},
+	})
+}
+
+// sendInvitationEmail emails a registration link for the invitation to its
+// recipient, naming the admin who created it.
+func (h *Handler) sendInvitationEmail(invitation *models.Invitation, inviteToken string, inviterID uint, locale models.Locale, expiresIn time.Duration) error {
+	inviter, err := h.stores.Users().ByID(inviterID)
+	if err != nil {
+		return fmt.Errorf("failed to load inviter: %w", err)
+	}
+
+	msg, err := utils.RenderEmail(utils.EmailTemplateInvitation, string(locale), utils.InvitationEmail{
+		InviterName: inviter.Username,
+		InviteURL:   fmt.Sprintf("%s/register?invite=%s", config.Get().ClientURL, inviteToken),
+		ExpiresIn:   expiresIn,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg.To = []string{invitation.Email}
+	return h.queueEmail(utils.EmailTemplateInvitation, nil, msg)
+}
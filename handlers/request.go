@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"api/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Machine-readable reasons returned when a request body is rejected.
+const (
+	reasonInvalidContentType = "invalid_content_type"
+	reasonBodyTooLarge       = "body_too_large"
+	reasonMalformedJSON      = "malformed_json"
+	reasonUnknownField       = "unknown_field"
+	reasonInvalidFieldType   = "invalid_field_type"
+)
+
+// setupRequestParsing configures body parsing from MAX_JSON_BODY_BYTES
+// (default 16384).
+func (h *Handler) setupRequestParsing() {
+	h.maxJSONBodyBytes = envInt("MAX_JSON_BODY_BYTES", 16*1024)
+}
+
+// parseJSONBody strictly decodes the request body into out. It requires an
+// application/json Content-Type, enforces MAX_JSON_BODY_BYTES, rejects unknown
+// fields and trailing data, checks the validate tags of struct bodies, and
+// returns a 400 ReasonError describing the problem.
+func (h *Handler) parseJSONBody(c *fiber.Ctx, out any) error {
+	return parseJSONBodyLimit(c, out, h.maxJSONBodyBytes)
+}
+
+// parseJSONBodyLimit is parseJSONBody with a caller-chosen size limit, for
+// endpoints such as bulk imports that accept larger bodies.
+func parseJSONBodyLimit(c *fiber.Ctx, out any, maxBytes int) error {
+	mediaType, _, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if err != nil || mediaType != fiber.MIMEApplicationJSON {
+		return utils.NewReasonError(400, reasonInvalidContentType, "Content-Type must be application/json")
+	}
+
+	body := c.Body()
+	if maxBytes > 0 && len(body) > maxBytes {
+		err := utils.NewReasonError(400, reasonBodyTooLarge, "Request body is too large")
+		err.Details = map[string]any{"max_bytes": maxBytes}
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(out); err != nil {
+		return jsonDecodeError(err)
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return utils.NewReasonError(400, reasonMalformedJSON, "Request body must contain a single JSON object")
+	}
+
+	if body, ok := out.(interface{ captchaToken() string }); ok {
+		c.Locals(captchaTokenLocal, body.captchaToken())
+	}
+
+	return validateBody(c, out)
+}
+
+// jsonDecodeError maps a json decoding error to a client-facing ReasonError.
+func jsonDecodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		// Errors from custom unmarshalers carry no field name
+		if typeErr.Field == "" {
+			return utils.NewReasonError(400, reasonInvalidFieldType, "Invalid value of type "+typeErr.Type.Name())
+		}
+		reasonErr := utils.NewReasonError(400, reasonInvalidFieldType, "Invalid type for field "+typeErr.Field)
+		reasonErr.Details = map[string]any{"field": typeErr.Field}
+		return reasonErr
+	}
+
+	// encoding/json has no typed error for unknown fields
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		reasonErr := utils.NewReasonError(400, reasonUnknownField, "Unknown field "+field)
+		reasonErr.Details = map[string]any{"field": field}
+		return reasonErr
+	}
+
+	return utils.NewReasonError(400, reasonMalformedJSON, "Malformed request")
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// reasonLoginDenied is returned when a login's risk score exceeds the deny
+// threshold.
+const reasonLoginDenied = "login_denied"
+
+// Actions taken on a risky login, from least to most severe.
+const (
+	riskActionNone      = "none"
+	riskActionCaptcha   = "captcha"
+	riskActionChallenge = "challenge"
+	riskActionDeny      = "deny"
+)
+
+// RiskRule scores one aspect of a login whose password has been verified.
+// Scores from all rules are summed; a rule returns 0 when its signal is
+// absent. Errors are logged and the rule is skipped.
+type RiskRule interface {
+	Name() string
+	Score(c *fiber.Ctx, user *models.User) (int, error)
+}
+
+// RegisterRiskRule adds a rule to the handler's login risk pipeline, after
+// the built-in rules. Rules only run when the security policy enables risk
+// scoring. Register rules before serving requests.
+func (h *Handler) RegisterRiskRule(rule RiskRule) {
+	h.riskRules = append(h.riskRules, rule)
+}
+
+// setupRiskScoring builds the built-in risk rules, which read their weights
+// from the security policy on every login. The Tor and VPN rules need
+// RISK_TOR_LIST and RISK_VPN_LIST, files with one IP or CIDR per line.
+func (h *Handler) setupRiskScoring() {
+	h.riskRules = []RiskRule{
+		newDeviceRule{db: h.db, policy: h.riskPolicy},
+		newLocationRule{db: h.db, policy: h.riskPolicy, locate: h.clientLocation},
+		velocityRule{db: h.db, policy: h.riskPolicy},
+	}
+
+	for _, list := range []struct {
+		name, file string
+		weight     func(utils.RiskPolicy) int
+	}{
+		{"tor", os.Getenv("RISK_TOR_LIST"), func(p utils.RiskPolicy) int { return p.TorScore }},
+		{"vpn", os.Getenv("RISK_VPN_LIST"), func(p utils.RiskPolicy) int { return p.VPNScore }},
+	} {
+		if list.file == "" {
+			continue
+		}
+		networks, err := loadIPList(list.file)
+		if err != nil {
+			log.Printf("risk: %s list disabled: %v", list.name, err)
+			continue
+		}
+		h.riskRules = append(h.riskRules, ipListRule{name: list.name, policy: h.riskPolicy, weight: list.weight, networks: networks})
+	}
+}
+
+// riskPolicy returns the risk section of the effective security policy.
+func (h *Handler) riskPolicy() utils.RiskPolicy {
+	return h.policy().Risk
+}
+
+// assessLoginRisk scores the login and enforces the resulting action. The
+// CAPTCHA action uses the login CAPTCHA; the challenge action emails a
+// one-time code that completes the login when sent back as stepUpCode.
+func (h *Handler) assessLoginRisk(c *fiber.Ctx, user *models.User, stepUpCode string) error {
+	policy := h.policy()
+	if !policy.Risk.Enabled {
+		return nil
+	}
+
+	score := 0
+	signals := fiber.Map{}
+	for _, rule := range h.riskRules {
+		s, err := rule.Score(c, user)
+		if err != nil {
+			log.Printf("risk: rule %s failed: %v", rule.Name(), err)
+			continue
+		}
+		if s > 0 {
+			score += s
+			signals[rule.Name()] = s
+		}
+	}
+
+	action := riskActionNone
+	switch {
+	case policy.Risk.DenyScore > 0 && score >= policy.Risk.DenyScore:
+		action = riskActionDeny
+	case policy.StepUp.RiskScore > 0 && score >= policy.StepUp.RiskScore:
+		action = riskActionChallenge
+	case policy.Risk.CaptchaScore > 0 && score >= policy.Risk.CaptchaScore:
+		action = riskActionCaptcha
+	}
+	if action == riskActionNone {
+		return nil
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventLoginRisk,
+		UserID:    &user.ID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"score":   score,
+		"signals": signals,
+		"action":  action,
+	})
+
+	switch action {
+	case riskActionDeny:
+		h.recordLoginAttempt(c, user.Email, &user.ID, false, loginFailureRiskDenied)
+		return utils.NewReasonError(403, reasonLoginDenied, "Sign-in blocked for security reasons")
+	case riskActionChallenge:
+		return h.requireStepUp(c, user, stepUpCode)
+	default:
+		return h.verifyCaptcha(c, captchaActionLogin)
+	}
+}
+
+// newDeviceRule flags logins from a User-Agent the user has never signed in
+// with. Users without any previous login are not flagged.
+type newDeviceRule struct {
+	db     *gorm.DB
+	policy func() utils.RiskPolicy
+}
+
+func (newDeviceRule) Name() string { return "new_device" }
+
+func (r newDeviceRule) Score(c *fiber.Ctx, user *models.User) (int, error) {
+	return scoreIfUnseen(r.db, r.policy().NewDeviceScore, user.ID, "user_agent = ?", c.Get("User-Agent"))
+}
+
+// newLocationRule flags logins from a country the user has never signed in
+// from. It needs impossible-travel geolocation (GEO_SOURCE) to be enabled.
+type newLocationRule struct {
+	db     *gorm.DB
+	policy func() utils.RiskPolicy
+	locate func(*fiber.Ctx) *utils.GeoLocation
+}
+
+func (newLocationRule) Name() string { return "new_location" }
+
+func (r newLocationRule) Score(c *fiber.Ctx, user *models.User) (int, error) {
+	loc := r.locate(c)
+	if loc == nil || loc.Country == "" {
+		return 0, nil
+	}
+	return scoreIfUnseen(r.db, r.policy().NewLocationScore, user.ID, "country = ?", loc.Country)
+}
+
+// scoreIfUnseen returns score when the user has previous successful logins
+// but none matching the condition.
+func scoreIfUnseen(db *gorm.DB, score int, userID uint, condition string, value string) (int, error) {
+	if score <= 0 {
+		return 0, nil
+	}
+
+	history := db.Model(&models.LoginAttempt{}).Where("user_id = ? AND success = ?", userID, true)
+
+	var logins, matching int64
+	if err := history.Session(&gorm.Session{}).Count(&logins).Error; err != nil {
+		return 0, err
+	}
+	if logins == 0 {
+		return 0, nil
+	}
+	if err := history.Session(&gorm.Session{}).Where(condition, value).Count(&matching).Error; err != nil {
+		return 0, err
+	}
+	if matching > 0 {
+		return 0, nil
+	}
+	return score, nil
+}
+
+// velocityRule flags accounts with a burst of recent failed logins.
+type velocityRule struct {
+	db     *gorm.DB
+	policy func() utils.RiskPolicy
+}
+
+func (velocityRule) Name() string { return "velocity" }
+
+func (r velocityRule) Score(c *fiber.Ctx, user *models.User) (int, error) {
+	policy := r.policy()
+	if policy.VelocityScore <= 0 || policy.VelocityFailures <= 0 {
+		return 0, nil
+	}
+
+	var failures int64
+	err := r.db.Model(&models.LoginAttempt{}).
+		Where("user_id = ? AND success = ? AND created_at > ?", user.ID, false, time.Now().Add(-time.Duration(policy.VelocityWindow))).
+		Count(&failures).Error
+	if err != nil {
+		return 0, err
+	}
+	if failures < int64(policy.VelocityFailures) {
+		return 0, nil
+	}
+	return policy.VelocityScore, nil
+}
+
+// ipListRule flags client IPs found in a list of ranges, such as Tor exit
+// nodes or VPN providers.
+type ipListRule struct {
+	name     string
+	policy   func() utils.RiskPolicy
+	weight   func(utils.RiskPolicy) int
+	networks []*net.IPNet
+}
+
+func (r ipListRule) Name() string { return r.name }
+
+func (r ipListRule) Score(c *fiber.Ctx, _ *models.User) (int, error) {
+	ip := net.ParseIP(c.IP())
+	if ip == nil {
+		return 0, nil
+	}
+	for _, network := range r.networks {
+		if network.Contains(ip) {
+			return r.weight(r.policy()), nil
+		}
+	}
+	return 0, nil
+}
+
+// loadIPList reads one IP or CIDR per line. Blank lines and lines starting
+// with # are ignored.
+func loadIPList(path string) ([]*net.IPNet, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var networks []*net.IPNet
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		cidr, err := parseIPRange(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks, scanner.Err()
+}
+
+// sendLoginChallenge emails the verification code for a challenged login.
+func (h *Handler) sendLoginChallenge(user *models.User, ip, code string) {
+	err := h.queueUserEmail(user, utils.EmailTemplateLoginChallenge, utils.LoginChallengeEmail{
+		IPAddress: ip,
+		Code:      code,
+		ExpiresIn: time.Duration(h.policy().StepUp.ChallengeTTL),
+	})
+	if err != nil {
+		log.Printf("failed to queue login verification code: %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// securityPolicyRefreshInterval bounds how stale the cached policy can get
+// when it is changed by another instance.
+const securityPolicyRefreshInterval = 30 * time.Second
+
+// securityPolicyOverrideID is the primary key of the single stored override.
+const securityPolicyOverrideID = 1
+
+// securityPolicyCache caches the effective policy: the configured defaults,
+// or the override saved by an admin.
+type securityPolicyCache struct {
+	sync.RWMutex
+	defaults   utils.SecurityPolicy
+	current    utils.SecurityPolicy
+	overridden bool
+	loadedAt   time.Time
+}
+
+// setupSecurityPolicy loads the default policy from environment variables
+// (see utils.NewSecurityPolicy). Admin overrides are read from the database
+// on first use.
+func (h *Handler) setupSecurityPolicy() {
+	defaults := utils.NewSecurityPolicy()
+	if err := defaults.Validate(); err != nil {
+		log.Printf("security policy: configured defaults are invalid: %v", err)
+	}
+
+	h.securityPolicy.Lock()
+	h.securityPolicy.defaults = defaults
+	h.securityPolicy.current = defaults
+	h.securityPolicy.overridden = false
+	h.securityPolicy.loadedAt = time.Time{}
+	h.securityPolicy.Unlock()
+}
+
+// policy returns the effective security policy, reloading the admin override
+// when the cached copy is stale.
+func (h *Handler) policy() utils.SecurityPolicy {
+	h.securityPolicy.RLock()
+	if time.Since(h.securityPolicy.loadedAt) < securityPolicyRefreshInterval {
+		current := h.securityPolicy.current
+		h.securityPolicy.RUnlock()
+		return current
+	}
+	h.securityPolicy.RUnlock()
+
+	h.securityPolicy.Lock()
+	defer h.securityPolicy.Unlock()
+
+	// Another request may have refreshed while we waited for the lock.
+	if time.Since(h.securityPolicy.loadedAt) < securityPolicyRefreshInterval {
+		return h.securityPolicy.current
+	}
+
+	var override models.SecurityPolicyOverride
+	err := h.db.First(&override, securityPolicyOverrideID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		h.securityPolicy.current = h.securityPolicy.defaults
+		h.securityPolicy.overridden = false
+	case err != nil:
+		// Keep enforcing the previous policy rather than failing open or
+		// closed, and wait a refresh interval before trying the database again.
+		log.Printf("security policy: failed to load override: %v", err)
+		h.securityPolicy.loadedAt = time.Now()
+		return h.securityPolicy.current
+	default:
+		current, err := decodeSecurityPolicy(override.Policy, h.securityPolicy.defaults)
+		if err != nil {
+			log.Printf("security policy: ignoring stored override: %v", err)
+			current = h.securityPolicy.defaults
+		}
+		h.securityPolicy.current = current
+		h.securityPolicy.overridden = err == nil
+	}
+
+	h.securityPolicy.loadedAt = time.Now()
+	return h.securityPolicy.current
+}
+
+// decodeSecurityPolicy decodes a stored override on top of base, so settings
+// added after the override was saved keep their defaults.
+func decodeSecurityPolicy(encoded string, base utils.SecurityPolicy) (utils.SecurityPolicy, error) {
+	if err := json.Unmarshal([]byte(encoded), &base); err != nil {
+		return utils.SecurityPolicy{}, err
+	}
+	if err := base.Validate(); err != nil {
+		return utils.SecurityPolicy{}, err
+	}
+	return base, nil
+}
+
+// setSecurityPolicy replaces the cached policy after an admin change.
+func (h *Handler) setSecurityPolicy(current utils.SecurityPolicy, overridden bool) {
+	h.securityPolicy.Lock()
+	h.securityPolicy.current = current
+	h.securityPolicy.overridden = overridden
+	h.securityPolicy.loadedAt = time.Now()
+	h.securityPolicy.Unlock()
+}
+
+// AdminGetSecurityPolicy returns the effective security policy along with the
+// configured defaults.
+func (h *Handler) AdminGetSecurityPolicy(c *fiber.Ctx) error {
+	current := h.policy()
+
+	h.securityPolicy.RLock()
+	defaults, overridden := h.securityPolicy.defaults, h.securityPolicy.overridden
+	h.securityPolicy.RUnlock()
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data: fiber.Map{
+			"policy":     current,
+			"defaults":   defaults,
+			"overridden": overridden,
+		},
+	})
+}
+
+// AdminUpdateSecurityPolicy applies the given settings on top of the
+// effective policy and stores the result. Omitted fields keep their current
+// values.
+func (h *Handler) AdminUpdateSecurityPolicy(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	previous := h.policy()
+	updated := previous
+	if err := h.parseJSONBody(c, &updated); err != nil {
+		return err
+	}
+	if err := updated.Validate(); err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+
+	encoded, err := json.Marshal(updated)
+	if err != nil {
+		return fmt.Errorf("failed to encode security policy: %w", err)
+	}
+
+	override := models.SecurityPolicyOverride{
+		ID:        securityPolicyOverrideID,
+		Policy:    string(encoded),
+		UpdatedBy: claims.Subject,
+	}
+	if err := h.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&override).Error; err != nil {
+		return fmt.Errorf("failed to save security policy: %w", err)
+	}
+	h.setSecurityPolicy(updated, true)
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventSecurityPolicyUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"previous": previous,
+		"policy":   updated,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Security policy updated",
+		Data:    updated,
+	})
+}
+
+// AdminResetSecurityPolicy deletes the stored override so the configured
+// defaults apply again.
+func (h *Handler) AdminResetSecurityPolicy(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	if err := h.db.Delete(&models.SecurityPolicyOverride{}, securityPolicyOverrideID).Error; err != nil {
+		return fmt.Errorf("failed to reset security policy: %w", err)
+	}
+
+	h.securityPolicy.RLock()
+	defaults := h.securityPolicy.defaults
+	h.securityPolicy.RUnlock()
+	h.setSecurityPolicy(defaults, false)
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventSecurityPolicyReset,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, nil)
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Security policy reset to defaults",
+		Data:    defaults,
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"api/utils"
+	"testing"
+	"time"
+)
+
+func TestDecodeSecurityPolicy(t *testing.T) {
+	base := utils.NewSecurityPolicy()
+
+	tests := []struct {
+		name    string
+		encoded string
+		check   func(t *testing.T, p utils.SecurityPolicy)
+		valid   bool
+	}{
+		{
+			name:    "empty override keeps the defaults",
+			encoded: `{}`,
+			check: func(t *testing.T, p utils.SecurityPolicy) {
+				if p != base {
+					t.Errorf("got %+v, want the defaults %+v", p, base)
+				}
+			},
+			valid: true,
+		},
+		{
+			name:    "override replaces only the fields it names",
+			encoded: `{"lockout":{"threshold":3},"step_up":{"require_for_admins":true,"challenge_ttl":"5m"}}`,
+			check: func(t *testing.T, p utils.SecurityPolicy) {
+				if p.Lockout.Threshold != 3 || !p.StepUp.RequireForAdmins || p.StepUp.ChallengeTTL != utils.Duration(5*time.Minute) {
+					t.Errorf("override not applied: %+v", p)
+				}
+				if p.Lockout.BaseDuration != base.Lockout.BaseDuration || p.Password != base.Password || p.StepUp.RiskScore != base.StepUp.RiskScore {
+					t.Errorf("unnamed fields lost their defaults: %+v", p)
+				}
+			},
+			valid: true,
+		},
+		{
+			name:    "thresholds moved into the policy",
+			encoded: `{"risk":{"deny_score":90},"impossible_travel":{"max_speed_kmh":500},"credential_stuffing":{"block_duration":"2h"}}`,
+			check: func(t *testing.T, p utils.SecurityPolicy) {
+				if p.Risk.DenyScore != 90 || p.ImpossibleTravel.MaxSpeedKmh != 500 || p.CredentialStuffing.BlockDuration != utils.Duration(2*time.Hour) {
+					t.Errorf("override not applied: %+v", p)
+				}
+			},
+			valid: true,
+		},
+		{name: "malformed JSON", encoded: `{"lockout":`},
+		{name: "wrong type", encoded: `{"lockout":{"threshold":"three"}}`},
+		{name: "invalid duration", encoded: `{"session":{"ttl":"forever"}}`},
+		{name: "fails validation", encoded: `{"password":{"min_length":0}}`},
+		{name: "inconsistent with the defaults", encoded: `{"lockout":{"max_duration":"1s"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeSecurityPolicy(tt.encoded, base)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("decoded %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			tt.check(t, got)
+		})
+	}
+}
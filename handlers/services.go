@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/service"
+	"api/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (h *Handler) setupServices() {
+	h.accounts = &service.Accounts{
+		Store:         h.stores,
+		Policy:        h.policy,
+		Usernames:     h.usernamePolicy,
+		UsernameGrace: h.usernameChanges.redirectGrace,
+		OnLocked:      h.sendUnlockEmail,
+	}
+	h.oauthLogins = &service.OAuth{
+		Store:             h.stores,
+		Usernames:         h.usernamePolicy,
+		UsernameGrace:     h.usernameChanges.redirectGrace,
+		AllowRegistration: h.checkRegistrationAllowed,
+	}
+	h.tokens = &service.Tokens{
+		Store:     h.stores,
+		OnRevoked: h.serviceRevokedSession,
+	}
+}
+
+// Tokens returns the token service, which audits and publishes the sessions
+// it revokes like the HTTP API does.
+func (h *Handler) Tokens() *service.Tokens {
+	return h.tokens
+}
+
+// serviceRevokedSession records a session revoked through the token service.
+func (h *Handler) serviceRevokedSession(session *models.Session) {
+	h.recordAuditEvent(models.AuditLog{
+		Event:  models.AuditEventSessionRevoked,
+		UserID: &session.UserID,
+	}, fiber.Map{"jti": session.JTI})
+	h.sessionsRevoked(session.UserID, 1, "service_revoked")
+}
+
+func (h *Handler) setupPasswordResetService() {
+	h.passwordResets = &service.PasswordResets{
+		Store:       h.stores,
+		Policy:      h.policy,
+		MaxAttempts: h.passwordResetMaxAttempts,
+		HistorySize: h.passwordHistorySize,
+		Allow: func(user *models.User) error {
+			return h.checkEmailSendLimit(utils.EmailTemplatePasswordReset, user.Email)
+		},
+		Send: func(user *models.User, token string, expiresIn time.Duration) error {
+			// Queue the reset email for the outbox worker
+			return h.queueUserEmail(user, utils.EmailTemplatePasswordReset, utils.PasswordResetEmail{
+				ResetURL:  fmt.Sprintf("%s/reset-password?token=%s", config.Get().ClientURL, token),
+				ExpiresIn: expiresIn,
+			})
+		},
+	}
+}
+
+// serviceStatus maps service error kinds to HTTP status codes.
+var serviceStatus = map[service.Kind]int{
+	service.KindInvalid:         400,
+	service.KindUnauthenticated: 401,
+	service.KindForbidden:       403,
+	service.KindNotFound:        404,
+	service.KindConflict:        409,
+	service.KindLocked:          423,
+}
+
+// serviceError converts a *service.Error into the API error for its kind.
+// Other errors are returned unchanged.
+func serviceError(err error) error {
+	var serr *service.Error
+	if !errors.As(err, &serr) {
+		return err
+	}
+
+	status := serviceStatus[serr.Kind]
+	if serr.Reason == "" {
+		return fiber.NewError(status, serr.Message)
+	}
+	reasonErr := utils.NewReasonError(status, serr.Reason, serr.Message)
+	reasonErr.Details = serr.Details
+	return reasonErr
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// issueSession creates a session for the user with a fresh JTI and refresh
+// token, sets the auth cookies and returns the access token and CSRF token.
+// The user's oldest sessions are revoked beyond the session policy's cap.
+func (h *Handler) issueSession(c *fiber.Ctx, st store.Store, userID uint) (accessToken, csrfToken string, err error) {
+	sessions := h.policy().Session
+
+	// The locale claim lets error messages be localized without a lookup,
+	// and the tenant claim ties the token to the user's tenant
+	user, err := st.Users().ByID(userID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to load user: %w", err)
+	}
+
+	jti, accessToken, err := utils.GetSignedKey(user)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, hashedToken := utils.GenerateRefreshToken()
+
+	session := models.Session{
+		JTI:            jti,
+		UserID:         userID,
+		RefreshToken:   hashedToken,
+		Revoked:        false,
+		IPAddress:      c.IP(),
+		UserAgent:      c.Get("User-Agent"),
+		TLSFingerprint: clientTLSFingerprint(c),
+		ExpiresAt:      time.Now().Add(time.Duration(sessions.TTL)),
+	}
+	if err := st.Sessions().Create(&session); err != nil {
+		return "", "", fmt.Errorf("failed to create session: %w", err)
+	}
+	if sessions.MaxPerUser > 0 {
+		// Revoke the oldest sessions beyond the cap
+		if err := st.Sessions().RevokeBeyond(userID, sessions.MaxPerUser); err != nil {
+			return "", "", fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+	}
+
+	csrfToken = setAuthCookies(c, refreshToken)
+	return accessToken, csrfToken, nil
+}
+
+// revokeUserSessions revokes every active session of the user and returns how
+// many were revoked.
+func revokeUserSessions(st store.Store, userID uint) (int64, error) {
+	revoked, err := st.Sessions().RevokeAll(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return revoked, nil
+}
+
+// rotateSessions is called after a user changes their own password. It
+// revokes all of the user's sessions, including the current one, and issues
+// a new session to the caller, so tokens obtained before the change can no
+// longer be used. It returns how many sessions were revoked.
+func (h *Handler) rotateSessions(c *fiber.Ctx, st store.Store, userID uint) (accessToken, csrfToken string, revoked int64, err error) {
+	if revoked, err = revokeUserSessions(st, userID); err != nil {
+		return "", "", 0, err
+	}
+	accessToken, csrfToken, err = h.issueSession(c, st, userID)
+	return accessToken, csrfToken, revoked, err
+}
+
+// sessionView is a session as listed to its owner.
+type sessionView struct {
+	models.Session
+	Current bool `json:"current"` // The session of the access token making the request
+}
+
+// ListMySessions returns one page of the authenticated user's login
+// sessions, newest first. active=true leaves out revoked and expired sessions.
+func (h *Handler) ListMySessions(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	sessions, pageInfo, err := h.listSessions(c, claims.Subject)
+	if err != nil {
+		return err
+	}
+
+	views := make([]sessionView, len(sessions))
+	for i, session := range sessions {
+		views[i] = sessionView{Session: session, Current: session.JTI == claims.ID}
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    views,
+		Meta:    pageInfo,
+	})
+}
+
+// AdminListUserSessions returns one page of the sessions of the user in
+// :id, newest first, with the same filters as ListMySessions.
+func (h *Handler) AdminListUserSessions(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	sessions, pageInfo, err := h.listSessions(c, uint(userID))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    sessions,
+		Meta:    pageInfo,
+	})
+}
+
+func (h *Handler) listSessions(c *fiber.Ctx, userID uint) ([]models.Session, *utils.PageInfo, error) {
+	params, err := parsePage(c, 50, 200)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Scoped tokens are listed with the personal access tokens and API clients
+	sessions, err := h.stores.Sessions().List(userID, c.QueryBool("active"), params.page())
+	if err != nil {
+		return nil, nil, fiber.NewError(500, "Failed to fetch sessions")
+	}
+	sessions, pageInfo := pageOf(params, sessions, func(s models.Session) utils.Cursor {
+		return utils.Cursor{Time: s.IssuedAt, ID: s.ID}
+	})
+	return sessions, pageInfo, nil
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"api/config"
+	"api/database/models"
+	"api/middleware"
+	"api/utils"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SettingsReload is the outcome of ReloadSettings.
+type SettingsReload struct {
+	Files    []string `json:"files"`            // Config files read
+	Reloaded []string `json:"reloaded"`         // Settings applied
+	Failed   []string `json:"failed,omitempty"` // Settings that kept their previous values, and why
+}
+
+// ReloadSettings reads the config files again and applies the runtime
+// settings that can change without a restart or signing anyone out: rate
+// limits, feature flag defaults, email templates and CORS origins. A setting
+// that fails to load keeps its previous value, and the failures are returned
+// as the error; an unreadable config file applies nothing.
+func (h *Handler) ReloadSettings() (SettingsReload, error) {
+	files, err := config.LoadFile()
+	if err != nil {
+		return SettingsReload{}, err
+	}
+	result := SettingsReload{Files: files, Reloaded: []string{}}
+
+	var errs []error
+	apply := func(name string, reload func() error) {
+		if err := reload(); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", name, err))
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+		result.Reloaded = append(result.Reloaded, name)
+	}
+
+	apply("rate_limits", func() error {
+		middleware.ReloadRateLimits()
+		return nil
+	})
+	apply("feature_flags", h.reloadFeatureFlagDefaults)
+	apply("email_templates", utils.InitEmailTemplates)
+	apply("cors", middleware.ReloadCORS)
+
+	return result, errors.Join(errs...)
+}
+
+// AdminReloadSettings reloads the runtime settings on the instance serving
+// the request, like sending it SIGHUP.
+func (h *Handler) AdminReloadSettings(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	result, err := h.ReloadSettings()
+	if err != nil && len(result.Reloaded) == 0 && len(result.Failed) == 0 {
+		// The config file itself could not be read
+		reasonErr := utils.NewReasonError(422, "settings_reload_failed", "Failed to reload settings")
+		reasonErr.Details = map[string]any{"errors": []string{err.Error()}}
+		return reasonErr
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventSettingsReloaded,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"files":    result.Files,
+		"reloaded": result.Reloaded,
+		"failed":   result.Failed,
+	})
+
+	if err != nil {
+		reasonErr := utils.NewReasonError(422, "settings_reload_failed", "Some settings failed to reload and kept their previous values")
+		reasonErr.Details = map[string]any{"errors": result.Failed, "reloaded": result.Reloaded}
+		return reasonErr
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Settings reloaded",
+		Data:    result,
+	})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Reasons returned when a sensitive account change needs a step-up code.
+const (
+	reasonStepUpRequired    = "step_up_required"
+	reasonInvalidStepUpCode = "invalid_step_up_code"
+)
+
+// requireStepUp completes a login flagged for step-up verification when
+// stepUpCode is valid. Without a code, a new one is emailed and the login is
+// refused until it is sent back.
+func (h *Handler) requireStepUp(c *fiber.Ctx, user *models.User, stepUpCode string) error {
+	if stepUpCode != "" {
+		if h.consumeLoginChallenge(c, user.ID, stepUpCode) {
+			return nil
+		}
+		h.recordLoginAttempt(c, user.Email, &user.ID, false, loginFailureInvalidCode)
+		return fiber.NewError(401, "Invalid or expired verification code")
+	}
+
+	code, err := h.issueLoginChallenge(c, user.ID)
+	if err != nil {
+		return err
+	}
+	h.sendLoginChallenge(user, c.IP(), code)
+	return fiber.NewError(403, "Additional verification required. A verification code has been sent to your email")
+}
+
+// checkAdminStepUp requires an emailed code for password logins of admins
+// when the security policy's step_up.require_for_admins is set.
+func (h *Handler) checkAdminStepUp(c *fiber.Ctx, user *models.User, stepUpCode string) error {
+	if user.Role != models.RoleAdmin || !h.policy().StepUp.RequireForAdmins {
+		return nil
+	}
+	return h.requireStepUp(c, user, stepUpCode)
+}
+
+// requireRecentLogin guards sensitive account changes. Sessions that signed
+// in within the step-up policy's recent_login window pass; others must send
+// back a code, which send emails to the user, so a stolen access token alone
+// is not enough.
+func (h *Handler) requireRecentLogin(c *fiber.Ctx, claims *utils.JWTClaims, stepUpCode string, send func(code string)) error {
+	if window := time.Duration(h.policy().StepUp.RecentLogin); window > 0 {
+		session, err := h.stores.Sessions().ByJTI(claims.ID)
+		if err == nil && time.Since(session.IssuedAt) < window {
+			return nil
+		}
+	}
+
+	if stepUpCode != "" {
+		if h.consumeLoginChallenge(c, claims.Subject, stepUpCode) {
+			return nil
+		}
+		return utils.NewReasonError(401, reasonInvalidStepUpCode, "Invalid or expired verification code")
+	}
+
+	code, err := h.issueLoginChallenge(c, claims.Subject)
+	if err != nil {
+		return err
+	}
+	send(code)
+	return utils.NewReasonError(403, reasonStepUpRequired, "Verification required. A verification code has been sent to your email")
+}
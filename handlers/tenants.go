@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/utils"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// tenantSlugPattern matches slugs that are valid DNS labels, so every tenant
+// can also be reached through a subdomain.
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+type CreateTenantRequest struct {
+	Slug string `json:"slug" validate:"required,max=63"`
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+type UpdateTenantRequest struct {
+	Name   string `json:"name,omitempty" validate:"max=255"`
+	Active *bool  `json:"active,omitempty"`
+}
+
+// tenantUsers selects the IDs of the tenant's users, including deleted ones,
+// for scoping queries on tables that only reference users.
+func (h *Handler) tenantUsers(tenantID uint) *gorm.DB {
+	return h.db.Unscoped().Model(&models.User{}).Select("id").Where("tenant_id = ?", tenantID)
+}
+
+// RequireTenantUser answers 404 for admin routes on a user in :id who
+// belongs to another tenant, so admins only manage their own tenant's users.
+func (h *Handler) RequireTenantUser(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	inTenant, err := h.stores.Users().InTenant(uint(userID), middleware.TenantID(c))
+	if err != nil {
+		return fmt.Errorf("failed to look up user tenant: %w", err)
+	}
+	if !inTenant {
+		return fiber.NewError(404, "User not found")
+	}
+	return c.Next()
+}
+
+// AdminListTenants returns every tenant, oldest first.
+func (h *Handler) AdminListTenants(c *fiber.Ctx) error {
+	var tenants []models.Tenant
+	if err := h.db.Order("id").Find(&tenants).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch tenants")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    tenants,
+	})
+}
+
+// AdminCreateTenant creates an active tenant. Its users sign up and sign in
+// with the tenant's slug in the tenant header or subdomain.
+func (h *Handler) AdminCreateTenant(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var req CreateTenantRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	tenant := models.Tenant{
+		Slug:   strings.ToLower(strings.TrimSpace(req.Slug)),
+		Name:   strings.TrimSpace(req.Name),
+		Active: true,
+	}
+	if !tenantSlugPattern.MatchString(tenant.Slug) {
+		return fiber.NewError(400, "Invalid slug. Use lowercase letters, digits and hyphens")
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Tenant{}).Where("slug = ?", tenant.Slug).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check tenant slug: %w", err)
+	}
+	if count > 0 {
+		return fiber.NewError(409, "A tenant with this slug already exists")
+	}
+
+	if err := h.db.Create(&tenant).Error; err != nil {
+		return fmt.Errorf("failed to create tenant: %w", err)
+	}
+	h.caches.Tenants.Invalidate()
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventTenantCreated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"tenant_id": tenant.ID,
+		"slug":      tenant.Slug,
+	})
+
+	return c.Status(201).JSON(utils.Response{
+		Success: true,
+		Code:    201,
+		Message: "Tenant created",
+		Data:    tenant,
+	})
+}
+
+// AdminUpdateTenant renames the tenant in :id or activates or deactivates
+// it. Requests to a deactivated tenant are refused, which signs its users
+// out. The default tenant cannot be deactivated.
+func (h *Handler) AdminUpdateTenant(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	tenantID, err := c.ParamsInt("id")
+	if err != nil || tenantID <= 0 {
+		return fiber.NewError(400, "Invalid tenant id")
+	}
+
+	var req UpdateTenantRequest
+	if err := h.parseJSONBody(c, &req); err != nil {
+		return err
+	}
+
+	var tenant models.Tenant
+	if err := h.db.First(&tenant, tenantID).Error; err != nil {
+		return fiber.NewError(404, "Tenant not found")
+	}
+
+	updates := map[string]any{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.Active != nil {
+		if !*req.Active && tenant.ID == models.DefaultTenantID {
+			return fiber.NewError(400, "The default tenant cannot be deactivated")
+		}
+		updates["active"] = *req.Active
+	}
+	if len(updates) == 0 {
+		return fiber.NewError(400, "No changes provided")
+	}
+
+	if err := h.db.Model(&tenant).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update tenant: %w", err)
+	}
+	h.caches.Tenants.Invalidate()
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventTenantUpdated,
+		ActorID:   &claims.Subject,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"tenant_id": tenant.ID,
+		"changes":   updates,
+	})
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Tenant updated",
+		Data:    tenant,
+	})
+}
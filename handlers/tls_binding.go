@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// reasonTLSFingerprintMismatch is returned when a refresh request comes from a
+// TLS client other than the one that signed in.
+const reasonTLSFingerprintMismatch = "tls_fingerprint_mismatch"
+
+// setupTLSBinding reads SESSION_TLS_BINDING (default false), which makes
+// refresh requests present the TLS fingerprint the session was created with.
+// Fingerprints are only available when the server terminates TLS itself
+// (TLS_CERT_FILE and TLS_KEY_FILE).
+func (h *Handler) setupTLSBinding() {
+	h.requireTLSBinding, _ = strconv.ParseBool(os.Getenv("SESSION_TLS_BINDING"))
+}
+
+// clientTLSFingerprint returns the JA3 fingerprint of the request's TLS
+// connection, or "" when TLS was not terminated in-app.
+func clientTLSFingerprint(c *fiber.Ctx) string {
+	return utils.TLSFingerprint(c.Context().RemoteAddr())
+}
+
+// checkTLSBinding rejects the refresh when binding is required and the
+// session's fingerprint differs from the current connection's. Sessions
+// created without a fingerprint are not bound.
+func (h *Handler) checkTLSBinding(c *fiber.Ctx, session *models.Session) error {
+	if !h.requireTLSBinding || session.TLSFingerprint == "" {
+		return nil
+	}
+
+	fingerprint := clientTLSFingerprint(c)
+	if fingerprint == session.TLSFingerprint {
+		return nil
+	}
+
+	h.recordAuditEvent(models.AuditLog{
+		Event:     models.AuditEventTLSFingerprintMismatch,
+		UserID:    &session.UserID,
+		IPAddress: c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}, fiber.Map{
+		"session_id":  session.ID,
+		"expected":    session.TLSFingerprint,
+		"fingerprint": fingerprint,
+	})
+
+	return utils.NewReasonError(401, reasonTLSFingerprintMismatch, "Unauthorized: TLS client does not match session")
+}
@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/service"
+	"api/store"
+	"api/utils"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Machine-readable reasons returned when an import is rejected as a whole.
+const (
+	reasonImportEmpty         = "import_empty"
+	reasonImportTooLarge      = "import_too_large"
+	reasonInvalidCSV          = "invalid_csv"
+	reasonUnknownCSVField     = "unknown_csv_column"
+	reasonUnknownImportFormat = "unknown_import_format"
+	reasonMalformedExport     = "malformed_export"
+)
+
+// Per-row import outcomes.
+const (
+	importStatusValid   = "valid"   // Passed validation in a dry run
+	importStatusCreated = "created" // User was created
+	importStatusInvalid = "invalid" // Failed validation; nothing was written
+	importStatusFailed  = "failed"  // Passed validation but could not be saved
+)
+
+type userImportConfig struct {
+	maxRows  int
+	maxBytes int
+}
+
+// setupUserImport reads USER_IMPORT_MAX_ROWS (default 1000) and
+// USER_IMPORT_MAX_BYTES (default 4 MiB).
+func (h *Handler) setupUserImport() {
+	h.userImport.maxRows = envInt("USER_IMPORT_MAX_ROWS", 1000)
+	h.userImport.maxBytes = envInt("USER_IMPORT_MAX_BYTES", 4<<20)
+}
+
+// ImportUser is one account migrated from another auth system. Users without
+// a username get one derived from their email.
+type ImportUser struct {
+	Email         string                `json:"email"`
+	Username      string                `json:"username,omitempty"`
+	PasswordHash  string                `json:"password_hash,omitempty"` // bcrypt hash; the user keeps their password
+	EmailVerified bool                  `json:"email_verified,omitempty"`
+	Role          models.Role           `json:"role,omitempty"`
+	Locale        models.Locale         `json:"locale,omitempty"`
+	FirstName     string                `json:"first_name,omitempty"`
+	LastName      string                `json:"last_name,omitempty"`
+	DisplayName   string                `json:"display_name,omitempty"`
+	OAuth         []ImportOAuthIdentity `json:"oauth,omitempty"`
+
+	// PasswordResetRequired imports a user without credentials, for sources
+	// that do not export password hashes. They sign in after a password reset.
+	PasswordResetRequired bool `json:"password_reset_required,omitempty"`
+
+	errors []string // Problems found while parsing the row
+}
+
+// ImportOAuthIdentity links an imported user to an OAuth provider account.
+type ImportOAuthIdentity struct {
+	Provider   models.OAuthProvider `json:"provider"`
+	ProviderID string               `json:"provider_id"`
+	Email      string               `json:"email,omitempty"`
+}
+
+type ImportUsersRequest struct {
+	Users []ImportUser `json:"users"`
+}
+
+// ImportRowResult reports what happened to one input row.
+type ImportRowResult struct {
+	Row    int      `json:"row"` // 1-based, not counting the CSV header
+	Email  string   `json:"email,omitempty"`
+	Status string   `json:"status"`
+	UserID *uint    `json:"user_id,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// importCSVColumns are the accepted CSV header names. OAuth identities are
+// given as one provider ID column per provider.
+var importCSVColumns = map[string]func(*ImportUser, string){
+	"email":         func(u *ImportUser, v string) { u.Email = v },
+	"username":      func(u *ImportUser, v string) { u.Username = v },
+	"password_hash": func(u *ImportUser, v string) { u.PasswordHash = v },
+	"role":          func(u *ImportUser, v string) { u.Role = models.Role(v) },
+	"locale":        func(u *ImportUser, v string) { u.Locale = models.Locale(v) },
+	"first_name":    func(u *ImportUser, v string) { u.FirstName = v },
+	"last_name":     func(u *ImportUser, v string) { u.LastName = v },
+	"display_name":  func(u *ImportUser, v string) { u.DisplayName = v },
+	"email_verified": func(u *ImportUser, v string) {
+		if v == "" {
+			return
+		}
+		verified, err := strconv.ParseBool(v)
+		if err != nil {
+			u.errors = append(u.errors, "email_verified must be true or false")
+		}
+		u.EmailVerified = verified
+	},
+	"google_id": func(u *ImportUser, v string) { addImportIdentity(u, models.OAuthProviderGoogle, v) },
+	"github_id": func(u *ImportUser, v string) { addImportIdentity(u, models.OAuthProviderGithub, v) },
+}
+
+func addImportIdentity(u *ImportUser, provider models.OAuthProvider, providerID string) {
+	if providerID != "" {
+		u.OAuth = append(u.OAuth, ImportOAuthIdentity{Provider: provider, ProviderID: providerID})
+	}
+}
+
+// AdminImportUsers creates accounts in bulk from a JSON body ({"users":
+// [...]}), a CSV file with a header row, or another provider's export named
+// by the format query parameter. Every row is validated and reported on;
+// valid rows are created unless dry_run=true is set.
+func (h *Handler) AdminImportUsers(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+	dryRun := c.QueryBool("dry_run")
+
+	users, err := h.parseImportBody(c)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return utils.NewReasonError(400, reasonImportEmpty, "No users to import")
+	}
+	if h.userImport.maxRows > 0 && len(users) > h.userImport.maxRows {
+		err := utils.NewReasonError(400, reasonImportTooLarge, "Too many users in one import")
+		err.Details = map[string]any{"max_rows": h.userImport.maxRows}
+		return err
+	}
+
+	results := make([]ImportRowResult, len(users))
+	counts := map[string]int{}
+	seen := importBatch{tenantID: middleware.TenantID(c), emails: map[string]bool{}, usernames: map[string]bool{}, identities: map[string]bool{}}
+	for i := range users {
+		user := &users[i]
+		result := ImportRowResult{Row: i + 1, Email: utils.NormalizeEmail(user.Email)}
+
+		record, problems, err := h.validateImportUser(user, &seen)
+		switch {
+		case err != nil:
+			return fmt.Errorf("import: validate row %d: %w", i+1, err)
+		case len(problems) > 0:
+			result.Status, result.Errors = importStatusInvalid, problems
+		case dryRun:
+			result.Status = importStatusValid
+		default:
+			if err := h.createImportedUser(record, user.OAuth); err != nil {
+				log.Printf("import: failed to create user from row %d: %v", i+1, err)
+				result.Status, result.Errors = importStatusFailed, []string{"Could not save user"}
+			} else {
+				result.Status, result.UserID = importStatusCreated, &record.ID
+			}
+		}
+
+		counts[result.Status]++
+		results[i] = result
+	}
+
+	if !dryRun {
+		h.recordAuditEvent(models.AuditLog{
+			Event:     models.AuditEventUsersImported,
+			ActorID:   &claims.Subject,
+			IPAddress: c.IP(),
+			UserAgent: c.Get("User-Agent"),
+		}, fiber.Map{
+			"created": counts[importStatusCreated],
+			"invalid": counts[importStatusInvalid],
+			"failed":  counts[importStatusFailed],
+		})
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Import processed",
+		Data: fiber.Map{
+			"dry_run": dryRun,
+			"total":   len(users),
+			"valid":   counts[importStatusValid],
+			"created": counts[importStatusCreated],
+			"invalid": counts[importStatusInvalid],
+			"failed":  counts[importStatusFailed],
+			"results": results,
+		},
+	})
+}
+
+// parseImportBody decodes the import rows according to the format query
+// parameter, or the Content-Type when it is not set.
+func (h *Handler) parseImportBody(c *fiber.Ctx) ([]ImportUser, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	format := c.Query("format")
+	if format == "" && mediaType != "text/csv" {
+		var req ImportUsersRequest
+		if err := parseJSONBodyLimit(c, &req, h.userImport.maxBytes); err != nil {
+			return nil, err
+		}
+		return req.Users, nil
+	}
+
+	body := c.Body()
+	if h.userImport.maxBytes > 0 && len(body) > h.userImport.maxBytes {
+		err := utils.NewReasonError(400, reasonBodyTooLarge, "Request body is too large")
+		err.Details = map[string]any{"max_bytes": h.userImport.maxBytes}
+		return nil, err
+	}
+	if format == "" {
+		return parseImportCSV(body)
+	}
+
+	adapter, ok := importAdapters[format]
+	if !ok {
+		err := utils.NewReasonError(400, reasonUnknownImportFormat, "Unknown import format "+format)
+		err.Details = map[string]any{"formats": []string{"auth0", "firebase", "cognito"}}
+		return nil, err
+	}
+	return adapter(body)
+}
+
+// parseImportCSV reads CSV rows into import users. The header row names the
+// columns, in any order; only the columns in importCSVColumns are accepted.
+func parseImportCSV(body []byte) ([]ImportUser, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, utils.NewReasonError(400, reasonInvalidCSV, "Malformed CSV header")
+	}
+
+	setters := make([]func(*ImportUser, string), len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		setter, ok := importCSVColumns[name]
+		if !ok {
+			err := utils.NewReasonError(400, reasonUnknownCSVField, "Unknown CSV column "+name)
+			err.Details = map[string]any{"column": name}
+			return nil, err
+		}
+		setters[i] = setter
+	}
+
+	var users []ImportUser
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return users, nil
+		}
+		if err != nil {
+			reasonErr := utils.NewReasonError(400, reasonInvalidCSV, "Malformed CSV")
+			reasonErr.Details = map[string]any{"row": len(users) + 1}
+			return nil, reasonErr
+		}
+
+		var user ImportUser
+		for i, value := range record {
+			setters[i](&user, strings.TrimSpace(value))
+		}
+		users = append(users, user)
+	}
+}
+
+// importBatch tracks the unique values already claimed by earlier rows of an
+// import into tenantID.
+type importBatch struct {
+	tenantID   uint
+	emails     map[string]bool
+	usernames  map[string]bool
+	identities map[string]bool // "<provider>:<provider id>"
+}
+
+// validateImportUser checks one row against the account rules, the existing
+// users and the rows before it, and returns the user to create. Problems are
+// reported as messages; err is only set for database failures.
+func (h *Handler) validateImportUser(in *ImportUser, seen *importBatch) (user *models.User, problems []string, err error) {
+	problems = append(problems, in.errors...)
+
+	email := utils.NormalizeEmail(in.Email)
+	switch {
+	case email == "":
+		problems = append(problems, "email is required")
+	case validate.Var(email, "email") != nil:
+		problems = append(problems, "email is invalid")
+	case seen.emails[email]:
+		problems = append(problems, "email appears earlier in the import")
+	default:
+		registered, err := h.stores.Users().EmailRegistered(seen.tenantID, email)
+		if err != nil {
+			return nil, nil, err
+		}
+		if registered {
+			problems = append(problems, "email is already registered")
+		}
+	}
+
+	username := h.usernamePolicy.Normalize(in.Username)
+	if username == "" {
+		if username, err = h.uniqueImportUsername(service.SuggestUsername(h.usernamePolicy, email, in.DisplayName), seen); err != nil {
+			return nil, nil, err
+		}
+	} else if err := h.usernamePolicy.Validate(username); err != nil {
+		problems = append(problems, err.Error())
+	} else if seen.usernames[username] {
+		problems = append(problems, "username appears earlier in the import")
+	} else if taken, err := h.importUsernameTaken(seen.tenantID, username); err != nil {
+		return nil, nil, err
+	} else if taken {
+		problems = append(problems, "username is already taken")
+	}
+
+	if in.PasswordHash != "" && !utils.IsImportedPasswordHash(in.PasswordHash) {
+		problems = append(problems, "password_hash must be a bcrypt hash")
+	}
+	if in.PasswordHash == "" && len(in.OAuth) == 0 && !in.PasswordResetRequired {
+		problems = append(problems, "a password_hash, an OAuth identity or password_reset_required is required")
+	}
+
+	role := in.Role
+	if role == "" {
+		role = models.RoleUser
+	} else if role != models.RoleUser && role != models.RoleAdmin {
+		problems = append(problems, "role must be user or admin")
+	}
+
+	locale := in.Locale
+	if locale == "" {
+		locale = models.LocaleEN
+	} else if !isValidLocale(locale) {
+		problems = append(problems, "locale must be one of en, ro, de")
+	}
+
+	names := []*string{&in.FirstName, &in.LastName, &in.DisplayName}
+	for i, value := range names {
+		field := profileTextFields[i]
+		text, err := normalizeProfileText(*value, field.maxLength, field.multiline)
+		if err != nil {
+			problems = append(problems, field.column+" "+err.Error())
+		}
+		*value = text
+	}
+
+	identities := make([]string, 0, len(in.OAuth))
+	for i := range in.OAuth {
+		identity := &in.OAuth[i]
+		identity.ProviderID = strings.TrimSpace(identity.ProviderID)
+		identity.Email = utils.NormalizeEmail(identity.Email)
+		if identity.Email == "" {
+			identity.Email = email
+		}
+
+		key := string(identity.Provider) + ":" + identity.ProviderID
+		switch {
+		case identity.Provider != models.OAuthProviderGoogle && identity.Provider != models.OAuthProviderGithub:
+			problems = append(problems, "oauth provider must be google or github")
+			continue
+		case identity.ProviderID == "":
+			problems = append(problems, string(identity.Provider)+" provider_id is required")
+			continue
+		case seen.identities[key] || slices.Contains(identities, key):
+			problems = append(problems, string(identity.Provider)+" identity appears earlier in the import")
+			continue
+		}
+
+		var count int64
+		if err := h.db.Model(&models.OAuthAccount{}).
+			Joins("JOIN users ON users.id = oauth_accounts.user_id AND users.tenant_id = ?", seen.tenantID).
+			Where("oauth_accounts.provider = ? AND oauth_accounts.provider_id = ?", identity.Provider, identity.ProviderID).
+			Count(&count).Error; err != nil {
+			return nil, nil, err
+		}
+		if count > 0 {
+			problems = append(problems, string(identity.Provider)+" identity is already linked to a user")
+		}
+		identities = append(identities, key)
+	}
+
+	// Claim the values even for invalid rows so later duplicates are reported
+	seen.emails[email] = true
+	seen.usernames[username] = true
+	for _, key := range identities {
+		seen.identities[key] = true
+	}
+
+	if len(problems) > 0 {
+		return nil, problems, nil
+	}
+
+	accountType := models.AccountTypeEmail
+	switch {
+	case in.PasswordHash == "" && !in.PasswordResetRequired:
+		accountType = models.AccountTypeOAuth
+	case len(in.OAuth) > 0:
+		accountType = models.AccountTypeHybrid
+	}
+
+	user = &models.User{
+		TenantID:    seen.tenantID,
+		Username:    username,
+		Email:       email,
+		EmailHash:   utils.EmailBlindIndex(email),
+		Password:    in.PasswordHash,
+		AccountType: accountType,
+		Role:        role,
+		Locale:      locale,
+		FirstName:   in.FirstName,
+		LastName:    in.LastName,
+		DisplayName: in.DisplayName,
+
+		PasswordResetRequired: in.PasswordResetRequired,
+	}
+	if in.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	return user, nil, nil
+}
+
+// importUsernameTaken reports whether an existing or recently given up
+// username of the tenant conflicts with username.
+func (h *Handler) importUsernameTaken(tenantID uint, username string) (bool, error) {
+	registered, err := h.stores.Users().UsernameRegistered(tenantID, username)
+	if err != nil || registered {
+		return registered, err
+	}
+	return h.usernameReserved(h.stores.Users(), tenantID, username, 0)
+}
+
+// uniqueImportUsername appends a numeric suffix to base until it is free in
+// both the database and the import.
+func (h *Handler) uniqueImportUsername(base string, seen *importBatch) (string, error) {
+	username := base
+	for suffix := 1; suffix <= 1000; suffix++ {
+		if !seen.usernames[username] {
+			taken, err := h.importUsernameTaken(seen.tenantID, username)
+			if err != nil {
+				return "", err
+			}
+			if !taken {
+				return username, nil
+			}
+		}
+		username = fmt.Sprintf("%s_%d", base, suffix)
+	}
+	return "", fmt.Errorf("unable to generate unique username for %q", base)
+}
+
+// createImportedUser saves a validated user and its OAuth identities.
+func (h *Handler) createImportedUser(user *models.User, identities []ImportOAuthIdentity) error {
+	return h.stores.Transaction(func(tx store.Store) error {
+		if err := tx.Users().Create(user); err != nil {
+			return err
+		}
+		for _, identity := range identities {
+			account := models.OAuthAccount{
+				UserID:     user.ID,
+				Provider:   identity.Provider,
+				ProviderID: identity.ProviderID,
+				Email:      identity.Email,
+			}
+			if err := tx.OAuth().Create(&account); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
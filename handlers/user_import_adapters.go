@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/utils"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// importAdapters convert other providers' user exports into import rows,
+// keyed by the format query parameter of AdminImportUsers.
+var importAdapters = map[string]func(body []byte) ([]ImportUser, error){
+	"auth0":    parseAuth0Export,
+	"firebase": parseFirebaseExport,
+	"cognito":  parseCognitoExport,
+}
+
+// auth0Providers maps Auth0 connection strategies to our providers. The
+// "auth0" database connection is the password credential, not an identity.
+var auth0Providers = map[string]models.OAuthProvider{
+	"google-oauth2": models.OAuthProviderGoogle,
+	"github":        models.OAuthProviderGithub,
+}
+
+type auth0ExportUser struct {
+	UserID        string `json:"user_id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Username      string `json:"username"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Name          string `json:"name"`
+	PasswordHash  string `json:"passwordHash"` // Merged in from the password hash export Auth0 support provides
+	Identities    []struct {
+		Provider string          `json:"provider"`
+		UserID   json.RawMessage `json:"user_id"` // A string or, for some providers, a number
+	} `json:"identities"`
+}
+
+// parseAuth0Export reads an Auth0 user export job file: newline-delimited
+// JSON objects, or a JSON array of them. Auth0 password hashes are bcrypt.
+func parseAuth0Export(body []byte) ([]ImportUser, error) {
+	var records []auth0ExportUser
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return nil, malformedExport("Auth0")
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		scanner.Buffer(nil, len(body)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var record auth0ExportUser
+			if err := json.Unmarshal(line, &record); err != nil {
+				return nil, malformedExport("Auth0")
+			}
+			records = append(records, record)
+		}
+	}
+
+	users := make([]ImportUser, len(records))
+	for i, record := range records {
+		user := ImportUser{
+			Email:         record.Email,
+			Username:      record.Username,
+			PasswordHash:  record.PasswordHash,
+			EmailVerified: record.EmailVerified,
+			FirstName:     record.GivenName,
+			LastName:      record.FamilyName,
+		}
+		if record.Name != record.Email {
+			user.DisplayName = record.Name
+		}
+
+		// Exports without the identities field still carry the primary
+		// identity in the user_id, as "<provider>|<id>"
+		if len(record.Identities) == 0 {
+			if provider, id, ok := strings.Cut(record.UserID, "|"); ok {
+				addAuth0Identity(&user, provider, id)
+			}
+		}
+		for _, identity := range record.Identities {
+			addAuth0Identity(&user, identity.Provider, strings.Trim(string(identity.UserID), `"`))
+		}
+		users[i] = user
+	}
+	return users, nil
+}
+
+func addAuth0Identity(user *ImportUser, provider, id string) {
+	if provider == "auth0" {
+		return
+	}
+	if ours, ok := auth0Providers[provider]; ok {
+		user.OAuth = append(user.OAuth, ImportOAuthIdentity{Provider: ours, ProviderID: id})
+		return
+	}
+	user.errors = append(user.errors, "unsupported identity provider "+provider)
+}
+
+// firebaseProviders maps Firebase provider IDs to ours. The "password"
+// provider is the password credential, not an identity.
+var firebaseProviders = map[string]models.OAuthProvider{
+	"google.com": models.OAuthProviderGoogle,
+	"github.com": models.OAuthProviderGithub,
+}
+
+type firebaseExport struct {
+	Users []struct {
+		Email            string `json:"email"`
+		EmailVerified    bool   `json:"emailVerified"`
+		PasswordHash     string `json:"passwordHash"` // base64 modified scrypt hash
+		Salt             string `json:"salt"`
+		DisplayName      string `json:"displayName"`
+		ProviderUserInfo []struct {
+			ProviderID string `json:"providerId"`
+			RawID      string `json:"rawId"`
+			Email      string `json:"email"`
+		} `json:"providerUserInfo"`
+	} `json:"users"`
+}
+
+// parseFirebaseExport reads the output of `firebase auth:export --format=json`.
+// Password hashes need the project's hash parameters to be configured (see
+// utils.InitImportedHashes) and are replaced with bcrypt on first login.
+func parseFirebaseExport(body []byte) ([]ImportUser, error) {
+	var export firebaseExport
+	if err := json.Unmarshal(body, &export); err != nil {
+		return nil, malformedExport("Firebase")
+	}
+
+	users := make([]ImportUser, len(export.Users))
+	for i, record := range export.Users {
+		user := ImportUser{
+			Email:         record.Email,
+			EmailVerified: record.EmailVerified,
+			DisplayName:   record.DisplayName,
+		}
+		if record.PasswordHash != "" {
+			hash, err := utils.FirebasePasswordHash(record.PasswordHash, record.Salt)
+			if err != nil {
+				user.errors = append(user.errors, err.Error())
+			}
+			user.PasswordHash = hash
+		}
+
+		for _, info := range record.ProviderUserInfo {
+			if info.ProviderID == "password" {
+				continue
+			}
+			provider, ok := firebaseProviders[info.ProviderID]
+			if !ok {
+				user.errors = append(user.errors, "unsupported identity provider "+info.ProviderID)
+				continue
+			}
+			user.OAuth = append(user.OAuth, ImportOAuthIdentity{Provider: provider, ProviderID: info.RawID, Email: info.Email})
+		}
+		users[i] = user
+	}
+	return users, nil
+}
+
+// cognitoProviders maps Cognito identity provider types, or names for OIDC
+// providers, to ours.
+var cognitoProviders = map[string]models.OAuthProvider{
+	"google": models.OAuthProviderGoogle,
+	"github": models.OAuthProviderGithub,
+}
+
+type cognitoExport struct {
+	Users []struct {
+		Username   string `json:"Username"`
+		Attributes []struct {
+			Name  string `json:"Name"`
+			Value string `json:"Value"`
+		} `json:"Attributes"`
+	} `json:"Users"`
+}
+
+// parseCognitoExport reads the output of `aws cognito-idp list-users`.
+// Cognito does not export password hashes, so users without a federated
+// identity are imported with a required password reset.
+func parseCognitoExport(body []byte) ([]ImportUser, error) {
+	var export cognitoExport
+	if err := json.Unmarshal(body, &export); err != nil {
+		return nil, malformedExport("Cognito")
+	}
+
+	users := make([]ImportUser, len(export.Users))
+	for i, record := range export.Users {
+		attributes := make(map[string]string, len(record.Attributes))
+		for _, attribute := range record.Attributes {
+			attributes[attribute.Name] = attribute.Value
+		}
+
+		user := ImportUser{
+			Email:         attributes["email"],
+			Username:      attributes["preferred_username"],
+			EmailVerified: attributes["email_verified"] == "true",
+			FirstName:     attributes["given_name"],
+			LastName:      attributes["family_name"],
+			DisplayName:   attributes["name"],
+		}
+
+		if raw := attributes["identities"]; raw != "" {
+			var identities []struct {
+				UserID       string `json:"userId"`
+				ProviderName string `json:"providerName"`
+				ProviderType string `json:"providerType"`
+			}
+			if err := json.Unmarshal([]byte(raw), &identities); err != nil {
+				user.errors = append(user.errors, "identities attribute is not valid JSON")
+			}
+			for _, identity := range identities {
+				provider, ok := cognitoProviders[strings.ToLower(identity.ProviderType)]
+				if !ok {
+					provider, ok = cognitoProviders[strings.ToLower(identity.ProviderName)]
+				}
+				if !ok {
+					user.errors = append(user.errors, "unsupported identity provider "+identity.ProviderName)
+					continue
+				}
+				user.OAuth = append(user.OAuth, ImportOAuthIdentity{Provider: provider, ProviderID: identity.UserID})
+			}
+		}
+		user.PasswordResetRequired = len(user.OAuth) == 0
+		users[i] = user
+	}
+	return users, nil
+}
+
+func malformedExport(provider string) error {
+	return utils.NewReasonError(400, reasonMalformedExport, "Malformed "+provider+" export")
+}
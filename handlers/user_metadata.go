@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// setupUserMetadata reads USER_METADATA_MAX_BYTES (default 4096).
+func (h *Handler) setupUserMetadata() {
+	h.maxUserMetadataBytes = envInt("USER_METADATA_MAX_BYTES", 4096)
+}
+
+// UpdateMyMetadata applies a JSON merge patch (RFC 7396) to the
+// authenticated user's metadata.
+func (h *Handler) UpdateMyMetadata(c *fiber.Ctx) error {
+	token := c.Locals("user").(*jwt.Token)
+	claims := token.Claims.(*utils.JWTClaims)
+
+	var patch map[string]any
+	if err := h.parseJSONBody(c, &patch); err != nil {
+		return err
+	}
+
+	metadata, err := h.patchUserMetadata(claims.Subject, patch)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Metadata updated",
+		Data:    metadata,
+	})
+}
+
+// AdminUpdateUserMetadata applies a JSON merge patch (RFC 7396) to the
+// metadata of the user in :id.
+func (h *Handler) AdminUpdateUserMetadata(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	var patch map[string]any
+	if err := h.parseJSONBody(c, &patch); err != nil {
+		return err
+	}
+
+	metadata, err := h.patchUserMetadata(uint(userID), patch)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Metadata updated",
+		Data:    metadata,
+	})
+}
+
+// patchUserMetadata merges patch into the user's metadata under a row lock,
+// so concurrent patches to different keys do not overwrite each other, and
+// returns the result.
+func (h *Handler) patchUserMetadata(userID uint, patch map[string]any) (map[string]any, error) {
+	if patch == nil {
+		return nil, fiber.NewError(400, "Metadata patch must be a JSON object")
+	}
+
+	var user *models.User
+	err := h.stores.Transaction(func(tx store.Store) error {
+		var err error
+		user, err = tx.Users().LockByID(userID)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && user.AnonymizedAt != nil) {
+			return fiber.NewError(404, "User not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		merged, _ := mergeJSONPatch(user.Metadata, patch).(map[string]any)
+		encoded, err := json.Marshal(merged)
+		if err != nil {
+			return fmt.Errorf("failed to encode metadata: %w", err)
+		}
+		if len(encoded) > h.maxUserMetadataBytes {
+			err := utils.NewReasonError(400, "metadata_too_large", "Metadata is too large")
+			err.Details = map[string]any{"max_bytes": h.maxUserMetadataBytes}
+			return err
+		}
+
+		user.Metadata = merged
+		return tx.Users().SaveMetadata(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Metadata == nil {
+		return map[string]any{}, nil
+	}
+	return user.Metadata, nil
+}
+
+// mergeJSONPatch applies an RFC 7396 merge patch to target: object members
+// are merged recursively, null removes a member, and any other value
+// replaces the target.
+func mergeJSONPatch(target any, patch any) any {
+	patchObject, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]any)
+	if !ok {
+		targetObject = map[string]any{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergeJSONPatch(targetObject[key], value)
+	}
+	return targetObject
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"api/database/models"
+	"api/middleware"
+	"api/service"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// reasonUsernameChangeCooldown is returned when a username change comes too
+// soon after the previous one.
+const reasonUsernameChangeCooldown = "username_change_cooldown"
+
+// usernameChangeConfig controls username changes. Zero durations disable the
+// cooldown and the redirect.
+type usernameChangeConfig struct {
+	cooldown      time.Duration // Minimum time between two changes
+	redirectGrace time.Duration // How long an old username keeps resolving to its previous owner
+}
+
+// setupUsernameChanges reads USERNAME_CHANGE_COOLDOWN and
+// USERNAME_REDIRECT_GRACE (both disabled by default).
+func (h *Handler) setupUsernameChanges() {
+	h.usernameChanges.cooldown = envDuration("USERNAME_CHANGE_COOLDOWN", 0)
+	h.usernameChanges.redirectGrace = envDuration("USERNAME_REDIRECT_GRACE", 0)
+}
+
+// checkUsernameChangeAllowed refuses a change within the cooldown of the
+// user's previous one.
+func (h *Handler) checkUsernameChangeAllowed(tx *gorm.DB, userID uint) error {
+	if h.usernameChanges.cooldown == 0 {
+		return nil
+	}
+
+	var last models.UsernameHistory
+	err := tx.Where("user_id = ?", userID).Order("changed_at DESC").First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check username history: %w", err)
+	}
+
+	next := last.ChangedAt.Add(h.usernameChanges.cooldown)
+	if time.Now().Before(next) {
+		err := utils.NewReasonError(429, reasonUsernameChangeCooldown, "Your username was changed recently. Try again later.")
+		err.Details = map[string]any{"next_change_at": next}
+		return err
+	}
+	return nil
+}
+
+// usernameReserved reports whether username was given up by another user of
+// the tenant within the redirect grace period, so it still resolves to them and cannot
+// be claimed.
+func (h *Handler) usernameReserved(users store.UserStore, tenantID uint, username string, userID uint) (bool, error) {
+	return service.UsernameReserved(users, tenantID, username, userID, h.usernameChanges.redirectGrace)
+}
+
+// recordUsernameChange remembers the username the user is giving up.
+func recordUsernameChange(tx *gorm.DB, userID uint, previous string) error {
+	return tx.Create(&models.UsernameHistory{UserID: userID, Username: previous}).Error
+}
+
+// AdminGetUserByUsername returns the tenant's user with the given username.
+// During the redirect grace period a previous username resolves to the user
+// who gave it up, and redirected_from names the username that was looked up.
+func (h *Handler) AdminGetUserByUsername(c *fiber.Ctx) error {
+	username := h.usernamePolicy.Normalize(c.Params("username"))
+	tenantID := middleware.TenantID(c)
+
+	user, err := h.stores.Users().ByUsername(tenantID, username)
+	if err == nil {
+		return c.JSON(utils.Response{
+			Success: true,
+			Code:    200,
+			Message: "Success",
+			Data:    fiber.Map{"user": user},
+		})
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("failed to look up username: %w", err)
+	}
+
+	if h.usernameChanges.redirectGrace > 0 {
+		user, err = h.stores.Users().ByPreviousUsername(tenantID, username, time.Now().Add(-h.usernameChanges.redirectGrace))
+		if err == nil {
+			return c.JSON(utils.Response{
+				Success: true,
+				Code:    200,
+				Message: "Success",
+				Data:    fiber.Map{"user": user, "redirected_from": username},
+			})
+		}
+	}
+
+	return fiber.NewError(404, "User not found")
+}
+
+// AdminListUsernameHistory returns the previous usernames of the user in :id,
+// newest first.
+func (h *Handler) AdminListUsernameHistory(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return fiber.NewError(400, "Invalid user id")
+	}
+
+	var history []models.UsernameHistory
+	if err := h.db.Where("user_id = ?", userID).Order("changed_at DESC").Find(&history).Error; err != nil {
+		return fiber.NewError(500, "Failed to fetch username history")
+	}
+
+	return c.JSON(utils.Response{
+		Success: true,
+		Code:    200,
+		Message: "Success",
+		Data:    history,
+	})
+}
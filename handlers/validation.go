@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"api/middleware"
+	"api/utils"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// reasonValidationFailed is returned when a decoded body fails its validate
+// tags. The response data carries an errors map of field to message.
+const reasonValidationFailed = "validation_failed"
+
+// validate checks the validate tags of request structs. Fields are named by
+// their JSON names, so error keys match what the client sent.
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			return ""
+		case "":
+			return strings.ToLower(field.Name)
+		}
+		return name
+	})
+	return v
+}
+
+// validateBody runs the validate tags of a decoded request body. Bodies that
+// are not structs, such as merge patches, are not validated.
+func validateBody(c *fiber.Ctx, body any) error {
+	value := reflect.ValueOf(body)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	err := validate.Struct(body)
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	locale := middleware.MessageLocale(c)
+	fields := make(map[string]string, len(invalid))
+	for _, fieldErr := range invalid {
+		// Drop the struct name so nested fields read "parent.child"
+		_, field, _ := strings.Cut(fieldErr.Namespace(), ".")
+		if _, seen := fields[field]; !seen {
+			fields[field] = utils.TranslateMessage(locale, validationMessage(fieldErr))
+		}
+	}
+
+	reasonErr := utils.NewReasonError(400, reasonValidationFailed, "Request validation failed")
+	reasonErr.Details = map[string]any{"errors": fields}
+	return reasonErr
+}
+
+// validationMessage describes a failed validate tag. Messages are catalog
+// keys, so they are translated like other response messages.
+func validationMessage(fieldErr validator.FieldError) string {
+	param := fieldErr.Param()
+	unit := ""
+	switch fieldErr.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map:
+		unit = " items"
+	}
+
+	switch fieldErr.Tag() {
+	case "required":
+		return "This field is required"
+	case "email":
+		return "Must be a valid email address"
+	case "url", "http_url":
+		return "Must be a valid URL"
+	case "oneof":
+		return "Must be one of: " + strings.ReplaceAll(param, " ", ", ")
+	case "min", "gte":
+		if unit != "" {
+			return "Must have at least " + param + unit
+		}
+		return "Must be " + param + " or more"
+	case "max", "lte":
+		if unit != "" {
+			return "Must have at most " + param + unit
+		}
+		return "Must be " + param + " or less"
+	}
+	return "Invalid value"
+}
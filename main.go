@@ -1,105 +1,57 @@
 package main
 
 import (
-	"api/database"
-	"api/database/models"
-	"api/middleware"
-	"api/routes"
-	"api/utils"
+	"api/config"
+	"api/server"
+	"flag"
 	"fmt"
 	"log"
+	"strings"
 
-	"os"
-
-	jwtware "github.com/gofiber/contrib/jwt"
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/monitor"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	godotenv.Load()
-
-	PORT := os.Getenv("PORT")
-
-	database.Init()
-	utils.InitOAuth() // Initialize OAuth configurations
-
-	db := database.GetInstance()
-
-	app := fiber.New(fiber.Config{
-		Prefork:      false,
-		ErrorHandler: middleware.NewErrorHandler(log.Default()),
-	})
-
-	// Attach request id middleware early so the error handler can include it.
-	app.Use(middleware.RequestID())
-
-	app.Use("/metrics", monitor.New())
-
-	api := app.Group("/api/v1")
-
-	// Public auth routes (register/login/refresh) should not require JWTs.
-	auth := api.Group("/auth")
-	routes.AuthRoutes(auth)
-
-	// Protected routes: apply JWT middleware only to this subgroup. Any routes
-	// that require authentication should be registered under `protected`.
-	protected := api.Group("/")
-	protected.Use(jwtware.New(jwtware.Config{
-		ContextKey: "user",
-		Claims:     &utils.JWTClaims{},
-		SigningKey: jwtware.SigningKey{JWTAlg: "HS256", Key: []byte(os.Getenv("JWT_SECRET"))},
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			return c.JSON(utils.Response{
-				Success: false,
-				Code:    401,
-				Message: "Unauthorized",
-				Data:    err.Error(),
-			})
-		},
-		SuccessHandler: func(c *fiber.Ctx) error {
-			token := c.Locals("user").(*jwt.Token)
-			claims := token.Claims.(*utils.JWTClaims)
-			jti := claims.ID
+	demo := flag.Bool("demo", false, "run against an embedded SQLite database with seeded users and captured emails")
+	flag.Parse()
 
-			// Look up session by JTI. Use Where + First to query by the JTI column.
-			var session models.Session
-			err := db.Where(&models.Session{JTI: jti}).First(&session).Error
-
-			if err != nil {
-				return c.JSON(utils.Response{
-					Success: false,
-					Code:    401,
-					Message: "Unauthorized",
-					Data:    nil,
-				})
-			}
+	godotenv.Load()
 
-			if session.Revoked {
-				return c.JSON(utils.Response{
-					Success: false,
-					Code:    401,
-					Message: "Unauthorized",
-					Data:    nil,
-				})
-			}
+	// Config files only fill in what the environment and .env leave unset
+	files, err := config.LoadFile()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(files) > 0 {
+		log.Printf("config: loaded %s", strings.Join(files, ", "))
+	}
 
-			return c.Next()
-		},
-	}))
+	// Secrets from a secrets manager override all of the above
+	watchSecrets := setupSecrets()
 
-	userGroup := protected.Group("/user")
-	routes.UserRoutes(userGroup)
+	if flag.Arg(0) == "seed" {
+		runSeed()
+		return
+	}
 
-	app.Get("/", func(c *fiber.Ctx) error {
-		return c.SendString("Hello world")
-	})
+	opts := []server.Option{server.WithSIGHUPReload()}
+	var afterStart func()
+	if *demo {
+		demoOpts, start := demoMode()
+		opts, afterStart = append(opts, demoOpts...), start
+	}
 
-	err := app.Listen(fmt.Sprintf(":%s", PORT))
+	app := server.NewApp(opts...)
+	if afterStart != nil {
+		afterStart()
+	}
+	if watchSecrets != nil {
+		watchSecrets()
+	}
+	stopped := shutdownOnSignal(app)
 
-	if err != nil {
+	if err := server.Listen(app, fmt.Sprintf(":%s", config.Get().Port)); err != nil {
 		log.Fatal(err)
 	}
+	<-stopped
 }
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"api/database/models"
+	"api/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// RequireAdmin rejects requests whose authenticated user does not have the
+// admin role. It must be mounted after the JWT middleware so that
+// c.Locals("user") holds a *jwt.Token with *utils.JWTClaims. The role is read
+// from the database on every request so demotions take effect immediately.
+func RequireAdmin(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := c.Locals("user").(*jwt.Token)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+		claims, ok := token.Claims.(*utils.JWTClaims)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+
+		var user models.User
+		if err := db.Select("id", "role").First(&user, claims.Subject).Error; err != nil {
+			return fiber.NewError(403, "Forbidden")
+		}
+
+		if user.Role != models.RoleAdmin {
+			return fiber.NewError(403, "Forbidden")
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import "gorm.io/gorm"
+
+// Caches are the settings the middleware reads from the database. Handlers
+// that change the underlying rows invalidate them, so they share one set.
+type Caches struct {
+	IPRules     *IPRuleCache
+	Tenants     *TenantCache
+	Maintenance *MaintenanceCache
+}
+
+// NewCaches returns empty caches of the settings stored in db.
+func NewCaches(db *gorm.DB) Caches {
+	return Caches{
+		IPRules:     NewIPRuleCache(db),
+		Tenants:     NewTenantCache(db),
+		Maintenance: NewMaintenanceCache(db),
+	}
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// Compress compresses responses with brotli or gzip, whichever the client
+// prefers in Accept-Encoding. COMPRESSION_LEVEL selects the trade-off:
+// "speed", "default" or "best", or "off" to disable it. Responses smaller
+// than COMPRESSION_MIN_SIZE bytes (default 1024) and content that is not
+// text, JSON or another compressible type are sent as is.
+//
+// Responses that set cookies are never compressed. They carry refresh and
+// CSRF tokens, which a BREACH-style attack could recover from compressed
+// sizes when other parts of the response echo attacker input.
+func Compress() fiber.Handler {
+	var brotliLevel, gzipLevel int
+	switch level := strings.ToLower(os.Getenv("COMPRESSION_LEVEL")); level {
+	case "", "default":
+		brotliLevel, gzipLevel = fasthttp.CompressBrotliDefaultCompression, fasthttp.CompressDefaultCompression
+	case "speed":
+		brotliLevel, gzipLevel = fasthttp.CompressBrotliBestSpeed, fasthttp.CompressBestSpeed
+	case "best":
+		brotliLevel, gzipLevel = fasthttp.CompressBrotliBestCompression, fasthttp.CompressBestCompression
+	case "off":
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	default:
+		log.Fatalf("unsupported COMPRESSION_LEVEL %q: use off, speed, default or best", level)
+	}
+
+	minSize := 1024
+	if value := os.Getenv("COMPRESSION_MIN_SIZE"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatalf("invalid COMPRESSION_MIN_SIZE %q", value)
+		}
+		minSize = n
+	}
+
+	compress := fasthttp.CompressHandlerBrotliLevel(func(*fasthttp.RequestCtx) {}, brotliLevel, gzipLevel)
+
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		for range resp.Header.Cookies() {
+			return nil
+		}
+		// Streamed bodies, such as data exports, have no size up front
+		if !resp.IsBodyStream() && len(resp.Body()) < minSize {
+			return nil
+		}
+		compress(c.Context())
+		return nil
+	}
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+)
+
+// corsHandler is the handler for the configured origins, or nil when the API
+// is same-origin only.
+var corsHandler atomic.Pointer[fiber.Handler]
+
+// CORS returns a CORS handler for the origins listed in CORS_ALLOWED_ORIGINS
+// (comma-separated). Credentials are allowed so browsers on those origins can
+// send the refresh_token cookie, which is why wildcard origins are rejected.
+// When no origins are configured the API stays same-origin only. The origins
+// can be changed later with ReloadCORS.
+func CORS() fiber.Handler {
+	if err := ReloadCORS(); err != nil {
+		log.Fatal(err)
+	}
+
+	return func(c *fiber.Ctx) error {
+		if handler := corsHandler.Load(); handler != nil {
+			return (*handler)(c)
+		}
+		return c.Next()
+	}
+}
+
+// ReloadCORS applies the current CORS_ALLOWED_ORIGINS. Invalid origins leave
+// the previous ones in place.
+func ReloadCORS() error {
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ALLOWED_ORIGINS"), ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			return errors.New("CORS_ALLOWED_ORIGINS must list explicit origins; '*' cannot be combined with credentials")
+		}
+		origins = append(origins, origin)
+	}
+
+	if len(origins) == 0 {
+		corsHandler.Store(nil)
+		return nil
+	}
+
+	handler, err := newCORS(cors.Config{
+		AllowOrigins:     strings.Join(origins, ","),
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization," + CSRFHeaderName + ",X-Captcha-Token," + IdempotencyKeyHeader + "," + ResponseFormatHeader + "," + tenantHeader(),
+		ExposeHeaders:    "X-Request-Id,Retry-After,Link," + IdempotentReplayedHeader,
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	if err != nil {
+		return err
+	}
+	corsHandler.Store(&handler)
+	return nil
+}
+
+// newCORS builds the CORS handler, returning the panic cors.New raises for a
+// malformed origin as an error so a reload cannot crash the server.
+func newCORS(cfg cors.Config) (handler fiber.Handler, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("CORS_ALLOWED_ORIGINS: %v", r)
+		}
+	}()
+	return cors.New(cfg), nil
+}
@@ -1,12 +1,15 @@
 package middleware
 
 import (
+	"api/database/models"
 	"api/utils"
 	"errors"
 	"log"
+	"slices"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
 )
 
 // Logger is a lightweight logging interface used by the error handler. The
@@ -15,25 +18,49 @@ type Logger interface {
 	Printf(format string, v ...interface{})
 }
 
+// MessageLocale returns the locale for response messages: the locale claim
+// of the request's access token when there is one, otherwise the best match
+// for the Accept-Language header.
+func MessageLocale(c *fiber.Ctx) models.Locale {
+	if token, ok := c.Locals("user").(*jwt.Token); ok {
+		if claims, ok := token.Claims.(*utils.JWTClaims); ok && slices.Contains(models.SupportedLocales, models.Locale(claims.Locale)) {
+			return models.Locale(claims.Locale)
+		}
+	}
+	return utils.NegotiateLocale(c.Get(fiber.HeaderAcceptLanguage))
+}
+
 // NewErrorHandler returns a Fiber error handler that:
 // - avoids nil dereferences when the incoming error is not a *fiber.Error
 // - logs the original error with a request id, method and path
-// - returns a generic message for 5xx responses and exposes details for 4xx
+// - returns a generic message for 5xx responses other than reason errors
+// - includes the reason of a *utils.ReasonError in the response data
+// - translates the message into the locale chosen by MessageLocale
+// - writes RFC 7807 problem details when the client negotiates them
 // - attempts a minimal fallback if writing the response fails
-func NewErrorHandler(logger Logger) func(*fiber.Ctx, error) error {
+func NewErrorHandler(logger Logger, responder *ErrorResponder) func(*fiber.Ctx, error) error {
 	if logger == nil {
 		logger = log.Default()
 	}
 
 	return func(ctx *fiber.Ctx, err error) error {
 		code := fiber.StatusInternalServerError
+		var data any
 		var fe *fiber.Error
+		var re *utils.ReasonError
 		if errors.As(err, &fe) {
 			// errors.As ensures fe won't be dereferenced when nil, but be
 			// defensive and only use the code if it's non-zero.
 			if fe != nil && fe.Code != 0 {
 				code = fe.Code
 			}
+		} else if errors.As(err, &re) && re.Code != 0 {
+			code = re.Code
+			details := fiber.Map{"reason": re.Reason}
+			for k, v := range re.Details {
+				details[k] = v
+			}
+			data = details
 		}
 
 		// pull request id if set by earlier middleware
@@ -48,21 +75,29 @@ func NewErrorHandler(logger Logger) func(*fiber.Ctx, error) error {
 		logger.Printf("time=%s request_id=%s method=%s path=%s status=%d error=%v",
 			time.Now().Format(time.RFC3339), rid, ctx.Method(), ctx.Path(), code, err)
 
-		// Only reveal error details for client errors (4xx). Server errors get
-		// a generic message to avoid leaking internals.
+		// Only reveal error details for client errors (4xx) and reason
+		// errors, which are written for clients. Other server errors get a
+		// generic message to avoid leaking internals.
 		msg := "Internal server error"
-		if code >= 400 && code < 500 {
+		if code >= 400 && code < 500 || re != nil {
 			msg = err.Error()
 		}
+		locale := MessageLocale(ctx)
+		msg = utils.TranslateMessage(locale, msg)
+		ctx.Set(fiber.HeaderContentLanguage, string(locale))
 
-		resp := utils.Response{
-			Success: false,
-			Code:    uint(code),
-			Message: msg,
-			Data:    nil,
+		var writeErr error
+		if responder.WantsProblem(ctx) {
+			writeErr = responder.WriteProblem(ctx, code, msg, data)
+		} else {
+			writeErr = ctx.Status(code).JSON(utils.Response{
+				Success: false,
+				Code:    uint(code),
+				Message: msg,
+				Data:    data,
+			})
 		}
-
-		if writeErr := ctx.Status(code).JSON(resp); writeErr != nil {
+		if writeErr != nil {
 			// If writing the JSON response failed, log and return a minimal
 			// fallback body.
 			logger.Printf("time=%s request_id=%s error_writing_response=%v",
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"api/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/idempotency"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying a client-chosen key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on responses served from cache.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// idempotencyPendingTTL bounds how long a crashed request keeps its key
+	// marked as in progress on other instances.
+	idempotencyPendingTTL = time.Minute
+)
+
+// idempotencyRecord is the stored outcome of a request. A pending record
+// marks a request that is still running.
+type idempotencyRecord struct {
+	Fingerprint string   `json:"fingerprint"`
+	Pending     bool     `json:"pending,omitempty"`
+	Status      int      `json:"status,omitempty"`
+	ContentType string   `json:"content_type,omitempty"`
+	Cookies     []string `json:"cookies,omitempty"`
+	Body        []byte   `json:"body,omitempty"`
+}
+
+var idempotencyLocks = idempotency.NewMemoryLock()
+
+// Idempotency replays the first successful response to requests repeating an
+// Idempotency-Key, so client retries do not repeat side effects. Keys are
+// scoped to the tenant and to name, which a route mounted under several API
+// versions shares, and bound to the request body: reusing a key with a different body is
+// rejected. Failed responses are not stored so the client can retry them.
+// Stored responses are kept for IDEMPOTENCY_TTL (default 24h) and are
+// encrypted when a key is configured, since they can contain tokens.
+func Idempotency(name string) fiber.Handler {
+	ttl := 24 * time.Hour
+	if value := os.Getenv("IDEMPOTENCY_TTL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid IDEMPOTENCY_TTL %q", value)
+		}
+		ttl = d
+	}
+	storage := sharedStorage()
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return utils.NewReasonError(400, "invalid_idempotency_key", "Idempotency-Key must be at most 255 characters")
+		}
+
+		// Hash the key so client-chosen values never reach Redis
+		storageKey := fmt.Sprintf("idempotency:%d:%s:%s", TenantID(c), name, utils.HashTokenSHA256(key))
+		sum := sha256.Sum256(c.Body())
+		fingerprint := hex.EncodeToString(sum[:])
+
+		if err := idempotencyLocks.Lock(storageKey); err != nil {
+			return err
+		}
+		defer idempotencyLocks.Unlock(storageKey)
+
+		record, err := loadIdempotencyRecord(storage, storageKey)
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			if record.Fingerprint != fingerprint {
+				return utils.NewReasonError(422, "idempotency_key_reused", "Idempotency-Key was already used with a different request")
+			}
+			if record.Pending {
+				return utils.NewReasonError(409, "idempotency_request_in_progress", "A request with this Idempotency-Key is still in progress")
+			}
+			return replayIdempotencyRecord(c, record)
+		}
+
+		pending := idempotencyRecord{Fingerprint: fingerprint, Pending: true}
+		if err := saveIdempotencyRecord(storage, storageKey, pending, idempotencyPendingTTL); err != nil {
+			return err
+		}
+
+		err = c.Next()
+		status := c.Response().StatusCode()
+		if err != nil || status < 200 || status >= 300 {
+			if delErr := storage.Delete(storageKey); delErr != nil {
+				log.Printf("idempotency: failed to release key: %v", delErr)
+			}
+			return err
+		}
+
+		record = &idempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        c.Response().Body(),
+		}
+		c.Response().Header.VisitAllCookie(func(_, cookie []byte) {
+			record.Cookies = append(record.Cookies, string(cookie))
+		})
+		if err := saveIdempotencyRecord(storage, storageKey, *record, ttl); err != nil {
+			// The request succeeded; a retry just won't be deduplicated
+			log.Printf("idempotency: failed to store response: %v", err)
+		}
+
+		return nil
+	}
+}
+
+func loadIdempotencyRecord(storage fiber.Storage, key string) (*idempotencyRecord, error) {
+	raw, err := storage.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("load idempotency record: %w", err)
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	plaintext, err := utils.DecryptValue(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt idempotency record: %w", err)
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(plaintext), &record); err != nil {
+		return nil, fmt.Errorf("decode idempotency record: %w", err)
+	}
+	return &record, nil
+}
+
+func saveIdempotencyRecord(storage fiber.Storage, key string, record idempotencyRecord, ttl time.Duration) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	value, err := utils.EncryptValue(string(raw))
+	if errors.Is(err, utils.ErrEncryptionKeyMissing) {
+		value = string(raw)
+	} else if err != nil {
+		return fmt.Errorf("encrypt idempotency record: %w", err)
+	}
+
+	return storage.Set(key, []byte(value), ttl)
+}
+
+func replayIdempotencyRecord(c *fiber.Ctx, record *idempotencyRecord) error {
+	for _, cookie := range record.Cookies {
+		c.Response().Header.Add(fiber.HeaderSetCookie, cookie)
+	}
+	if record.ContentType != "" {
+		c.Set(fiber.HeaderContentType, record.ContentType)
+	}
+	c.Set(IdempotentReplayedHeader, "true")
+
+	return c.Status(record.Status).Send(record.Body)
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIdempotencyIsScopedToTenant(t *testing.T) {
+	calls := 0
+	app := fiber.New(fiber.Config{ErrorHandler: NewErrorHandler(nil, NewErrorResponder())})
+	app.Use(func(c *fiber.Ctx) error {
+		id, _ := strconv.Atoi(c.Get("X-Test-Tenant"))
+		c.Locals(tenantLocalsKey, uint(id))
+		return c.Next()
+	})
+	app.Post("/register", Idempotency("register"), func(c *fiber.Ctx) error {
+		calls++
+		return c.Status(201).SendString(strconv.Itoa(calls))
+	})
+
+	tests := []struct {
+		name     string
+		tenant   string
+		body     string
+		status   int
+		response string
+		replayed bool
+	}{
+		{"first request", "1", "a", 201, "1", false},
+		{"retry", "1", "a", 201, "1", true},
+		{"other body", "1", "b", 422, "", false},
+		{"other tenant, same body", "2", "a", 201, "2", false},
+		{"other tenant, other body", "3", "b", 201, "3", false},
+		{"other tenant's retry", "2", "a", 201, "2", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/register", strings.NewReader(tt.body))
+			req.Header.Set("X-Test-Tenant", tt.tenant)
+			req.Header.Set(IdempotencyKeyHeader, "same-key")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if replayed := resp.Header.Get(IdempotentReplayedHeader) == "true"; replayed != tt.replayed {
+				t.Errorf("replayed = %t, want %t", replayed, tt.replayed)
+			}
+			if tt.response == "" {
+				return
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.response {
+				t.Errorf("body = %q, want %q", body, tt.response)
+			}
+		})
+	}
+}
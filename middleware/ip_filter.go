@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"api/database/models"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ipRuleRefreshInterval bounds how stale the cached rule set can get when
+// rules are changed by another instance.
+const ipRuleRefreshInterval = 30 * time.Second
+
+type compiledIPRule struct {
+	network   *net.IPNet
+	prefix    int
+	action    models.IPRuleAction
+	expiresAt *time.Time
+}
+
+// IPRuleCache caches the ip_rules table of a database for IPFilter.
+type IPRuleCache struct {
+	db *gorm.DB
+
+	sync.RWMutex
+	rules    []compiledIPRule
+	loadedAt time.Time
+}
+
+// NewIPRuleCache returns an empty cache of the rules stored in db.
+func NewIPRuleCache(db *gorm.DB) *IPRuleCache {
+	return &IPRuleCache{db: db}
+}
+
+// Invalidate forces the next request to reload rules from the database.
+// Call it after creating or deleting rules.
+func (r *IPRuleCache) Invalidate() {
+	r.Lock()
+	r.loadedAt = time.Time{}
+	r.Unlock()
+}
+
+// IPFilter blocks requests whose client IP is denied by the cached ip_rules
+// table. Rules are refreshed periodically. Unmatched IPs, and client
+// addresses that cannot be parsed, are allowed unless
+// IP_RULES_DEFAULT_ACTION=deny.
+func IPFilter(rules *IPRuleCache) fiber.Handler {
+	defaultAction := models.IPRuleAllow
+	if models.IPRuleAction(os.Getenv("IP_RULES_DEFAULT_ACTION")) == models.IPRuleDeny {
+		defaultAction = models.IPRuleDeny
+	}
+
+	return func(c *fiber.Ctx) error {
+		action := defaultAction
+		if ip := net.ParseIP(c.IP()); ip != nil {
+			action = rules.match(ip, defaultAction)
+		}
+
+		if action == models.IPRuleDeny {
+			return fiber.NewError(403, "Access denied")
+		}
+
+		return c.Next()
+	}
+}
+
+func (r *IPRuleCache) match(ip net.IP, defaultAction models.IPRuleAction) models.IPRuleAction {
+	rules := r.load()
+	now := time.Now()
+
+	action := defaultAction
+	best := -1
+	for _, rule := range rules {
+		if rule.expiresAt != nil && rule.expiresAt.Before(now) {
+			continue
+		}
+		if !rule.network.Contains(ip) {
+			continue
+		}
+		if rule.prefix > best || (rule.prefix == best && rule.action == models.IPRuleAllow) {
+			best = rule.prefix
+			action = rule.action
+		}
+	}
+
+	return action
+}
+
+func (r *IPRuleCache) load() []compiledIPRule {
+	r.RLock()
+	if time.Since(r.loadedAt) < ipRuleRefreshInterval {
+		rules := r.rules
+		r.RUnlock()
+		return rules
+	}
+	r.RUnlock()
+
+	r.Lock()
+	defer r.Unlock()
+
+	// Another request may have refreshed while we waited for the lock.
+	if time.Since(r.loadedAt) < ipRuleRefreshInterval {
+		return r.rules
+	}
+
+	var stored []models.IPRule
+	err := r.db.
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Find(&stored).Error
+	if err != nil {
+		// Keep serving the previous rule set rather than failing open or
+		// closed, and wait out the refresh interval before retrying so an
+		// outage doesn't put every request behind the write lock.
+		log.Printf("ip_filter: failed to load ip rules: %v", err)
+		r.loadedAt = time.Now()
+		return r.rules
+	}
+
+	rules := make([]compiledIPRule, 0, len(stored))
+	for _, rule := range stored {
+		_, network, err := net.ParseCIDR(rule.CIDR)
+		if err != nil {
+			log.Printf("ip_filter: skipping invalid rule %d (%q): %v", rule.ID, rule.CIDR, err)
+			continue
+		}
+		prefix, _ := network.Mask.Size()
+		rules = append(rules, compiledIPRule{
+			network:   network,
+			prefix:    prefix,
+			action:    rule.Action,
+			expiresAt: rule.ExpiresAt,
+		})
+	}
+
+	r.rules = rules
+	r.loadedAt = time.Now()
+	return rules
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"api/database/models"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestIPFilter(t *testing.T) {
+	_, blocked, _ := net.ParseCIDR("203.0.113.0/24")
+	_, allowed, _ := net.ParseCIDR("198.51.100.0/24")
+	// A freshly loaded cache is served without touching the database
+	rules := &IPRuleCache{
+		rules: []compiledIPRule{
+			{network: blocked, prefix: 24, action: models.IPRuleDeny},
+			{network: allowed, prefix: 24, action: models.IPRuleAllow},
+		},
+		loadedAt: time.Now(),
+	}
+
+	tests := []struct {
+		name          string
+		defaultAction string
+		ip            string
+		status        int
+	}{
+		{"denied range", "", "203.0.113.7", 403},
+		{"unmatched", "", "192.0.2.1", 200},
+		{"unparseable", "", "not-an-ip", 200},
+		{"allowed range with default deny", "deny", "198.51.100.7", 200},
+		{"unmatched with default deny", "deny", "192.0.2.1", 403},
+		{"unparseable with default deny", "deny", "not-an-ip", 403},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IP_RULES_DEFAULT_ACTION", tt.defaultAction)
+			app := fiber.New(fiber.Config{ProxyHeader: "X-Client-IP"})
+			app.Use(IPFilter(rules))
+			app.Get("/", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Client-IP", tt.ip)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
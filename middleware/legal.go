@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"api/database/models"
+	"api/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// RequireLegalAcceptance rejects requests from users who have not accepted
+// the current terms of service and privacy policy, so a version bump forces
+// everyone to re-accept before using the API. It must be mounted after the
+// JWT middleware. When no versions are configured nil is returned.
+func RequireLegalAcceptance(db *gorm.DB) fiber.Handler {
+	documents := utils.CurrentLegalDocuments()
+	if !documents.Tracked() {
+		return nil
+	}
+
+	return func(c *fiber.Ctx) error {
+		token, ok := c.Locals("user").(*jwt.Token)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+		claims, ok := token.Claims.(*utils.JWTClaims)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+
+		var user models.User
+		if err := db.Select("id", "terms_version", "privacy_version").First(&user, claims.Subject).Error; err != nil {
+			return fiber.NewError(401, "Unauthorized")
+		}
+
+		if !documents.AcceptedBy(&user) {
+			err := utils.NewReasonError(403, "legal_acceptance_required", "Accept the current terms of service and privacy policy to continue")
+			err.Details = map[string]any{"documents": documents}
+			return err
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"api/database/models"
+	"api/utils"
+	"errors"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// MaintenanceModeID is the primary key of the single stored maintenance mode.
+const MaintenanceModeID = 1
+
+// maintenanceRefreshInterval bounds how long other instances take to see
+// maintenance mode switched through the admin API.
+const maintenanceRefreshInterval = 10 * time.Second
+
+// defaultMaintenanceMessage is returned when no message is configured.
+const defaultMaintenanceMessage = "The service is down for maintenance. Please try again later."
+
+// adminPath matches the admin API of every version, and maintenancePath the
+// endpoints that switch maintenance mode, which admins can always reach.
+// signInPath matches login and refresh, which admins need to get a token for
+// them.
+var (
+	adminPath       = regexp.MustCompile(`^/api/[^/]+/admin(/|$)`)
+	maintenancePath = regexp.MustCompile(`^/api/[^/]+/admin/maintenance/?$`)
+	signInPath      = regexp.MustCompile(`^/api/[^/]+/auth/(login|refresh)/?$`)
+)
+
+// MaintenanceCache caches the maintenance mode stored in a database for
+// Maintenance.
+type MaintenanceCache struct {
+	db       *gorm.DB
+	defaults models.MaintenanceMode // Applies while no admin setting is stored
+
+	sync.RWMutex
+	current  models.MaintenanceMode
+	loadedAt time.Time
+}
+
+// NewMaintenanceCache returns a cache of the maintenance mode stored in db.
+// MAINTENANCE_MODE=true turns maintenance on by default, with
+// MAINTENANCE_MESSAGE and MAINTENANCE_ALLOW_ADMINS. A setting saved through
+// the admin API takes precedence.
+func NewMaintenanceCache(db *gorm.DB) *MaintenanceCache {
+	enabled, _ := strconv.ParseBool(os.Getenv("MAINTENANCE_MODE"))
+	allowAdmins, _ := strconv.ParseBool(os.Getenv("MAINTENANCE_ALLOW_ADMINS"))
+	defaults := models.MaintenanceMode{
+		Enabled:     enabled,
+		Message:     os.Getenv("MAINTENANCE_MESSAGE"),
+		AllowAdmins: allowAdmins,
+	}
+	return &MaintenanceCache{db: db, defaults: defaults, current: defaults}
+}
+
+// Invalidate forces the next request to reload maintenance mode from the
+// database. Call it after changing it.
+func (m *MaintenanceCache) Invalidate() {
+	m.Lock()
+	m.loadedAt = time.Time{}
+	m.Unlock()
+}
+
+// Defaults returns maintenance mode as configured by environment variables,
+// which applies while no admin setting is stored.
+func (m *MaintenanceCache) Defaults() models.MaintenanceMode {
+	return m.defaults
+}
+
+// Maintenance answers every request with 503 Service Unavailable and a
+// Retry-After header while the cached maintenance mode is on, so planned
+// database work does not surface as errors. Routes registered before it,
+// such as /health, are not affected. Admins can always reach the maintenance
+// endpoints to switch it off, and the whole admin API when allow_admins is
+// set. Login and refresh stay open so their tokens do not run out while
+// maintenance is on, e.g. after starting with MAINTENANCE_MODE=true. Other
+// users who sign in still get 503 elsewhere. MAINTENANCE_RETRY_AFTER (default 5m) is used when no end time is
+// known.
+func Maintenance(maintenance *MaintenanceCache) fiber.Handler {
+	retryAfter := 5 * time.Minute
+	if value := os.Getenv("MAINTENANCE_RETRY_AFTER"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			log.Fatalf("invalid MAINTENANCE_RETRY_AFTER %q", value)
+		}
+		retryAfter = d
+	}
+
+	return func(c *fiber.Ctx) error {
+		mode := maintenance.load()
+		if !mode.Enabled {
+			return c.Next()
+		}
+
+		path := c.Path()
+		if maintenancePath.MatchString(path) || signInPath.MatchString(path) || (mode.AllowAdmins && adminPath.MatchString(path)) {
+			// The admin group still authenticates the request
+			return c.Next()
+		}
+
+		wait := retryAfter
+		if mode.Until != nil && time.Until(*mode.Until) > 0 {
+			wait = time.Until(*mode.Until)
+		}
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+
+		message := mode.Message
+		if message == "" {
+			message = defaultMaintenanceMessage
+		}
+		err := utils.NewReasonError(503, "maintenance", message)
+		if mode.Until != nil {
+			err.Details = map[string]any{"until": mode.Until}
+		}
+		return err
+	}
+}
+
+func (m *MaintenanceCache) load() models.MaintenanceMode {
+	m.RLock()
+	if time.Since(m.loadedAt) < maintenanceRefreshInterval {
+		mode := m.current
+		m.RUnlock()
+		return mode
+	}
+	m.RUnlock()
+
+	m.Lock()
+	defer m.Unlock()
+
+	if time.Since(m.loadedAt) < maintenanceRefreshInterval {
+		return m.current
+	}
+
+	var stored models.MaintenanceMode
+	err := m.db.First(&stored, MaintenanceModeID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		m.current = m.defaults
+	case err != nil:
+		// The database may be the thing under maintenance; keep the last
+		// known state and retry soon
+		log.Printf("maintenance: failed to load state: %v", err)
+		m.loadedAt = time.Now()
+		return m.current
+	default:
+		m.current = stored
+	}
+
+	m.loadedAt = time.Now()
+	return m.current
+}
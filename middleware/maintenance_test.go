@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"api/database/models"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMaintenance(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowAdmins bool
+		method      string
+		path        string
+		status      int
+	}{
+		{"api", false, "GET", "/api/v1/user/@me", 503},
+		{"register", false, "POST", "/api/v1/auth/register", 503},
+		{"login", false, "POST", "/api/v1/auth/login", 200},
+		{"refresh", false, "POST", "/api/v2/auth/refresh", 200},
+		{"maintenance endpoint", false, "PUT", "/api/v1/admin/maintenance", 200},
+		{"admin api", false, "GET", "/api/v1/admin/users", 503},
+		{"admin api allowed", true, "GET", "/api/v1/admin/users", 200},
+		{"api with admins allowed", true, "GET", "/api/v1/user/@me", 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A freshly loaded cache is served without touching the database
+			cache := &MaintenanceCache{
+				current:  models.MaintenanceMode{Enabled: true, AllowAdmins: tt.allowAdmins},
+				loadedAt: time.Now(),
+			}
+			app := fiber.New(fiber.Config{ErrorHandler: NewErrorHandler(nil, NewErrorResponder())})
+			app.Use(Maintenance(cache))
+			app.All("/*", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == 503 && resp.Header.Get(fiber.HeaderRetryAfter) != "300" {
+				t.Errorf("Retry-After = %q, want 300", resp.Header.Get(fiber.HeaderRetryAfter))
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"api/utils"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorResponder decides whether an error response is written as the usual
+// utils.Response envelope or as RFC 7807 problem details, and writes the
+// latter.
+type ErrorResponder struct {
+	preferProblem bool
+	typeBaseURL   string
+}
+
+// NewErrorResponder reads ERROR_FORMAT, the format used when the client
+// expresses no preference ("envelope", the default, or "problem"), and
+// PROBLEM_TYPE_BASE_URL, which the reason of a *utils.ReasonError is
+// appended to to form the problem type. Without a base URL the type is
+// "about:blank".
+func NewErrorResponder() *ErrorResponder {
+	r := &ErrorResponder{typeBaseURL: os.Getenv("PROBLEM_TYPE_BASE_URL")}
+	switch strings.ToLower(os.Getenv("ERROR_FORMAT")) {
+	case "", "envelope":
+	case "problem":
+		r.preferProblem = true
+	default:
+		log.Fatalf("unsupported ERROR_FORMAT %q: use envelope or problem", os.Getenv("ERROR_FORMAT"))
+	}
+	return r
+}
+
+// WantsProblem reports whether the error response to c should be problem
+// details. Raw responses always use them. Otherwise the Accept header
+// decides; the configured format breaks ties and applies to clients
+// accepting anything.
+func (r *ErrorResponder) WantsProblem(c *fiber.Ctx) bool {
+	if r == nil {
+		return false
+	}
+	if WantsRawResponse(c) {
+		return true
+	}
+	if r.preferProblem {
+		return c.Accepts(utils.ProblemContentType, fiber.MIMEApplicationJSON) == utils.ProblemContentType
+	}
+	return c.Accepts(fiber.MIMEApplicationJSON, utils.ProblemContentType) == utils.ProblemContentType
+}
+
+// WriteProblem writes problem details for a response with the given status
+// and already localized detail message. The members of a fiber.Map data,
+// such as an error's reason and details, become extension members; any
+// other non-nil data is sent as "data".
+func (r *ErrorResponder) WriteProblem(c *fiber.Ctx, status int, detail string, data any) error {
+	problem := utils.Problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     detail,
+		Instance:   c.Path(),
+		Extensions: map[string]any{},
+	}
+
+	switch data := data.(type) {
+	case nil:
+	case fiber.Map:
+		for k, v := range data {
+			problem.Extensions[k] = v
+		}
+		if reason, ok := data["reason"].(string); ok && reason != "" && r.typeBaseURL != "" {
+			problem.Type = strings.TrimRight(r.typeBaseURL, "/") + "/" + reason
+		}
+	default:
+		problem.Extensions["data"] = data
+	}
+	if rid, ok := c.Locals("requestid").(string); ok && rid != "" {
+		problem.Extensions["request_id"] = rid
+	}
+
+	c.Set(fiber.HeaderContentType, utils.ProblemContentType)
+	body, err := problem.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return c.Status(status).Send(body)
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"api/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// RateLimitRule describes a fixed-window limit: at most Max requests per
+// Window. A rule with Max <= 0 disables limiting.
+type RateLimitRule struct {
+	Max    int
+	Window time.Duration
+}
+
+// RateLimiter builds per-IP and per-account limiters that share a single
+// storage backend. When REDIS_URL is set counters are kept in Redis so limits
+// hold across instances; otherwise an in-memory store is used.
+type RateLimiter struct {
+	storage fiber.Storage
+}
+
+// NewRateLimiter creates a RateLimiter on the shared storage, so a route
+// mounted under several API versions still has a single counter per key.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{storage: sharedStorage()}
+}
+
+// PerIP limits requests to the named route by client IP. The default rule can
+// be overridden with RATE_LIMIT_<NAME>_IP (e.g. RATE_LIMIT_LOGIN_IP=20/1m).
+func (r *RateLimiter) PerIP(name string, rule RateLimitRule) fiber.Handler {
+	return r.newLimiter(fmt.Sprintf("RATE_LIMIT_%s_IP", strings.ToUpper(name)), rule, nil, func(c *fiber.Ctx) string {
+		return fmt.Sprintf("ratelimit:%s:ip:%s", name, c.IP())
+	})
+}
+
+// PerAccount limits requests to the named route by the "email" field of the
+// JSON body. Requests without an email are not counted. The default rule can
+// be overridden with RATE_LIMIT_<NAME>_ACCOUNT.
+func (r *RateLimiter) PerAccount(name string, rule RateLimitRule) fiber.Handler {
+	skip := func(c *fiber.Ctx) bool {
+		return accountKey(c) == ""
+	}
+
+	return r.newLimiter(fmt.Sprintf("RATE_LIMIT_%s_ACCOUNT", strings.ToUpper(name)), rule, skip, func(c *fiber.Ctx) string {
+		return fmt.Sprintf("ratelimit:%s:account:%s", name, accountKey(c))
+	})
+}
+
+// PerToken limits requests to the named route by the "token" field of the
+// JSON body, so a single token cannot be hammered from many IPs. Requests
+// without a token are not counted. The default rule can be overridden with
+// RATE_LIMIT_<NAME>_TOKEN.
+func (r *RateLimiter) PerToken(name string, rule RateLimitRule) fiber.Handler {
+	skip := func(c *fiber.Ctx) bool {
+		return tokenKey(c) == ""
+	}
+
+	return r.newLimiter(fmt.Sprintf("RATE_LIMIT_%s_TOKEN", strings.ToUpper(name)), rule, skip, func(c *fiber.Ctx) string {
+		return fmt.Sprintf("ratelimit:%s:token:%s", name, tokenKey(c))
+	})
+}
+
+// rateLimiters are every limiter built, which ReloadRateLimits rebuilds.
+var rateLimiters struct {
+	sync.Mutex
+	all []*reloadableLimiter
+}
+
+// reloadableLimiter is a limiter whose rule is read from envKey, falling
+// back to its default, and can be rebuilt when the setting changes. Counters
+// live in the shared storage, so rebuilding keeps them.
+type reloadableLimiter struct {
+	limiter  *RateLimiter
+	envKey   string
+	fallback RateLimitRule
+	skip     func(*fiber.Ctx) bool
+	key      func(*fiber.Ctx) string
+	handler  atomic.Pointer[fiber.Handler]
+}
+
+func (l *reloadableLimiter) build() {
+	handler := l.limiter.buildLimiter(rateLimitRuleFromEnv(l.envKey, l.fallback), l.skip, l.key)
+	l.handler.Store(&handler)
+}
+
+// ReloadRateLimits applies the current RATE_LIMIT_* settings to every
+// limiter without resetting its counters.
+func ReloadRateLimits() {
+	rateLimiters.Lock()
+	defer rateLimiters.Unlock()
+
+	for _, l := range rateLimiters.all {
+		l.build()
+	}
+}
+
+func (r *RateLimiter) newLimiter(envKey string, fallback RateLimitRule, skip func(*fiber.Ctx) bool, key func(*fiber.Ctx) string) fiber.Handler {
+	l := &reloadableLimiter{limiter: r, envKey: envKey, fallback: fallback, skip: skip, key: key}
+	l.build()
+
+	rateLimiters.Lock()
+	rateLimiters.all = append(rateLimiters.all, l)
+	rateLimiters.Unlock()
+
+	return func(c *fiber.Ctx) error {
+		return (*l.handler.Load())(c)
+	}
+}
+
+func (r *RateLimiter) buildLimiter(rule RateLimitRule, skip func(*fiber.Ctx) bool, key func(*fiber.Ctx) string) fiber.Handler {
+	if rule.Max <= 0 {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	return limiter.New(limiter.Config{
+		Next:         skip,
+		Max:          rule.Max,
+		Expiration:   rule.Window,
+		KeyGenerator: key,
+		Storage:      r.storage,
+		// The limiter sets the Retry-After header before calling this, so
+		// the global error handler only has to render the body.
+		LimitReached: func(c *fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusTooManyRequests, "Too many requests. Please try again later.")
+		},
+	})
+}
+
+// accountKey extracts a stable, non-reversible key for the account targeted by
+// the request body. The email is hashed so raw addresses never reach Redis.
+func accountKey(c *fiber.Ctx) string {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if !decodeJSONField(c, &body) {
+		return ""
+	}
+
+	email := utils.NormalizeEmail(body.Email)
+	if email == "" {
+		return ""
+	}
+
+	return utils.HashTokenSHA256(email)
+}
+
+// tokenKey returns the hash of the "token" field of the request body, so raw
+// tokens never reach Redis.
+func tokenKey(c *fiber.Ctx) string {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if !decodeJSONField(c, &body) || body.Token == "" {
+		return ""
+	}
+
+	return utils.HashTokenSHA256(body.Token)
+}
+
+// decodeJSONField decodes the fields of out from a JSON request body. Unlike
+// the handlers' strict parsing it ignores other fields, but like it, it only
+// accepts application/json, so a form-encoded body the handler will reject
+// cannot steer the limiter key.
+func decodeJSONField(c *fiber.Ctx, out any) bool {
+	mediaType, _, err := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	if err != nil || mediaType != fiber.MIMEApplicationJSON {
+		return false
+	}
+	return json.Unmarshal(c.Body(), out) == nil
+}
+
+// rateLimitRuleFromEnv parses a "<max>/<window>" value such as "5/15m". An
+// empty or malformed value falls back to the given default.
+func rateLimitRuleFromEnv(key string, fallback RateLimitRule) RateLimitRule {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) != 2 {
+		log.Printf("invalid %s=%q, expected <max>/<window>; using default", key, value)
+		return fallback
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		log.Printf("invalid %s=%q: %v; using default", key, value, err)
+		return fallback
+	}
+
+	window, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || window <= 0 {
+		log.Printf("invalid %s=%q: bad window; using default", key, value)
+		return fallback
+	}
+
+	return RateLimitRule{Max: limit, Window: window}
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"api/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ResponseFormatHeader lets a client ask for responses without the
+// utils.Response envelope by sending "raw".
+const ResponseFormatHeader = "X-Response-Format"
+
+const rawResponsesLocal = "raw_responses"
+
+// RawResponses makes the routes it is mounted on respond without the
+// envelope regardless of ResponseFormatHeader, e.g. for endpoints consumed by
+// other services.
+func RawResponses() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(rawResponsesLocal, true)
+		return c.Next()
+	}
+}
+
+// WantsRawResponse reports whether the response to c is sent without the
+// envelope.
+func WantsRawResponse(c *fiber.Ctx) bool {
+	if raw, ok := c.Locals(rawResponsesLocal).(bool); ok && raw {
+		return true
+	}
+	return strings.EqualFold(c.Get(ResponseFormatHeader), "raw")
+}
+
+// envelope is utils.Response as written, with data and meta left encoded.
+type envelope struct {
+	Success *bool           `json:"success"`
+	Code    uint            `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Meta    *utils.PageInfo `json:"meta"`
+}
+
+// ResponseFormat unwraps enveloped JSON responses for clients that want them
+// raw (see WantsRawResponse). A successful response becomes its data, sent
+// with the status in its code, or 204 No Content without data; the next page
+// of a list is linked in a Link header. Errors become RFC 7807 problem
+// details, which the error handler writes directly. Other responses, such as
+// images, HTML and streams, pass through unchanged.
+func ResponseFormat(responder *ErrorResponder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := c.Next(); err != nil || !WantsRawResponse(c) {
+			return err
+		}
+
+		resp := c.Response()
+		if resp.IsBodyStream() || !bytes.HasPrefix(resp.Header.ContentType(), []byte(fiber.MIMEApplicationJSON)) {
+			return nil
+		}
+		var env envelope
+		if err := json.Unmarshal(resp.Body(), &env); err != nil || env.Success == nil || env.Code < 100 || env.Code > 599 {
+			return nil
+		}
+		status := int(env.Code)
+
+		if !*env.Success {
+			var data any
+			var details fiber.Map
+			if json.Unmarshal(env.Data, &details) == nil && details != nil {
+				data = details
+			} else if len(env.Data) > 0 {
+				data = env.Data
+			}
+			return responder.WriteProblem(c, status, env.Message, data)
+		}
+
+		if env.Meta != nil && env.Meta.NextCursor != "" {
+			c.Append(fiber.HeaderLink, fmt.Sprintf(`<%s>; rel="next"`, nextPageURL(c, env.Meta.NextCursor)))
+		}
+		if len(env.Data) == 0 || string(env.Data) == "null" {
+			if status == fiber.StatusOK {
+				status = fiber.StatusNoContent
+			}
+			resp.ResetBody()
+			c.Status(status)
+			return nil
+		}
+		return c.Status(status).Send(env.Data)
+	}
+}
+
+// nextPageURL returns the request's URL with cursor set to the next page.
+func nextPageURL(c *fiber.Ctx, cursor string) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	query.Set("cursor", cursor)
+	query.Del("page")
+	return c.Path() + "?" + query.Encode()
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"api/utils"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestResponseFormat(t *testing.T) {
+	app := fiber.New()
+	app.Use(ResponseFormat(NewErrorResponder()))
+	app.Get("/user", func(c *fiber.Ctx) error {
+		return c.JSON(utils.Response{Success: true, Code: 200, Message: "Success", Data: fiber.Map{"id": 1}})
+	})
+	app.Post("/user", func(c *fiber.Ctx) error {
+		return c.Status(201).JSON(utils.Response{Success: true, Code: 201, Message: "Created", Data: fiber.Map{"id": 2}})
+	})
+	app.Delete("/user", func(c *fiber.Ctx) error {
+		return c.JSON(utils.Response{Success: true, Code: 200, Message: "Deleted"})
+	})
+	app.Get("/users", func(c *fiber.Ctx) error {
+		return c.JSON(utils.Response{Success: true, Code: 200, Message: "Success", Data: []int{1, 2}, Meta: &utils.PageInfo{Limit: 2, HasMore: true, NextCursor: "abc"}})
+	})
+	app.Get("/locked", func(c *fiber.Ctx) error {
+		return c.Status(423).JSON(utils.Response{Success: false, Code: 423, Message: "Account locked", Data: fiber.Map{"reason": "account_locked"}})
+	})
+	app.Get("/html", func(c *fiber.Ctx) error {
+		c.Type("html")
+		return c.SendString("<p>hi</p>")
+	})
+	app.Get("/other-json", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{"status": "ok"})
+	})
+	app.Get("/always-raw", RawResponses(), func(c *fiber.Ctx) error {
+		return c.JSON(utils.Response{Success: true, Code: 200, Message: "Success", Data: "raw"})
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		raw    bool
+		status int
+		body   string
+		link   string
+	}{
+		{name: "enveloped by default", method: "GET", path: "/user", status: 200, body: `{"success":true,"code":200,"message":"Success","data":{"id":1}}`},
+		{name: "data is unwrapped", method: "GET", path: "/user", raw: true, status: 200, body: `{"id":1}`},
+		{name: "status comes from the code", method: "POST", path: "/user", raw: true, status: 201, body: `{"id":2}`},
+		{name: "no data is no content", method: "DELETE", path: "/user", raw: true, status: 204},
+		{name: "next page is linked", method: "GET", path: "/users?limit=2&page=3", raw: true, status: 200, body: `[1,2]`, link: `</users?cursor=abc&limit=2>; rel="next"`},
+		{name: "errors become problem details", method: "GET", path: "/locked", raw: true, status: 423},
+		{name: "non-JSON passes through", method: "GET", path: "/html", raw: true, status: 200, body: `<p>hi</p>`},
+		{name: "JSON without an envelope passes through", method: "GET", path: "/other-json", raw: true, status: 200, body: `{"status":"ok"}`},
+		{name: "route forces raw responses", method: "GET", path: "/always-raw", status: 200, body: `"raw"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.raw {
+				req.Header.Set(ResponseFormatHeader, "raw")
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.body != "" && strings.TrimSpace(string(body)) != tt.body {
+				t.Errorf("body = %s, want %s", body, tt.body)
+			}
+			if tt.status == 204 && len(body) != 0 {
+				t.Errorf("204 response has body %s", body)
+			}
+			if got := resp.Header.Get(fiber.HeaderLink); got != tt.link {
+				t.Errorf("Link = %q, want %q", got, tt.link)
+			}
+		})
+	}
+
+	t.Run("problem details", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/locked", nil)
+		req.Header.Set(ResponseFormatHeader, "raw")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ct := resp.Header.Get(fiber.HeaderContentType); !strings.HasPrefix(ct, utils.ProblemContentType) {
+			t.Errorf("Content-Type = %q, want %q", ct, utils.ProblemContentType)
+		}
+		var problem map[string]any
+		if err := json.NewDecoder(resp.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		if problem["status"] != float64(423) || problem["detail"] != "Account locked" || problem["reason"] != "account_locked" {
+			t.Errorf("unexpected problem %v", problem)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"api/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RequireScope rejects requests whose token is scoped and does not grant
+// scope. Unscoped login tokens pass. It must be mounted after the JWT
+// middleware so that c.Locals("user") holds a *jwt.Token with
+// *utils.JWTClaims.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := c.Locals("user").(*jwt.Token)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+		claims, ok := token.Claims.(*utils.JWTClaims)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+
+		if !claims.HasScope(scope) {
+			err := utils.NewReasonError(403, "insufficient_scope", "Token is missing the required scope")
+			err.Details = map[string]any{"required_scope": scope}
+			return err
+		}
+
+		return c.Next()
+	}
+}
+
+// RequireLoginToken rejects scoped tokens, for routes that personal access
+// and client-credentials tokens must never reach, such as changing the
+// password or creating more tokens. Like RequireScope, it must be mounted
+// after the JWT middleware.
+func RequireLoginToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := c.Locals("user").(*jwt.Token)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+		claims, ok := token.Claims.(*utils.JWTClaims)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+
+		if claims.Scope != "" {
+			return utils.NewReasonError(403, "login_token_required", "This endpoint cannot be used with a scoped token")
+		}
+
+		return c.Next()
+	}
+}
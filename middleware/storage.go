@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/storage/redis/v3"
+)
+
+var shared struct {
+	once    sync.Once
+	storage fiber.Storage
+}
+
+// sharedStorage returns the storage used for rate limits and idempotency
+// keys: Redis when REDIS_URL is set, so state holds across instances,
+// otherwise one in-memory store for the process.
+func sharedStorage() fiber.Storage {
+	shared.once.Do(func() {
+		if url := os.Getenv("REDIS_URL"); url != "" {
+			shared.storage = redis.New(redis.Config{URL: url})
+			return
+		}
+		log.Printf("REDIS_URL not set; rate limits and idempotency keys are kept in memory and are per-instance")
+		shared.storage = newMemoryStorage()
+	})
+	return shared.storage
+}
+
+// CloseStorage closes the shared storage, e.g. the Redis connection pool, if
+// it was opened.
+func CloseStorage() error {
+	// Marks the storage as set up, so none is opened after this
+	shared.once.Do(func() {})
+	if shared.storage == nil {
+		return nil
+	}
+	return shared.storage.Close()
+}
+
+// memoryStorage is a minimal in-process fiber.Storage. Expired entries are
+// ignored on read and swept every minute.
+type memoryStorage struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time // Zero means no expiry
+}
+
+func newMemoryStorage() *memoryStorage {
+	s := &memoryStorage{entries: make(map[string]memoryEntry)}
+	go func() {
+		for range time.Tick(time.Minute) {
+			s.sweep()
+		}
+	}()
+	return s
+}
+
+func (s *memoryStorage) Get(key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		return nil, nil
+	}
+	return entry.value, nil
+}
+
+func (s *memoryStorage) Set(key string, value []byte, exp time.Duration) error {
+	if key == "" || len(value) == 0 {
+		return nil
+	}
+	entry := memoryEntry{value: append([]byte(nil), value...)}
+	if exp > 0 {
+		entry.expiresAt = time.Now().Add(exp)
+	}
+	s.mu.Lock()
+	s.entries[key] = entry
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) Reset() error {
+	s.mu.Lock()
+	s.entries = make(map[string]memoryEntry)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *memoryStorage) Close() error {
+	return nil
+}
+
+func (s *memoryStorage) sweep() {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.entries {
+		if !entry.expiresAt.IsZero() && now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"api/database/models"
+	"api/utils"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// tenantRefreshInterval bounds how long other instances take to see a tenant
+// created or deactivated through the admin API.
+const tenantRefreshInterval = 30 * time.Second
+
+// tenantLocalsKey is the c.Locals key holding the resolved tenant ID.
+const tenantLocalsKey = "tenant"
+
+// TenantCache caches the tenants of a database by slug for Tenant.
+type TenantCache struct {
+	db *gorm.DB
+
+	sync.RWMutex
+	bySlug   map[string]models.Tenant
+	loadedAt time.Time
+}
+
+// NewTenantCache returns an empty cache of the tenants stored in db.
+func NewTenantCache(db *gorm.DB) *TenantCache {
+	return &TenantCache{db: db}
+}
+
+// Invalidate forces the next request to reload tenants from the database.
+// Call it after creating or changing one.
+func (t *TenantCache) Invalidate() {
+	t.Lock()
+	t.loadedAt = time.Time{}
+	t.Unlock()
+}
+
+// Tenant resolves the tenant a request is made in from tenants and stores its
+// ID for TenantID. The tenant's slug is read from the TENANT_HEADER header (default
+// X-Tenant) or, when TENANT_BASE_DOMAIN is set, from the subdomain of the
+// Host, e.g. "acme" for acme.auth.example.com. Requests naming neither use
+// the default tenant; unknown and inactive tenants get 404.
+func Tenant(tenants *TenantCache) fiber.Handler {
+	header := tenantHeader()
+	baseDomain := strings.ToLower(strings.Trim(os.Getenv("TENANT_BASE_DOMAIN"), "."))
+
+	return func(c *fiber.Ctx) error {
+		slug := strings.ToLower(strings.TrimSpace(c.Get(header)))
+		if slug == "" && baseDomain != "" {
+			host := strings.ToLower(c.Hostname())
+			if name, _, err := net.SplitHostPort(host); err == nil {
+				host = name
+			}
+			if sub, ok := strings.CutSuffix(host, "."+baseDomain); ok && !strings.Contains(sub, ".") {
+				slug = sub
+			}
+		}
+
+		if slug == "" {
+			c.Locals(tenantLocalsKey, uint(models.DefaultTenantID))
+			return c.Next()
+		}
+
+		tenant, ok := tenants.load()[slug]
+		if !ok || !tenant.Active {
+			return utils.NewReasonError(404, "tenant_not_found", "Tenant not found")
+		}
+
+		c.Locals(tenantLocalsKey, tenant.ID)
+		return c.Next()
+	}
+}
+
+// tenantHeader is the request header naming the tenant.
+func tenantHeader() string {
+	if header := os.Getenv("TENANT_HEADER"); header != "" {
+		return header
+	}
+	return "X-Tenant"
+}
+
+// TenantID returns the tenant resolved by the Tenant middleware, or the
+// default tenant on routes it does not cover.
+func TenantID(c *fiber.Ctx) uint {
+	if id, ok := c.Locals(tenantLocalsKey).(uint); ok {
+		return id
+	}
+	return models.DefaultTenantID
+}
+
+// RequireTenantToken rejects access tokens issued in a tenant other than the
+// request's, so a token cannot be replayed against another tenant. It must be
+// mounted after the JWT middleware.
+func RequireTenantToken() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := c.Locals("user").(*jwt.Token)
+		if !ok {
+			return fiber.NewError(401, "Unauthorized")
+		}
+		claims, ok := token.Claims.(*utils.JWTClaims)
+		if !ok || claims.TenantID() != TenantID(c) {
+			return fiber.NewError(401, "Unauthorized")
+		}
+		return c.Next()
+	}
+}
+
+// RequireDefaultTenant restricts deployment-wide settings, such as IP rules
+// and maintenance mode, to admins of the default tenant.
+func RequireDefaultTenant() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if TenantID(c) != models.DefaultTenantID {
+			return fiber.NewError(403, "Forbidden")
+		}
+		return c.Next()
+	}
+}
+
+func (t *TenantCache) load() map[string]models.Tenant {
+	t.RLock()
+	if time.Since(t.loadedAt) < tenantRefreshInterval {
+		bySlug := t.bySlug
+		t.RUnlock()
+		return bySlug
+	}
+	t.RUnlock()
+
+	t.Lock()
+	defer t.Unlock()
+
+	if time.Since(t.loadedAt) < tenantRefreshInterval {
+		return t.bySlug
+	}
+
+	var stored []models.Tenant
+	if err := t.db.Find(&stored).Error; err != nil {
+		// Keep resolving with the previous set rather than failing requests,
+		// and retry after the refresh interval rather than on every request
+		log.Printf("tenant: failed to load tenants: %v", err)
+		t.loadedAt = time.Now()
+		return t.bySlug
+	}
+
+	bySlug := make(map[string]models.Tenant, len(stored))
+	for _, tenant := range stored {
+		bySlug[tenant.Slug] = tenant
+	}
+
+	t.bySlug = bySlug
+	t.loadedAt = time.Now()
+	return bySlug
+}
@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"api/handlers"
+	"api/middleware"
+	"api/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AdminRoutes(router fiber.Router, h *handlers.Handler) {
+	// Routes on a single user only reach users of the admin's tenant
+	tenantUser := h.RequireTenantUser
+
+	readUsers := middleware.RequireScope(utils.ScopeUsersRead)
+	writeUsers := middleware.RequireScope(utils.ScopeUsersWrite)
+
+	// Session management
+	sessions := router.Group("/sessions")
+	sessions.Post("/revoke", writeUsers, h.AdminRevokeSessions)
+	router.Post("/users/:id/revoke-sessions", writeUsers, tenantUser, h.AdminRevokeUserSessions)
+
+	// Users
+	router.Get("/users", readUsers, h.AdminListUsers)
+	router.Post("/users/import", writeUsers, h.AdminImportUsers)
+	router.Get("/users/by-username/:username", readUsers, h.AdminGetUserByUsername)
+	router.Get("/users/:id", readUsers, tenantUser, h.AdminGetUser)
+	router.Get("/users/:id/sessions", readUsers, tenantUser, h.AdminListUserSessions)
+	router.Get("/users/:id/username-history", readUsers, tenantUser, h.AdminListUsernameHistory)
+	router.Get("/users/:id/oauth-accounts", readUsers, tenantUser, h.AdminListUserOAuthAccounts)
+	router.Delete("/users/:id/oauth-accounts/:provider", writeUsers, tenantUser, h.AdminUnlinkUserOAuthAccount)
+	router.Patch("/users/:id/metadata", writeUsers, tenantUser, h.AdminUpdateUserMetadata)
+
+	// Account lockouts
+	router.Get("/lockouts", readUsers, h.AdminListLockouts)
+	router.Delete("/users/:id/lockout", writeUsers, tenantUser, h.AdminClearLockout)
+
+	// Roles
+	router.Put("/users/:id/role", writeUsers, tenantUser, h.AdminUpdateUserRole)
+
+	// Bans and suspensions
+	router.Post("/users/:id/ban", writeUsers, tenantUser, h.AdminBanUser)
+	router.Delete("/users/:id/ban", writeUsers, tenantUser, h.AdminUnbanUser)
+	router.Post("/users/:id/suspend", writeUsers, tenantUser, h.AdminSuspendUser)
+	router.Delete("/users/:id/suspend", writeUsers, tenantUser, h.AdminUnsuspendUser)
+
+	// Incident response
+	router.Post("/users/:id/force-password-reset", writeUsers, tenantUser, h.AdminForcePasswordReset)
+
+	// Erasure requests
+	router.Post("/users/:id/anonymize", writeUsers, tenantUser, h.AdminAnonymizeUser)
+
+	// Invitations
+	router.Post("/invitations", writeUsers, h.AdminCreateInvitation)
+
+	// Everything registered after this can only be reached with a login
+	// token, not a personal access or client-credentials token
+	router.Use(middleware.RequireLoginToken())
+
+	// Audit log
+	router.Get("/audit-logs", h.AdminListAuditLogs)
+
+	// Everything below applies to the whole deployment, so only admins of
+	// the default tenant can change it
+	router.Use(middleware.RequireDefaultTenant())
+
+	// Tenants
+	router.Get("/tenants", h.AdminListTenants)
+	router.Post("/tenants", h.AdminCreateTenant)
+	router.Patch("/tenants/:id", h.AdminUpdateTenant)
+
+	// IP allow/deny rules
+	ipRules := router.Group("/ip-rules")
+	ipRules.Get("/", h.AdminListIPRules)
+	ipRules.Post("/", h.AdminCreateIPRule)
+	ipRules.Delete("/:id", h.AdminDeleteIPRule)
+
+	// Currencies
+	router.Get("/currencies", h.AdminListCurrencies)
+	router.Put("/currencies/:code", h.AdminUpdateCurrency)
+
+	// Maintenance mode
+	router.Get("/maintenance", h.AdminGetMaintenance)
+	router.Put("/maintenance", h.AdminUpdateMaintenance)
+	router.Delete("/maintenance", h.AdminResetMaintenance)
+
+	// Feature flags
+	router.Get("/feature-flags", h.AdminListFeatureFlags)
+	router.Put("/feature-flags/:name", h.AdminUpdateFeatureFlag)
+	router.Delete("/feature-flags/:name", h.AdminResetFeatureFlag)
+
+	// Security policy
+	router.Get("/security-policy", h.AdminGetSecurityPolicy)
+	router.Put("/security-policy", h.AdminUpdateSecurityPolicy)
+	router.Delete("/security-policy", h.AdminResetSecurityPolicy)
+
+	// Runtime settings
+	router.Post("/settings/reload", h.AdminReloadSettings)
+
+	// Email outbox
+	router.Get("/email-outbox", h.AdminListEmailOutbox)
+	router.Get("/email-outbox/:id/events", h.AdminGetEmailDeliveryEvents)
+	router.Post("/email-outbox/:id/retry", h.AdminRetryEmail)
+	router.Get("/email-events", h.AdminListEmailDeliveryEvents)
+	router.Get("/email-suppressions", h.AdminListEmailSuppressions)
+	router.Delete("/email-suppressions/:id", h.AdminDeleteEmailSuppression)
+
+	// Event webhooks
+	webhooks := router.Group("/webhooks")
+	webhooks.Get("/", h.AdminListWebhooks)
+	webhooks.Post("/", h.AdminCreateWebhook)
+	webhooks.Patch("/:id", h.AdminUpdateWebhook)
+	webhooks.Delete("/:id", h.AdminDeleteWebhook)
+	webhooks.Get("/deliveries", h.AdminListWebhookDeliveries)
+	webhooks.Post("/deliveries/:id/retry", h.AdminRetryWebhookDelivery)
+}
@@ -2,24 +2,75 @@ package routes
 
 import (
 	"api/handlers"
+	"api/middleware"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func AuthRoutes(router fiber.Router) {
-	handlers.SetupAuth()
-	handlers.SetupPasswordReset()
+// AuthRoutes registers the public auth routes of API v1.
+func AuthRoutes(router fiber.Router, h *handlers.Handler) {
+	authRoutes(router, h, h.RefreshToken)
+}
+
+// AuthRoutesV2 registers the public auth routes of API v2, where refreshing
+// rotates the refresh token.
+func AuthRoutesV2(router fiber.Router, h *handlers.Handler) {
+	authRoutes(router, h, h.RotateRefreshToken)
+}
+
+func authRoutes(router fiber.Router, h *handlers.Handler, refresh fiber.Handler) {
+	limiter := middleware.NewRateLimiter()
 
 	// Traditional auth routes
-	router.Post("/register", handlers.Register)
-	router.Post("/login", handlers.Login)
-	router.Get("/refresh", handlers.RefreshToken)
-	router.Get("/revoke", handlers.RevokeToken)
-	router.Post("/request-password-reset", handlers.RequestPasswordReset)
-	router.Post("/confirm-password-reset", handlers.ConfirmPasswordReset)
+	router.Get("/register/form", h.RegistrationForm)
+	router.Get("/legal", h.GetLegalDocuments)
+	router.Post("/register",
+		middleware.Idempotency("register"),
+		limiter.PerIP("register", middleware.RateLimitRule{Max: 10, Window: time.Hour}),
+		limiter.PerAccount("register", middleware.RateLimitRule{Max: 3, Window: time.Hour}),
+		h.Register)
+	router.Get("/invitations/:token",
+		limiter.PerIP("invitation_lookup", middleware.RateLimitRule{Max: 20, Window: time.Minute}),
+		h.GetInvitation)
+	router.Post("/login",
+		limiter.PerIP("login", middleware.RateLimitRule{Max: 20, Window: time.Minute}),
+		limiter.PerAccount("login", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.Login)
+	// Cookie-authenticated routes are POST-only and require a CSRF token
+	router.Post("/refresh", middleware.CSRF(), refresh)
+	router.Post("/revoke", middleware.CSRF(), h.RevokeToken)
+	// Client-credentials grant for API clients
+	router.Post("/token",
+		limiter.PerIP("client_credentials", middleware.RateLimitRule{Max: 30, Window: time.Minute}),
+		h.ClientCredentialsToken)
+	router.Post("/request-password-reset",
+		middleware.Idempotency("password_reset"),
+		limiter.PerIP("password_reset", middleware.RateLimitRule{Max: 5, Window: 15 * time.Minute}),
+		limiter.PerAccount("password_reset", middleware.RateLimitRule{Max: 3, Window: time.Hour}),
+		h.RequestPasswordReset)
+	router.Post("/confirm-password-reset",
+		limiter.PerIP("password_reset_confirm", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		limiter.PerToken("password_reset_confirm", middleware.RateLimitRule{Max: 5, Window: 15 * time.Minute}),
+		h.ConfirmPasswordReset)
+	router.Post("/unlock-account",
+		limiter.PerIP("unlock_account", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.UnlockAccount)
+	router.Post("/secure-account",
+		limiter.PerIP("secure_account", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.SecureAccount)
+	router.Post("/verify-email",
+		limiter.PerIP("verify_email", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.VerifyEmail)
+	router.Post("/resend-verification",
+		limiter.PerIP("resend_verification", middleware.RateLimitRule{Max: 5, Window: 15 * time.Minute}),
+		h.ResendVerification)
 
 	// OAuth routes
 	oauth := router.Group("/oauth")
-	oauth.Post("/initiate", handlers.OAuthInitiate)
-	oauth.Get("/:provider/callback", handlers.OAuthCallback)
+	oauth.Post("/initiate",
+		middleware.Idempotency("oauth_initiate"),
+		limiter.PerIP("oauth_initiate", middleware.RateLimitRule{Max: 20, Window: time.Minute}),
+		h.OAuthInitiate)
+	oauth.Get("/:provider/callback", h.OAuthCallback)
 }
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConfigRoutes registers the public, read-only client configuration.
+func ConfigRoutes(router fiber.Router, h *handlers.Handler) {
+	router.Get("/", h.GetClientConfig)
+}
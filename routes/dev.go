@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DevRoutes registers development helpers. They are only mounted when
+// ENV=development and in demo mode.
+func DevRoutes(router fiber.Router, h *handlers.Handler) {
+	router.Get("/email-templates", h.ListEmailPreviews)
+	router.Get("/email-templates/:name", h.PreviewEmailTemplate)
+	router.Get("/emails", h.ListCapturedEmails)
+}
@@ -2,18 +2,91 @@ package routes
 
 import (
 	"api/handlers"
+	"api/middleware"
+	"api/utils"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func UserRoutes(router fiber.Router) {
+// UserRoutes registers the authenticated user routes.
+func UserRoutes(router fiber.Router, h *handlers.Handler) {
+	limiter := middleware.NewRateLimiter()
+
+	readProfile := middleware.RequireScope(utils.ScopeProfileRead)
+	writeProfile := middleware.RequireScope(utils.ScopeProfileWrite)
+	readActivity := middleware.RequireScope(utils.ScopeActivityRead)
+	loginToken := middleware.RequireLoginToken()
+
+	// Legal acceptance. These stay reachable when acceptance is outstanding;
+	// everything registered after the middleware requires it
+	router.Get("/@me/legal", readProfile, h.GetMyLegalAcceptance)
+	router.Post("/@me/legal/accept", loginToken, h.AcceptLegalDocuments)
+	router.Get("/@me", readProfile, h.GetMe)
+	if requireLegal := h.RequireLegalAcceptance(); requireLegal != nil {
+		router.Use(requireLegal)
+	}
+
 	// Profile management
-	router.Get("/@me", handlers.GetMe)
-	router.Patch("/profile", handlers.UpdateProfile)
-	router.Get("/profile/options", handlers.GetProfileOptions)
+	router.Patch("/profile", writeProfile, h.UpdateProfile)
+	router.Get("/profile/options", readProfile, h.GetProfileOptions)
+	router.Patch("/@me/metadata", writeProfile, h.UpdateMyMetadata)
+
+	// Account activity
+	router.Get("/sessions", readActivity, h.ListMySessions)
+	router.Get("/activity", readActivity, h.GetMyActivity)
+	router.Get("/login-history", readActivity, h.GetMyLoginHistory)
+
+	// Spreadsheet downloads
+	export := router.Group("/export",
+		readActivity,
+		limiter.PerIP("data_export", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}))
+	export.Get("/sessions.csv", h.ExportMySessionsCSV)
+	export.Get("/login-history.csv", h.ExportMyLoginHistoryCSV)
+
+	// Notification preferences
+	router.Get("/notifications", readProfile, h.GetNotificationPreferences)
+	router.Patch("/notifications", writeProfile, h.UpdateNotificationPreferences)
+	router.Post("/avatar",
+		writeProfile,
+		limiter.PerIP("avatar_upload", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.UploadAvatar)
+	router.Delete("/avatar", writeProfile, h.DeleteAvatar)
+
+	// Everything registered after this can only be reached with a login
+	// token, not a personal access or client-credentials token
+	router.Use(loginToken)
+
+	router.Post("/@me/anonymize",
+		limiter.PerIP("anonymize", middleware.RateLimitRule{Max: 5, Window: 15 * time.Minute}),
+		h.AnonymizeMe)
+
+	// Password management
+	router.Post("/password",
+		limiter.PerIP("set_password", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.SetPassword)
+	router.Patch("/password",
+		limiter.PerIP("change_password", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.ChangePassword)
+
+	// Personal access tokens
+	tokens := router.Group("/tokens")
+	tokens.Get("/", h.ListPersonalTokens)
+	tokens.Post("/",
+		limiter.PerIP("create_token", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.CreatePersonalToken)
+	tokens.Delete("/:id", h.RevokePersonalToken)
+
+	// API clients for the client-credentials grant
+	clients := router.Group("/api-clients")
+	clients.Get("/", h.ListAPIClients)
+	clients.Post("/",
+		limiter.PerIP("create_api_client", middleware.RateLimitRule{Max: 10, Window: 15 * time.Minute}),
+		h.CreateAPIClient)
+	clients.Delete("/:id", h.DeleteAPIClient)
 
 	// OAuth account management
 	oauth := router.Group("/oauth")
-	oauth.Get("/accounts", handlers.GetOAuthAccounts)
-	oauth.Delete("/accounts/:provider", handlers.UnlinkOAuthAccount)
+	oauth.Get("/accounts", h.GetOAuthAccounts)
+	oauth.Delete("/accounts/:provider", h.UnlinkOAuthAccount)
 }
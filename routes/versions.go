@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterFunc registers one route group of an API version.
+type RegisterFunc func(router fiber.Router, h *handlers.Handler)
+
+// Version is an API version served under /api/<Name>. A version reuses the
+// previous version's RegisterFunc for every group whose behavior is
+// unchanged, and only swaps in new ones where it differs.
+type Version struct {
+	Name     string
+	Config   RegisterFunc // Public client configuration under /config
+	Auth     RegisterFunc // Public auth routes under /auth
+	Webhooks RegisterFunc // Provider callbacks under /webhooks
+	Dev      RegisterFunc // Development helpers under /dev
+	User     RegisterFunc // Authenticated routes under /user
+	Admin    RegisterFunc // Admin routes under /admin
+}
+
+// Versions lists the API versions served, oldest first.
+var Versions = []Version{
+	{
+		Name:     "v1",
+		Config:   ConfigRoutes,
+		Auth:     AuthRoutes,
+		Webhooks: WebhookRoutes,
+		Dev:      DevRoutes,
+		User:     UserRoutes,
+		Admin:    AdminRoutes,
+	},
+	{
+		// Refreshing rotates the refresh token
+		Name:     "v2",
+		Config:   ConfigRoutes,
+		Auth:     AuthRoutesV2,
+		Webhooks: WebhookRoutes,
+		Dev:      DevRoutes,
+		User:     UserRoutes,
+		Admin:    AdminRoutes,
+	},
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookRoutes registers provider callbacks. They authenticate by signature
+// rather than JWT.
+func WebhookRoutes(router fiber.Router, h *handlers.Handler) {
+	router.Post("/email/ses", h.SESWebhook)
+	router.Post("/email/sendgrid", h.SendGridWebhook)
+}
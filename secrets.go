@@ -0,0 +1,72 @@
+package main
+
+import (
+	"api/utils"
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// oauthSecrets are the settings applied without a restart when a refresh
+// from the secrets manager changes them.
+var oauthSecrets = map[string]bool{
+	"GOOGLE_CLIENT_ID":     true,
+	"GOOGLE_CLIENT_SECRET": true,
+	"GITHUB_CLIENT_ID":     true,
+	"GITHUB_CLIENT_SECRET": true,
+}
+
+// setupSecrets loads settings from the secrets manager selected by
+// SECRETS_PROVIDER into the environment, before the configuration is
+// validated. With SECRETS_REFRESH_INTERVAL set, the returned function starts
+// refreshing them once the app is built; it is nil otherwise.
+func setupSecrets() func() {
+	provider, err := utils.NewSecretsProvider()
+	if err != nil {
+		log.Fatalf("secrets: %v", err)
+	}
+	if provider == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	keys, err := utils.LoadSecrets(ctx, provider)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("secrets: loaded %s from %s", strings.Join(keys, ", "), provider.Name())
+
+	interval, _ := time.ParseDuration(os.Getenv("SECRETS_REFRESH_INTERVAL"))
+	if interval <= 0 {
+		return nil
+	}
+	return func() {
+		go utils.WatchSecrets(context.Background(), provider, interval, applySecrets)
+	}
+}
+
+// applySecrets applies refreshed OAuth client credentials. Other settings,
+// such as JWT_SECRET, DB_URI and SMTP_PASSWORD, are bound to clients and
+// keys built at startup, so changes to them take effect on restart.
+func applySecrets(changed []string) {
+	var reloadOAuth bool
+	var pending []string
+	for _, key := range changed {
+		if oauthSecrets[key] {
+			reloadOAuth = true
+		} else {
+			pending = append(pending, key)
+		}
+	}
+
+	if reloadOAuth {
+		utils.InitOAuth()
+		log.Printf("secrets: reloaded OAuth client credentials")
+	}
+	if len(pending) > 0 {
+		log.Printf("secrets: %s changed; restart to apply", strings.Join(pending, ", "))
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"api/config"
+	"api/database"
+	"api/seed"
+	"api/utils"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+// runSeed implements the seed command: it migrates the DB_URI database and
+// fills it with sample accounts, sessions and password resets whose
+// credentials are printed, for local frontend development and integration
+// tests. The tokens are well known, so it refuses to run with ENV=production.
+func runSeed() {
+	cfg, err := config.Load(config.WithoutSMTP())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Production() {
+		log.Fatal("seed: refusing to seed a production database")
+	}
+
+	// The schema depends on whether emails are encrypted
+	if err := utils.InitPII(); err != nil {
+		log.Fatalf("pii: %v", err)
+	}
+
+	db := database.Open()
+	if err := utils.InitPepper(); err != nil {
+		log.Fatalf("password pepper: %v", err)
+	}
+
+	credentials, err := seed.Dev(db)
+	if err != nil {
+		log.Fatalf("seed: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tTYPE\tROLE\tPASSWORD\tREFRESH TOKEN\tRESET TOKEN")
+	for _, creds := range credentials {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", creds.Email, creds.AccountType, creds.Role, orDash(creds.Password), creds.RefreshToken, orDash(creds.ResetToken))
+	}
+	w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,133 @@
+package seed
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// devCredentialTTL is how long seeded sessions and reset tokens stay valid.
+// Seeding again renews them.
+const devCredentialTTL = 30 * 24 * time.Hour
+
+// DevAccounts are the accounts created by Dev: DemoAccounts plus an
+// OAuth-only and a hybrid account.
+var DevAccounts = append(append([]Account{}, DemoAccounts...),
+	Account{Username: "oauth", Email: "oauth@demo.local", Role: models.RoleUser, AccountType: models.AccountTypeOAuth, Providers: []models.OAuthProvider{models.OAuthProviderGithub}},
+	Account{Username: "hybrid", Email: "hybrid@demo.local", Role: models.RoleUser, AccountType: models.AccountTypeHybrid, Providers: []models.OAuthProvider{models.OAuthProviderGoogle}},
+)
+
+// Credentials are the known secrets of a seeded account.
+type Credentials struct {
+	Account
+	Password     string // Empty for OAuth-only accounts
+	RefreshToken string // Value of the refresh_token cookie of a seeded session
+	ResetToken   string // Password reset token, empty for OAuth-only accounts
+}
+
+// Dev creates DevAccounts in the default tenant, each with a session and,
+// when it has a password, a pending password reset. Their tokens are derived
+// from the username, so they stay the same across runs. Like Demo, it must
+// run after the PII and password pepper keys are loaded.
+func Dev(db *gorm.DB) ([]Credentials, error) {
+	stores := store.NewGorm(db)
+	credentials := make([]Credentials, 0, len(DevAccounts))
+	for _, account := range DevAccounts {
+		creds, err := devAccount(db, stores, account)
+		if err != nil {
+			return nil, fmt.Errorf("seed %s: %w", account.Email, err)
+		}
+		credentials = append(credentials, creds)
+	}
+	return credentials, nil
+}
+
+func devAccount(db *gorm.DB, stores store.Store, account Account) (Credentials, error) {
+	user, err := createUser(stores.Users(), account)
+	if err != nil {
+		return Credentials{}, err
+	}
+	for _, provider := range account.Providers {
+		if err := linkProvider(stores.OAuth(), user, provider); err != nil {
+			return Credentials{}, err
+		}
+	}
+
+	creds := Credentials{
+		Account:      account,
+		RefreshToken: "seed-refresh-" + account.Username,
+	}
+	creds.AccountType = user.AccountType
+	if err := upsertSession(db, user, creds.RefreshToken); err != nil {
+		return Credentials{}, err
+	}
+
+	if user.AccountType != models.AccountTypeOAuth {
+		creds.Password = Password
+		creds.ResetToken = "seed-reset-" + account.Username
+		if err := upsertReset(db, user, creds.ResetToken); err != nil {
+			return Credentials{}, err
+		}
+	}
+	return creds, nil
+}
+
+// linkProvider links a fake provider account to user unless one is linked.
+func linkProvider(accounts store.OAuthStore, user *models.User, provider models.OAuthProvider) error {
+	_, err := accounts.ByUser(user.ID, provider)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	// Seeded accounts have no provider tokens, as they never call the
+	// provider
+	now := time.Now()
+	return accounts.Create(&models.OAuthAccount{
+		UserID:     user.ID,
+		Provider:   provider,
+		ProviderID: fmt.Sprintf("seed-%s-%d", provider, user.ID),
+		Email:      user.Email,
+		Name:       user.Username,
+		LinkedAt:   now,
+		LastUsedAt: &now,
+	})
+}
+
+// upsertSession creates the session with refreshToken, or revives and renews
+// it when it was revoked or has expired.
+func upsertSession(db *gorm.DB, user *models.User, refreshToken string) error {
+	hash := utils.HashTokenSHA256(refreshToken)
+	session := models.Session{
+		JTI:          "seed-" + user.Username,
+		UserID:       user.ID,
+		RefreshToken: hash,
+		IPAddress:    "127.0.0.1",
+		UserAgent:    "seed",
+	}
+	return db.Where(models.Session{RefreshToken: hash}).
+		Assign(map[string]any{"revoked": false, "expires_at": time.Now().Add(devCredentialTTL)}).
+		FirstOrCreate(&session).Error
+}
+
+// upsertReset creates the password reset with token, or makes it usable
+// again when it was used or has expired.
+func upsertReset(db *gorm.DB, user *models.User, token string) error {
+	hash := utils.HashTokenSHA256(token)
+	reset := models.PasswordReset{
+		TenantID:  user.TenantID,
+		Email:     utils.NormalizeEmail(user.Email),
+		EmailHash: utils.EmailBlindIndex(user.Email),
+		Token:     hash,
+	}
+	return db.Where(models.PasswordReset{Token: hash}).
+		Assign(map[string]any{"used": false, "failed_attempts": 0, "expires_at": time.Now().Add(devCredentialTTL)}).
+		FirstOrCreate(&reset).Error
+}
@@ -0,0 +1,96 @@
+// Package seed creates sample accounts with known credentials for demo mode,
+// local frontend development and integration tests. Accounts are written
+// directly, without sending emails, and are skipped when they already exist.
+package seed
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Password is the password of every seeded account.
+const Password = "demo-password"
+
+// Account is a seeded account, signed in to with its email and Password
+// unless it is OAuth-only.
+type Account struct {
+	Username    string
+	Email       string
+	Role        models.Role
+	AccountType models.AccountType     // Defaults to an email account
+	Providers   []models.OAuthProvider // Linked fake provider accounts
+}
+
+// DemoAccounts are the accounts created by Demo.
+var DemoAccounts = []Account{
+	{Username: "admin", Email: "admin@demo.local", Role: models.RoleAdmin},
+	{Username: "demo", Email: "demo@demo.local", Role: models.RoleUser},
+}
+
+// Demo creates DemoAccounts in the default tenant. It must run after
+// server.NewApp, which configures email encryption and password hashing.
+func Demo(db *gorm.DB) error {
+	users := store.NewGorm(db).Users()
+	for _, account := range DemoAccounts {
+		if _, err := createUser(users, account); err != nil {
+			return fmt.Errorf("seed %s: %w", account.Email, err)
+		}
+	}
+	return nil
+}
+
+// createUser creates a verified account that has accepted the current legal
+// documents, or returns the existing one with the same email.
+func createUser(users store.UserStore, account Account) (*models.User, error) {
+	email := utils.NormalizeEmail(account.Email)
+	existing, err := users.ByEmail(models.DefaultTenantID, email)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	accountType := account.AccountType
+	if accountType == "" {
+		accountType = models.AccountTypeEmail
+	}
+	var hash string
+	if accountType != models.AccountTypeOAuth {
+		if hash, err = utils.HashPassword(Password); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	legal := utils.CurrentLegalDocuments()
+	user := models.User{
+		TenantID:        models.DefaultTenantID,
+		Username:        account.Username,
+		Email:           email,
+		EmailHash:       utils.EmailBlindIndex(email),
+		Password:        hash,
+		AccountType:     accountType,
+		Role:            account.Role,
+		Locale:          models.LocaleEN,
+		EmailVerifiedAt: &now,
+		TermsVersion:    legal.TermsVersion,
+		PrivacyVersion:  legal.PrivacyVersion,
+	}
+	if legal.TermsVersion != "" {
+		user.TermsAcceptedAt = &now
+	}
+	if legal.PrivacyVersion != "" {
+		user.PrivacyAcceptedAt = &now
+	}
+	if err := users.Create(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
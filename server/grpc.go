@@ -0,0 +1,49 @@
+package server
+
+import (
+	"api/grpcapi"
+	"api/service"
+	"context"
+	"log"
+	"net"
+
+	"google.golang.org/grpc"
+)
+
+// listenGRPC serves the gRPC API for internal services (see grpcapi) on
+// addr. Failing to start it is fatal, like any other startup error.
+func listenGRPC(addr string, tokens *service.Tokens) *grpc.Server {
+	srv, err := grpcapi.NewServer(tokens)
+	if err != nil {
+		log.Fatalf("grpc: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("grpc: %v", err)
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			log.Printf("grpc: listener on %s: %v", addr, err)
+		}
+	}()
+	log.Printf("grpc: listening on %s", addr)
+	return srv
+}
+
+// stopGRPC stops srv from accepting calls and waits for running ones to
+// finish, cutting them off when ctx is done.
+func stopGRPC(ctx context.Context, srv *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		srv.Stop()
+		<-stopped
+	}
+}
@@ -0,0 +1,88 @@
+package server_test
+
+import (
+	"api/database/models"
+	"api/seed"
+	"api/utils"
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestSetPasswordStepUp adds a password to an OAuth-only account whose
+// session signed in too long ago, which takes the emailed code, and checks
+// that its other sessions are revoked.
+func TestSetPasswordStepUp(t *testing.T) {
+	app, db := newDevApp(t)
+	if _, err := seed.Dev(db); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", "oauth").First(&user).Error; err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	jti, token, err := utils.GetSignedKey(&user)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	session := models.Session{JTI: jti, UserID: user.ID, IssuedAt: time.Now().Add(-time.Hour), ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	setPassword := func(token, body string) (int, map[string]any) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/v1/user/password", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var envelope struct {
+			Data map[string]any `json:"data"`
+		}
+		json.NewDecoder(resp.Body).Decode(&envelope)
+		return resp.StatusCode, envelope.Data
+	}
+
+	status, data := setPassword(token, `{"password":"a-new-long-password"}`)
+	if status != 403 || data["reason"] != "step_up_required" {
+		t.Fatalf("without a code: status %d data %v, want 403 step_up_required", status, data)
+	}
+
+	status, data = setPassword(token, `{"password":"a-new-long-password","step_up_code":"000000"}`)
+	if status != 401 || data["reason"] != "invalid_step_up_code" {
+		t.Fatalf("wrong code: status %d data %v, want 401 invalid_step_up_code", status, data)
+	}
+
+	// The wrong code did not use up the one that was emailed
+	var email models.EmailOutbox
+	if err := db.Where("type = ?", utils.EmailTemplatePasswordSetChallenge).Last(&email).Error; err != nil {
+		t.Fatalf("find challenge email: %v", err)
+	}
+	code := regexp.MustCompile(`\b\d{6}\b`).FindString(email.Body)
+	if code == "" {
+		t.Fatalf("no code in %q", email.Body)
+	}
+
+	status, data = setPassword(token, `{"password":"a-new-long-password","step_up_code":"`+code+`"}`)
+	if status != 200 {
+		t.Fatalf("with the code: status %d data %v, want 200", status, data)
+	}
+	if data["token"] == "" || data["token"] == token || data["revoked_sessions"] != float64(1) {
+		t.Errorf("unexpected response %v", data)
+	}
+
+	var active int64
+	db.Model(&models.Session{}).Where("user_id = ? AND revoked = false", user.ID).Count(&active)
+	if active != 1 {
+		t.Errorf("%d active sessions, want only the new one", active)
+	}
+}
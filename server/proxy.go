@@ -0,0 +1,54 @@
+package server
+
+import (
+	"log"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// withTrustedProxies makes c.IP() return the client address that a proxy in
+// TRUSTED_PROXIES (comma-separated IPs or CIDRs) put in PROXY_HEADER, e.g.
+// X-Real-IP, so per-IP rate limits, IP rules and stuffing blocks act on the
+// client rather than the load balancer. Requests from other addresses keep
+// their connection's address, so clients cannot spoof the header. Without
+// PROXY_HEADER the connection's address is used.
+func withTrustedProxies(cfg fiber.Config) fiber.Config {
+	header := strings.TrimSpace(os.Getenv("PROXY_HEADER"))
+	proxies := splitList(os.Getenv("TRUSTED_PROXIES"))
+	if header == "" {
+		if len(proxies) > 0 {
+			log.Fatal("TRUSTED_PROXIES is set but PROXY_HEADER is not")
+		}
+		return cfg
+	}
+	if len(proxies) == 0 {
+		log.Fatal("PROXY_HEADER requires TRUSTED_PROXIES")
+	}
+	for _, proxy := range proxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				log.Fatalf("invalid TRUSTED_PROXIES entry %q", proxy)
+			}
+		}
+	}
+
+	cfg.ProxyHeader = header
+	cfg.EnableTrustedProxyCheck = true
+	cfg.TrustedProxies = proxies
+	// Fall back to the connection's address when the header holds no IP
+	cfg.EnableIPValidation = true
+	return cfg
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
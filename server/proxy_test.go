@@ -0,0 +1,51 @@
+package server
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWithTrustedProxies(t *testing.T) {
+	// Requests made through app.Test come from 0.0.0.0
+	tests := []struct {
+		name    string
+		header  string
+		proxies string
+		value   string
+		want    string
+	}{
+		{"not configured", "", "", "198.51.100.7", "0.0.0.0"},
+		{"trusted proxy", "X-Real-IP", "0.0.0.0", "198.51.100.7", "198.51.100.7"},
+		{"trusted range", "X-Real-IP", "10.0.0.0/8, 0.0.0.0/32", "198.51.100.7", "198.51.100.7"},
+		{"untrusted peer", "X-Real-IP", "10.0.0.0/8", "198.51.100.7", "0.0.0.0"},
+		{"header without an ip", "X-Real-IP", "0.0.0.0", "garbage", "0.0.0.0"},
+		{"first forwarded address", "X-Forwarded-For", "0.0.0.0", "198.51.100.7, 10.0.0.1", "198.51.100.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PROXY_HEADER", tt.header)
+			t.Setenv("TRUSTED_PROXIES", tt.proxies)
+			app := fiber.New(withTrustedProxies(fiber.Config{}))
+			app.Get("/", func(c *fiber.Ctx) error { return c.SendString(c.IP()) })
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("X-Real-IP", tt.value)
+			req.Header.Set("X-Forwarded-For", tt.value)
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(body); got != tt.want {
+				t.Errorf("c.IP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"api/handlers"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WithSIGHUPReload reloads the app's runtime settings each time the process
+// receives SIGHUP, e.g. from `kill -HUP` after editing the config file.
+func WithSIGHUPReload() Option {
+	return func(c *options) { c.reloadOnSIGHUP = true }
+}
+
+// reloadOnSIGHUP reloads h's settings on every SIGHUP until app shuts down.
+func reloadOnSIGHUP(app *fiber.App, h *handlers.Handler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	app.Hooks().OnShutdown(func() error {
+		signal.Stop(signals)
+		close(signals)
+		return nil
+	})
+
+	go func() {
+		for range signals {
+			result, err := h.ReloadSettings()
+			if len(result.Reloaded) > 0 {
+				log.Printf("settings: reloaded %s", strings.Join(result.Reloaded, ", "))
+			}
+			if err != nil {
+				log.Printf("settings: reload failed, previous values kept: %v", err)
+			}
+		}
+	}()
+}
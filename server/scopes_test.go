@@ -0,0 +1,165 @@
+package server_test
+
+import (
+	"api/seed"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiClient sends requests to the app with a bearer token.
+type apiClient struct {
+	t   *testing.T
+	app *fiber.App
+}
+
+func (a apiClient) do(method, path, token, contentType, body string) (int, map[string]any) {
+	a.t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, contentType)
+	}
+	if token != "" {
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+	}
+	resp, err := a.app.Test(req, -1)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&envelope)
+	var data map[string]any
+	json.Unmarshal(envelope.Data, &data)
+	return resp.StatusCode, data
+}
+
+func (a apiClient) login(email string) string {
+	a.t.Helper()
+	status, data := a.do("POST", "/api/v1/auth/login", "", fiber.MIMEApplicationJSON,
+		fmt.Sprintf(`{"email":%q,"password":%q}`, email, seed.Password))
+	token, _ := data["token"].(string)
+	if status != 200 || token == "" {
+		a.t.Fatalf("login %s: status %d data %v", email, status, data)
+	}
+	return token
+}
+
+type scopeCheck struct {
+	method, path, body string
+	status             int
+	reason             string
+}
+
+func (a apiClient) check(token string, checks []scopeCheck) {
+	a.t.Helper()
+	for _, tt := range checks {
+		a.t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			status, data := a.do(tt.method, tt.path, token, fiber.MIMEApplicationJSON, tt.body)
+			if status != tt.status || (tt.reason != "" && data["reason"] != tt.reason) {
+				t.Errorf("status %d data %v, want %d %s", status, data, tt.status, tt.reason)
+			}
+		})
+	}
+}
+
+// TestPersonalAccessToken creates a personal access token and checks that
+// the user routes enforce its scope.
+func TestPersonalAccessToken(t *testing.T) {
+	app, db := newDevApp(t)
+	if err := seed.Demo(db); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	api := apiClient{t: t, app: app}
+	login := api.login("demo@demo.local")
+
+	status, data := api.do("POST", "/api/v1/user/tokens", login, fiber.MIMEApplicationJSON,
+		`{"name":"script","scopes":["profile:read"],"expires_in_days":7}`)
+	pat, _ := data["access_token"].(string)
+	if status != 201 || pat == "" {
+		t.Fatalf("create token: status %d data %v", status, data)
+	}
+	id := data["token"].(map[string]any)["id"]
+
+	status, data = api.do("POST", "/api/v1/user/tokens", login, fiber.MIMEApplicationJSON,
+		`{"name":"admin","scopes":["users:read"]}`)
+	if status != 400 || data["reason"] != "invalid_scope" {
+		t.Errorf("non-admin granted users:read: status %d data %v", status, data)
+	}
+
+	api.check(pat, []scopeCheck{
+		{"GET", "/api/v1/user/@me", "", 200, ""},
+		{"GET", "/api/v1/user/notifications", "", 200, ""},
+		{"PATCH", "/api/v1/user/profile", `{"bio":"scoped"}`, 403, "insufficient_scope"},
+		{"GET", "/api/v1/user/sessions", "", 403, "insufficient_scope"},
+		{"GET", "/api/v1/user/tokens", "", 403, "login_token_required"},
+		{"PATCH", "/api/v1/user/password", `{"current_password":"demo-password","new_password":"another-long-password"}`, 403, "login_token_required"},
+		{"GET", "/api/v1/admin/users", "", 403, ""},
+	})
+
+	// Login tokens are unscoped
+	api.check(login, []scopeCheck{
+		{"GET", "/api/v1/user/sessions", "", 200, ""},
+		{"GET", "/api/v1/user/tokens", "", 200, ""},
+	})
+
+	status, data = api.do("DELETE", fmt.Sprintf("/api/v1/user/tokens/%v", id), login, "", "")
+	if status != 200 {
+		t.Fatalf("revoke token: status %d data %v", status, data)
+	}
+	api.check(pat, []scopeCheck{{"GET", "/api/v1/user/@me", "", 401, ""}})
+}
+
+// TestClientCredentialsGrant registers an API client, exchanges its
+// credentials for a token and checks that the admin routes enforce its
+// scope.
+func TestClientCredentialsGrant(t *testing.T) {
+	app, db := newDevApp(t)
+	if err := seed.Demo(db); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	api := apiClient{t: t, app: app}
+	login := api.login("admin@demo.local")
+
+	status, data := api.do("POST", "/api/v1/user/api-clients", login, fiber.MIMEApplicationJSON,
+		`{"name":"sync","scopes":["users:read","profile:read"]}`)
+	if status != 201 {
+		t.Fatalf("create client: status %d data %v", status, data)
+	}
+	clientID := data["client"].(map[string]any)["client_id"].(string)
+	secret := data["client_secret"].(string)
+
+	status, data = api.do("POST", "/api/v1/auth/token", "", fiber.MIMEApplicationForm,
+		"grant_type=client_credentials&client_id="+clientID+"&client_secret=wrong")
+	if status != 401 || data["reason"] != "invalid_client" {
+		t.Errorf("wrong secret: status %d data %v", status, data)
+	}
+
+	status, data = api.do("POST", "/api/v1/auth/token", "", fiber.MIMEApplicationForm,
+		"grant_type=client_credentials&client_id="+clientID+"&client_secret="+secret+"&scope=users:read")
+	token, _ := data["access_token"].(string)
+	if status != 200 || token == "" || data["token_type"] != "Bearer" || data["scope"] != "users:read" {
+		t.Fatalf("token: status %d data %v", status, data)
+	}
+
+	api.check(token, []scopeCheck{
+		{"GET", "/api/v1/admin/users", "", 200, ""},
+		{"POST", "/api/v1/admin/invitations", `{"role":"user"}`, 403, "insufficient_scope"},
+		{"GET", "/api/v1/admin/audit-logs", "", 403, "login_token_required"},
+		{"GET", "/api/v1/admin/security-policy", "", 403, "login_token_required"},
+		// Narrowed to users:read, so profile:read is not granted
+		{"GET", "/api/v1/user/@me", "", 403, "insufficient_scope"},
+	})
+
+	// JSON bodies work as well
+	status, data = api.do("POST", "/api/v1/auth/token", "", fiber.MIMEApplicationJSON,
+		fmt.Sprintf(`{"grant_type":"client_credentials","client_id":%q,"client_secret":%q}`, clientID, secret))
+	if status != 200 || data["scope"] != "profile:read users:read" {
+		t.Errorf("JSON token request: status %d data %v", status, data)
+	}
+}
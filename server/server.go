@@ -0,0 +1,354 @@
+// Package server assembles the auth API into a Fiber app, so it can be run
+// by main, embedded in another service or started in integration tests.
+package server
+
+import (
+	"api/config"
+	"api/database"
+	"api/handlers"
+	"api/middleware"
+	"api/routes"
+	"api/store"
+	"api/tokenauth"
+	"api/utils"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/monitor"
+	"golang.org/x/crypto/acme/autocert"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+// options holds the dependencies NewApp wires together. Anything left unset
+// is built from environment variables, as when running the binary.
+type options struct {
+	db          *gorm.DB
+	logger      middleware.Logger
+	mailer      utils.EmailSender
+	storage     utils.ObjectStorage
+	storageSet  bool
+	devRoutes   bool
+	devRoutesOK bool
+
+	riskRules      []handlers.RiskRule
+	reloadOnSIGHUP bool
+}
+
+// Option customizes the app built by NewApp.
+type Option func(*options)
+
+// WithDB uses db instead of connecting to DB_URI. The schema is migrated on
+// startup either way.
+func WithDB(db *gorm.DB) Option {
+	return func(c *options) { c.db = db }
+}
+
+// WithLogger sends request error logs to logger instead of the standard
+// logger.
+func WithLogger(logger middleware.Logger) Option {
+	return func(c *options) { c.logger = logger }
+}
+
+// WithEmailSender delivers emails through sender instead of the provider
+// configured by EMAIL_PROVIDER.
+func WithEmailSender(sender utils.EmailSender) Option {
+	return func(c *options) { c.mailer = sender }
+}
+
+// WithObjectStorage stores avatars in storage instead of the one configured
+// by STORAGE_PROVIDER. A nil storage disables avatar uploads.
+func WithObjectStorage(storage utils.ObjectStorage) Option {
+	return func(c *options) { c.storage, c.storageSet = storage, true }
+}
+
+// WithDevRoutes mounts the unauthenticated development helpers under
+// /api/v1/dev. By default they are mounted only when ENV is "development".
+func WithDevRoutes(enabled bool) Option {
+	return func(c *options) { c.devRoutes, c.devRoutesOK = enabled, true }
+}
+
+// WithRiskRules adds rules to the login risk pipeline, after the built-in
+// rules (see handlers.RiskRule).
+func WithRiskRules(rules ...handlers.RiskRule) Option {
+	return func(c *options) { c.riskRules = append(c.riskRules, rules...) }
+}
+
+// NewApp initializes the service and returns the Fiber app serving it.
+// Configuration errors at startup are fatal, as they are for the binary.
+func NewApp(opts ...Option) *fiber.App {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// Injected dependencies replace the settings they would be built from
+	var required []config.Option
+	if o.db != nil {
+		required = append(required, config.WithoutDatabase())
+	}
+	if o.mailer != nil {
+		required = append(required, config.WithoutSMTP())
+	}
+	cfg, err := config.Load(required...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := utils.InitEncryption(); err != nil {
+		log.Fatalf("encryption: %v", err)
+	}
+
+	// The schema depends on whether emails are encrypted
+	if err := utils.InitPII(); err != nil {
+		log.Fatalf("pii: %v", err)
+	}
+
+	db := o.db
+	if db == nil {
+		db = database.Open()
+	} else if err := database.Migrate(db); err != nil {
+		log.Printf("database: migration failed: %v", err)
+	}
+	if o.logger == nil {
+		o.logger = log.Default()
+	}
+	if !o.devRoutesOK {
+		o.devRoutes = cfg.Development()
+	}
+
+	utils.InitOAuth() // Initialize OAuth configurations
+
+	if err := utils.InitPepper(); err != nil {
+		log.Fatalf("password pepper: %v", err)
+	}
+
+	if err := utils.InitImportedHashes(); err != nil {
+		log.Fatalf("imported password hashes: %v", err)
+	}
+
+	if err := utils.InitMessages(); err != nil {
+		log.Fatalf("messages: %v", err)
+	}
+
+	if err := utils.InitEmailTemplates(); err != nil {
+		log.Fatalf("email templates: %v", err)
+	}
+
+	if o.mailer == nil {
+		mailer, err := utils.NewEmailSender()
+		if err != nil {
+			log.Fatalf("email: %v", err)
+		}
+		o.mailer = mailer
+	}
+
+	if !o.storageSet {
+		storage, err := utils.NewObjectStorage()
+		if err != nil {
+			log.Fatalf("storage: %v", err)
+		}
+		o.storage = storage
+	}
+
+	caches := middleware.NewCaches(db)
+	h := handlers.New(db, caches, o.mailer, o.storage)
+	for _, rule := range o.riskRules {
+		h.RegisterRiskRule(rule)
+	}
+	sessions := store.NewGorm(db).Sessions()
+
+	errorResponder := middleware.NewErrorResponder()
+	app := fiber.New(withTrustedProxies(fiber.Config{
+		Prefork:      false,
+		BodyLimit:    h.BodyLimit(),
+		ErrorHandler: middleware.NewErrorHandler(o.logger, errorResponder),
+	}))
+
+	// Internal services can validate tokens over gRPC beside the HTTP API
+	var rpc *grpc.Server
+	if addr := os.Getenv("GRPC_ADDR"); addr != "" {
+		rpc = listenGRPC(addr, h.Tokens())
+	}
+
+	// The database is closed only when NewApp opened it
+	var ownDB *gorm.DB
+	if o.db == nil {
+		ownDB = db
+	}
+	setupShutdown(app, h, rpc, ownDB)
+	if o.reloadOnSIGHUP {
+		reloadOnSIGHUP(app, h)
+	}
+
+	// Attach request id middleware early so the error handler can include it.
+	app.Use(middleware.RequestID())
+
+	// Compress responses once every later middleware and handler has written
+	// them
+	app.Use(middleware.Compress())
+
+	// Unwrap the response envelope for clients asking for raw responses
+	app.Use(middleware.ResponseFormat(errorResponder))
+
+	// CORS must run before any route so preflight requests are answered.
+	app.Use(middleware.CORS())
+
+	app.Use("/metrics", monitor.New())
+
+	// Health checks and metrics stay up during maintenance; everything
+	// registered after the maintenance middleware answers 503 while it is on.
+	app.Get("/health", h.Health)
+	app.Use(middleware.Maintenance(caches.Maintenance))
+
+	// Access tokens are checked only on the protected groups below
+	requireToken := tokenauth.Fiber(tokenauth.New([]byte(cfg.JWTSecret), sessionRevocation(sessions)), tokenauth.FiberConfig{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			// Say why the token itself was rejected, but not why the
+			// session lookup failed
+			var data any
+			if errors.Is(err, tokenauth.ErrMissingToken) || errors.Is(err, tokenauth.ErrInvalidToken) {
+				data = err.Error()
+			}
+			message := utils.TranslateMessage(middleware.MessageLocale(c), "Unauthorized")
+			if errorResponder.WantsProblem(c) {
+				return errorResponder.WriteProblem(c, 401, message, data)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(utils.Response{
+				Success: false,
+				Code:    401,
+				Message: message,
+				Data:    data,
+			})
+		},
+	})
+	ipFilter := middleware.IPFilter(caches.IPRules)
+	tenant := middleware.Tenant(caches.Tenants)
+	requireTenantToken := middleware.RequireTenantToken()
+	requireAdmin := middleware.RequireAdmin(db)
+	requireLegal := h.RequireLegalAcceptance()
+
+	// Every API version gets the same middleware; versions differ only in
+	// the route groups they register (see routes.Versions).
+	for _, version := range routes.Versions {
+		api := app.Group("/api/" + version.Name)
+
+		// Enforce IP allow/deny rules before any auth handler runs.
+		api.Use(ipFilter)
+
+		// Resolve the tenant from the tenant header or subdomain
+		api.Use(tenant)
+
+		// Read-only configuration for frontends, such as feature flags
+		config := api.Group("/config")
+		version.Config(config, h)
+
+		// Public auth routes (register/login/refresh) should not require JWTs.
+		auth := api.Group("/auth")
+		version.Auth(auth, h)
+
+		// Email provider callbacks (bounces and complaints), verified by signature
+		webhooks := api.Group("/webhooks")
+		version.Webhooks(webhooks, h)
+
+		// Unauthenticated development helpers such as email template previews
+		if o.devRoutes {
+			dev := api.Group("/dev")
+			version.Dev(dev, h)
+		}
+
+		// Protected routes: apply JWT middleware only to this subgroup. Any routes
+		// that require authentication should be registered under `protected`.
+		protected := api.Group("/")
+		protected.Use(requireToken, requireTenantToken)
+
+		userGroup := protected.Group("/user")
+		version.User(userGroup, h)
+
+		// Admin routes: additionally require the authenticated user to be an admin.
+		adminGroup := protected.Group("/admin")
+		adminGroup.Use(requireAdmin)
+		if requireLegal != nil {
+			adminGroup.Use(requireLegal)
+		}
+		version.Admin(adminGroup, h)
+	}
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendString("Hello world")
+	})
+
+	return app
+}
+
+// sessionRevocation treats a token as revoked when its session is revoked or
+// no longer exists.
+func sessionRevocation(sessions store.SessionStore) tokenauth.RevocationChecker {
+	return tokenauth.RevocationFunc(func(_ context.Context, jti string) (bool, error) {
+		session, err := sessions.ByJTI(jti)
+		if errors.Is(err, store.ErrNotFound) {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return session.Revoked, nil
+	})
+}
+
+// Listen serves app on addr. TLS is terminated in-app with certificates from
+// Let's Encrypt when ACME_DOMAINS is set, or from TLS_CERT_FILE and
+// TLS_KEY_FILE, which also records client TLS fingerprints for session
+// binding.
+func Listen(app *fiber.App, addr string) error {
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+
+	manager, err := utils.NewAutocert()
+	if err != nil {
+		return err
+	}
+	if manager != nil {
+		if certFile != "" || keyFile != "" {
+			return errors.New("set either ACME_DOMAINS or TLS_CERT_FILE and TLS_KEY_FILE, not both")
+		}
+		ln, err := utils.ListenAutocert(addr, manager)
+		if err != nil {
+			return err
+		}
+		if httpAddr := os.Getenv("ACME_HTTP_ADDR"); httpAddr != "" {
+			listenACMEHTTP(app, httpAddr, manager)
+		}
+		return app.Listener(ln)
+	}
+
+	if certFile == "" || keyFile == "" {
+		return app.Listen(addr)
+	}
+
+	ln, err := utils.ListenTLS(addr, certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	return app.Listener(ln)
+}
+
+// listenACMEHTTP answers HTTP-01 challenges on addr, usually ":80", and
+// redirects everything else to HTTPS. It stops when app shuts down.
+func listenACMEHTTP(app *fiber.App, addr string, manager *autocert.Manager) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           manager.HTTPHandler(nil),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("acme: http listener on %s: %v", addr, err)
+		}
+	}()
+	app.Hooks().OnShutdown(srv.Close)
+}
@@ -0,0 +1,122 @@
+package server_test
+
+import (
+	"api/database"
+	"api/seed"
+	"api/server"
+	"api/utils"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// newDevApp builds the app the way --demo does, on an in-memory SQLite
+// database.
+func newDevApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "smoke-test-secret")
+	t.Setenv("BASE_URL", "http://localhost:8080")
+	t.Setenv("BCRYPT_COST", "4")
+
+	db, err := database.OpenSQLite("")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	app := server.NewApp(
+		server.WithDB(db),
+		server.WithEmailSender(utils.NewMemorySender(10)),
+		server.WithDevRoutes(true),
+	)
+	t.Cleanup(func() {
+		server.Shutdown(app)
+		database.Close(db)
+	})
+	return app, db
+}
+
+// TestDemoSmoke runs the app with the demo accounts and exercises a sign-in.
+func TestDemoSmoke(t *testing.T) {
+	app, db := newDevApp(t)
+	if err := seed.Demo(db); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	var token string
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		auth   bool
+		status int
+		check  func(t *testing.T, data json.RawMessage)
+	}{
+		{name: "health", method: "GET", path: "/health", status: 200},
+		{name: "unknown account", method: "POST", path: "/api/v1/auth/login", body: `{"email":"nobody@demo.local","password":"demo-password"}`, status: 404},
+		{name: "wrong password", method: "POST", path: "/api/v1/auth/login", body: `{"email":"demo@demo.local","password":"wrong-password"}`, status: 401},
+		{
+			name:   "demo login",
+			method: "POST",
+			path:   "/api/v1/auth/login",
+			body:   `{"email":"demo@demo.local","password":"` + seed.Password + `"}`,
+			status: 200,
+			check: func(t *testing.T, data json.RawMessage) {
+				var login struct {
+					Token string `json:"token"`
+				}
+				if err := json.Unmarshal(data, &login); err != nil || login.Token == "" {
+					t.Fatalf("no token in %s", data)
+				}
+				token = login.Token
+			},
+		},
+		{name: "me without a token", method: "GET", path: "/api/v1/user/@me", status: 401},
+		{
+			name:   "me",
+			method: "GET",
+			path:   "/api/v1/user/@me",
+			auth:   true,
+			status: 200,
+			check: func(t *testing.T, data json.RawMessage) {
+				var user struct {
+					Email string `json:"email"`
+				}
+				if err := json.Unmarshal(data, &user); err != nil || user.Email != "demo@demo.local" {
+					t.Errorf("unexpected user %s", data)
+				}
+			},
+		},
+		{name: "captured emails", method: "GET", path: "/api/v1/dev/emails", status: 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			}
+			if tt.auth {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var envelope struct {
+				Data json.RawMessage `json:"data"`
+			}
+			json.NewDecoder(resp.Body).Decode(&envelope)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d (data %s)", resp.StatusCode, tt.status, envelope.Data)
+			}
+			if tt.check != nil {
+				tt.check(t, envelope.Data)
+			}
+		})
+	}
+}
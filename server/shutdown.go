@@ -0,0 +1,65 @@
+package server
+
+import (
+	"api/database"
+	"api/handlers"
+	"api/middleware"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
+	"gorm.io/gorm"
+)
+
+// shutdownTimeout bounds each step of Shutdown, set from SHUTDOWN_TIMEOUT
+// (default 15s) by NewApp.
+var shutdownTimeout = 15 * time.Second
+
+// setupShutdown reads SHUTDOWN_TIMEOUT and has app release h's workers and
+// the shared connections once it has shut down. The gRPC server rpc is
+// stopped first and db closed last, unless they are nil.
+func setupShutdown(app *fiber.App, h *handlers.Handler, rpc *grpc.Server, db *gorm.DB) {
+	if value := os.Getenv("SHUTDOWN_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			log.Fatalf("SHUTDOWN_TIMEOUT must be a positive duration, got %q", value)
+		}
+		shutdownTimeout = timeout
+	}
+
+	app.Hooks().OnShutdown(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+
+		// Let in-flight gRPC calls finish, like the HTTP requests before them
+		if rpc != nil {
+			stopGRPC(ctx, rpc)
+		}
+
+		// Workers still write to the database, so stop them first
+		errs := []error{h.Close(ctx)}
+		if err := middleware.CloseStorage(); err != nil {
+			errs = append(errs, fmt.Errorf("storage: %w", err))
+		}
+		if db != nil {
+			if err := database.Close(db); err != nil {
+				errs = append(errs, fmt.Errorf("database: %w", err))
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+// Shutdown stops app gracefully for a rolling deploy. It stops accepting
+// connections and waits for in-flight requests, such as logins, to finish.
+// Then it stops the background workers, letting each finish its batch,
+// sends queued audit and bus events, and closes the Redis and database
+// connections. Each of the two steps is cut off after SHUTDOWN_TIMEOUT.
+func Shutdown(app *fiber.App) error {
+	return app.ShutdownWithTimeout(shutdownTimeout)
+}
@@ -0,0 +1,272 @@
+// Package service implements registration, sign-in, OAuth account linking
+// and password resets independently of any transport. Handlers translate
+// requests into calls on these types and map the returned *Error values to
+// responses.
+package service
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"time"
+)
+
+// Accounts registers users and signs them in with a password.
+type Accounts struct {
+	Store     store.Store
+	Policy    func() utils.SecurityPolicy // The effective policy, which may be reloaded at runtime
+	Usernames utils.UsernamePolicy
+
+	// UsernameGrace is how long a username someone gave up stays reserved
+	UsernameGrace time.Duration
+
+	// OnLocked is called when a failed login locks an account whose lockout
+	// policy allows unlocking by email
+	OnLocked func(user *models.User, lockedUntil time.Time)
+}
+
+// RegisterInput is a self-registration. Request-level checks such as
+// CAPTCHAs and invitation lookups are left to the caller.
+type RegisterInput struct {
+	TenantID   uint // Tenant the account is created in
+	Username   string
+	Email      string
+	Password   string
+	Locale     models.Locale
+	Invitation *models.Invitation // Accepted in the transaction creating the user
+	Legal      utils.LegalDocuments
+}
+
+// Register validates the input against the username and password policies
+// and creates the user.
+func (a *Accounts) Register(in RegisterInput) (*models.User, error) {
+	if in.Username == "" {
+		return nil, invalid("Username is required")
+	}
+	in.Email = utils.NormalizeEmail(in.Email)
+	if in.Email == "" {
+		return nil, invalid("Email is required")
+	}
+	if in.Password == "" {
+		return nil, invalid("Password is required")
+	}
+
+	in.Username = a.Usernames.Normalize(in.Username)
+	if err := a.Usernames.Validate(in.Username); err != nil {
+		return nil, invalid(err.Error())
+	}
+
+	if err := a.Policy().Password.Validate(in.Password, in.Username, in.Email); err != nil {
+		return nil, invalid(err.Error())
+	}
+
+	hash, err := utils.HashPassword(in.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		TenantID:  in.TenantID,
+		Username:  in.Username,
+		Email:     in.Email,
+		EmailHash: utils.EmailBlindIndex(in.Email),
+		Password:  hash,
+		Locale:    in.Locale,
+	}
+	if in.Invitation != nil {
+		user.Role = in.Invitation.Role
+	}
+	if in.Legal.TermsVersion != "" {
+		now := time.Now()
+		user.TermsVersion, user.TermsAcceptedAt = in.Legal.TermsVersion, &now
+	}
+	if in.Legal.PrivacyVersion != "" {
+		now := time.Now()
+		user.PrivacyVersion, user.PrivacyAcceptedAt = in.Legal.PrivacyVersion, &now
+	}
+
+	err = a.Store.Transaction(func(tx store.Store) error {
+		if reserved, err := UsernameReserved(tx.Users(), user.TenantID, user.Username, 0, a.UsernameGrace); err != nil || reserved {
+			return ErrAccountExists
+		}
+		if err := tx.Users().Create(&user); err != nil {
+			return ErrAccountExists
+		}
+		if in.Invitation == nil {
+			return nil
+		}
+		// The conditional update makes concurrent registrations with the
+		// same invitation fail
+		accepted, err := tx.Invitations().Accept(in.Invitation.ID, user.ID)
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return ErrInvitationInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LoginChecks are caller-supplied steps run during Login. Either may be nil.
+type LoginChecks struct {
+	// BeforePassword runs once the account is found and not locked, e.g.
+	// to require a CAPTCHA from accounts with recent failures
+	BeforePassword func(user *models.User) error
+	// AfterPassword runs once the password matched and the account is not
+	// restricted, e.g. to score the login's risk
+	AfterPassword func(user *models.User) error
+}
+
+// Login verifies the email and password of an account in the tenant. The
+// user is returned whenever the account was found, including alongside
+// errors, so callers can attribute failed attempts. Errors from checks are
+// returned unchanged.
+func (a *Accounts) Login(tenantID uint, email, password string, checks LoginChecks) (*models.User, error) {
+	user, err := a.Store.Users().ByEmail(tenantID, utils.NormalizeEmail(email))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse logins while the account is locked out
+	if user.LockedUntil != nil && user.LockedUntil.After(time.Now()) {
+		return user, ErrAccountLocked
+	}
+
+	if checks.BeforePassword != nil {
+		if err := checks.BeforePassword(user); err != nil {
+			return user, err
+		}
+	}
+
+	// A forced reset invalidates the password, whether or not it matches
+	if user.PasswordResetRequired {
+		return user, ErrPasswordResetRequired
+	}
+
+	if !utils.ComparePassword(password, user.Password) {
+		a.recordFailedLogin(user)
+		return user, ErrInvalidCredentials
+	}
+
+	// Banned and suspended users cannot sign in
+	if err := CheckRestriction(user); err != nil {
+		return user, err
+	}
+
+	if checks.AfterPassword != nil {
+		if err := checks.AfterPassword(user); err != nil {
+			return user, err
+		}
+	}
+
+	// Transparently upgrade hashes created with a different bcrypt cost
+	if utils.PasswordNeedsRehash(user.Password) {
+		if hash, err := utils.HashPassword(password); err == nil {
+			a.Store.Users().Update(user, map[string]any{"password": hash})
+		}
+	}
+
+	// Successful login clears any lockout state
+	if user.FailedLoginAttempts > 0 || user.LockedUntil != nil {
+		a.Store.Users().Update(user, map[string]any{
+			"failed_login_attempts": 0,
+			"locked_until":          nil,
+		})
+	}
+
+	return user, nil
+}
+
+// recordFailedLogin increments the user's consecutive failure counter and
+// locks the account once the lockout policy threshold is reached.
+func (a *Accounts) recordFailedLogin(user *models.User) {
+	lockout := a.Policy().Lockout
+
+	var lockedUntil *time.Time
+	if d := lockout.LockDuration(user.FailedLoginAttempts + 1); d > 0 {
+		until := time.Now().Add(d)
+		lockedUntil = &until
+	}
+
+	a.Store.Users().RecordFailedLogin(user, lockedUntil)
+
+	if lockedUntil != nil && lockout.UnlockByEmail && a.OnLocked != nil {
+		a.OnLocked(user, *lockedUntil)
+	}
+}
+
+// CheckRestriction returns an error wrapping ErrAccountRestricted if the
+// user is banned or currently suspended.
+func CheckRestriction(user *models.User) error {
+	if user.Banned {
+		err := &Error{Kind: KindForbidden, Reason: "account_banned", Message: "This account has been banned", err: ErrAccountRestricted}
+		if user.RestrictionReason != "" {
+			err.Details = map[string]any{"restriction_reason": user.RestrictionReason}
+		}
+		return err
+	}
+
+	if user.SuspendedUntil != nil && user.SuspendedUntil.After(time.Now()) {
+		err := &Error{Kind: KindForbidden, Reason: "account_suspended", Message: "This account is suspended", err: ErrAccountRestricted}
+		err.Details = map[string]any{"suspended_until": user.SuspendedUntil}
+		if user.RestrictionReason != "" {
+			err.Details["restriction_reason"] = user.RestrictionReason
+		}
+		return err
+	}
+
+	return nil
+}
+
+// UsernameReserved reports whether username was given up by a user of the
+// tenant other than userID within grace, so it still resolves to them and
+// cannot be claimed.
+func UsernameReserved(users store.UserStore, tenantID uint, username string, userID uint, grace time.Duration) (bool, error) {
+	if grace == 0 {
+		return false, nil
+	}
+	return users.UsernameReleased(tenantID, username, userID, time.Now().Add(-grace))
+}
+
+// CheckPasswordReuse rejects password if it matches the user's current
+// password or one of their historySize previous passwords.
+func CheckPasswordReuse(users store.UserStore, user *models.User, password string, historySize int) error {
+	if user.Password != "" && utils.ComparePassword(password, user.Password) {
+		return invalid("New password must be different from your current password")
+	}
+
+	if historySize == 0 {
+		return nil
+	}
+
+	history, err := users.PasswordHistory(user.ID, historySize)
+	if err != nil {
+		return err
+	}
+
+	for _, hash := range history {
+		if utils.ComparePassword(password, hash) {
+			return invalid("You cannot reuse one of your recent passwords")
+		}
+	}
+
+	return nil
+}
+
+// RememberPassword moves the user's current hash into the history before it
+// is replaced, keeping historySize entries.
+func RememberPassword(users store.UserStore, user *models.User, historySize int) error {
+	if user.Password == "" || historySize == 0 {
+		return nil
+	}
+	return users.RememberPassword(user.ID, user.Password, historySize)
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"api/database/models"
+	"api/utils"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name string
+		in   RegisterInput
+		kind Kind
+	}{
+		{"valid", RegisterInput{Username: "bob", Email: "bob@example.com", Password: testPassword}, 0},
+		{"email is normalized", RegisterInput{Username: "carol", Email: "  Carol@Example.COM ", Password: testPassword}, 0},
+		{"missing username", RegisterInput{Email: "bob@example.com", Password: testPassword}, KindInvalid},
+		{"missing email", RegisterInput{Username: "bob", Password: testPassword}, KindInvalid},
+		{"missing password", RegisterInput{Username: "bob", Email: "bob@example.com"}, KindInvalid},
+		{"invalid username", RegisterInput{Username: "-bob", Email: "bob@example.com", Password: testPassword}, KindInvalid},
+		{"weak password", RegisterInput{Username: "bob", Email: "bob@example.com", Password: "password"}, KindInvalid},
+		{"taken email", RegisterInput{Username: "bob", Email: "alice@example.com", Password: testPassword}, KindInvalid},
+		{"taken username", RegisterInput{Username: "alice", Email: "bob@example.com", Password: testPassword}, KindInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := newTestStore(t)
+			mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+
+			tt.in.TenantID = models.DefaultTenantID
+			user, err := newTestAccounts(st).Register(tt.in)
+			assertKind(t, err, tt.kind)
+			if err != nil {
+				return
+			}
+
+			if want := utils.NormalizeEmail(tt.in.Email); user.Email != want {
+				t.Errorf("email = %q, want %q", user.Email, want)
+			}
+			if !utils.ComparePassword(tt.in.Password, user.Password) {
+				t.Error("stored hash does not match the password")
+			}
+		})
+	}
+}
+
+func TestLogin(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		prepare  map[string]any // Applied to the user before logging in
+		kind     Kind
+	}{
+		{"valid", "alice@example.com", testPassword, nil, 0},
+		{"email is normalized", " ALICE@example.com", testPassword, nil, 0},
+		{"wrong password", "alice@example.com", "wrong-password", nil, KindUnauthenticated},
+		{"unknown email", "nobody@example.com", testPassword, nil, KindNotFound},
+		{"locked", "alice@example.com", testPassword, map[string]any{"locked_until": future}, KindLocked},
+		{"lock expired", "alice@example.com", testPassword, map[string]any{"locked_until": past, "failed_login_attempts": 5}, 0},
+		{"reset required", "alice@example.com", testPassword, map[string]any{"password_reset_required": true}, KindForbidden},
+		{"banned", "alice@example.com", testPassword, map[string]any{"banned": true}, KindForbidden},
+		{"suspended", "alice@example.com", testPassword, map[string]any{"suspended_until": future}, KindForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := newTestStore(t)
+			user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+			if tt.prepare != nil {
+				if err := st.Users().Update(user, tt.prepare); err != nil {
+					t.Fatalf("prepare user: %v", err)
+				}
+			}
+
+			got, err := newTestAccounts(st).Login(models.DefaultTenantID, tt.email, tt.password, LoginChecks{})
+			assertKind(t, err, tt.kind)
+			if err != nil {
+				return
+			}
+
+			if got.ID != user.ID {
+				t.Errorf("logged in as user %d, want %d", got.ID, user.ID)
+			}
+			stored, _ := st.Users().ByID(user.ID)
+			if stored.FailedLoginAttempts != 0 || stored.LockedUntil != nil {
+				t.Errorf("lockout state not cleared: %d failures, locked until %v", stored.FailedLoginAttempts, stored.LockedUntil)
+			}
+		})
+	}
+}
+
+func TestLoginLocksAfterThreshold(t *testing.T) {
+	st, _ := newTestStore(t)
+	user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+
+	var locked []uint
+	accounts := newTestAccounts(st)
+	accounts.OnLocked = func(user *models.User, _ time.Time) { locked = append(locked, user.ID) }
+
+	threshold := utils.NewLockoutPolicy().Threshold
+	for i := 0; i < threshold; i++ {
+		_, err := accounts.Login(models.DefaultTenantID, "alice@example.com", "wrong-password", LoginChecks{})
+		assertKind(t, err, KindUnauthenticated)
+	}
+
+	_, err := accounts.Login(models.DefaultTenantID, "alice@example.com", testPassword, LoginChecks{})
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("login after %d failures: got %v, want ErrAccountLocked", threshold, err)
+	}
+	if len(locked) != 1 || locked[0] != user.ID {
+		t.Errorf("OnLocked called for %v, want [%d]", locked, user.ID)
+	}
+}
+
+func TestLoginChecks(t *testing.T) {
+	errCheck := &Error{Kind: KindForbidden, Message: "check failed"}
+
+	tests := []struct {
+		name     string
+		password string
+		checks   func(calls *[]string) LoginChecks
+		want     error
+		calls    []string
+	}{
+		{
+			name:     "both run on success",
+			password: testPassword,
+			checks: func(calls *[]string) LoginChecks {
+				return LoginChecks{
+					BeforePassword: func(*models.User) error { *calls = append(*calls, "before"); return nil },
+					AfterPassword:  func(*models.User) error { *calls = append(*calls, "after"); return nil },
+				}
+			},
+			calls: []string{"before", "after"},
+		},
+		{
+			name:     "before password stops the login",
+			password: testPassword,
+			checks: func(calls *[]string) LoginChecks {
+				return LoginChecks{
+					BeforePassword: func(*models.User) error { *calls = append(*calls, "before"); return errCheck },
+					AfterPassword:  func(*models.User) error { *calls = append(*calls, "after"); return nil },
+				}
+			},
+			want:  errCheck,
+			calls: []string{"before"},
+		},
+		{
+			name:     "after password is skipped for a wrong password",
+			password: "wrong-password",
+			checks: func(calls *[]string) LoginChecks {
+				return LoginChecks{
+					AfterPassword: func(*models.User) error { *calls = append(*calls, "after"); return nil },
+				}
+			},
+			want: ErrInvalidCredentials,
+		},
+		{
+			name:     "after password error is returned unchanged",
+			password: testPassword,
+			checks: func(calls *[]string) LoginChecks {
+				return LoginChecks{
+					AfterPassword: func(*models.User) error { *calls = append(*calls, "after"); return errCheck },
+				}
+			},
+			want:  errCheck,
+			calls: []string{"after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := newTestStore(t)
+			mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+
+			var calls []string
+			_, err := newTestAccounts(st).Login(models.DefaultTenantID, "alice@example.com", tt.password, tt.checks(&calls))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if len(calls) != len(tt.calls) {
+				t.Fatalf("checks called %v, want %v", calls, tt.calls)
+			}
+			for i := range calls {
+				if calls[i] != tt.calls[i] {
+					t.Fatalf("checks called %v, want %v", calls, tt.calls)
+				}
+			}
+		})
+	}
+}
+
+func TestLoginRehashesPassword(t *testing.T) {
+	st, _ := newTestStore(t)
+	user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+
+	// A hash made with a cost other than BCRYPT_COST needs upgrading
+	stale, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Users().Update(user, map[string]any{"password": string(stale)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newTestAccounts(st).Login(models.DefaultTenantID, "alice@example.com", testPassword, LoginChecks{}); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	stored, _ := st.Users().ByID(user.ID)
+	if stored.Password == string(stale) {
+		t.Fatal("password was not rehashed")
+	}
+	if utils.PasswordNeedsRehash(stored.Password) || !utils.ComparePassword(testPassword, stored.Password) {
+		t.Errorf("rehashed password %q is not current", stored.Password)
+	}
+}
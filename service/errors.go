@@ -0,0 +1,50 @@
+package service
+
+import "errors"
+
+// Kind classifies an Error so each transport can map it to its own status
+// codes.
+type Kind int
+
+const (
+	KindInvalid         Kind = iota + 1 // The input was rejected
+	KindUnauthenticated                 // Credentials or tokens did not match
+	KindForbidden                       // The account may not perform the action
+	KindNotFound                        // The subject of the request does not exist
+	KindConflict                        // The request conflicts with existing state
+	KindLocked                          // The account is temporarily locked
+)
+
+// Error is a failure the caller can act on, as opposed to an internal error.
+type Error struct {
+	Kind    Kind
+	Reason  string         // Machine-readable reason, when the API defines one
+	Message string         // Human-readable message, safe to show to the client
+	Details map[string]any // Structured context, e.g. when a suspension ends
+
+	err error // A sentinel this error is an instance of
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Unwrap() error { return e.err }
+
+func invalid(message string) *Error {
+	return &Error{Kind: KindInvalid, Message: message}
+}
+
+// Sentinel errors, matched with errors.Is.
+var (
+	ErrUserNotFound          = &Error{Kind: KindNotFound, Message: "User not found"}
+	ErrInvalidCredentials    = &Error{Kind: KindUnauthenticated, Message: "Invalid credentials"}
+	ErrAccountLocked         = &Error{Kind: KindLocked, Message: "Account temporarily locked due to too many failed login attempts"}
+	ErrPasswordResetRequired = &Error{Kind: KindForbidden, Reason: "password_reset_required", Message: "A password reset is required. Check your email for a reset link."}
+	ErrAccountExists         = &Error{Kind: KindInvalid, Message: "User with this email or username already exists"}
+	ErrInvitationInvalid     = &Error{Kind: KindForbidden, Message: "Invalid or expired invitation"}
+	ErrInvalidResetToken     = &Error{Kind: KindInvalid, Message: "Invalid or expired reset token"}
+	ErrResetAttemptsExceeded = &Error{Kind: KindInvalid, Message: "Too many failed attempts. Please request a new password reset link."}
+
+	// ErrAccountRestricted is wrapped by the errors for banned and
+	// suspended accounts, which carry the restriction details.
+	ErrAccountRestricted = errors.New("account restricted")
+)
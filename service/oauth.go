@@ -0,0 +1,297 @@
+package service
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrLinkRequired is returned when an OAuth sign-in matches an email and
+// password account, which must link the provider from its settings.
+var ErrLinkRequired = &Error{Kind: KindConflict, Message: "Account with this email already exists. Please log in with your email and link your OAuth account in settings."}
+
+// OAuth decides whether an OAuth sign-in logs in, registers or links an
+// account. The caller issues the session, like after a password login, so
+// the session policy applies to both.
+type OAuth struct {
+	Store         store.Store
+	Usernames     utils.UsernamePolicy
+	UsernameGrace time.Duration // See Accounts.UsernameGrace
+
+	// AllowRegistration enforces the self-registration policy for accounts
+	// created by an OAuth sign-in
+	AllowRegistration func(email string) error
+}
+
+// OAuthUserInfo is the normalized profile returned by a provider.
+type OAuthUserInfo struct {
+	ID        string
+	Email     string
+	Name      string
+	AvatarURL string
+}
+
+// Client describes where a request came from.
+type Client struct {
+	TenantID  uint // Tenant the request was made in, which new accounts join
+	IPAddress string
+	UserAgent string
+	Locale    models.Locale // Negotiated from Accept-Language, used for new accounts
+}
+
+// OAuthLogin is the outcome of a successful OAuth sign-in.
+type OAuthLogin struct {
+	Action string // "login" or "register"
+	User   *models.User
+	Linked bool // The provider was newly linked to an existing account
+}
+
+// Login signs in with the provider account described by info, creating the
+// user or linking the provider to the account with the same email as
+// needed. Everything is written in one transaction.
+func (o *OAuth) Login(provider models.OAuthProvider, info OAuthUserInfo, token *oauth2.Token, client Client) (*OAuthLogin, error) {
+	var login *OAuthLogin
+	err := o.Store.Transaction(func(tx store.Store) error {
+		var err error
+		login, err = o.resolve(tx, provider, info, token, client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return login, nil
+}
+
+func (o *OAuth) resolve(tx store.Store, provider models.OAuthProvider, info OAuthUserInfo, token *oauth2.Token, client Client) (*OAuthLogin, error) {
+	// Check if OAuth account already exists
+	existingOAuth, err := tx.OAuth().ByProviderID(client.TenantID, provider, info.ID)
+	if err == nil {
+		return o.loginExisting(tx, existingOAuth, info, token, client)
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("OAuth account lookup failed: %w", err)
+	}
+
+	// OAuth account doesn't exist - check if email user exists
+	existingUser, err := tx.Users().ByEmail(client.TenantID, info.Email)
+	if errors.Is(err, store.ErrNotFound) {
+		// No user with this email - create new OAuth user, subject to the
+		// same self-registration policy as password sign-ups
+		if o.AllowRegistration != nil {
+			if err := o.AllowRegistration(info.Email); err != nil {
+				return nil, err
+			}
+		}
+		return o.register(tx, provider, info, token, client)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user lookup failed: %w", err)
+	}
+
+	switch existingUser.AccountType {
+	case models.AccountTypeEmail:
+		// Email account exists - require explicit linking
+		return nil, ErrLinkRequired
+	case models.AccountTypeOAuth, models.AccountTypeHybrid:
+		return o.link(tx, existingUser, provider, info, token, client)
+	default:
+		return nil, fmt.Errorf("unknown account type %q", existingUser.AccountType)
+	}
+}
+
+// loginExisting signs in with an already linked provider account.
+func (o *OAuth) loginExisting(tx store.Store, oauthAccount *models.OAuthAccount, info OAuthUserInfo, token *oauth2.Token, client Client) (*OAuthLogin, error) {
+	user, err := tx.Users().ByID(oauthAccount.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	if err := CheckRestriction(user); err != nil {
+		return nil, err
+	}
+
+	// Update OAuth account with latest info
+	encryptedAccess, encryptedRefresh, err := encryptOAuthToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map updates bypass the pii serializer
+	email, err := utils.EncryptPII(info.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt OAuth email: %w", err)
+	}
+
+	err = tx.OAuth().Update(oauthAccount, map[string]any{
+		"email":         email,
+		"name":          info.Name,
+		"avatar_url":    info.AvatarURL,
+		"access_token":  encryptedAccess,
+		"refresh_token": encryptedRefresh,
+		"token_expiry":  token.Expiry,
+		"last_used_at":  time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update OAuth account: %w", err)
+	}
+
+	return &OAuthLogin{Action: "login", User: user}, nil
+}
+
+// register creates an OAuth-only account. Providers only return verified
+// emails.
+func (o *OAuth) register(tx store.Store, provider models.OAuthProvider, info OAuthUserInfo, token *oauth2.Token, client Client) (*OAuthLogin, error) {
+	username, err := o.uniqueUsername(tx.Users(), client.TenantID, SuggestUsername(o.Usernames, info.Email, info.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	verifiedAt := time.Now()
+	user := models.User{
+		TenantID:        client.TenantID,
+		Username:        username,
+		Email:           info.Email,
+		EmailHash:       utils.EmailBlindIndex(info.Email),
+		AccountType:     models.AccountTypeOAuth,
+		Locale:          client.Locale,
+		EmailVerifiedAt: &verifiedAt,
+		// Password is null for OAuth-only accounts
+	}
+	if err := tx.Users().Create(&user); err != nil {
+		return nil, invalid("Failed to create user account")
+	}
+
+	account, err := newOAuthAccount(user.ID, provider, info, token)
+	if err != nil {
+		return nil, err
+	}
+	// Only first sign-ups record the granted scopes
+	if scope, ok := token.Extra("scope").(string); ok {
+		account.Scopes = scope
+	}
+	if err := tx.OAuth().Create(account); err != nil {
+		return nil, fmt.Errorf("failed to create OAuth account: %w", err)
+	}
+
+	return &OAuthLogin{Action: "register", User: &user}, nil
+}
+
+// link adds the provider to an existing OAuth or hybrid account.
+func (o *OAuth) link(tx store.Store, user *models.User, provider models.OAuthProvider, info OAuthUserInfo, token *oauth2.Token, client Client) (*OAuthLogin, error) {
+	if err := CheckRestriction(user); err != nil {
+		return nil, err
+	}
+
+	// Check if this provider is already linked
+	_, err := tx.OAuth().ByUser(user.ID, provider)
+	if err == nil {
+		return nil, &Error{Kind: KindConflict, Message: fmt.Sprintf("%s account already linked to this user", string(provider))}
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("OAuth link lookup failed: %w", err)
+	}
+
+	account, err := newOAuthAccount(user.ID, provider, info, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.OAuth().Create(account); err != nil {
+		return nil, fmt.Errorf("failed to link OAuth account: %w", err)
+	}
+
+	// An OAuth-only account with a second provider becomes hybrid
+	if user.AccountType == models.AccountTypeOAuth {
+		user.AccountType = models.AccountTypeHybrid
+		if err := tx.Users().Update(user, map[string]any{"account_type": user.AccountType}); err != nil {
+			return nil, fmt.Errorf("failed to update account type: %w", err)
+		}
+	}
+
+	return &OAuthLogin{Action: "login", User: user, Linked: true}, nil
+}
+
+// uniqueUsername returns base, or base with the lowest numeric suffix that
+// is neither taken nor reserved.
+func (o *OAuth) uniqueUsername(users store.UserStore, tenantID uint, base string) (string, error) {
+	username := base
+	for suffix := 1; ; suffix++ {
+		taken, err := users.UsernameTaken(tenantID, username)
+		if err != nil {
+			return "", err
+		}
+		reserved, err := UsernameReserved(users, tenantID, username, 0, o.UsernameGrace)
+		if err != nil {
+			return "", err
+		}
+		if !taken && !reserved {
+			return username, nil
+		}
+
+		// Prevent infinite loops
+		if suffix > 1000 {
+			return "", fmt.Errorf("unable to generate unique username")
+		}
+		username = fmt.Sprintf("%s_%d", base, suffix)
+	}
+}
+
+// SuggestUsername derives a username that satisfies policy from the email
+// local part or, failing that, the display name.
+func SuggestUsername(policy utils.UsernamePolicy, email, name string) string {
+	if email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		if username := policy.Sanitize(local); username != "" {
+			return username
+		}
+	}
+
+	if username := policy.Sanitize(name); username != "" {
+		return username
+	}
+
+	return "user"
+}
+
+// newOAuthAccount builds the link record with the provider tokens encrypted.
+func newOAuthAccount(userID uint, provider models.OAuthProvider, info OAuthUserInfo, token *oauth2.Token) (*models.OAuthAccount, error) {
+	encryptedAccess, encryptedRefresh, err := encryptOAuthToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &models.OAuthAccount{
+		UserID:       userID,
+		Provider:     provider,
+		ProviderID:   info.ID,
+		Email:        info.Email,
+		Name:         info.Name,
+		AvatarURL:    info.AvatarURL,
+		AccessToken:  encryptedAccess,
+		RefreshToken: encryptedRefresh,
+		TokenExpiry:  &token.Expiry,
+		LinkedAt:     now,
+		LastUsedAt:   &now,
+	}, nil
+}
+
+// encryptOAuthToken encrypts the provider access and refresh tokens for storage
+func encryptOAuthToken(token *oauth2.Token) (string, string, error) {
+	encryptedAccess, err := utils.EncryptToken(token.AccessToken)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt OAuth access token: %w", err)
+	}
+
+	encryptedRefresh, err := utils.EncryptToken(token.RefreshToken)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt OAuth refresh token: %w", err)
+	}
+
+	return encryptedAccess, encryptedRefresh, nil
+}
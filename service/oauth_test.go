@@ -0,0 +1,160 @@
+package service
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func newTestOAuth(st store.Store) *OAuth {
+	return &OAuth{Store: st, Usernames: utils.NewUsernamePolicy()}
+}
+
+func oauthToken() *oauth2.Token {
+	return &oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}
+}
+
+func TestOAuthLogin(t *testing.T) {
+	alice := OAuthUserInfo{ID: "gh-1", Email: "alice@example.com", Name: "Alice"}
+	errClosed := &Error{Kind: KindForbidden, Message: "registration closed"}
+
+	tests := []struct {
+		name     string
+		prepare  func(t *testing.T, st store.Store, o *OAuth)
+		provider models.OAuthProvider
+		info     OAuthUserInfo
+		action   string
+		linked   bool
+		account  models.AccountType
+		want     error
+		kind     Kind
+	}{
+		{
+			name:     "new user registers",
+			provider: models.OAuthProviderGithub,
+			info:     alice,
+			action:   "register",
+			account:  models.AccountTypeOAuth,
+		},
+		{
+			name: "linked provider logs in",
+			prepare: func(t *testing.T, st store.Store, o *OAuth) {
+				mustOAuthLogin(t, o, models.OAuthProviderGithub, alice, models.DefaultTenantID)
+			},
+			provider: models.OAuthProviderGithub,
+			info:     alice,
+			action:   "login",
+			account:  models.AccountTypeOAuth,
+		},
+		{
+			name: "second provider is linked",
+			prepare: func(t *testing.T, st store.Store, o *OAuth) {
+				mustOAuthLogin(t, o, models.OAuthProviderGithub, alice, models.DefaultTenantID)
+			},
+			provider: models.OAuthProviderGoogle,
+			info:     OAuthUserInfo{ID: "g-1", Email: alice.Email},
+			action:   "login",
+			linked:   true,
+			account:  models.AccountTypeHybrid,
+		},
+		{
+			name: "email account must link explicitly",
+			prepare: func(t *testing.T, st store.Store, o *OAuth) {
+				mustRegister(t, st, models.DefaultTenantID, "alice", alice.Email)
+			},
+			provider: models.OAuthProviderGithub,
+			info:     alice,
+			want:     ErrLinkRequired,
+			kind:     KindConflict,
+		},
+		{
+			name: "provider already linked to the account",
+			prepare: func(t *testing.T, st store.Store, o *OAuth) {
+				mustOAuthLogin(t, o, models.OAuthProviderGithub, alice, models.DefaultTenantID)
+			},
+			provider: models.OAuthProviderGithub,
+			info:     OAuthUserInfo{ID: "gh-2", Email: alice.Email},
+			kind:     KindConflict,
+		},
+		{
+			name: "banned user",
+			prepare: func(t *testing.T, st store.Store, o *OAuth) {
+				login := mustOAuthLogin(t, o, models.OAuthProviderGithub, alice, models.DefaultTenantID)
+				st.Users().Update(login.User, map[string]any{"banned": true})
+			},
+			provider: models.OAuthProviderGithub,
+			info:     alice,
+			want:     ErrAccountRestricted,
+			kind:     KindForbidden,
+		},
+		{
+			name: "registration policy applies",
+			prepare: func(t *testing.T, st store.Store, o *OAuth) {
+				o.AllowRegistration = func(string) error { return errClosed }
+			},
+			provider: models.OAuthProviderGithub,
+			info:     alice,
+			want:     errClosed,
+			kind:     KindForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := newTestStore(t)
+			o := newTestOAuth(st)
+			if tt.prepare != nil {
+				tt.prepare(t, st, o)
+			}
+
+			login, err := o.Login(tt.provider, tt.info, oauthToken(), Client{TenantID: models.DefaultTenantID})
+			assertKind(t, err, tt.kind)
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+
+			if login.Action != tt.action || login.Linked != tt.linked {
+				t.Errorf("got action %q linked %t, want %q linked %t", login.Action, login.Linked, tt.action, tt.linked)
+			}
+			stored, _ := st.Users().ByID(login.User.ID)
+			if stored.AccountType != tt.account {
+				t.Errorf("account type = %q, want %q", stored.AccountType, tt.account)
+			}
+			account, err := st.OAuth().ByUser(login.User.ID, tt.provider)
+			if err != nil {
+				t.Fatalf("provider not linked: %v", err)
+			}
+			if account.AccessToken == "access" {
+				t.Error("provider access token stored in plaintext")
+			}
+		})
+	}
+}
+
+func TestOAuthRegisterPicksFreeUsername(t *testing.T) {
+	st, _ := newTestStore(t)
+	mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.org")
+
+	login := mustOAuthLogin(t, newTestOAuth(st), models.OAuthProviderGithub,
+		OAuthUserInfo{ID: "gh-1", Email: "alice@example.com"}, models.DefaultTenantID)
+	if login.User.Username == "alice" {
+		t.Fatalf("registered with the taken username %q", login.User.Username)
+	}
+}
+
+func mustOAuthLogin(t *testing.T, o *OAuth, provider models.OAuthProvider, info OAuthUserInfo, tenantID uint) *OAuthLogin {
+	t.Helper()
+
+	login, err := o.Login(provider, info, oauthToken(), Client{TenantID: tenantID})
+	if err != nil {
+		t.Fatalf("OAuth login: %v", err)
+	}
+	return login
+}
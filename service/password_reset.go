@@ -0,0 +1,162 @@
+package service
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// passwordResetTTL is how long a reset link stays valid.
+const passwordResetTTL = time.Hour
+
+// PasswordResets issues and redeems password reset tokens.
+type PasswordResets struct {
+	Store  store.Store
+	Policy func() utils.SecurityPolicy
+
+	// MaxAttempts is how many rejected confirmations a token survives
+	// before it is invalidated. 0 disables the cap.
+	MaxAttempts int
+	// HistorySize is how many previous passwords may not be reused
+	HistorySize int
+
+	// Allow is called before issuing a token, e.g. to enforce email send
+	// limits. An error skips the reset.
+	Allow func(user *models.User) error
+	// Send delivers the reset token to the user
+	Send func(user *models.User, token string, expiresIn time.Duration) error
+}
+
+// Request issues a reset for the tenant's account with email, if there is
+// one. It reports no error for unknown emails so callers cannot reveal which
+// addresses are registered; errors from Allow are returned unchanged.
+func (r *PasswordResets) Request(tenantID uint, email string) error {
+	email = utils.NormalizeEmail(email)
+	if email == "" {
+		return invalid("Email is required")
+	}
+
+	user, err := r.Store.Users().ByEmail(tenantID, email)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.Allow != nil {
+		if err := r.Allow(user); err != nil {
+			return err
+		}
+	}
+	return r.Issue(user)
+}
+
+// Issue invalidates the user's unused reset tokens, creates a new one and
+// sends it.
+func (r *PasswordResets) Issue(user *models.User) error {
+	token, hashedToken := utils.GenerateSecureToken()
+	if token == "" {
+		return fmt.Errorf("failed to generate reset token")
+	}
+	email := utils.NormalizeEmail(user.Email)
+
+	// Mark any existing unused tokens as used
+	r.Store.Resets().InvalidateAll(user.TenantID, email)
+
+	passwordReset := models.PasswordReset{
+		TenantID:  user.TenantID,
+		Email:     email,
+		EmailHash: utils.EmailBlindIndex(email),
+		Token:     hashedToken,
+		Used:      false,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := r.Store.Resets().Create(&passwordReset); err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	return r.Send(user, token, passwordResetTTL)
+}
+
+// Confirm sets a new password using a reset token, completing any forced
+// reset and revoking all of the user's sessions. It returns the user and the
+// number of sessions revoked.
+func (r *PasswordResets) Confirm(token, password string) (*models.User, int64, error) {
+	if token == "" {
+		return nil, 0, invalid("Reset token is required")
+	}
+	if password == "" {
+		return nil, 0, invalid("New password is required")
+	}
+
+	passwordReset, err := r.Store.Resets().ValidByToken(utils.HashTokenSHA256(token))
+	if err != nil {
+		return nil, 0, ErrInvalidResetToken
+	}
+
+	user, err := r.Store.Users().ByEmail(passwordReset.TenantID, utils.NormalizeEmail(passwordReset.Email))
+	if err != nil {
+		return nil, 0, ErrUserNotFound
+	}
+
+	if err := r.Policy().Password.Validate(password, user.Username, user.Email); err != nil {
+		return nil, 0, r.recordFailedAttempt(passwordReset, invalid(err.Error()))
+	}
+
+	if err := CheckPasswordReuse(r.Store.Users(), user, password, r.HistorySize); err != nil {
+		return nil, 0, r.recordFailedAttempt(passwordReset, err)
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var revoked int64
+	err = r.Store.Transaction(func(tx store.Store) error {
+		if err := RememberPassword(tx.Users(), user, r.HistorySize); err != nil {
+			return fmt.Errorf("failed to record password history: %w", err)
+		}
+
+		err := tx.Users().Update(user, map[string]any{
+			"password":                hashedPassword,
+			"password_reset_required": false,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update password: %w", err)
+		}
+
+		if err := tx.Resets().MarkUsed(passwordReset); err != nil {
+			return fmt.Errorf("failed to mark reset token as used: %w", err)
+		}
+
+		// Revoke all existing sessions for security
+		revoked, err = tx.Sessions().RevokeAll(user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return user, revoked, nil
+}
+
+// recordFailedAttempt counts a rejected confirmation against the reset and
+// invalidates it once MaxAttempts is reached. It returns the error to report.
+func (r *PasswordResets) recordFailedAttempt(passwordReset *models.PasswordReset, cause error) error {
+	exhausted := r.MaxAttempts > 0 && passwordReset.FailedAttempts+1 >= r.MaxAttempts
+	if err := r.Store.Resets().RecordFailedAttempt(passwordReset, exhausted); err != nil {
+		return fmt.Errorf("failed to record reset attempt: %w", err)
+	}
+
+	if exhausted {
+		return ErrResetAttemptsExceeded
+	}
+	return cause
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"testing"
+	"time"
+)
+
+const newPassword = "purple-monkey-dishwasher-tango"
+
+// newTestResets returns resets that record the tokens they send.
+func newTestResets(st store.Store, sent *[]string) *PasswordResets {
+	return &PasswordResets{
+		Store:       st,
+		Policy:      utils.NewSecurityPolicy,
+		MaxAttempts: 3,
+		HistorySize: 2,
+		Send: func(_ *models.User, token string, _ time.Duration) error {
+			*sent = append(*sent, token)
+			return nil
+		},
+	}
+}
+
+func TestPasswordResetRequest(t *testing.T) {
+	errNotAllowed := &Error{Kind: KindForbidden, Message: "not allowed"}
+
+	tests := []struct {
+		name  string
+		email string
+		allow error
+		sent  int
+		want  error
+	}{
+		{"known email", "alice@example.com", nil, 1, nil},
+		{"email is normalized", " Alice@Example.com ", nil, 1, nil},
+		{"unknown email is not revealed", "nobody@example.com", nil, 0, nil},
+		{"rejected by allow", "alice@example.com", errNotAllowed, 0, errNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := newTestStore(t)
+			mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+
+			var sent []string
+			resets := newTestResets(st, &sent)
+			resets.Allow = func(*models.User) error { return tt.allow }
+
+			err := resets.Request(models.DefaultTenantID, tt.email)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if len(sent) != tt.sent {
+				t.Errorf("sent %d tokens, want %d", len(sent), tt.sent)
+			}
+		})
+	}
+
+	t.Run("missing email", func(t *testing.T) {
+		st, _ := newTestStore(t)
+		var sent []string
+		assertKind(t, newTestResets(st, &sent).Request(models.DefaultTenantID, " "), KindInvalid)
+	})
+}
+
+func TestPasswordResetConfirm(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    func(issued string) string
+		password string
+		want     error
+		kind     Kind
+	}{
+		{"valid", func(issued string) string { return issued }, newPassword, nil, 0},
+		{"missing token", func(string) string { return "" }, newPassword, nil, KindInvalid},
+		{"missing password", func(issued string) string { return issued }, "", nil, KindInvalid},
+		{"unknown token", func(string) string { return "not-a-token" }, newPassword, ErrInvalidResetToken, KindInvalid},
+		{"weak password", func(issued string) string { return issued }, "password", nil, KindInvalid},
+		{"current password", func(issued string) string { return issued }, testPassword, nil, KindInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, _ := newTestStore(t)
+			user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+			session := &models.Session{JTI: "session-1", UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+			if err := st.Sessions().Create(session); err != nil {
+				t.Fatalf("create session: %v", err)
+			}
+
+			var sent []string
+			resets := newTestResets(st, &sent)
+			if err := resets.Issue(user); err != nil {
+				t.Fatalf("issue: %v", err)
+			}
+
+			got, revoked, err := resets.Confirm(tt.token(sent[0]), tt.password)
+			assertKind(t, err, tt.kind)
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got error %v, want %v", err, tt.want)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.ID != user.ID {
+				t.Errorf("reset user %d, want %d", got.ID, user.ID)
+			}
+			if revoked != 1 {
+				t.Errorf("revoked %d sessions, want 1", revoked)
+			}
+			stored, _ := st.Users().ByID(user.ID)
+			if !utils.ComparePassword(newPassword, stored.Password) {
+				t.Error("password was not changed")
+			}
+
+			// Tokens are single use
+			_, _, err = resets.Confirm(sent[0], "yet-another-horse-battery")
+			if !errors.Is(err, ErrInvalidResetToken) {
+				t.Errorf("reusing the token: got %v, want ErrInvalidResetToken", err)
+			}
+		})
+	}
+}
+
+func TestPasswordResetReissueInvalidatesOlderTokens(t *testing.T) {
+	st, _ := newTestStore(t)
+	user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+
+	var sent []string
+	resets := newTestResets(st, &sent)
+	for range 2 {
+		if err := resets.Issue(user); err != nil {
+			t.Fatalf("issue: %v", err)
+		}
+	}
+
+	if _, _, err := resets.Confirm(sent[0], newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("older token: got %v, want ErrInvalidResetToken", err)
+	}
+	if _, _, err := resets.Confirm(sent[1], newPassword); err != nil {
+		t.Errorf("newer token: %v", err)
+	}
+}
+
+func TestPasswordResetAttemptsExceeded(t *testing.T) {
+	st, _ := newTestStore(t)
+	user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+
+	var sent []string
+	resets := newTestResets(st, &sent)
+	if err := resets.Issue(user); err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	for attempt := 1; attempt <= resets.MaxAttempts; attempt++ {
+		_, _, err := resets.Confirm(sent[0], "password")
+		if attempt < resets.MaxAttempts {
+			assertKind(t, err, KindInvalid)
+		} else if !errors.Is(err, ErrResetAttemptsExceeded) {
+			t.Fatalf("attempt %d: got %v, want ErrResetAttemptsExceeded", attempt, err)
+		}
+	}
+
+	if _, _, err := resets.Confirm(sent[0], newPassword); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("after the cap: got %v, want ErrInvalidResetToken", err)
+	}
+}
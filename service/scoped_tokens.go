@@ -0,0 +1,259 @@
+package service
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ClientTokenTTL is how long client-credentials tokens are valid.
+	// Clients request a new one instead of refreshing it.
+	ClientTokenTTL = time.Hour
+	// MaxPersonalTokenTTL caps the lifetime of personal access tokens.
+	MaxPersonalTokenTTL = 365 * 24 * time.Hour
+
+	maxTokenNameLength = 100
+)
+
+// Scoped token errors, matched with errors.Is.
+var (
+	ErrInvalidClient     = &Error{Kind: KindUnauthenticated, Reason: "invalid_client", Message: "Invalid client credentials"}
+	ErrTokenNotFound     = &Error{Kind: KindNotFound, Message: "Token not found"}
+	ErrAPIClientNotFound = &Error{Kind: KindNotFound, Message: "API client not found"}
+)
+
+// PersonalTokenInput describes a personal access token to create.
+type PersonalTokenInput struct {
+	Name      string
+	Scopes    []string
+	TTL       time.Duration
+	IPAddress string
+	UserAgent string
+}
+
+// ClientCredentialsInput is a client-credentials token request.
+type ClientCredentialsInput struct {
+	ClientID     string
+	ClientSecret string
+	Scopes       []string // Defaults to every scope of the client
+	IPAddress    string
+	UserAgent    string
+}
+
+// CreatePersonalToken issues a personal access token for the user, limited
+// to the input's scopes. It returns the token, which is not stored and
+// cannot be shown again, and its session.
+func (t *Tokens) CreatePersonalToken(user *models.User, in PersonalTokenInput) (string, *models.Session, error) {
+	name, err := tokenName(in.Name)
+	if err != nil {
+		return "", nil, err
+	}
+	scopes, err := grantableScopes(user, in.Scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if in.TTL <= 0 || in.TTL > MaxPersonalTokenTTL {
+		return "", nil, invalid(fmt.Sprintf("Tokens must expire within %d days", MaxPersonalTokenTTL/(24*time.Hour)))
+	}
+
+	return t.issueScoped(user, scopes, in.TTL, models.Session{
+		Kind:      models.SessionKindPersonalToken,
+		Name:      name,
+		IPAddress: in.IPAddress,
+		UserAgent: in.UserAgent,
+	})
+}
+
+// PersonalTokens returns the user's active personal access tokens, newest
+// first.
+func (t *Tokens) PersonalTokens(userID uint) ([]models.Session, error) {
+	return t.Store.Sessions().ListActive(userID, models.SessionKindPersonalToken)
+}
+
+// RevokePersonalToken revokes the user's personal access token whose
+// session has the given id, and returns that session.
+func (t *Tokens) RevokePersonalToken(userID, id uint) (*models.Session, error) {
+	session, err := t.Store.Sessions().ByID(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && (session.UserID != userID || session.Kind != models.SessionKindPersonalToken)) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("session lookup failed: %w", err)
+	}
+	if session.Revoked {
+		return session, nil
+	}
+
+	session.Revoked = true
+	if err := t.Store.Sessions().Save(session); err != nil {
+		return nil, fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return session, nil
+}
+
+// CreateClient registers an API client owned by the user, whose tokens may
+// carry the given scopes. It returns the client and its secret, which is not
+// stored and cannot be shown again.
+func (t *Tokens) CreateClient(user *models.User, name string, scopes []string) (*models.APIClient, string, error) {
+	name, err := tokenName(name)
+	if err != nil {
+		return nil, "", err
+	}
+	scopes, err = grantableScopes(user, scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, hash := utils.GenerateSecureToken()
+	if secret == "" {
+		return nil, "", fmt.Errorf("failed to generate client secret")
+	}
+	client := &models.APIClient{
+		TenantID:   user.TenantID,
+		UserID:     user.ID,
+		Name:       name,
+		ClientID:   uuid.NewString(),
+		SecretHash: hash,
+		Scope:      strings.Join(scopes, " "),
+	}
+	if err := t.Store.APIClients().Create(client); err != nil {
+		return nil, "", fmt.Errorf("failed to create API client: %w", err)
+	}
+	return client, secret, nil
+}
+
+// Clients returns the user's API clients, newest first.
+func (t *Tokens) Clients(userID uint) ([]models.APIClient, error) {
+	return t.Store.APIClients().ListByUser(userID)
+}
+
+// DeleteClient deletes the user's API client with the given id and revokes
+// its tokens. It returns the client and how many tokens were revoked.
+func (t *Tokens) DeleteClient(userID, id uint) (*models.APIClient, int64, error) {
+	client, err := t.Store.APIClients().ByID(id)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && client.UserID != userID) {
+		return nil, 0, ErrAPIClientNotFound
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("API client lookup failed: %w", err)
+	}
+
+	var revoked int64
+	err = t.Store.Transaction(func(tx store.Store) error {
+		if revoked, err = tx.Sessions().RevokeByClient(client.ID); err != nil {
+			return fmt.Errorf("failed to revoke tokens: %w", err)
+		}
+		if err := tx.APIClients().Delete(client); err != nil {
+			return fmt.Errorf("failed to delete API client: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return client, revoked, nil
+}
+
+// ClientCredentials authenticates an API client of the tenant with its
+// secret and issues it an access token valid for ClientTokenTTL. The token
+// acts as the client's owner, who must still be allowed to sign in.
+func (t *Tokens) ClientCredentials(tenantID uint, in ClientCredentialsInput) (string, *models.Session, error) {
+	client, err := t.Store.APIClients().ByClientID(strings.TrimSpace(in.ClientID))
+	if errors.Is(err, store.ErrNotFound) {
+		return "", nil, ErrInvalidClient
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("API client lookup failed: %w", err)
+	}
+	if client.TenantID != tenantID ||
+		subtle.ConstantTimeCompare([]byte(utils.HashTokenSHA256(in.ClientSecret)), []byte(client.SecretHash)) != 1 {
+		return "", nil, ErrInvalidClient
+	}
+
+	scopes := strings.Fields(client.Scope)
+	if len(in.Scopes) > 0 {
+		if !utils.ScopeSubset(in.Scopes, client.Scope) {
+			return "", nil, &Error{Kind: KindInvalid, Reason: "invalid_scope", Message: "The client is not allowed the requested scopes"}
+		}
+		scopes, _ = utils.ParseScopes(in.Scopes)
+	}
+
+	user, err := t.Store.Users().ByID(client.UserID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && user.AnonymizedAt != nil) {
+		return "", nil, ErrInvalidClient
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("user lookup failed: %w", err)
+	}
+	if err := CheckRestriction(user); err != nil {
+		return "", nil, err
+	}
+
+	token, session, err := t.issueScoped(user, scopes, ClientTokenTTL, models.Session{
+		Kind:        models.SessionKindClientCredentials,
+		Name:        client.Name,
+		APIClientID: &client.ID,
+		IPAddress:   in.IPAddress,
+		UserAgent:   in.UserAgent,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	t.Store.APIClients().Touch(client)
+	return token, session, nil
+}
+
+// issueScoped signs a token for the user limited to scopes and stores
+// session, filled in with its JTI, scope and expiry, so the token can be
+// revoked like a login token.
+func (t *Tokens) issueScoped(user *models.User, scopes []string, ttl time.Duration, session models.Session) (string, *models.Session, error) {
+	jti, token, err := utils.GetScopedSignedKey(user.ID, user.TenantID, scopes, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	session.JTI = jti
+	session.UserID = user.ID
+	session.Scope = strings.Join(scopes, " ")
+	session.ExpiresAt = time.Now().Add(ttl)
+	if err := t.Store.Sessions().Create(&session); err != nil {
+		return "", nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return token, &session, nil
+}
+
+// grantableScopes validates scopes and checks that the user may grant them.
+func grantableScopes(user *models.User, scopes []string) ([]string, error) {
+	parsed, err := utils.ParseScopes(scopes)
+	if err != nil {
+		return nil, &Error{Kind: KindInvalid, Reason: "invalid_scope", Message: err.Error()}
+	}
+	if user.Role != models.RoleAdmin {
+		for _, scope := range parsed {
+			if slices.Contains(utils.AdminScopes, scope) {
+				return nil, &Error{Kind: KindInvalid, Reason: "invalid_scope", Message: fmt.Sprintf("Only admins can grant %s", scope)}
+			}
+		}
+	}
+	return parsed, nil
+}
+
+func tokenName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", invalid("Name is required")
+	}
+	if len(name) > maxTokenNameLength {
+		return "", invalid(fmt.Sprintf("Name must be at most %d characters", maxTokenNameLength))
+	}
+	return name, nil
+}
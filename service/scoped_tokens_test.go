@@ -0,0 +1,182 @@
+package service
+
+import (
+	"api/database/models"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCreatePersonalToken(t *testing.T) {
+	st, _ := newTestStore(t)
+	user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+	tokens := &Tokens{Store: st}
+
+	tests := []struct {
+		name   string
+		in     PersonalTokenInput
+		kind   Kind
+		reason string
+	}{
+		{"valid", PersonalTokenInput{Name: "script", Scopes: []string{"profile:read", "activity:read", "profile:read"}, TTL: time.Hour}, 0, ""},
+		{"missing name", PersonalTokenInput{Name: " ", Scopes: []string{"profile:read"}, TTL: time.Hour}, KindInvalid, ""},
+		{"no scopes", PersonalTokenInput{Name: "script", TTL: time.Hour}, KindInvalid, "invalid_scope"},
+		{"unknown scope", PersonalTokenInput{Name: "script", Scopes: []string{"everything"}, TTL: time.Hour}, KindInvalid, "invalid_scope"},
+		{"admin scope", PersonalTokenInput{Name: "script", Scopes: []string{"users:read"}, TTL: time.Hour}, KindInvalid, "invalid_scope"},
+		{"too long-lived", PersonalTokenInput{Name: "script", Scopes: []string{"profile:read"}, TTL: MaxPersonalTokenTTL + time.Hour}, KindInvalid, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, session, err := tokens.CreatePersonalToken(user, tt.in)
+			assertKind(t, err, tt.kind)
+			if tt.reason != "" && err.(*Error).Reason != tt.reason {
+				t.Errorf("reason %q, want %q", err.(*Error).Reason, tt.reason)
+			}
+			if err != nil {
+				return
+			}
+
+			claims, validated, err := tokens.Validate(token)
+			if err != nil {
+				t.Fatalf("token does not validate: %v", err)
+			}
+			if validated.ID != session.ID || validated.Kind != models.SessionKindPersonalToken {
+				t.Errorf("token validated against session %+v, want %+v", validated, session)
+			}
+			if !slices.Equal(claims.Scopes(), []string{"activity:read", "profile:read"}) || session.Scope != claims.Scope {
+				t.Errorf("scopes %v, session scope %q", claims.Scopes(), session.Scope)
+			}
+		})
+	}
+
+	listed, err := tokens.PersonalTokens(user.ID)
+	if err != nil || len(listed) != 1 {
+		t.Fatalf("listed %d tokens (%v), want 1", len(listed), err)
+	}
+
+	// Only the owner can revoke a token
+	if _, err := tokens.RevokePersonalToken(user.ID+1, listed[0].ID); !errors.Is(err, ErrTokenNotFound) {
+		t.Errorf("revoked another user's token: %v", err)
+	}
+	if _, err := tokens.RevokePersonalToken(user.ID, listed[0].ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if listed, _ := tokens.PersonalTokens(user.ID); len(listed) != 0 {
+		t.Errorf("revoked token still listed: %+v", listed)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	st, otherTenant := newTestStore(t)
+	admin := mustRegister(t, st, models.DefaultTenantID, "carol", "carol@example.com")
+	if err := st.Users().Update(admin, map[string]any{"role": models.RoleAdmin}); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	admin.Role = models.RoleAdmin
+	tokens := &Tokens{Store: st}
+
+	client, secret, err := tokens.CreateClient(admin, "sync", []string{"users:write", "users:read"})
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	if client.Scope != "users:read users:write" || client.SecretHash == secret {
+		t.Fatalf("unexpected client %+v", client)
+	}
+
+	tests := []struct {
+		name      string
+		tenantID  uint
+		clientID  string
+		secret    string
+		scopes    []string
+		kind      Kind
+		wantScope string
+	}{
+		{"all scopes", models.DefaultTenantID, client.ClientID, secret, nil, 0, "users:read users:write"},
+		{"narrowed", models.DefaultTenantID, client.ClientID, secret, []string{"users:read"}, 0, "users:read"},
+		{"scope not granted", models.DefaultTenantID, client.ClientID, secret, []string{"profile:read"}, KindInvalid, ""},
+		{"wrong secret", models.DefaultTenantID, client.ClientID, secret + "x", nil, KindUnauthenticated, ""},
+		{"unknown client", models.DefaultTenantID, "no-such-client", secret, nil, KindUnauthenticated, ""},
+		{"empty client id", models.DefaultTenantID, "", secret, nil, KindUnauthenticated, ""},
+		{"other tenant", otherTenant, client.ClientID, secret, nil, KindUnauthenticated, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, session, err := tokens.ClientCredentials(tt.tenantID, ClientCredentialsInput{
+				ClientID:     tt.clientID,
+				ClientSecret: tt.secret,
+				Scopes:       tt.scopes,
+			})
+			assertKind(t, err, tt.kind)
+			if err != nil {
+				return
+			}
+
+			claims, _, err := tokens.Validate(token)
+			if err != nil {
+				t.Fatalf("token does not validate: %v", err)
+			}
+			if claims.Subject != admin.ID || claims.Scope != tt.wantScope {
+				t.Errorf("token for user %d with scope %q, want %d %q", claims.Subject, claims.Scope, admin.ID, tt.wantScope)
+			}
+			if session.Kind != models.SessionKindClientCredentials || session.APIClientID == nil || *session.APIClientID != client.ID {
+				t.Errorf("unexpected session %+v", session)
+			}
+		})
+	}
+
+	t.Run("banned owner", func(t *testing.T) {
+		if err := st.Users().Update(admin, map[string]any{"banned": true}); err != nil {
+			t.Fatalf("ban: %v", err)
+		}
+		defer st.Users().Update(admin, map[string]any{"banned": false})
+
+		_, _, err := tokens.ClientCredentials(models.DefaultTenantID, ClientCredentialsInput{ClientID: client.ClientID, ClientSecret: secret})
+		if !errors.Is(err, ErrAccountRestricted) {
+			t.Errorf("got %v, want a restriction error", err)
+		}
+	})
+
+	t.Run("delete revokes tokens", func(t *testing.T) {
+		token, _, err := tokens.ClientCredentials(models.DefaultTenantID, ClientCredentialsInput{ClientID: client.ClientID, ClientSecret: secret})
+		if err != nil {
+			t.Fatalf("issue: %v", err)
+		}
+		if _, _, err := tokens.DeleteClient(admin.ID+1, client.ID); !errors.Is(err, ErrAPIClientNotFound) {
+			t.Errorf("deleted another user's client: %v", err)
+		}
+		if _, _, err := tokens.DeleteClient(admin.ID, client.ID); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if _, _, err := tokens.Validate(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("token of a deleted client still validates: %v", err)
+		}
+		_, _, err = tokens.ClientCredentials(models.DefaultTenantID, ClientCredentialsInput{ClientID: client.ClientID, ClientSecret: secret})
+		assertKind(t, err, KindUnauthenticated)
+	})
+}
+
+func TestScopedTokensDoNotCountTowardsSessionCap(t *testing.T) {
+	st, _ := newTestStore(t)
+	user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+	tokens := &Tokens{Store: st}
+
+	token, _, err := tokens.CreatePersonalToken(user, PersonalTokenInput{Name: "script", Scopes: []string{"profile:read"}, TTL: time.Hour})
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	login := &models.Session{JTI: "login", UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := st.Sessions().Create(login); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := st.Sessions().RevokeBeyond(user.ID, 1); err != nil {
+		t.Fatalf("revoke beyond: %v", err)
+	}
+
+	if _, _, err := tokens.Validate(token); err != nil {
+		t.Errorf("personal access token revoked by the session cap: %v", err)
+	}
+}
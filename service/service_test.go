@@ -0,0 +1,88 @@
+package service
+
+import (
+	"api/database"
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPassword = "correct-horse-battery-staple"
+
+func TestMain(m *testing.M) {
+	// Keep bcrypt cheap and give the token encryption and JWT signing keys
+	// fixed values
+	os.Setenv("BCRYPT_COST", "4")
+	os.Setenv("JWT_SECRET", "service-test-secret")
+	os.Setenv("TOKEN_ENCRYPTION_KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	os.Exit(m.Run())
+}
+
+// newTestStore returns a store on a fresh SQLite database with the default
+// tenant and a second one, whose id it returns.
+func newTestStore(t *testing.T) (store.Store, uint) {
+	t.Helper()
+
+	db, err := database.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { database.Close(db) })
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	other := models.Tenant{Slug: "other", Name: "Other", Active: true}
+	if err := db.Create(&other).Error; err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	return store.NewGorm(db), other.ID
+}
+
+func newTestAccounts(st store.Store) *Accounts {
+	return &Accounts{
+		Store:     st,
+		Policy:    utils.NewSecurityPolicy,
+		Usernames: utils.NewUsernamePolicy(),
+	}
+}
+
+// mustRegister registers username with testPassword in the tenant.
+func mustRegister(t *testing.T, st store.Store, tenantID uint, username, email string) *models.User {
+	t.Helper()
+
+	user, err := newTestAccounts(st).Register(RegisterInput{
+		TenantID: tenantID,
+		Username: username,
+		Email:    email,
+		Password: testPassword,
+	})
+	if err != nil {
+		t.Fatalf("register %s: %v", email, err)
+	}
+	return user
+}
+
+// assertKind fails the test unless err is an *Error of the given kind, or
+// nil when kind is 0.
+func assertKind(t *testing.T, err error, kind Kind) {
+	t.Helper()
+
+	if kind == 0 {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	var serviceErr *Error
+	if !errors.As(err, &serviceErr) {
+		t.Fatalf("got error %v, want a service error of kind %d", err, kind)
+	}
+	if serviceErr.Kind != kind {
+		t.Fatalf("got kind %d (%v), want %d", serviceErr.Kind, err, kind)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"api/database/models"
+	"testing"
+)
+
+func TestLoginTenantIsolation(t *testing.T) {
+	st, otherTenant := newTestStore(t)
+	accounts := newTestAccounts(st)
+
+	// The same username and email can be registered once per tenant
+	first := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+	second, err := accounts.Register(RegisterInput{
+		TenantID: otherTenant,
+		Username: "alice",
+		Email:    "alice@example.com",
+		Password: "another-horse-battery-staple",
+	})
+	if err != nil {
+		t.Fatalf("register in second tenant: %v", err)
+	}
+	if first.ID == second.ID {
+		t.Fatal("both tenants share one user")
+	}
+
+	tests := []struct {
+		name     string
+		tenantID uint
+		password string
+		want     uint
+		kind     Kind
+	}{
+		{"default tenant", models.DefaultTenantID, testPassword, first.ID, 0},
+		{"other tenant", otherTenant, "another-horse-battery-staple", second.ID, 0},
+		{"other tenant's password", models.DefaultTenantID, "another-horse-battery-staple", 0, KindUnauthenticated},
+		{"default tenant's password", otherTenant, testPassword, 0, KindUnauthenticated},
+		{"unknown tenant", otherTenant + 1, testPassword, 0, KindNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := accounts.Login(tt.tenantID, "alice@example.com", tt.password, LoginChecks{})
+			assertKind(t, err, tt.kind)
+			if err == nil && user.ID != tt.want {
+				t.Errorf("logged in as user %d, want %d", user.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordResetTenantIsolation(t *testing.T) {
+	st, otherTenant := newTestStore(t)
+	mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+	other := mustRegister(t, st, otherTenant, "alice", "alice@example.com")
+
+	var sent []string
+	resets := newTestResets(st, &sent)
+	if err := resets.Request(models.DefaultTenantID, "alice@example.com"); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	// Requesting in another tenant must not invalidate the first token
+	if err := resets.Request(otherTenant, "alice@example.com"); err != nil {
+		t.Fatalf("request: %v", err)
+	}
+
+	got, _, err := resets.Confirm(sent[1], newPassword)
+	if err != nil {
+		t.Fatalf("confirm other tenant: %v", err)
+	}
+	if got.ID != other.ID {
+		t.Errorf("reset user %d, want %d of the other tenant", got.ID, other.ID)
+	}
+	if _, _, err := resets.Confirm(sent[0], newPassword); err != nil {
+		t.Errorf("confirm default tenant: %v", err)
+	}
+}
+
+func TestOAuthTenantIsolation(t *testing.T) {
+	st, otherTenant := newTestStore(t)
+	o := newTestOAuth(st)
+	info := OAuthUserInfo{ID: "gh-1", Email: "alice@example.com"}
+
+	first := mustOAuthLogin(t, o, models.OAuthProviderGithub, info, models.DefaultTenantID)
+
+	// The same provider account signs up separately in another tenant
+	second := mustOAuthLogin(t, o, models.OAuthProviderGithub, info, otherTenant)
+	if second.Action != "register" || second.User.ID == first.User.ID {
+		t.Fatalf("other tenant: got %s as user %d, want a new registration", second.Action, second.User.ID)
+	}
+	if second.User.TenantID != otherTenant {
+		t.Errorf("registered in tenant %d, want %d", second.User.TenantID, otherTenant)
+	}
+
+	again := mustOAuthLogin(t, o, models.OAuthProviderGithub, info, models.DefaultTenantID)
+	if again.Action != "login" || again.User.ID != first.User.ID {
+		t.Errorf("default tenant: got %s as user %d, want login as %d", again.Action, again.User.ID, first.User.ID)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"api/database/models"
+	"api/store"
+	"api/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Token errors, matched with errors.Is.
+var (
+	ErrInvalidToken    = &Error{Kind: KindUnauthenticated, Message: "Invalid or expired token"}
+	ErrSessionNotFound = &Error{Kind: KindNotFound, Message: "Session not found"}
+)
+
+// Tokens validates access tokens and manages their sessions on behalf of
+// other services, independently of the transport that exposes it.
+type Tokens struct {
+	Store store.Store
+
+	// OnRevoked is called after RevokeSession revoked an active session, to
+	// audit it and notify subscribers
+	OnRevoked func(session *models.Session)
+}
+
+// Validate checks the token's signature and expiry and that its session has
+// not been revoked. It returns the claims and the session.
+func (t *Tokens) Validate(token string) (*utils.JWTClaims, *models.Session, error) {
+	claims, err := utils.ParseAccessToken(token)
+	if err != nil || claims.ID == "" {
+		return nil, nil, ErrInvalidToken
+	}
+
+	session, err := t.Store.Sessions().ByJTI(claims.ID)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("session lookup failed: %w", err)
+	}
+	if session.Revoked {
+		return nil, nil, ErrInvalidToken
+	}
+	return claims, session, nil
+}
+
+// Introspection describes a token in the shape of an RFC 7662 response.
+// Only Active is set for inactive tokens.
+type Introspection struct {
+	Active    bool
+	Subject   uint
+	Scopes    []string // Empty for unscoped tokens, which grant everything
+	JTI       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	Issuer    string
+	Audience  []string
+}
+
+// Introspect reports whether the token is active and, if so, its claims.
+// Invalid, expired and revoked tokens are reported inactive rather than as
+// errors.
+func (t *Tokens) Introspect(token string) (*Introspection, error) {
+	claims, _, err := t.Validate(token)
+	if errors.Is(err, ErrInvalidToken) {
+		return &Introspection{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Introspection{
+		Active:   true,
+		Subject:  claims.Subject,
+		Scopes:   claims.Scopes(),
+		JTI:      claims.ID,
+		Issuer:   claims.Issuer,
+		Audience: claims.Audience,
+	}
+	if claims.IssuedAt != nil {
+		info.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		info.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return info, nil
+}
+
+// RevokeSession revokes the session with the given JTI, which invalidates
+// its access token and refresh token. Revoking a revoked session is a no-op.
+func (t *Tokens) RevokeSession(jti string) error {
+	jti = strings.TrimSpace(jti)
+	if jti == "" {
+		return invalid("Session id is required")
+	}
+
+	session, err := t.Store.Sessions().ByJTI(jti)
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("session lookup failed: %w", err)
+	}
+	if session.Revoked {
+		return nil
+	}
+
+	session.Revoked = true
+	if err := t.Store.Sessions().Save(session); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if t.OnRevoked != nil {
+		t.OnRevoked(session)
+	}
+	return nil
+}
+
+// User returns the user with the given id.
+func (t *Tokens) User(id uint) (*models.User, error) {
+	user, err := t.Store.Users().ByID(id)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("user lookup failed: %w", err)
+	}
+	return user, nil
+}
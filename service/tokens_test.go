@@ -0,0 +1,45 @@
+package service
+
+import (
+	"api/database/models"
+	"testing"
+	"time"
+)
+
+func TestRevokeSession(t *testing.T) {
+	st, _ := newTestStore(t)
+	user := mustRegister(t, st, models.DefaultTenantID, "alice", "alice@example.com")
+	session := &models.Session{JTI: "session-1", UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := st.Sessions().Create(session); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	var revoked []string
+	tokens := &Tokens{Store: st, OnRevoked: func(s *models.Session) { revoked = append(revoked, s.JTI) }}
+
+	tests := []struct {
+		name string
+		jti  string
+		kind Kind
+		hook int // Calls of OnRevoked so far
+	}{
+		{"missing", " ", KindInvalid, 0},
+		{"unknown", "no-such-session", KindNotFound, 0},
+		{"active", "session-1", 0, 1},
+		{"already revoked", "session-1", 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertKind(t, tokens.RevokeSession(tt.jti), tt.kind)
+			if len(revoked) != tt.hook {
+				t.Errorf("OnRevoked called %d times, want %d", len(revoked), tt.hook)
+			}
+		})
+	}
+
+	stored, err := st.Sessions().ByJTI("session-1")
+	if err != nil || !stored.Revoked {
+		t.Errorf("session not revoked: %+v, %v", stored, err)
+	}
+}
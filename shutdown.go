@@ -0,0 +1,32 @@
+package main
+
+import (
+	"api/server"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// shutdownOnSignal shuts app down gracefully on SIGTERM or SIGINT; a second
+// signal exits at once. The returned channel is closed when shutdown has
+// finished, so main can wait for it after Listen returns.
+func shutdownOnSignal(app *fiber.App) <-chan struct{} {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+
+		log.Printf("shutdown: received %s, draining connections", sig)
+		if err := server.Shutdown(app); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		close(done)
+	}()
+	return done
+}
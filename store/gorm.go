@@ -0,0 +1,518 @@
+package store
+
+import (
+	"api/database/models"
+	"api/utils"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormStore implements Store on a GORM connection or transaction.
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGorm returns a Store backed by db. Passing a transaction binds every
+// store to it.
+func NewGorm(db *gorm.DB) Store {
+	return gormStore{db: db}
+}
+
+func (s gormStore) Users() UserStore             { return gormUsers(s) }
+func (s gormStore) Sessions() SessionStore       { return gormSessions(s) }
+func (s gormStore) OAuth() OAuthStore            { return gormOAuth(s) }
+func (s gormStore) Resets() ResetStore           { return gormResets(s) }
+func (s gormStore) Invitations() InvitationStore { return gormInvitations(s) }
+func (s gormStore) APIClients() APIClientStore   { return gormAPIClients(s) }
+
+func (s gormStore) Transaction(fn func(tx Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(gormStore{db: tx})
+	})
+}
+
+// first loads the first record matching query into out, mapping a missing
+// record to ErrNotFound.
+func first[T any](query *gorm.DB, conds ...any) (*T, error) {
+	var out T
+	if err := query.First(&out, conds...).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Apply orders query newest first by timeColumn and then idColumn and
+// selects the page. It is exported for lists of tables without a store.
+func (p Page) Apply(query *gorm.DB, timeColumn, idColumn string) *gorm.DB {
+	query = query.Order(timeColumn + " DESC, " + idColumn + " DESC").Limit(p.Limit)
+	if p.After != nil {
+		query = query.Where("("+timeColumn+", "+idColumn+") < (?, ?)", p.After.Time, p.After.ID)
+	} else if p.Offset > 0 {
+		query = query.Offset(p.Offset)
+	}
+	return query
+}
+
+// whereEmail matches a normalized email, through the blind index when one
+// is configured.
+func whereEmail(query *gorm.DB, email string) *gorm.DB {
+	if index := utils.EmailBlindIndex(email); index != nil {
+		return query.Where("email_hash = ?", *index)
+	}
+	return query.Where("lower(email) = ?", email)
+}
+
+type gormUsers gormStore
+
+func (s gormUsers) ByID(id uint) (*models.User, error) {
+	return first[models.User](s.db, id)
+}
+
+func (s gormUsers) ByEmail(tenantID uint, email string) (*models.User, error) {
+	return first[models.User](whereEmail(s.db.Where("tenant_id = ?", tenantID), email))
+}
+
+func (s gormUsers) AnyByEmail(email string) (*models.User, error) {
+	return first[models.User](whereEmail(s.db, email))
+}
+
+func (s gormUsers) ByUsername(tenantID uint, username string) (*models.User, error) {
+	return first[models.User](s.db.Where("tenant_id = ? AND username = ?", tenantID, username))
+}
+
+func (s gormUsers) ByPreviousUsername(tenantID uint, username string, since time.Time) (*models.User, error) {
+	return first[models.User](s.db.
+		Joins("JOIN username_histories ON username_histories.user_id = users.id").
+		Where("users.tenant_id = ? AND username_histories.username = ? AND username_histories.changed_at > ?", tenantID, username, since).
+		Order("username_histories.changed_at DESC"))
+}
+
+func (s gormUsers) WithDetails(id uint, includeDeleted bool) (*models.User, error) {
+	query := s.db
+	if includeDeleted {
+		query = query.Unscoped()
+	}
+	return first[models.User](query.
+		Preload("Sessions", func(tx *gorm.DB) *gorm.DB { return tx.Order("issued_at DESC, id DESC") }).
+		Preload("OAuthLinks"), id)
+}
+
+func (s gormUsers) LockByID(id uint) (*models.User, error) {
+	return first[models.User](s.db.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+}
+
+func (s gormUsers) InTenant(id, tenantID uint) (bool, error) {
+	var count int64
+	err := s.db.Unscoped().Model(&models.User{}).Where("id = ? AND tenant_id = ?", id, tenantID).Count(&count).Error
+	return count > 0, err
+}
+
+func (s gormUsers) List(filter UserFilter, page Page) ([]models.User, int64, error) {
+	query := s.db.Model(&models.User{}).Where("users.tenant_id = ?", filter.TenantID)
+	if filter.Email != "" {
+		query = whereEmail(query, filter.Email)
+	}
+	if filter.Username != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Username) + "%"
+		// Portable ILIKE, so the filter also works on SQLite in demo mode
+		query = query.Where(`LOWER(username) LIKE LOWER(?) ESCAPE '\'`, pattern)
+	}
+	if filter.AccountType != "" {
+		query = query.Where("account_type = ?", filter.AccountType)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("users.created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("users.created_at < ?", *filter.CreatedBefore)
+	}
+	query = whereUserStatus(query, filter.Status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	err := page.Apply(query, "users.created_at", "users.id").Find(&users).Error
+	return users, total, err
+}
+
+// whereUserStatus narrows a user query to one account status.
+func whereUserStatus(query *gorm.DB, status UserStatus) *gorm.DB {
+	now := time.Now()
+	switch status {
+	case UserStatusActive:
+		return query.Where("banned = false AND anonymized_at IS NULL").
+			Where("suspended_until IS NULL OR suspended_until <= ?", now).
+			Where("locked_until IS NULL OR locked_until <= ?", now)
+	case UserStatusLocked:
+		return query.Where("locked_until > ?", now)
+	case UserStatusBanned:
+		return query.Where("banned = true")
+	case UserStatusSuspended:
+		return query.Where("suspended_until > ?", now)
+	case UserStatusUnverified:
+		return query.Where("email_verified_at IS NULL AND anonymized_at IS NULL")
+	case UserStatusAnonymized:
+		return query.Where("anonymized_at IS NOT NULL")
+	case UserStatusDeleted:
+		return query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
+	return query
+}
+
+func (s gormUsers) Locked(tenantID uint) ([]models.User, error) {
+	var users []models.User
+	err := s.db.Where("tenant_id = ? AND locked_until > ?", tenantID, time.Now()).
+		Order("locked_until DESC").Find(&users).Error
+	return users, err
+}
+
+func (s gormUsers) DeletedBefore(before time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	err := s.db.Unscoped().Model(&models.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Order("deleted_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (s gormUsers) EmailRegistered(tenantID uint, email string) (bool, error) {
+	var count int64
+	err := whereEmail(s.db.Unscoped().Model(&models.User{}).Where("tenant_id = ?", tenantID), email).Count(&count).Error
+	return count > 0, err
+}
+
+func (s gormUsers) UsernameRegistered(tenantID uint, username string) (bool, error) {
+	var count int64
+	err := s.db.Unscoped().Model(&models.User{}).Where("tenant_id = ? AND username = ?", tenantID, username).Count(&count).Error
+	return count > 0, err
+}
+
+func (s gormUsers) UsernameTaken(tenantID uint, username string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.User{}).Where("tenant_id = ? AND username = ?", tenantID, username).Count(&count).Error
+	return count > 0, err
+}
+
+func (s gormUsers) UsernameReleased(tenantID uint, username string, exceptUserID uint, since time.Time) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.UsernameHistory{}).
+		Joins("JOIN users ON users.id = username_histories.user_id").
+		Where("users.tenant_id = ? AND username_histories.username = ? AND username_histories.user_id != ? AND username_histories.changed_at > ?", tenantID, username, exceptUserID, since).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s gormUsers) Create(user *models.User) error {
+	return s.db.Create(user).Error
+}
+
+func (s gormUsers) Update(user *models.User, fields map[string]any) error {
+	return s.db.Model(user).Updates(fields).Error
+}
+
+func (s gormUsers) UpdateByID(id uint, fields map[string]any) (bool, error) {
+	result := s.db.Model(&models.User{}).Where("id = ?", id).Updates(fields)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormUsers) SaveMetadata(user *models.User) error {
+	return s.db.Model(user).Select("metadata").Updates(user).Error
+}
+
+func (s gormUsers) SetInitialPassword(id uint, hash string) (bool, error) {
+	// The condition keeps two concurrent requests from both setting one
+	result := s.db.Model(&models.User{}).
+		Where("id = ? AND (password IS NULL OR password = '')", id).
+		Updates(map[string]any{
+			"password":     hash,
+			"account_type": models.AccountTypeHybrid,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (s gormUsers) MarkEmailVerified(id uint) error {
+	return s.db.Model(&models.User{}).
+		Where("id = ? AND email_verified_at IS NULL", id).
+		Update("email_verified_at", time.Now()).Error
+}
+
+func (s gormUsers) ClearLiftedRestriction(id uint) error {
+	return s.db.Model(&models.User{}).
+		Where("id = ? AND banned = ? AND (suspended_until IS NULL OR suspended_until <= ?)", id, false, time.Now()).
+		Update("restriction_reason", "").Error
+}
+
+func (s gormUsers) RecordFailedLogin(user *models.User, lockedUntil *time.Time) error {
+	updates := map[string]any{
+		"failed_login_attempts": gorm.Expr("failed_login_attempts + 1"),
+	}
+	if lockedUntil != nil {
+		updates["locked_until"] = *lockedUntil
+	}
+	return s.db.Model(user).Updates(updates).Error
+}
+
+func (s gormUsers) PasswordHistory(userID uint, limit int) ([]string, error) {
+	var hashes []string
+	err := s.db.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).
+		Order("created_at DESC").Limit(limit).Pluck("hash", &hashes).Error
+	return hashes, err
+}
+
+func (s gormUsers) RememberPassword(userID uint, hash string, keep int) error {
+	if err := s.db.Create(&models.PasswordHistory{UserID: userID, Hash: hash}).Error; err != nil {
+		return err
+	}
+
+	newest := s.db.Model(&models.PasswordHistory{}).Select("id").
+		Where("user_id = ?", userID).Order("created_at DESC").Limit(keep)
+
+	return s.db.Where("user_id = ? AND id NOT IN (?)", userID, newest).Delete(&models.PasswordHistory{}).Error
+}
+
+type gormSessions gormStore
+
+func (s gormSessions) Create(session *models.Session) error {
+	return s.db.Create(session).Error
+}
+
+func (s gormSessions) ByJTI(jti string) (*models.Session, error) {
+	return first[models.Session](s.db.Where(&models.Session{JTI: jti}))
+}
+
+func (s gormSessions) ByRefreshToken(hash string) (*models.Session, error) {
+	return first[models.Session](s.db.Where(&models.Session{RefreshToken: hash}))
+}
+
+func (s gormSessions) Save(session *models.Session) error {
+	return s.db.Save(session).Error
+}
+
+func (s gormSessions) Retire(sessionID uint, hash string) error {
+	return s.db.Create(&models.RetiredRefreshToken{SessionID: sessionID, Token: hash}).Error
+}
+
+func (s gormSessions) ByRetiredRefreshToken(hash string) (*models.Session, error) {
+	return first[models.Session](s.db.
+		Joins("JOIN retired_refresh_tokens ON retired_refresh_tokens.session_id = sessions.id").
+		Where("retired_refresh_tokens.token = ?", hash))
+}
+
+func (s gormSessions) RevokeAll(userID uint) (int64, error) {
+	result := s.db.Model(&models.Session{}).
+		Where("user_id = ? AND revoked = ?", userID, false).
+		Update("revoked", true)
+	return result.RowsAffected, result.Error
+}
+
+func (s gormSessions) RevokeBeyond(userID uint, keep int) error {
+	var stale []uint
+	err := s.db.Model(&models.Session{}).
+		Where("user_id = ? AND kind = ? AND revoked = ? AND expires_at > ?", userID, models.SessionKindLogin, false, time.Now()).
+		Order("issued_at DESC, id DESC").
+		Offset(keep).
+		Pluck("id", &stale).Error
+	if err != nil || len(stale) == 0 {
+		return err
+	}
+	return s.db.Model(&models.Session{}).Where("id IN ?", stale).Update("revoked", true).Error
+}
+
+func (s gormSessions) ByID(id uint) (*models.Session, error) {
+	return first[models.Session](s.db, id)
+}
+
+func (s gormSessions) ListActive(userID uint, kind models.SessionKind) ([]models.Session, error) {
+	var sessions []models.Session
+	err := s.db.Where("user_id = ? AND kind = ? AND revoked = ? AND expires_at > ?", userID, kind, false, time.Now()).
+		Order("issued_at DESC, id DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (s gormSessions) RevokeByClient(clientID uint) (int64, error) {
+	result := s.db.Model(&models.Session{}).
+		Where("api_client_id = ? AND revoked = ?", clientID, false).
+		Update("revoked", true)
+	return result.RowsAffected, result.Error
+}
+
+func (s gormSessions) RevokeMatching(filter SessionFilter) (map[uint]int64, error) {
+	tenantUsers := s.db.Unscoped().Model(&models.User{}).Select("id").Where("tenant_id = ?", filter.TenantID)
+	query := s.db.Model(&models.Session{}).Where("revoked = ? AND user_id IN (?)", false, tenantUsers)
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.IPRange != "" {
+		query = query.Where("NULLIF(ip_address, '')::inet <<= ?::cidr", filter.IPRange)
+	}
+	if filter.IssuedBefore != nil {
+		query = query.Where("issued_at < ?", *filter.IssuedBefore)
+	}
+
+	// Return the owners so callers can tell each affected user
+	var revoked []models.Session
+	err := query.Model(&revoked).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "user_id"}}}).
+		Update("revoked", true).Error
+	if err != nil {
+		return nil, err
+	}
+
+	perUser := make(map[uint]int64)
+	for _, session := range revoked {
+		perUser[session.UserID]++
+	}
+	return perUser, nil
+}
+
+func (s gormSessions) List(userID uint, activeOnly bool, page Page) ([]models.Session, error) {
+	query := s.db.Where("user_id = ? AND kind = ?", userID, models.SessionKindLogin)
+	if activeOnly {
+		query = query.Where("revoked = ? AND expires_at > ?", false, time.Now())
+	}
+	var sessions []models.Session
+	err := page.Apply(query, "issued_at", "id").Find(&sessions).Error
+	return sessions, err
+}
+
+func (s gormSessions) EachBatch(userID uint, batchSize int, fn func([]models.Session) error) error {
+	var sessions []models.Session
+	return s.db.Where("user_id = ?", userID).FindInBatches(&sessions, batchSize, func(_ *gorm.DB, _ int) error {
+		return fn(sessions)
+	}).Error
+}
+
+type gormOAuth gormStore
+
+func (s gormOAuth) CreateState(state *models.OAuthState) error {
+	return s.db.Create(state).Error
+}
+
+func (s gormOAuth) ValidState(state string, provider models.OAuthProvider) (*models.OAuthState, error) {
+	return first[models.OAuthState](s.db.Where("state = ? AND provider = ? AND expires_at > ?", state, provider, time.Now()))
+}
+
+func (s gormOAuth) DeleteState(state *models.OAuthState) error {
+	return s.db.Delete(state).Error
+}
+
+func (s gormOAuth) ByProviderID(tenantID uint, provider models.OAuthProvider, providerID string) (*models.OAuthAccount, error) {
+	return first[models.OAuthAccount](s.db.
+		Joins("JOIN users ON users.id = oauth_accounts.user_id AND users.tenant_id = ?", tenantID).
+		Where("oauth_accounts.provider = ? AND oauth_accounts.provider_id = ?", provider, providerID))
+}
+
+func (s gormOAuth) ByUser(userID uint, provider models.OAuthProvider) (*models.OAuthAccount, error) {
+	return first[models.OAuthAccount](s.db.Where("user_id = ? AND provider = ?", userID, provider))
+}
+
+func (s gormOAuth) ListByUser(userID uint) ([]models.OAuthAccount, error) {
+	var accounts []models.OAuthAccount
+	err := s.db.Where("user_id = ?", userID).Order("linked_at").Find(&accounts).Error
+	return accounts, err
+}
+
+func (s gormOAuth) CountByUser(userID uint) (int64, error) {
+	var count int64
+	err := s.db.Model(&models.OAuthAccount{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (s gormOAuth) Create(account *models.OAuthAccount) error {
+	return s.db.Create(account).Error
+}
+
+func (s gormOAuth) Update(account *models.OAuthAccount, fields map[string]any) error {
+	return s.db.Model(account).Updates(fields).Error
+}
+
+func (s gormOAuth) Delete(account *models.OAuthAccount) error {
+	return s.db.Delete(account).Error
+}
+
+type gormInvitations gormStore
+
+func (s gormInvitations) Accept(id, userID uint) (bool, error) {
+	result := s.db.Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL", id).
+		Updates(map[string]any{
+			"accepted_at": time.Now(),
+			"accepted_by": userID,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+type gormResets gormStore
+
+func (s gormResets) Create(reset *models.PasswordReset) error {
+	return s.db.Create(reset).Error
+}
+
+func (s gormResets) ValidByToken(hash string) (*models.PasswordReset, error) {
+	return first[models.PasswordReset](s.db.Where("token = ? AND used = false AND expires_at > ?", hash, time.Now()))
+}
+
+func (s gormResets) InvalidateAll(tenantID uint, email string) error {
+	query := s.db.Model(&models.PasswordReset{}).Where("tenant_id = ? AND used = false", tenantID)
+	if index := utils.EmailBlindIndex(email); index != nil {
+		query = query.Where("email_hash = ?", *index)
+	} else {
+		query = query.Where("lower(email) = ?", email)
+	}
+	return query.Update("used", true).Error
+}
+
+func (s gormResets) MarkUsed(reset *models.PasswordReset) error {
+	return s.db.Model(reset).Update("used", true).Error
+}
+
+func (s gormResets) RecordFailedAttempt(reset *models.PasswordReset, invalidate bool) error {
+	updates := map[string]any{
+		"failed_attempts": gorm.Expr("failed_attempts + 1"),
+	}
+	if invalidate {
+		updates["used"] = true
+	}
+	return s.db.Model(reset).Updates(updates).Error
+}
+
+type gormAPIClients gormStore
+
+func (s gormAPIClients) Create(client *models.APIClient) error {
+	return s.db.Create(client).Error
+}
+
+func (s gormAPIClients) ByID(id uint) (*models.APIClient, error) {
+	return first[models.APIClient](s.db, id)
+}
+
+func (s gormAPIClients) ByClientID(clientID string) (*models.APIClient, error) {
+	return first[models.APIClient](s.db.Where("client_id = ?", clientID))
+}
+
+func (s gormAPIClients) ListByUser(userID uint) ([]models.APIClient, error) {
+	var clients []models.APIClient
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&clients).Error
+	return clients, err
+}
+
+func (s gormAPIClients) Touch(client *models.APIClient) error {
+	return s.db.Model(client).Update("last_used_at", time.Now()).Error
+}
+
+func (s gormAPIClients) Delete(client *models.APIClient) error {
+	return s.db.Delete(client).Error
+}
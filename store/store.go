@@ -0,0 +1,239 @@
+// Package store defines the persistence interfaces for users, sessions,
+// OAuth links, password resets and API clients, so handlers do not depend on
+// a particular database. NewGorm provides the implementation backed by GORM.
+//
+// Only the account tables are behind these interfaces. Audit logs, webhooks,
+// the email outbox, settings and the other operational tables are still
+// queried through GORM directly, as is the multi-table erasure run when an
+// account is anonymized or purged.
+package store
+
+import (
+	"api/database/models"
+	"api/utils"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a lookup matches no record.
+var ErrNotFound = errors.New("record not found")
+
+// Page selects part of a list ordered newest first: at most Limit rows
+// after the cursor in After, or after skipping Offset rows.
+type Page struct {
+	Limit  int
+	After  *utils.Cursor
+	Offset int
+}
+
+// UserStatus is an account status a user list can be filtered by.
+type UserStatus string
+
+const (
+	UserStatusActive     UserStatus = "active"
+	UserStatusLocked     UserStatus = "locked"
+	UserStatusBanned     UserStatus = "banned"
+	UserStatusSuspended  UserStatus = "suspended"
+	UserStatusUnverified UserStatus = "unverified"
+	UserStatusAnonymized UserStatus = "anonymized"
+	UserStatusDeleted    UserStatus = "deleted" // Soft-deleted, within the grace period
+)
+
+// UserStatuses lists every UserStatus.
+var UserStatuses = []UserStatus{
+	UserStatusActive, UserStatusLocked, UserStatusBanned, UserStatusSuspended,
+	UserStatusUnverified, UserStatusAnonymized, UserStatusDeleted,
+}
+
+// UserFilter narrows a list of the tenant's users. Zero fields match every
+// user.
+type UserFilter struct {
+	TenantID      uint
+	Email         string // Normalized, matched exactly
+	Username      string // Case-insensitive substring
+	AccountType   models.AccountType
+	Role          models.Role
+	Status        UserStatus
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// SessionFilter selects the tenant's sessions for a bulk revocation. The
+// optional fields are combined with AND.
+type SessionFilter struct {
+	TenantID     uint
+	UserID       uint
+	IPRange      string // CIDR; sessions without a recorded IP never match
+	IssuedBefore *time.Time
+}
+
+// Store groups the stores sharing one connection or transaction.
+type Store interface {
+	Users() UserStore
+	Sessions() SessionStore
+	OAuth() OAuthStore
+	Resets() ResetStore
+	Invitations() InvitationStore
+	APIClients() APIClientStore
+
+	// Transaction runs fn with stores bound to a single transaction, which
+	// is committed when fn returns nil and rolled back otherwise.
+	Transaction(fn func(tx Store) error) error
+}
+
+// UserStore persists user accounts.
+type UserStore interface {
+	ByID(id uint) (*models.User, error)
+	// ByEmail finds a user of the tenant by normalized email, through the
+	// blind index when one is configured.
+	ByEmail(tenantID uint, email string) (*models.User, error)
+	// AnyByEmail finds the oldest user with the normalized email in any
+	// tenant, for records that are not scoped to a tenant.
+	AnyByEmail(email string) (*models.User, error)
+	ByUsername(tenantID uint, username string) (*models.User, error)
+	// ByPreviousUsername finds the user of the tenant who most recently gave
+	// up username after since.
+	ByPreviousUsername(tenantID uint, username string, since time.Time) (*models.User, error)
+	// WithDetails loads the user with their sessions, newest first, and
+	// linked OAuth accounts. includeDeleted also finds soft-deleted users.
+	WithDetails(id uint, includeDeleted bool) (*models.User, error)
+	// LockByID loads the user and locks the row until the transaction ends.
+	LockByID(id uint) (*models.User, error)
+	// InTenant reports whether the user with id, deleted or not, belongs to
+	// the tenant.
+	InTenant(id, tenantID uint) (bool, error)
+	// List returns a page of the users matching filter, newest first, and
+	// how many users match in total.
+	List(filter UserFilter, page Page) ([]models.User, int64, error)
+	// Locked returns the tenant's users that are locked out now, latest
+	// lockout first.
+	Locked(tenantID uint) ([]models.User, error)
+	// DeletedBefore returns the IDs of up to limit users soft-deleted before
+	// the given time, longest deleted first.
+	DeletedBefore(before time.Time, limit int) ([]uint, error)
+	// EmailRegistered and UsernameRegistered report whether a user of the
+	// tenant has the email or username, counting deleted users who may
+	// still be restored.
+	EmailRegistered(tenantID uint, email string) (bool, error)
+	UsernameRegistered(tenantID uint, username string) (bool, error)
+	UsernameTaken(tenantID uint, username string) (bool, error)
+	// UsernameReleased reports whether a user of the tenant other than
+	// exceptUserID gave up username after since.
+	UsernameReleased(tenantID uint, username string, exceptUserID uint, since time.Time) (bool, error)
+	Create(user *models.User) error
+	// Update sets the given columns on the user.
+	Update(user *models.User, fields map[string]any) error
+	// UpdateByID sets the given columns on the user with id and reports
+	// whether the user exists.
+	UpdateByID(id uint, fields map[string]any) (bool, error)
+	// SaveMetadata writes the user's Metadata.
+	SaveMetadata(user *models.User) error
+	// SetInitialPassword sets the password of a user without one and makes
+	// the account a hybrid one. It reports false when a password is already
+	// set.
+	SetInitialPassword(id uint, hash string) (bool, error)
+	// MarkEmailVerified records that the user verified their email, unless
+	// they already had.
+	MarkEmailVerified(id uint) error
+	// ClearLiftedRestriction clears the restriction reason once the user is
+	// neither banned nor suspended.
+	ClearLiftedRestriction(id uint) error
+	// RecordFailedLogin increments the consecutive failed login counter and,
+	// when lockedUntil is not nil, locks the account until then.
+	RecordFailedLogin(user *models.User, lockedUntil *time.Time) error
+	// PasswordHistory returns the user's newest limit previous password
+	// hashes, newest first.
+	PasswordHistory(userID uint, limit int) ([]string, error)
+	// RememberPassword adds hash to the user's password history and prunes
+	// it to the newest keep entries.
+	RememberPassword(userID uint, hash string, keep int) error
+}
+
+// SessionStore persists sessions, which pair an access token JTI with a
+// hashed refresh token.
+type SessionStore interface {
+	Create(session *models.Session) error
+	ByJTI(jti string) (*models.Session, error)
+	ByRefreshToken(hash string) (*models.Session, error)
+	Save(session *models.Session) error
+	// Retire records hash as a refresh token the session no longer accepts.
+	Retire(sessionID uint, hash string) error
+	// ByRetiredRefreshToken returns the session a retired refresh token
+	// belonged to.
+	ByRetiredRefreshToken(hash string) (*models.Session, error)
+	// RevokeAll revokes the user's active sessions and returns how many
+	// were revoked.
+	RevokeAll(userID uint) (int64, error)
+	// RevokeBeyond revokes the user's unexpired login sessions beyond the
+	// newest keep ones. Token sessions do not count towards the cap.
+	RevokeBeyond(userID uint, keep int) error
+
+	ByID(id uint) (*models.Session, error)
+	// ListActive returns the user's unrevoked, unexpired sessions of kind,
+	// newest first.
+	ListActive(userID uint, kind models.SessionKind) ([]models.Session, error)
+	// RevokeByClient revokes the active sessions of an API client and
+	// returns how many were revoked.
+	RevokeByClient(clientID uint) (int64, error)
+	// RevokeMatching revokes the active sessions matching filter and returns
+	// how many were revoked per user.
+	RevokeMatching(filter SessionFilter) (map[uint]int64, error)
+	// List returns a page of the user's login sessions, newest first.
+	// activeOnly leaves out revoked and expired sessions.
+	List(userID uint, activeOnly bool, page Page) ([]models.Session, error)
+	// EachBatch calls fn with all of the user's sessions, batchSize at a
+	// time.
+	EachBatch(userID uint, batchSize int, fn func([]models.Session) error) error
+}
+
+// APIClientStore persists API clients, whose secrets are stored as SHA-256
+// hashes.
+type APIClientStore interface {
+	Create(client *models.APIClient) error
+	ByID(id uint) (*models.APIClient, error)
+	ByClientID(clientID string) (*models.APIClient, error)
+	ListByUser(userID uint) ([]models.APIClient, error)
+	// Touch records that the client was just issued a token.
+	Touch(client *models.APIClient) error
+	Delete(client *models.APIClient) error
+}
+
+// OAuthStore persists linked provider accounts and pending OAuth states.
+type OAuthStore interface {
+	CreateState(state *models.OAuthState) error
+	// ValidState finds an unexpired state issued for provider.
+	ValidState(state string, provider models.OAuthProvider) (*models.OAuthState, error)
+	DeleteState(state *models.OAuthState) error
+
+	// ByProviderID finds the provider account linked to a user of the
+	// tenant.
+	ByProviderID(tenantID uint, provider models.OAuthProvider, providerID string) (*models.OAuthAccount, error)
+	ByUser(userID uint, provider models.OAuthProvider) (*models.OAuthAccount, error)
+	ListByUser(userID uint) ([]models.OAuthAccount, error)
+	CountByUser(userID uint) (int64, error)
+	Create(account *models.OAuthAccount) error
+	// Update sets the given columns on the account.
+	Update(account *models.OAuthAccount, fields map[string]any) error
+	Delete(account *models.OAuthAccount) error
+}
+
+// InvitationStore persists admin-issued registration invitations.
+type InvitationStore interface {
+	// Accept marks the invitation as used by userID. It reports false when
+	// the invitation was already accepted.
+	Accept(id, userID uint) (bool, error)
+}
+
+// ResetStore persists password reset tokens, stored as SHA-256 hashes.
+type ResetStore interface {
+	Create(reset *models.PasswordReset) error
+	// ValidByToken finds an unused, unexpired reset by token hash.
+	ValidByToken(hash string) (*models.PasswordReset, error)
+	// InvalidateAll marks every unused reset for email in the tenant as
+	// used.
+	InvalidateAll(tenantID uint, email string) error
+	MarkUsed(reset *models.PasswordReset) error
+	// RecordFailedAttempt counts a rejected confirmation against the reset
+	// and, when invalidate is set, marks it used.
+	RecordFailedAttempt(reset *models.PasswordReset, invalidate bool) error
+}
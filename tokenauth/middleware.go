@@ -0,0 +1,112 @@
+package tokenauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrMissingToken is returned when a request has no bearer token.
+var ErrMissingToken = errors.New("missing or malformed bearer token")
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(header string) (string, error) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", ErrMissingToken
+	}
+	return strings.TrimSpace(token), nil
+}
+
+// rejected reports whether err rejects the token, as opposed to a failed
+// revocation check.
+func rejected(err error) bool {
+	return errors.Is(err, ErrMissingToken) || errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrRevoked)
+}
+
+// errorStatus is the status the default error handlers reply with.
+func errorStatus(err error) int {
+	if rejected(err) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusServiceUnavailable
+}
+
+// FiberConfig configures the Fiber middleware.
+type FiberConfig struct {
+	// ContextKey is the Locals key the *jwt.Token is stored under. Defaults
+	// to "user".
+	ContextKey string
+	// ErrorHandler replies to requests that are rejected or whose
+	// revocation check failed. The default replies 401, or 503 when the
+	// revocation backend failed.
+	ErrorHandler fiber.ErrorHandler
+}
+
+// Fiber returns middleware that requires a valid bearer token and stores it
+// in c.Locals. The token's Claims are a *Claims.
+func Fiber(v *Validator, config FiberConfig) fiber.Handler {
+	if config.ContextKey == "" {
+		config.ContextKey = "user"
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = func(c *fiber.Ctx, err error) error {
+			return fiber.NewError(errorStatus(err), http.StatusText(errorStatus(err)))
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		tokenString, err := bearerToken(c.Get(fiber.HeaderAuthorization))
+		if err != nil {
+			return config.ErrorHandler(c, err)
+		}
+		token, err := v.Validate(c.UserContext(), tokenString)
+		if err != nil {
+			return config.ErrorHandler(c, err)
+		}
+
+		c.Locals(config.ContextKey, token)
+		return c.Next()
+	}
+}
+
+type contextKey struct{}
+
+// Middleware returns net/http middleware that requires a valid bearer token
+// and stores its claims in the request context, for ClaimsFromContext. With
+// Echo, wrap it with echo.WrapMiddleware. Rejected requests get 401, and
+// failed revocation checks 503, with the API's JSON error envelope.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString, err := bearerToken(r.Header.Get("Authorization"))
+		var token *jwt.Token
+		if err == nil {
+			token, err = v.Validate(r.Context(), tokenString)
+		}
+		if err != nil {
+			status := errorStatus(err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]any{
+				"success": false,
+				"code":    status,
+				"message": http.StatusText(status),
+			})
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKey{}, token.Claims.(*Claims))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ClaimsFromContext returns the claims stored by Middleware.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
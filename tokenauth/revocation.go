@@ -0,0 +1,51 @@
+package tokenauth
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RevocationChecker reports whether the session with the given JWT ID has
+// been revoked.
+type RevocationChecker interface {
+	Revoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RevocationFunc adapts a function to RevocationChecker, e.g. to look the
+// session up in the auth database.
+type RevocationFunc func(ctx context.Context, jti string) (bool, error)
+
+func (f RevocationFunc) Revoked(ctx context.Context, jti string) (bool, error) {
+	return f(ctx, jti)
+}
+
+// Denylist is a RevocationChecker backed by a fiber.Storage such as Redis.
+// Revoked JWT IDs are stored until the tokens would have expired anyway, so
+// services that cannot reach the auth database can still honour revocations
+// pushed to the shared store.
+type Denylist struct {
+	storage fiber.Storage
+	prefix  string
+}
+
+// NewDenylist returns a denylist keeping its entries in storage under
+// "revoked_jti:".
+func NewDenylist(storage fiber.Storage) *Denylist {
+	return &Denylist{storage: storage, prefix: "revoked_jti:"}
+}
+
+// Revoke denies the JWT ID for ttl, which should cover the remaining
+// lifetime of its access token.
+func (d *Denylist) Revoke(jti string, ttl time.Duration) error {
+	return d.storage.Set(d.prefix+jti, []byte{1}, ttl)
+}
+
+func (d *Denylist) Revoked(_ context.Context, jti string) (bool, error) {
+	value, err := d.storage.Get(d.prefix + jti)
+	if err != nil {
+		return false, err
+	}
+	return len(value) > 0, nil
+}
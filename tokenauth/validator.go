@@ -0,0 +1,67 @@
+package tokenauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, wrongly
+	// signed or expired.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrRevoked is returned for tokens whose session has been revoked.
+	ErrRevoked = errors.New("token revoked")
+)
+
+// Validator checks access tokens.
+type Validator struct {
+	secret     []byte
+	revocation RevocationChecker
+}
+
+// New returns a validator for tokens signed with secret using HS256.
+// revocation decides whether a token's session has been revoked; nil only
+// checks the signature and expiry.
+func New(secret []byte, revocation RevocationChecker) *Validator {
+	return &Validator{secret: secret, revocation: revocation}
+}
+
+// Parse verifies the token's signature and expiry and returns the parsed
+// token, whose Claims are a *Claims. It does not check revocation.
+func (v *Validator) Parse(tokenString string) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(*jwt.Token) (interface{}, error) {
+		return v.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if token.Claims.(*Claims).ID == "" {
+		return nil, fmt.Errorf("%w: missing jti", ErrInvalidToken)
+	}
+	return token, nil
+}
+
+// Validate parses the token and checks that its session has not been
+// revoked. Errors from the revocation backend are returned as they are, so
+// callers can tell an outage from a rejected token.
+func (v *Validator) Validate(ctx context.Context, tokenString string) (*jwt.Token, error) {
+	token, err := v.Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if v.revocation == nil {
+		return token, nil
+	}
+
+	revoked, err := v.revocation.Revoked(ctx, token.Claims.(*Claims).ID)
+	if err != nil {
+		return nil, fmt.Errorf("revocation check failed: %w", err)
+	}
+	if revoked {
+		return nil, ErrRevoked
+	}
+	return token, nil
+}
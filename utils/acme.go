@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// NewAutocert builds a certificate manager that obtains and renews
+// certificates from Let's Encrypt, or another ACME CA, from environment
+// variables: ACME_DOMAINS (comma-separated; certificates are only requested
+// for these), ACME_CACHE_DIR (default "certs"), the optional ACME_EMAIL for
+// expiry notices and ACME_DIRECTORY_URL, e.g. the Let's Encrypt staging
+// directory. It returns nil when ACME_DOMAINS is not set.
+func NewAutocert() (*autocert.Manager, error) {
+	var domains []string
+	for _, domain := range strings.Split(os.Getenv("ACME_DOMAINS"), ",") {
+		if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+	if len(domains) == 0 {
+		return nil, nil
+	}
+	for _, domain := range domains {
+		if strings.Contains(domain, "*") {
+			return nil, errors.New("ACME_DOMAINS cannot contain wildcards; they need a DNS challenge")
+		}
+	}
+
+	cacheDir := os.Getenv("ACME_CACHE_DIR")
+	if cacheDir == "" {
+		cacheDir = "certs"
+	}
+
+	m := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Cache:      autocert.DirCache(cacheDir),
+		Email:      os.Getenv("ACME_EMAIL"),
+	}
+	if directory := os.Getenv("ACME_DIRECTORY_URL"); directory != "" {
+		m.Client = &acme.Client{DirectoryURL: directory}
+	}
+	return m, nil
+}
+
+// ListenAutocert listens on addr and terminates TLS with certificates from
+// m, answering TLS-ALPN-01 challenges on the same port. Client fingerprints
+// are recorded as with ListenTLS.
+func ListenAutocert(addr string, m *autocert.Manager) (net.Listener, error) {
+	return listenTLS(addr, &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: m.GetCertificate,
+		// Not m.TLSConfig(), which also offers h2 that fasthttp cannot serve
+		NextProtos: []string{"http/1.1", acme.ALPNProto},
+	})
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Register decoders for accepted upload formats
+	_ "image/jpeg"
+	"image/png"
+)
+
+// maxAvatarSourcePixels bounds the decoded size of uploaded images, so a
+// small file that decompresses into a huge bitmap is rejected before decoding.
+const maxAvatarSourcePixels = 25_000_000
+
+// ErrInvalidImage is returned for uploads that are not a supported image.
+var ErrInvalidImage = errors.New("invalid image")
+
+// ProcessAvatar decodes a JPEG, PNG or GIF image, crops it to a centered
+// square and scales it to size x size pixels. The result is PNG encoded,
+// which also strips any metadata from the original.
+func ProcessAvatar(data []byte, size int) ([]byte, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if format != "jpeg" && format != "png" && format != "gif" {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxAvatarSourcePixels {
+		return nil, fmt.Errorf("%w: dimensions %dx%d are not allowed", ErrInvalidImage, config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaleSquare(src, size)); err != nil {
+		return nil, fmt.Errorf("encode avatar: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleSquare crops src to its centered square and resamples it to size x
+// size by averaging the source pixels that fall into each target pixel.
+// Images smaller than size are scaled up with nearest-neighbour sampling.
+func scaleSquare(src image.Image, size int) *image.NRGBA {
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0 := y0 + y*side/size
+		sy1 := max(y0+(y+1)*side/size, sy0+1)
+		for x := 0; x < size; x++ {
+			sx0 := x0 + x*side/size
+			sx1 := max(x0+(x+1)*side/size, sx0+1)
+
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			// Average premultiplied values, then convert back to straight alpha
+			c := color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)}
+			dst.Set(x, y, c)
+		}
+	}
+	return dst
+}
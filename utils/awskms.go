@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// AWSKMSProvider implements EncryptionProvider using AWS KMS GenerateDataKey
+// and Decrypt. Requests are signed with SigV4 using static credentials from
+// the standard AWS_* environment variables.
+type AWSKMSProvider struct {
+	keyID        string
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// NewAWSKMSProvider builds an AWSKMSProvider from environment variables:
+// AWS_KMS_KEY_ID, AWS_REGION, AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// optionally AWS_SESSION_TOKEN and AWS_KMS_ENDPOINT.
+func NewAWSKMSProvider() (*AWSKMSProvider, error) {
+	p := &AWSKMSProvider{
+		keyID:        os.Getenv("AWS_KMS_KEY_ID"),
+		region:       os.Getenv("AWS_REGION"),
+		endpoint:     os.Getenv("AWS_KMS_ENDPOINT"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if p.keyID == "" || p.region == "" {
+		return nil, errors.New("AWS_KMS_KEY_ID and AWS_REGION are required for the awskms provider")
+	}
+	if p.accessKey == "" || p.secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the awskms provider")
+	}
+	if p.endpoint == "" {
+		p.endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com/", p.region)
+	}
+
+	return p, nil
+}
+
+// Name implements EncryptionProvider.
+func (p *AWSKMSProvider) Name() string {
+	return "awskms"
+}
+
+// GenerateDataKey implements EncryptionProvider.
+func (p *AWSKMSProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	var out struct {
+		Plaintext      []byte `json:"Plaintext"`
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+
+	err := p.call(ctx, "GenerateDataKey", map[string]any{
+		"KeyId":   p.keyID,
+		"KeySpec": "AES_256",
+	}, &out)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+// DecryptDataKey implements EncryptionProvider.
+func (p *AWSKMSProvider) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+
+	err := p.call(ctx, "Decrypt", map[string]any{
+		"KeyId":          p.keyID,
+		"CiphertextBlob": wrapped,
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Plaintext, nil
+}
+
+// call invokes a KMS JSON API action. []byte fields are base64-encoded by
+// encoding/json, which matches the KMS wire format.
+func (p *AWSKMSProvider) call(ctx context.Context, action string, in any, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	p.sign(req, payload, time.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kms %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("kms %s: read response: %w", action, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kms %s returned status %d: %s", action, resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("kms %s: decode response: %w", action, err)
+	}
+	return nil
+}
+
+// sign adds AWS Signature Version 4 headers to the request.
+func (p *AWSKMSProvider) sign(req *http.Request, payload []byte, now time.Time) {
+	creds := awsCredentials{accessKey: p.accessKey, secretKey: p.secretKey, sessionToken: p.sessionToken}
+	signAWSRequest(req, payload, now, creds, p.region, "kms")
+}
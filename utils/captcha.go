@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCaptchaRejected is returned when the CAPTCHA provider rejects a token.
+var ErrCaptchaRejected = errors.New("captcha rejected")
+
+// CaptchaVerifier verifies a client-side CAPTCHA token with the provider.
+// Implementations should be safe for concurrent use by multiple goroutines.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// SiteVerifyCaptcha implements CaptchaVerifier for providers exposing the
+// common "siteverify" API (Cloudflare Turnstile, hCaptcha, Google reCAPTCHA).
+type SiteVerifyCaptcha struct {
+	endpoint string
+	secret   string
+	minScore float64 // reCAPTCHA v3 only; 0 disables the score check
+	client   *http.Client
+}
+
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score,omitempty"`
+	ErrorCodes []string `json:"error-codes,omitempty"`
+}
+
+// NewTurnstileVerifier returns a verifier for Cloudflare Turnstile.
+func NewTurnstileVerifier(secret string) *SiteVerifyCaptcha {
+	return newSiteVerifyCaptcha("https://challenges.cloudflare.com/turnstile/v0/siteverify", secret, 0)
+}
+
+// NewHCaptchaVerifier returns a verifier for hCaptcha.
+func NewHCaptchaVerifier(secret string) *SiteVerifyCaptcha {
+	return newSiteVerifyCaptcha("https://api.hcaptcha.com/siteverify", secret, 0)
+}
+
+// NewReCaptchaVerifier returns a verifier for Google reCAPTCHA. For v3 keys,
+// tokens scoring below minScore are rejected; pass 0 for v2.
+func NewReCaptchaVerifier(secret string, minScore float64) *SiteVerifyCaptcha {
+	return newSiteVerifyCaptcha("https://www.google.com/recaptcha/api/siteverify", secret, minScore)
+}
+
+func newSiteVerifyCaptcha(endpoint, secret string, minScore float64) *SiteVerifyCaptcha {
+	return &SiteVerifyCaptcha{
+		endpoint: endpoint,
+		secret:   secret,
+		minScore: minScore,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewCaptchaVerifier builds a CaptchaVerifier from environment variables:
+// CAPTCHA_PROVIDER (turnstile, hcaptcha or recaptcha), CAPTCHA_SECRET and,
+// for reCAPTCHA v3, CAPTCHA_MIN_SCORE. It returns nil when no provider is
+// configured.
+func NewCaptchaVerifier() (CaptchaVerifier, error) {
+	return newCaptchaVerifier("CAPTCHA_MIN_SCORE")
+}
+
+// NewRegistrationCaptchaVerifier returns a verifier for sign-ups that holds
+// reCAPTCHA v3 tokens to REGISTRATION_CAPTCHA_MIN_SCORE instead of
+// CAPTCHA_MIN_SCORE. It returns nil when that variable is not set, in which
+// case the default verifier applies.
+func NewRegistrationCaptchaVerifier() (CaptchaVerifier, error) {
+	if os.Getenv("REGISTRATION_CAPTCHA_MIN_SCORE") == "" {
+		return nil, nil
+	}
+	return newCaptchaVerifier("REGISTRATION_CAPTCHA_MIN_SCORE")
+}
+
+func newCaptchaVerifier(minScoreKey string) (CaptchaVerifier, error) {
+	provider := strings.ToLower(os.Getenv("CAPTCHA_PROVIDER"))
+	if provider == "" {
+		return nil, nil
+	}
+
+	secret := os.Getenv("CAPTCHA_SECRET")
+	if secret == "" {
+		return nil, errors.New("CAPTCHA_SECRET is not configured")
+	}
+
+	switch provider {
+	case "turnstile":
+		return NewTurnstileVerifier(secret), nil
+	case "hcaptcha":
+		return NewHCaptchaVerifier(secret), nil
+	case "recaptcha":
+		minScore := 0.0
+		if v := os.Getenv(minScoreKey); v != "" {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", minScoreKey, err)
+			}
+			minScore = parsed
+		}
+		return NewReCaptchaVerifier(secret, minScore), nil
+	default:
+		return nil, fmt.Errorf("unsupported CAPTCHA_PROVIDER %q", provider)
+	}
+}
+
+// Verify posts the token to the provider's siteverify endpoint. It returns
+// ErrCaptchaRejected when the provider rejects the token and a wrapped error
+// when the provider could not be reached.
+func (s *SiteVerifyCaptcha) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrCaptchaRejected
+	}
+
+	form := url.Values{}
+	form.Set("secret", s.secret)
+	form.Set("response", token)
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("build captcha request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("captcha siteverify: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha siteverify returned status %d", resp.StatusCode)
+	}
+
+	var result siteVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decode captcha response: %w", err)
+	}
+
+	if !result.Success {
+		return ErrCaptchaRejected
+	}
+
+	if s.minScore > 0 && result.Score != nil && *result.Score < s.minScore {
+		return ErrCaptchaRejected
+	}
+
+	return nil
+}
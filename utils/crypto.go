@@ -5,22 +5,135 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"fmt"
 	"log"
+	"math/big"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	bcryptCost   = bcrypt.DefaultCost
+	hashSlots    chan struct{}
+	hashInitOnce sync.Once
+)
+
+// initPasswordHashing reads BCRYPT_COST (default 10, valid 4-31) and
+// HASH_CONCURRENCY (default: number of CPUs), which caps how many bcrypt
+// operations run at once so login bursts cannot starve the CPU.
+func initPasswordHashing() {
+	hashInitOnce.Do(func() {
+		if v := os.Getenv("BCRYPT_COST"); v != "" {
+			cost, err := strconv.Atoi(v)
+			if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+				log.Printf("invalid BCRYPT_COST=%q; using %d", v, bcrypt.DefaultCost)
+			} else {
+				bcryptCost = cost
+			}
+		}
+
+		slots := runtime.NumCPU()
+		if v, err := strconv.Atoi(os.Getenv("HASH_CONCURRENCY")); err == nil && v > 0 {
+			slots = v
+		}
+		hashSlots = make(chan struct{}, slots)
+	})
+}
+
+// acquireHashSlot blocks until a bcrypt worker slot is free and returns the
+// function that releases it.
+func acquireHashSlot() func() {
+	initPasswordHashing()
+	hashSlots <- struct{}{}
+	return func() { <-hashSlots }
+}
+
+// HashPassword hashes the password with bcrypt. When a pepper is configured
+// it is mixed in first and the result is tagged with the pepper id.
 func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	release := acquireHashSlot()
+	defer release()
+
+	input := []byte(password)
+	if peppers.currentID != "" {
+		input = pepperPassword(peppers.keys[peppers.currentID], password)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(input, bcryptCost)
 	if err != nil {
-		log.Fatal(err)
+		return "", fmt.Errorf("hash password: %w", err)
 	}
 
+	if peppers.currentID != "" {
+		return fmt.Sprintf("%s:%s:%s", pepperedHashPrefix, peppers.currentID, hash), nil
+	}
 	return string(hash), nil
 }
 
+// ComparePassword reports whether password matches the stored hash, which may
+// be unpeppered or peppered with any known pepper id.
 func ComparePassword(password string, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if hash == "" {
+		// OAuth-only accounts have no password; skip the bcrypt work.
+		return false
+	}
+
+	if isForeignHash(hash) {
+		return compareForeignHash(password, hash)
+	}
+
+	input := []byte(password)
+	id, bcryptHash := splitPepperedHash(hash)
+	if id != "" {
+		pepper, ok := peppers.keys[id]
+		if !ok {
+			log.Printf("password hash uses unknown pepper id %q", id)
+			return false
+		}
+		input = pepperPassword(pepper, password)
+	}
+
+	release := acquireHashSlot()
+	defer release()
+
+	err := bcrypt.CompareHashAndPassword([]byte(bcryptHash), input)
+	return err == nil
+}
+
+// PasswordNeedsRehash reports whether hash was created with a bcrypt cost
+// other than the configured one, with a pepper other than the current one,
+// or imported in another format, so it can be upgraded on the next login.
+func PasswordNeedsRehash(hash string) bool {
+	initPasswordHashing()
+
+	if isForeignHash(hash) {
+		return true
+	}
+
+	id, bcryptHash := splitPepperedHash(hash)
+	if id != peppers.currentID {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(bcryptHash))
+	if err != nil {
+		return false
+	}
+	return cost != bcryptCost
+}
+
+// IsImportedPasswordHash reports whether hash, exported by another auth
+// system, can be verified by ComparePassword: a plain bcrypt hash or one
+// produced by FirebasePasswordHash.
+func IsImportedPasswordHash(hash string) bool {
+	if isForeignHash(hash) {
+		return true
+	}
+	_, err := bcrypt.Cost([]byte(hash))
 	return err == nil
 }
 
@@ -58,3 +171,17 @@ func GenerateSecureToken() (token string, hash string) {
 	hash = HashTokenSHA256(token)
 	return token, hash
 }
+
+// GenerateNumericCode generates a random numeric code of the given length,
+// suitable for codes users type in from an email. Returns the code and its
+// SHA256 hash.
+func GenerateNumericCode(digits int) (code string, hash string) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", ""
+	}
+
+	code = fmt.Sprintf("%0*d", digits, n)
+	return code, HashTokenSHA256(code)
+}
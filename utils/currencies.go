@@ -0,0 +1,192 @@
+package utils
+
+import "strings"
+
+// Currency describes an ISO 4217 currency. Codes are stored lower-cased.
+type Currency struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"` // Digits after the decimal separator (minor unit)
+}
+
+// currencyCatalog lists the active ISO 4217 currencies, excluding funds,
+// precious metals and testing codes.
+var currencyCatalog = []Currency{
+	{Code: "aed", Name: "UAE Dirham", Symbol: "د.إ", Decimals: 2},
+	{Code: "afn", Name: "Afghani", Symbol: "؋", Decimals: 2},
+	{Code: "all", Name: "Lek", Symbol: "L", Decimals: 2},
+	{Code: "amd", Name: "Armenian Dram", Symbol: "֏", Decimals: 2},
+	{Code: "ang", Name: "Netherlands Antillean Guilder", Symbol: "ƒ", Decimals: 2},
+	{Code: "aoa", Name: "Kwanza", Symbol: "Kz", Decimals: 2},
+	{Code: "ars", Name: "Argentine Peso", Symbol: "$", Decimals: 2},
+	{Code: "aud", Name: "Australian Dollar", Symbol: "$", Decimals: 2},
+	{Code: "awg", Name: "Aruban Florin", Symbol: "ƒ", Decimals: 2},
+	{Code: "azn", Name: "Azerbaijan Manat", Symbol: "₼", Decimals: 2},
+	{Code: "bam", Name: "Convertible Mark", Symbol: "KM", Decimals: 2},
+	{Code: "bbd", Name: "Barbados Dollar", Symbol: "$", Decimals: 2},
+	{Code: "bdt", Name: "Taka", Symbol: "৳", Decimals: 2},
+	{Code: "bgn", Name: "Bulgarian Lev", Symbol: "лв", Decimals: 2},
+	{Code: "bhd", Name: "Bahraini Dinar", Symbol: ".د.ب", Decimals: 3},
+	{Code: "bif", Name: "Burundi Franc", Symbol: "FBu", Decimals: 0},
+	{Code: "bmd", Name: "Bermudian Dollar", Symbol: "$", Decimals: 2},
+	{Code: "bnd", Name: "Brunei Dollar", Symbol: "$", Decimals: 2},
+	{Code: "bob", Name: "Boliviano", Symbol: "Bs.", Decimals: 2},
+	{Code: "brl", Name: "Brazilian Real", Symbol: "R$", Decimals: 2},
+	{Code: "bsd", Name: "Bahamian Dollar", Symbol: "$", Decimals: 2},
+	{Code: "btn", Name: "Ngultrum", Symbol: "Nu.", Decimals: 2},
+	{Code: "bwp", Name: "Pula", Symbol: "P", Decimals: 2},
+	{Code: "byn", Name: "Belarusian Ruble", Symbol: "Br", Decimals: 2},
+	{Code: "bzd", Name: "Belize Dollar", Symbol: "$", Decimals: 2},
+	{Code: "cad", Name: "Canadian Dollar", Symbol: "$", Decimals: 2},
+	{Code: "cdf", Name: "Congolese Franc", Symbol: "FC", Decimals: 2},
+	{Code: "chf", Name: "Swiss Franc", Symbol: "CHF", Decimals: 2},
+	{Code: "clp", Name: "Chilean Peso", Symbol: "$", Decimals: 0},
+	{Code: "cny", Name: "Yuan Renminbi", Symbol: "¥", Decimals: 2},
+	{Code: "cop", Name: "Colombian Peso", Symbol: "$", Decimals: 2},
+	{Code: "crc", Name: "Costa Rican Colon", Symbol: "₡", Decimals: 2},
+	{Code: "cup", Name: "Cuban Peso", Symbol: "$", Decimals: 2},
+	{Code: "cve", Name: "Cabo Verde Escudo", Symbol: "$", Decimals: 2},
+	{Code: "czk", Name: "Czech Koruna", Symbol: "Kč", Decimals: 2},
+	{Code: "djf", Name: "Djibouti Franc", Symbol: "Fdj", Decimals: 0},
+	{Code: "dkk", Name: "Danish Krone", Symbol: "kr", Decimals: 2},
+	{Code: "dop", Name: "Dominican Peso", Symbol: "$", Decimals: 2},
+	{Code: "dzd", Name: "Algerian Dinar", Symbol: "د.ج", Decimals: 2},
+	{Code: "egp", Name: "Egyptian Pound", Symbol: "E£", Decimals: 2},
+	{Code: "ern", Name: "Nakfa", Symbol: "Nfk", Decimals: 2},
+	{Code: "etb", Name: "Ethiopian Birr", Symbol: "Br", Decimals: 2},
+	{Code: "eur", Name: "Euro", Symbol: "€", Decimals: 2},
+	{Code: "fjd", Name: "Fiji Dollar", Symbol: "$", Decimals: 2},
+	{Code: "fkp", Name: "Falkland Islands Pound", Symbol: "£", Decimals: 2},
+	{Code: "gbp", Name: "Pound Sterling", Symbol: "£", Decimals: 2},
+	{Code: "gel", Name: "Lari", Symbol: "₾", Decimals: 2},
+	{Code: "ghs", Name: "Ghana Cedi", Symbol: "₵", Decimals: 2},
+	{Code: "gip", Name: "Gibraltar Pound", Symbol: "£", Decimals: 2},
+	{Code: "gmd", Name: "Dalasi", Symbol: "D", Decimals: 2},
+	{Code: "gnf", Name: "Guinean Franc", Symbol: "FG", Decimals: 0},
+	{Code: "gtq", Name: "Quetzal", Symbol: "Q", Decimals: 2},
+	{Code: "gyd", Name: "Guyana Dollar", Symbol: "$", Decimals: 2},
+	{Code: "hkd", Name: "Hong Kong Dollar", Symbol: "$", Decimals: 2},
+	{Code: "hnl", Name: "Lempira", Symbol: "L", Decimals: 2},
+	{Code: "htg", Name: "Gourde", Symbol: "G", Decimals: 2},
+	{Code: "huf", Name: "Forint", Symbol: "Ft", Decimals: 2},
+	{Code: "idr", Name: "Rupiah", Symbol: "Rp", Decimals: 2},
+	{Code: "ils", Name: "New Israeli Sheqel", Symbol: "₪", Decimals: 2},
+	{Code: "inr", Name: "Indian Rupee", Symbol: "₹", Decimals: 2},
+	{Code: "iqd", Name: "Iraqi Dinar", Symbol: "ع.د", Decimals: 3},
+	{Code: "irr", Name: "Iranian Rial", Symbol: "﷼", Decimals: 2},
+	{Code: "isk", Name: "Iceland Krona", Symbol: "kr", Decimals: 0},
+	{Code: "jmd", Name: "Jamaican Dollar", Symbol: "$", Decimals: 2},
+	{Code: "jod", Name: "Jordanian Dinar", Symbol: "د.ا", Decimals: 3},
+	{Code: "jpy", Name: "Yen", Symbol: "¥", Decimals: 0},
+	{Code: "kes", Name: "Kenyan Shilling", Symbol: "KSh", Decimals: 2},
+	{Code: "kgs", Name: "Som", Symbol: "сом", Decimals: 2},
+	{Code: "khr", Name: "Riel", Symbol: "៛", Decimals: 2},
+	{Code: "kmf", Name: "Comorian Franc", Symbol: "CF", Decimals: 0},
+	{Code: "kpw", Name: "North Korean Won", Symbol: "₩", Decimals: 2},
+	{Code: "krw", Name: "Won", Symbol: "₩", Decimals: 0},
+	{Code: "kwd", Name: "Kuwaiti Dinar", Symbol: "د.ك", Decimals: 3},
+	{Code: "kyd", Name: "Cayman Islands Dollar", Symbol: "$", Decimals: 2},
+	{Code: "kzt", Name: "Tenge", Symbol: "₸", Decimals: 2},
+	{Code: "lak", Name: "Lao Kip", Symbol: "₭", Decimals: 2},
+	{Code: "lbp", Name: "Lebanese Pound", Symbol: "ل.ل", Decimals: 2},
+	{Code: "lkr", Name: "Sri Lanka Rupee", Symbol: "Rs", Decimals: 2},
+	{Code: "lrd", Name: "Liberian Dollar", Symbol: "$", Decimals: 2},
+	{Code: "lsl", Name: "Loti", Symbol: "L", Decimals: 2},
+	{Code: "lyd", Name: "Libyan Dinar", Symbol: "ل.د", Decimals: 3},
+	{Code: "mad", Name: "Moroccan Dirham", Symbol: "د.م.", Decimals: 2},
+	{Code: "mdl", Name: "Moldovan Leu", Symbol: "L", Decimals: 2},
+	{Code: "mga", Name: "Malagasy Ariary", Symbol: "Ar", Decimals: 2},
+	{Code: "mkd", Name: "Denar", Symbol: "ден", Decimals: 2},
+	{Code: "mmk", Name: "Kyat", Symbol: "K", Decimals: 2},
+	{Code: "mnt", Name: "Tugrik", Symbol: "₮", Decimals: 2},
+	{Code: "mop", Name: "Pataca", Symbol: "MOP$", Decimals: 2},
+	{Code: "mru", Name: "Ouguiya", Symbol: "UM", Decimals: 2},
+	{Code: "mur", Name: "Mauritius Rupee", Symbol: "₨", Decimals: 2},
+	{Code: "mvr", Name: "Rufiyaa", Symbol: "Rf", Decimals: 2},
+	{Code: "mwk", Name: "Malawi Kwacha", Symbol: "MK", Decimals: 2},
+	{Code: "mxn", Name: "Mexican Peso", Symbol: "$", Decimals: 2},
+	{Code: "myr", Name: "Malaysian Ringgit", Symbol: "RM", Decimals: 2},
+	{Code: "mzn", Name: "Mozambique Metical", Symbol: "MT", Decimals: 2},
+	{Code: "nad", Name: "Namibia Dollar", Symbol: "$", Decimals: 2},
+	{Code: "ngn", Name: "Naira", Symbol: "₦", Decimals: 2},
+	{Code: "nio", Name: "Cordoba Oro", Symbol: "C$", Decimals: 2},
+	{Code: "nok", Name: "Norwegian Krone", Symbol: "kr", Decimals: 2},
+	{Code: "npr", Name: "Nepalese Rupee", Symbol: "₨", Decimals: 2},
+	{Code: "nzd", Name: "New Zealand Dollar", Symbol: "$", Decimals: 2},
+	{Code: "omr", Name: "Rial Omani", Symbol: "ر.ع.", Decimals: 3},
+	{Code: "pab", Name: "Balboa", Symbol: "B/.", Decimals: 2},
+	{Code: "pen", Name: "Sol", Symbol: "S/", Decimals: 2},
+	{Code: "pgk", Name: "Kina", Symbol: "K", Decimals: 2},
+	{Code: "php", Name: "Philippine Peso", Symbol: "₱", Decimals: 2},
+	{Code: "pkr", Name: "Pakistan Rupee", Symbol: "₨", Decimals: 2},
+	{Code: "pln", Name: "Zloty", Symbol: "zł", Decimals: 2},
+	{Code: "pyg", Name: "Guarani", Symbol: "₲", Decimals: 0},
+	{Code: "qar", Name: "Qatari Rial", Symbol: "ر.ق", Decimals: 2},
+	{Code: "ron", Name: "Romanian Leu", Symbol: "lei", Decimals: 2},
+	{Code: "rsd", Name: "Serbian Dinar", Symbol: "дин.", Decimals: 2},
+	{Code: "rub", Name: "Russian Ruble", Symbol: "₽", Decimals: 2},
+	{Code: "rwf", Name: "Rwanda Franc", Symbol: "FRw", Decimals: 0},
+	{Code: "sar", Name: "Saudi Riyal", Symbol: "ر.س", Decimals: 2},
+	{Code: "sbd", Name: "Solomon Islands Dollar", Symbol: "$", Decimals: 2},
+	{Code: "scr", Name: "Seychelles Rupee", Symbol: "₨", Decimals: 2},
+	{Code: "sdg", Name: "Sudanese Pound", Symbol: "ج.س.", Decimals: 2},
+	{Code: "sek", Name: "Swedish Krona", Symbol: "kr", Decimals: 2},
+	{Code: "sgd", Name: "Singapore Dollar", Symbol: "$", Decimals: 2},
+	{Code: "shp", Name: "Saint Helena Pound", Symbol: "£", Decimals: 2},
+	{Code: "sle", Name: "Leone", Symbol: "Le", Decimals: 2},
+	{Code: "sos", Name: "Somali Shilling", Symbol: "Sh", Decimals: 2},
+	{Code: "srd", Name: "Surinam Dollar", Symbol: "$", Decimals: 2},
+	{Code: "ssp", Name: "South Sudanese Pound", Symbol: "£", Decimals: 2},
+	{Code: "stn", Name: "Dobra", Symbol: "Db", Decimals: 2},
+	{Code: "svc", Name: "El Salvador Colon", Symbol: "₡", Decimals: 2},
+	{Code: "syp", Name: "Syrian Pound", Symbol: "£", Decimals: 2},
+	{Code: "szl", Name: "Lilangeni", Symbol: "E", Decimals: 2},
+	{Code: "thb", Name: "Baht", Symbol: "฿", Decimals: 2},
+	{Code: "tjs", Name: "Somoni", Symbol: "SM", Decimals: 2},
+	{Code: "tmt", Name: "Turkmenistan New Manat", Symbol: "m", Decimals: 2},
+	{Code: "tnd", Name: "Tunisian Dinar", Symbol: "د.ت", Decimals: 3},
+	{Code: "top", Name: "Pa'anga", Symbol: "T$", Decimals: 2},
+	{Code: "try", Name: "Turkish Lira", Symbol: "₺", Decimals: 2},
+	{Code: "ttd", Name: "Trinidad and Tobago Dollar", Symbol: "$", Decimals: 2},
+	{Code: "twd", Name: "New Taiwan Dollar", Symbol: "$", Decimals: 2},
+	{Code: "tzs", Name: "Tanzanian Shilling", Symbol: "TSh", Decimals: 2},
+	{Code: "uah", Name: "Hryvnia", Symbol: "₴", Decimals: 2},
+	{Code: "ugx", Name: "Uganda Shilling", Symbol: "USh", Decimals: 0},
+	{Code: "usd", Name: "US Dollar", Symbol: "$", Decimals: 2},
+	{Code: "uyu", Name: "Peso Uruguayo", Symbol: "$", Decimals: 2},
+	{Code: "uzs", Name: "Uzbekistan Sum", Symbol: "so'm", Decimals: 2},
+	{Code: "ved", Name: "Bolívar Soberano", Symbol: "Bs.D", Decimals: 2},
+	{Code: "ves", Name: "Bolívar Soberano", Symbol: "Bs.S", Decimals: 2},
+	{Code: "vnd", Name: "Dong", Symbol: "₫", Decimals: 0},
+	{Code: "vuv", Name: "Vatu", Symbol: "VT", Decimals: 0},
+	{Code: "wst", Name: "Tala", Symbol: "T", Decimals: 2},
+	{Code: "xaf", Name: "CFA Franc BEAC", Symbol: "FCFA", Decimals: 0},
+	{Code: "xcd", Name: "East Caribbean Dollar", Symbol: "$", Decimals: 2},
+	{Code: "xcg", Name: "Caribbean Guilder", Symbol: "Cg", Decimals: 2},
+	{Code: "xof", Name: "CFA Franc BCEAO", Symbol: "CFA", Decimals: 0},
+	{Code: "xpf", Name: "CFP Franc", Symbol: "₣", Decimals: 0},
+	{Code: "yer", Name: "Yemeni Rial", Symbol: "﷼", Decimals: 2},
+	{Code: "zar", Name: "Rand", Symbol: "R", Decimals: 2},
+	{Code: "zmw", Name: "Zambian Kwacha", Symbol: "ZK", Decimals: 2},
+	{Code: "zwg", Name: "Zimbabwe Gold", Symbol: "ZiG", Decimals: 2},
+}
+
+var currenciesByCode = func() map[string]Currency {
+	byCode := make(map[string]Currency, len(currencyCatalog))
+	for _, c := range currencyCatalog {
+		byCode[c.Code] = c
+	}
+	return byCode
+}()
+
+// CurrencyCatalog returns every known currency, sorted by code.
+func CurrencyCatalog() []Currency {
+	return append([]Currency(nil), currencyCatalog...)
+}
+
+// LookupCurrency finds a currency by its ISO 4217 code, in any case.
+func LookupCurrency(code string) (Currency, bool) {
+	c, ok := currenciesByCode[strings.ToLower(strings.TrimSpace(code))]
+	return c, ok
+}
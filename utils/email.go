@@ -1,48 +1,123 @@
 package utils
 
 import (
+	"api/config"
+	"bytes"
 	"context"
-	"crypto/tls"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
 	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
 	"os"
 	"strings"
 	"time"
 )
 
+// EmailMessage is an outgoing email. When HTML is set it is sent alongside
+// Text as multipart/alternative, so text-only clients still get a readable
+// message. Empty From, FromName and ReplyTo fields take the sender's
+// configured EmailIdentity.
+type EmailMessage struct {
+	From        string // Sender address
+	FromName    string // Sender display name
+	ReplyTo     string
+	To          []string
+	Subject     string
+	Text        string // Plain-text body
+	HTML        string // Optional HTML body
+	Attachments []EmailAttachment
+}
+
+// EmailAttachment is a file attached to an EmailMessage.
+type EmailAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"` // Defaults to application/octet-stream
+	Data        []byte `json:"data"`
+}
+
+// EmailIdentity is the default sender of outgoing emails.
+type EmailIdentity struct {
+	Address string
+	Name    string
+	ReplyTo string
+}
+
+// EmailIdentityFromEnv reads EMAIL_FROM_ADDRESS (falling back to EMAIL_FROM
+// and then SMTP_EMAIL), EMAIL_FROM_NAME and EMAIL_REPLY_TO.
+func EmailIdentityFromEnv() EmailIdentity {
+	id := EmailIdentity{
+		Address: os.Getenv("EMAIL_FROM_ADDRESS"),
+		Name:    os.Getenv("EMAIL_FROM_NAME"),
+		ReplyTo: os.Getenv("EMAIL_REPLY_TO"),
+	}
+	if id.Address == "" {
+		id.Address = os.Getenv("EMAIL_FROM")
+	}
+	if id.Address == "" {
+		id.Address = config.Get().SMTP.Email
+	}
+	return id
+}
+
+// apply fills in the sender fields msg leaves empty.
+func (id EmailIdentity) apply(msg EmailMessage) EmailMessage {
+	if msg.From == "" {
+		msg.From = id.Address
+		if msg.FromName == "" {
+			msg.FromName = id.Name
+		}
+	}
+	if msg.ReplyTo == "" {
+		msg.ReplyTo = id.ReplyTo
+	}
+	return msg
+}
+
+// fromHeader formats the sender as a From header value, encoding the display
+// name when it is not plain ASCII.
+func (msg EmailMessage) fromHeader() string {
+	return (&mail.Address{Name: msg.FromName, Address: msg.From}).String()
+}
+
 // EmailSender defines the behaviour for sending emails. Implementations should
-// be safe for concurrent use by multiple goroutines.
+// be safe for concurrent use by multiple goroutines. Send returns the
+// provider's ID for the accepted message, or "" if it does not report one.
 type EmailSender interface {
-	Send(ctx context.Context, to []string, subject, body string) error
+	Send(ctx context.Context, msg EmailMessage) (messageID string, err error)
 }
 
 // SMTPClient sends emails using an SMTP server. It reads configuration from
 // environment variables: SMTP_HOST, SMTP_PORT, SMTP_EMAIL, SMTP_PASSWORD.
 // If not provided, sensible defaults are used (smtp.gmail.com:587).
+// Setting SMTP_POOL_SIZE keeps authenticated connections open for reuse.
+// Mail is sent from EmailIdentityFromEnv, which defaults to SMTP_EMAIL.
 type SMTPClient struct {
 	host     string
 	port     string
-	email    string
+	email    string // Login username
 	password string
+	identity EmailIdentity
 	auth     smtp.Auth
 	addr     string
 	timeout  time.Duration
-	useTLS   bool // whether to use implicit TLS (port 465)
+	useTLS   bool        // whether to use implicit TLS (port 465)
+	pool     *smtpPool   // nil dials a new connection per message
+	dkim     *DKIMSigner // nil sends unsigned mail
 }
 
 // NewSMTPClient builds an SMTPClient from environment variables.
 func NewSMTPClient() *SMTPClient {
-	host := os.Getenv("SMTP_HOST")
-	if host == "" {
-		host = "smtp.gmail.com"
-	}
-	port := os.Getenv("SMTP_PORT")
-	if port == "" {
-		port = "587"
-	}
-	email := os.Getenv("SMTP_EMAIL")
-	password := os.Getenv("SMTP_PASSWORD")
+	smtpConfig := config.Get().SMTP
+	host, port := smtpConfig.Host, smtpConfig.Port
+	email, password := smtpConfig.Email, smtpConfig.Password
 
 	auth := smtp.PlainAuth("", email, password, host)
 	useTLS := port == "465"
@@ -52,108 +127,196 @@ func NewSMTPClient() *SMTPClient {
 		port:     port,
 		email:    email,
 		password: password,
+		identity: EmailIdentityFromEnv(),
 		auth:     auth,
 		addr:     net.JoinHostPort(host, port),
 		timeout:  10 * time.Second,
 		useTLS:   useTLS,
+		pool:     newSMTPPoolFromEnv(),
 	}
 }
 
-// Send composes and sends a plain-text email to one or more recipients.
-// It validates inputs and returns detailed errors for hard failures.
-func (s *SMTPClient) Send(ctx context.Context, to []string, subject, body string) error {
+// Send composes and sends an email to one or more recipients, as plain text
+// or multipart/alternative when msg.HTML is set. It validates inputs and
+// returns detailed errors for hard failures. The returned ID is the
+// Message-ID header generated for the message.
+func (s *SMTPClient) Send(ctx context.Context, msg EmailMessage) (string, error) {
+	to := msg.To
 	if len(to) == 0 {
-		return fmt.Errorf("no recipients provided")
+		return "", fmt.Errorf("no recipients provided")
 	}
-	if s.email == "" {
-		return fmt.Errorf("sender email (SMTP_EMAIL) is not configured")
+	msg = s.identity.apply(msg)
+	if msg.From == "" {
+		return "", fmt.Errorf("sender email (EMAIL_FROM_ADDRESS or SMTP_EMAIL) is not configured")
 	}
 
-	header := make(map[string]string)
-	header["From"] = s.email
-	header["To"] = strings.Join(to, ", ")
-	header["Subject"] = subject
-	header["MIME-Version"] = "1.0"
-	header["Content-Type"] = "text/plain; charset=\"utf-8\""
+	messageID := newMessageID(msg.From)
+	data, err := buildMIMEMessage(messageID, msg)
+	if err != nil {
+		return "", fmt.Errorf("build message: %w", err)
+	}
+	if s.dkim != nil {
+		if data, err = s.dkim.Sign(data); err != nil {
+			return "", err
+		}
+	}
 
-	var msg strings.Builder
-	for k, v := range header {
-		msg.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
+	conn, err := s.acquire(ctx)
+	if err != nil {
+		return "", err
 	}
-	msg.WriteString("\r\n")
-	msg.WriteString(body)
 
-	// Dial the SMTP server with a timeout
-	var conn net.Conn
-	var err error
-	d := net.Dialer{Timeout: s.timeout}
+	err = conn.send(ctx, msg.From, to, data)
+	s.release(conn, err)
+	if err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
 
-	if s.useTLS {
-		// implicit TLS (port 465)
-		tlsConfig := &tls.Config{ServerName: s.host}
-		conn, err = tls.DialWithDialer(&d, "tcp", s.addr, tlsConfig)
-		if err != nil {
-			return fmt.Errorf("tls dial: %w", err)
-		}
-	} else {
-		conn, err = d.DialContext(ctx, "tcp", s.addr)
-		if err != nil {
-			return fmt.Errorf("dial smtp: %w", err)
-		}
+// newMessageID returns a unique Message-ID in the sender's domain.
+func newMessageID(from string) string {
+	domain := "localhost"
+	if _, d, ok := strings.Cut(from, "@"); ok && d != "" {
+		domain = d
 	}
 
-	c, err := smtp.NewClient(conn, s.host)
-	if err != nil {
-		return fmt.Errorf("smtp client: %w", err)
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().Unix(), hex.EncodeToString(b), domain)
+}
+
+// buildMIMEMessage renders msg as an RFC 5322 message. Bodies are
+// quoted-printable encoded so long lines and non-ASCII text survive transit.
+// With attachments the body is wrapped in multipart/mixed.
+func buildMIMEMessage(messageID string, msg EmailMessage) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeHeader := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+	writeHeader("From", msg.fromHeader())
+	if msg.ReplyTo != "" {
+		writeHeader("Reply-To", msg.ReplyTo)
 	}
+	writeHeader("To", strings.Join(msg.To, ", "))
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	writeHeader("Date", time.Now().Format(time.RFC1123Z))
+	writeHeader("Message-ID", messageID)
+	writeHeader("MIME-Version", "1.0")
 
-	// If using STARTTLS (typically port 587), upgrade the connection.
-	if !s.useTLS {
-		tlsConfig := &tls.Config{ServerName: s.host}
-		if ok, _ := c.Extension("STARTTLS"); ok {
-			if err = c.StartTLS(tlsConfig); err != nil {
-				c.Close()
-				return fmt.Errorf("starttls: %w", err)
-			}
+	entity := bodyEntity(msg)
+	if len(msg.Attachments) > 0 {
+		parts := []mimeEntity{entity}
+		for _, a := range msg.Attachments {
+			parts = append(parts, attachmentEntity(a))
 		}
+		entity = multipartEntity("mixed", parts)
 	}
 
-	// Authenticate if credentials are present
-	if s.email != "" && s.password != "" {
-		if err = c.Auth(s.auth); err != nil {
-			c.Close()
-			return fmt.Errorf("auth: %w", err)
-		}
+	writeHeader("Content-Type", entity.header.Get("Content-Type"))
+	if encoding := entity.header.Get("Content-Transfer-Encoding"); encoding != "" {
+		writeHeader("Content-Transfer-Encoding", encoding)
+	}
+	buf.WriteString("\r\n")
+	if err := entity.write(&buf); err != nil {
+		return nil, err
 	}
 
-	if err = c.Mail(s.email); err != nil {
-		c.Close()
-		return fmt.Errorf("mail from: %w", err)
+	return buf.Bytes(), nil
+}
+
+// mimeEntity is a MIME part: its headers and a function writing its body.
+type mimeEntity struct {
+	header textproto.MIMEHeader
+	write  func(io.Writer) error
+}
+
+// bodyEntity is the plain-text body, or multipart/alternative when msg.HTML
+// is set.
+func bodyEntity(msg EmailMessage) mimeEntity {
+	text := textEntity(`text/plain; charset="utf-8"`, msg.Text)
+	if msg.HTML == "" {
+		return text
 	}
-	for _, rcpt := range to {
-		if err = c.Rcpt(rcpt); err != nil {
-			c.Close()
-			return fmt.Errorf("rcpt %s: %w", rcpt, err)
-		}
+	// Clients show the last part they understand, so HTML goes last
+	return multipartEntity("alternative", []mimeEntity{text, textEntity(`text/html; charset="utf-8"`, msg.HTML)})
+}
+
+func textEntity(contentType, body string) mimeEntity {
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		write: func(w io.Writer) error { return writeQuotedPrintable(w, body) },
 	}
+}
 
-	w, err := c.Data()
-	if err != nil {
-		c.Close()
-		return fmt.Errorf("data: %w", err)
+func attachmentEntity(a EmailAttachment) mimeEntity {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
 	}
-	if _, err = w.Write([]byte(msg.String())); err != nil {
-		w.Close()
-		c.Close()
-		return fmt.Errorf("write message: %w", err)
+
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		},
+		write: func(w io.Writer) error {
+			// Base64 lines must not exceed 76 characters
+			encoded := base64.StdEncoding.EncodeToString(a.Data)
+			for len(encoded) > 76 {
+				if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+					return err
+				}
+				encoded = encoded[76:]
+			}
+			_, err := io.WriteString(w, encoded+"\r\n")
+			return err
+		},
 	}
-	if err = w.Close(); err != nil {
-		c.Close()
-		return fmt.Errorf("close write: %w", err)
+}
+
+func multipartEntity(subtype string, parts []mimeEntity) mimeEntity {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type": {fmt.Sprintf("multipart/%s; boundary=%q", subtype, boundary)},
+		},
+		write: func(w io.Writer) error {
+			mw := multipart.NewWriter(w)
+			if err := mw.SetBoundary(boundary); err != nil {
+				return err
+			}
+			for _, part := range parts {
+				pw, err := mw.CreatePart(part.header)
+				if err != nil {
+					return err
+				}
+				if err := part.write(pw); err != nil {
+					return err
+				}
+			}
+			return mw.Close()
+		},
 	}
+}
 
-	if err = c.Quit(); err != nil {
-		return fmt.Errorf("quit: %w", err)
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(body)); err != nil {
+		return err
 	}
-	return nil
+	return qp.Close()
+}
+
+// NormalizeEmail returns the canonical form used to store and look up email
+// addresses: trimmed and lower-cased. Lookups against existing rows should
+// compare with lower(email) so addresses stored before normalization match.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
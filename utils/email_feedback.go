@@ -0,0 +1,300 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email feedback types reported by providers.
+const (
+	EmailFeedbackBounce    = "bounce"
+	EmailFeedbackComplaint = "complaint"
+)
+
+// EmailFeedback is a permanent bounce or spam complaint for one address.
+type EmailFeedback struct {
+	Email  string
+	Type   string // EmailFeedbackBounce or EmailFeedbackComplaint
+	Detail string // Provider diagnostic, e.g. the SMTP response
+}
+
+// SNSMessage is an Amazon SNS HTTP(S) delivery, used by SES to publish
+// bounce and complaint notifications.
+type SNSMessage struct {
+	Type             string `json:"Type"`
+	MessageID        string `json:"MessageId"`
+	Token            string `json:"Token"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject"`
+	Message          string `json:"Message"`
+	SubscribeURL     string `json:"SubscribeURL"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+}
+
+var (
+	snsHostPattern = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+	snsCerts       sync.Map // SigningCertURL -> *x509.Certificate
+	snsClient      = &http.Client{Timeout: 10 * time.Second}
+)
+
+// VerifySNSMessage checks the message signature against the AWS signing
+// certificate, which must be served over HTTPS from an SNS host.
+func VerifySNSMessage(ctx context.Context, msg *SNSMessage) error {
+	cert, err := snsCertificate(ctx, msg.SigningCertURL)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil {
+		return fmt.Errorf("sns: malformed signature: %w", err)
+	}
+
+	var hash crypto.Hash
+	var digest []byte
+	canonical := []byte(msg.canonicalString())
+	switch msg.SignatureVersion {
+	case "1":
+		sum := sha1.Sum(canonical)
+		hash, digest = crypto.SHA1, sum[:]
+	case "2":
+		sum := sha256.Sum256(canonical)
+		hash, digest = crypto.SHA256, sum[:]
+	default:
+		return fmt.Errorf("sns: unsupported signature version %q", msg.SignatureVersion)
+	}
+
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("sns: signing certificate does not hold an RSA key")
+	}
+	if err := rsa.VerifyPKCS1v15(key, hash, digest, signature); err != nil {
+		return errors.New("sns: invalid signature")
+	}
+	return nil
+}
+
+// canonicalString builds the string SNS signs, which depends on the type.
+func (m *SNSMessage) canonicalString() string {
+	fields := [][2]string{{"Message", m.Message}, {"MessageId", m.MessageID}}
+	if m.Type == "Notification" {
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+		fields = append(fields, [2]string{"Timestamp", m.Timestamp}, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+	} else {
+		fields = append(fields,
+			[2]string{"SubscribeURL", m.SubscribeURL},
+			[2]string{"Timestamp", m.Timestamp},
+			[2]string{"Token", m.Token},
+			[2]string{"TopicArn", m.TopicArn},
+			[2]string{"Type", m.Type},
+		)
+	}
+
+	var b strings.Builder
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+func snsCertificate(ctx context.Context, certURL string) (*x509.Certificate, error) {
+	if cached, ok := snsCerts.Load(certURL); ok {
+		return cached.(*x509.Certificate), nil
+	}
+
+	if err := checkSNSURL(certURL); err != nil {
+		return nil, err
+	}
+
+	body, err := snsGet(ctx, certURL)
+	if err != nil {
+		return nil, fmt.Errorf("sns: fetch signing certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("sns: signing certificate is not PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("sns: parse signing certificate: %w", err)
+	}
+
+	snsCerts.Store(certURL, cert)
+	return cert, nil
+}
+
+// ConfirmSNSSubscription visits the SubscribeURL of a verified
+// SubscriptionConfirmation message so SNS starts delivering notifications.
+func ConfirmSNSSubscription(ctx context.Context, msg *SNSMessage) error {
+	if err := checkSNSURL(msg.SubscribeURL); err != nil {
+		return err
+	}
+	_, err := snsGet(ctx, msg.SubscribeURL)
+	return err
+}
+
+// checkSNSURL rejects URLs that are not HTTPS on an SNS host, so a forged
+// message cannot point us at an attacker-controlled server.
+func checkSNSURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || !snsHostPattern.MatchString(u.Hostname()) {
+		return fmt.Errorf("sns: untrusted URL %q", raw)
+	}
+	return nil
+}
+
+func snsGet(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := snsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// ParseSESNotification extracts permanent bounces and complaints from an
+// SES notification. Both SNS notifications ("notificationType") and
+// configuration set event publishing ("eventType") are understood;
+// transient bounces and other event types yield no feedback.
+func ParseSESNotification(message string) ([]EmailFeedback, error) {
+	var n struct {
+		NotificationType string `json:"notificationType"`
+		EventType        string `json:"eventType"`
+		Bounce           struct {
+			BounceType        string `json:"bounceType"`
+			BounceSubType     string `json:"bounceSubType"`
+			BouncedRecipients []struct {
+				EmailAddress   string `json:"emailAddress"`
+				DiagnosticCode string `json:"diagnosticCode"`
+			} `json:"bouncedRecipients"`
+		} `json:"bounce"`
+		Complaint struct {
+			ComplaintFeedbackType string `json:"complaintFeedbackType"`
+			ComplainedRecipients  []struct {
+				EmailAddress string `json:"emailAddress"`
+			} `json:"complainedRecipients"`
+		} `json:"complaint"`
+	}
+	if err := json.Unmarshal([]byte(message), &n); err != nil {
+		return nil, fmt.Errorf("ses: malformed notification: %w", err)
+	}
+
+	kind := n.NotificationType
+	if kind == "" {
+		kind = n.EventType
+	}
+
+	var feedback []EmailFeedback
+	switch kind {
+	case "Bounce":
+		if n.Bounce.BounceType != "Permanent" {
+			return nil, nil
+		}
+		for _, r := range n.Bounce.BouncedRecipients {
+			detail := r.DiagnosticCode
+			if detail == "" {
+				detail = n.Bounce.BounceSubType
+			}
+			feedback = append(feedback, EmailFeedback{Email: r.EmailAddress, Type: EmailFeedbackBounce, Detail: detail})
+		}
+	case "Complaint":
+		for _, r := range n.Complaint.ComplainedRecipients {
+			feedback = append(feedback, EmailFeedback{Email: r.EmailAddress, Type: EmailFeedbackComplaint, Detail: n.Complaint.ComplaintFeedbackType})
+		}
+	}
+	return feedback, nil
+}
+
+// ParseSendGridPublicKey parses the base64 verification key shown in the
+// SendGrid signed Event Webhook settings.
+func ParseSendGridPublicKey(encoded string) (*ecdsa.PublicKey, error) {
+	der, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("sendgrid webhook key: %w", err)
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("sendgrid webhook key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("sendgrid webhook key is not an ECDSA key")
+	}
+	return ecKey, nil
+}
+
+// VerifySendGridSignature checks a signed Event Webhook request: the
+// signature covers the timestamp header followed by the raw body.
+func VerifySendGridSignature(key *ecdsa.PublicKey, signature, timestamp string, body []byte) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return errors.New("sendgrid: malformed signature")
+	}
+
+	digest := sha256.Sum256(append([]byte(timestamp), body...))
+	if !ecdsa.VerifyASN1(key, digest[:], sig) {
+		return errors.New("sendgrid: invalid signature")
+	}
+	return nil
+}
+
+// ParseSendGridEvents extracts hard bounces and spam reports from an Event
+// Webhook batch. Blocks (temporary rejections) are ignored.
+func ParseSendGridEvents(body []byte) ([]EmailFeedback, error) {
+	var events []struct {
+		Email  string `json:"email"`
+		Event  string `json:"event"`
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("sendgrid: malformed events: %w", err)
+	}
+
+	var feedback []EmailFeedback
+	for _, e := range events {
+		switch {
+		case e.Event == "bounce" && e.Type != "blocked":
+			feedback = append(feedback, EmailFeedback{Email: e.Email, Type: EmailFeedbackBounce, Detail: e.Reason})
+		case e.Event == "spamreport":
+			feedback = append(feedback, EmailFeedback{Email: e.Email, Type: EmailFeedbackComplaint})
+		}
+	}
+	return feedback, nil
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogSender is a dry-run EmailSender for local development and CI. It writes
+// each message to the log, or to EMAIL_LOG_DIR as an .eml file that mail
+// clients can open, instead of sending it.
+type LogSender struct {
+	identity EmailIdentity
+	dir      string // Empty logs messages instead of writing files
+}
+
+// NewLogSender builds a LogSender from EMAIL_LOG_DIR, creating the directory
+// if needed.
+func NewLogSender() (*LogSender, error) {
+	dir := os.Getenv("EMAIL_LOG_DIR")
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("email log dir: %w", err)
+		}
+	}
+
+	identity := EmailIdentityFromEnv()
+	if identity.Address == "" {
+		identity.Address = "no-reply@localhost"
+	}
+	return &LogSender{identity: identity, dir: dir}, nil
+}
+
+// Send records the message and returns a generated Message-ID.
+func (s *LogSender) Send(ctx context.Context, msg EmailMessage) (string, error) {
+	if len(msg.To) == 0 {
+		return "", fmt.Errorf("no recipients provided")
+	}
+	msg = s.identity.apply(msg)
+	messageID := newMessageID(msg.From)
+
+	if s.dir == "" {
+		var b strings.Builder
+		fmt.Fprintf(&b, "email (log mode) %s\nFrom: %s\nTo: %s\nSubject: %s\n",
+			messageID, msg.fromHeader(), strings.Join(msg.To, ", "), msg.Subject)
+		for _, a := range msg.Attachments {
+			fmt.Fprintf(&b, "Attachment: %s (%d bytes)\n", a.Filename, len(a.Data))
+		}
+		b.WriteString("\n" + msg.Text)
+		log.Print(b.String())
+		return messageID, nil
+	}
+
+	data, err := buildMIMEMessage(messageID, msg)
+	if err != nil {
+		return "", err
+	}
+	// Bodies carry live tokens, so keep the files private
+	name := filepath.Join(s.dir, time.Now().UTC().Format("20060102T150405.000000000")+".eml")
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return "", fmt.Errorf("write email: %w", err)
+	}
+	return messageID, nil
+}
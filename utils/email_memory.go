@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CapturedEmail is a message kept by a MemorySender.
+type CapturedEmail struct {
+	MessageID string    `json:"message_id"`
+	From      string    `json:"from"`
+	To        []string  `json:"to"`
+	Subject   string    `json:"subject"`
+	Text      string    `json:"text"`
+	HTML      string    `json:"html,omitempty"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// MemorySender is a fake EmailSender for demos and tests. It keeps the
+// newest messages in memory, so links in them can be followed without a
+// mail server, and also logs them like a LogSender.
+type MemorySender struct {
+	log   *LogSender
+	limit int
+
+	mu       sync.Mutex
+	messages []CapturedEmail // Oldest first
+}
+
+// NewMemorySender returns a MemorySender keeping up to limit messages.
+func NewMemorySender(limit int) *MemorySender {
+	identity := EmailIdentityFromEnv()
+	if identity.Address == "" {
+		identity.Address = "no-reply@localhost"
+	}
+	return &MemorySender{log: &LogSender{identity: identity}, limit: limit}
+}
+
+// Send records the message and returns a generated Message-ID.
+func (s *MemorySender) Send(ctx context.Context, msg EmailMessage) (string, error) {
+	if len(msg.To) == 0 {
+		return "", fmt.Errorf("no recipients provided")
+	}
+	messageID, err := s.log.Send(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+
+	msg = s.log.identity.apply(msg)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, CapturedEmail{
+		MessageID: messageID,
+		From:      msg.fromHeader(),
+		To:        msg.To,
+		Subject:   msg.Subject,
+		Text:      msg.Text,
+		HTML:      msg.HTML,
+		SentAt:    time.Now().UTC(),
+	})
+	if len(s.messages) > s.limit {
+		s.messages = s.messages[len(s.messages)-s.limit:]
+	}
+	return messageID, nil
+}
+
+// Messages returns the kept messages, newest first.
+func (s *MemorySender) Messages() []CapturedEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make([]CapturedEmail, len(s.messages))
+	for i, msg := range s.messages {
+		messages[len(s.messages)-1-i] = msg
+	}
+	return messages
+}
@@ -0,0 +1,392 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// NewEmailSender builds the EmailSender selected by EMAIL_PROVIDER: smtp
+// (default), sendgrid, ses or mailgun. All send from EmailIdentityFromEnv.
+// SMTP mail is DKIM-signed when DKIM_PRIVATE_KEY or DKIM_PRIVATE_KEY_FILE
+// is set. EMAIL_MODE=log replaces the provider with a LogSender that never
+// sends.
+func NewEmailSender() (EmailSender, error) {
+	switch mode := strings.ToLower(os.Getenv("EMAIL_MODE")); mode {
+	case "", "send":
+	case "log":
+		return NewLogSender()
+	default:
+		return nil, fmt.Errorf("unsupported EMAIL_MODE %q", mode)
+	}
+
+	provider := strings.ToLower(os.Getenv("EMAIL_PROVIDER"))
+	if provider == "" || provider == "smtp" {
+		client := NewSMTPClient()
+		signer, err := NewDKIMSignerFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		client.dkim = signer
+		return client, nil
+	}
+
+	from := EmailIdentityFromEnv()
+	if from.Address == "" {
+		return nil, errors.New("EMAIL_FROM_ADDRESS is not configured")
+	}
+
+	switch provider {
+	case "sendgrid":
+		return NewSendGridSender(from)
+	case "ses":
+		return NewSESSender(from)
+	case "mailgun":
+		return NewMailgunSender(from)
+	default:
+		return nil, fmt.Errorf("unsupported EMAIL_PROVIDER %q", provider)
+	}
+}
+
+// SendGridSender sends emails through the SendGrid v3 Mail Send API.
+type SendGridSender struct {
+	from     EmailIdentity
+	apiKey   string
+	endpoint string
+	client   *http.Client
+}
+
+// NewSendGridSender builds a SendGridSender using SENDGRID_API_KEY.
+func NewSendGridSender(from EmailIdentity) (*SendGridSender, error) {
+	apiKey := os.Getenv("SENDGRID_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("SENDGRID_API_KEY is required for the sendgrid provider")
+	}
+
+	return &SendGridSender{
+		from:     from,
+		apiKey:   apiKey,
+		endpoint: "https://api.sendgrid.com/v3/mail/send",
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Send implements EmailSender.
+func (s *SendGridSender) Send(ctx context.Context, msg EmailMessage) (string, error) {
+	if len(msg.To) == 0 {
+		return "", fmt.Errorf("no recipients provided")
+	}
+	msg = s.from.apply(msg)
+
+	type address struct {
+		Email string `json:"email"`
+		Name  string `json:"name,omitempty"`
+	}
+	recipients := make([]address, len(msg.To))
+	for i, addr := range msg.To {
+		recipients[i] = address{Email: addr}
+	}
+
+	// SendGrid requires text/plain to come before text/html
+	content := []map[string]string{{"type": "text/plain", "value": msg.Text}}
+	if msg.HTML != "" {
+		content = append(content, map[string]string{"type": "text/html", "value": msg.HTML})
+	}
+
+	input := map[string]any{
+		"personalizations": []map[string]any{{"to": recipients}},
+		"from":             address{Email: msg.From, Name: msg.FromName},
+		"subject":          msg.Subject,
+		"content":          content,
+	}
+	if msg.ReplyTo != "" {
+		input["reply_to"] = address{Email: msg.ReplyTo}
+	}
+	if len(msg.Attachments) > 0 {
+		attachments := make([]map[string]string, len(msg.Attachments))
+		for i, a := range msg.Attachments {
+			attachments[i] = map[string]string{
+				"content":     base64.StdEncoding.EncodeToString(a.Data),
+				"filename":    a.Filename,
+				"disposition": "attachment",
+			}
+			if a.ContentType != "" {
+				attachments[i]["type"] = a.ContentType
+			}
+		}
+		input["attachments"] = attachments
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("build sendgrid request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	header, _, err := doEmailRequest(s.client, req, "sendgrid")
+	if err != nil {
+		return "", err
+	}
+	return header.Get("X-Message-Id"), nil
+}
+
+// SESSender sends emails through the Amazon SES v2 SendEmail API. Requests
+// are signed with SigV4 using static credentials from the standard AWS_*
+// environment variables.
+type SESSender struct {
+	from             EmailIdentity
+	region           string
+	endpoint         string
+	configurationSet string
+	creds            awsCredentials
+	client           *http.Client
+}
+
+// NewSESSender builds an SESSender from environment variables: AWS_REGION
+// (or SES_REGION), AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and optionally
+// AWS_SESSION_TOKEN, SES_CONFIGURATION_SET and SES_ENDPOINT.
+func NewSESSender(from EmailIdentity) (*SESSender, error) {
+	s := &SESSender{
+		from:             from,
+		region:           os.Getenv("SES_REGION"),
+		endpoint:         os.Getenv("SES_ENDPOINT"),
+		configurationSet: os.Getenv("SES_CONFIGURATION_SET"),
+		creds: awsCredentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if s.region == "" {
+		s.region = os.Getenv("AWS_REGION")
+	}
+	if s.region == "" {
+		return nil, errors.New("AWS_REGION or SES_REGION is required for the ses provider")
+	}
+	if s.creds.accessKey == "" || s.creds.secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the ses provider")
+	}
+	if s.endpoint == "" {
+		s.endpoint = fmt.Sprintf("https://email.%s.amazonaws.com", s.region)
+	}
+	s.endpoint = strings.TrimSuffix(s.endpoint, "/") + "/v2/email/outbound-emails"
+
+	return s, nil
+}
+
+// Send implements EmailSender.
+func (s *SESSender) Send(ctx context.Context, msg EmailMessage) (string, error) {
+	if len(msg.To) == 0 {
+		return "", fmt.Errorf("no recipients provided")
+	}
+	msg = s.from.apply(msg)
+
+	input := map[string]any{
+		"FromEmailAddress": msg.fromHeader(),
+		"Destination":      map[string]any{"ToAddresses": msg.To},
+	}
+	if len(msg.Attachments) > 0 {
+		// Simple content has no attachments, so send the full MIME message
+		raw, err := buildMIMEMessage(newMessageID(msg.From), msg)
+		if err != nil {
+			return "", fmt.Errorf("build message: %w", err)
+		}
+		input["Content"] = map[string]any{
+			"Raw": map[string]string{"Data": base64.StdEncoding.EncodeToString(raw)},
+		}
+	} else {
+		body := map[string]any{
+			"Text": map[string]string{"Data": msg.Text, "Charset": "UTF-8"},
+		}
+		if msg.HTML != "" {
+			body["Html"] = map[string]string{"Data": msg.HTML, "Charset": "UTF-8"}
+		}
+
+		input["Content"] = map[string]any{
+			"Simple": map[string]any{
+				"Subject": map[string]string{"Data": msg.Subject, "Charset": "UTF-8"},
+				"Body":    body,
+			},
+		}
+		if msg.ReplyTo != "" {
+			input["ReplyToAddresses"] = []string{msg.ReplyTo}
+		}
+	}
+	if s.configurationSet != "" {
+		input["ConfigurationSetName"] = s.configurationSet
+	}
+
+	payload, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("build ses request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signAWSRequest(req, payload, time.Now().UTC(), s.creds, s.region, "ses")
+
+	_, resp, err := doEmailRequest(s.client, req, "ses")
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		MessageID string `json:"MessageId"`
+	}
+	json.Unmarshal(resp, &out)
+	return out.MessageID, nil
+}
+
+// MailgunSender sends emails through the Mailgun Messages API.
+type MailgunSender struct {
+	from     EmailIdentity
+	apiKey   string
+	endpoint string
+	client   *http.Client
+}
+
+// NewMailgunSender builds a MailgunSender from environment variables:
+// MAILGUN_API_KEY, MAILGUN_DOMAIN and optionally MAILGUN_API_BASE (default
+// https://api.mailgun.net; use https://api.eu.mailgun.net for EU domains).
+func NewMailgunSender(from EmailIdentity) (*MailgunSender, error) {
+	apiKey := os.Getenv("MAILGUN_API_KEY")
+	domain := os.Getenv("MAILGUN_DOMAIN")
+	if apiKey == "" || domain == "" {
+		return nil, errors.New("MAILGUN_API_KEY and MAILGUN_DOMAIN are required for the mailgun provider")
+	}
+
+	base := os.Getenv("MAILGUN_API_BASE")
+	if base == "" {
+		base = "https://api.mailgun.net"
+	}
+
+	return &MailgunSender{
+		from:     from,
+		apiKey:   apiKey,
+		endpoint: strings.TrimSuffix(base, "/") + "/v3/" + url.PathEscape(domain) + "/messages",
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Send implements EmailSender.
+func (s *MailgunSender) Send(ctx context.Context, msg EmailMessage) (string, error) {
+	if len(msg.To) == 0 {
+		return "", fmt.Errorf("no recipients provided")
+	}
+	msg = s.from.apply(msg)
+
+	form := url.Values{}
+	form.Set("from", msg.fromHeader())
+	if msg.ReplyTo != "" {
+		form.Set("h:Reply-To", msg.ReplyTo)
+	}
+	for _, addr := range msg.To {
+		form.Add("to", addr)
+	}
+	form.Set("subject", msg.Subject)
+	form.Set("text", msg.Text)
+	if msg.HTML != "" {
+		form.Set("html", msg.HTML)
+	}
+
+	body, contentType, err := mailgunBody(form, msg.Attachments)
+	if err != nil {
+		return "", fmt.Errorf("build mailgun request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, body)
+	if err != nil {
+		return "", fmt.Errorf("build mailgun request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.SetBasicAuth("api", s.apiKey)
+
+	_, resp, err := doEmailRequest(s.client, req, "mailgun")
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(resp, &out)
+	return out.ID, nil
+}
+
+// mailgunBody encodes the form as urlencoded, or as multipart/form-data with
+// one "attachment" file per attachment.
+func mailgunBody(form url.Values, attachments []EmailAttachment) (io.Reader, string, error) {
+	if len(attachments) == 0 {
+		return strings.NewReader(form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for key, values := range form {
+		for _, value := range values {
+			if err := mw.WriteField(key, value); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for _, a := range attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": {mime.FormatMediaType("form-data", map[string]string{"name": "attachment", "filename": a.Filename})},
+			"Content-Type":        {contentType},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := w.Write(a.Data); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, mw.FormDataContentType(), nil
+}
+
+// doEmailRequest sends an email API request and returns the response headers
+// and body. Non-2xx responses become errors that include the provider's
+// message.
+func doEmailRequest(client *http.Client, req *http.Request, provider string) (http.Header, []byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("%s returned status %d: %s", provider, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp.Header, body, nil
+}
@@ -0,0 +1,432 @@
+package utils
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/email/*.html templates/email/*.txt templates/email/locales/*.json
+var embeddedEmailTemplates embed.FS
+
+// Email template names. Each has a <name>.html file defining "title" and
+// "content" blocks, rendered inside layout.html, and a <name>.txt file
+// defining "subject" and "content" blocks, rendered inside layout.txt.
+// Text comes from the locale bundles through the "t" function.
+const (
+	EmailTemplateWelcome              = "welcome"
+	EmailTemplatePasswordReset        = "password_reset"
+	EmailTemplatePasswordChanged      = "password_changed"
+	EmailTemplateOAuthLinked          = "oauth_linked"
+	EmailTemplateOAuthUnlinked        = "oauth_unlinked"
+	EmailTemplateVerifyEmail          = "verify_email"
+	EmailTemplateAccountLocked        = "account_locked"
+	EmailTemplateLoginChallenge       = "login_challenge"
+	EmailTemplatePasswordSetChallenge = "password_set_challenge"
+	EmailTemplateImpossibleTravel     = "impossible_travel"
+	EmailTemplateInvitation           = "invitation"
+)
+
+// ErrUnknownEmailTemplate is returned for template names that do not exist.
+var ErrUnknownEmailTemplate = errors.New("unknown email template")
+
+// DefaultEmailLocale is used for users without a locale and for keys missing
+// from a locale bundle.
+const DefaultEmailLocale = "en"
+
+// WelcomeEmail is the data for the welcome email template. VerifyURL is
+// omitted from the email when empty.
+type WelcomeEmail struct {
+	Username  string
+	VerifyURL string
+}
+
+// VerifyEmail is the data for the email verification template.
+type VerifyEmail struct {
+	VerifyURL string
+	ExpiresIn time.Duration
+}
+
+// PasswordResetEmail is the data for the password reset email template.
+type PasswordResetEmail struct {
+	ResetURL  string
+	ExpiresIn time.Duration
+}
+
+// PasswordChangedEmail is the data for the password changed notice.
+// SecureURL revokes every session if the user did not make the change.
+type PasswordChangedEmail struct {
+	IPAddress string
+	ChangedAt string
+	SecureURL string
+}
+
+// OAuthLinkEmail is the data for the OAuth linked and unlinked notices.
+type OAuthLinkEmail struct {
+	Provider  string // Display name, e.g. "GitHub"
+	IPAddress string
+	ChangedAt string
+}
+
+// AccountLockedEmail is the data for the account locked email template.
+type AccountLockedEmail struct {
+	UnlockURL   string
+	LockedUntil string
+}
+
+// LoginChallengeEmail is the data for the login verification code template.
+type LoginChallengeEmail struct {
+	IPAddress string
+	Code      string
+	ExpiresIn time.Duration
+}
+
+// PasswordSetChallengeEmail is the data for the code confirming that a
+// password is added to an OAuth-only account.
+type PasswordSetChallengeEmail struct {
+	IPAddress string
+	Code      string
+	ExpiresIn time.Duration
+}
+
+// ImpossibleTravelEmail is the data for the impossible travel alert
+// template. Code is empty when no step-up verification is required.
+type ImpossibleTravelEmail struct {
+	Location         string
+	PreviousLocation string
+	IPAddress        string
+	Code             string
+	ExpiresIn        time.Duration
+}
+
+// InvitationEmail is the data for the invitation email template.
+type InvitationEmail struct {
+	InviterName string
+	InviteURL   string
+	ExpiresIn   time.Duration
+}
+
+// emailTemplate is the parsed HTML and plain-text variant of one email.
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// localeBundle maps message keys to fmt format strings.
+type localeBundle map[string]string
+
+// emailTemplateSet is everything loaded by InitEmailTemplates.
+type emailTemplateSet struct {
+	templates map[string]emailTemplate
+	locales   map[string]localeBundle
+}
+
+var (
+	emailTemplatesMu sync.Mutex
+	emailTemplates   *emailTemplateSet
+)
+
+// placeholderEmailFuncs declares the template functions at parse time; they
+// are rebound to the recipient's locale on every render.
+var placeholderEmailFuncs = map[string]any{
+	"t":        func(string, ...any) (string, error) { return "", nil },
+	"duration": func(time.Duration) string { return "" },
+	"locale":   func() string { return "" },
+}
+
+// InitEmailTemplates parses the email templates and locale bundles. Files in
+// EMAIL_TEMPLATE_DIR (bundles in its locales/ subdirectory) replace the
+// embedded file of the same name, so deployments can rebrand or reword
+// emails without rebuilding. Calling it again reloads everything.
+func InitEmailTemplates() error {
+	set, err := loadEmailTemplates(os.Getenv("EMAIL_TEMPLATE_DIR"))
+	if err != nil {
+		return err
+	}
+
+	emailTemplatesMu.Lock()
+	emailTemplates = set
+	emailTemplatesMu.Unlock()
+	return nil
+}
+
+func loadEmailTemplates(overrideDir string) (*emailTemplateSet, error) {
+	embedded, err := fs.Sub(embeddedEmailTemplates, "templates/email")
+	if err != nil {
+		return nil, err
+	}
+
+	read := func(file string) (string, error) {
+		if overrideDir != "" {
+			data, err := os.ReadFile(filepath.Join(overrideDir, file))
+			if err == nil {
+				return string(data), nil
+			}
+			if !os.IsNotExist(err) {
+				return "", err
+			}
+		}
+		data, err := fs.ReadFile(embedded, file)
+		return string(data), err
+	}
+
+	htmlLayout, err := read("layout.html")
+	if err != nil {
+		return nil, fmt.Errorf("email template layout: %w", err)
+	}
+	textLayout, err := read("layout.txt")
+	if err != nil {
+		return nil, fmt.Errorf("email template layout: %w", err)
+	}
+
+	locales, err := loadLocaleBundles(embedded, read)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(embedded, "*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make(map[string]emailTemplate)
+	for _, file := range files {
+		name := strings.TrimSuffix(file, ".html")
+		if name == "layout" {
+			continue
+		}
+
+		htmlContent, err := read(name + ".html")
+		if err != nil {
+			return nil, fmt.Errorf("email template %s: %w", name, err)
+		}
+		textContent, err := read(name + ".txt")
+		if err != nil {
+			return nil, fmt.Errorf("email template %s: %w", name, err)
+		}
+
+		html, err := htmltemplate.New(name).Option("missingkey=error").Funcs(placeholderEmailFuncs).Parse(htmlLayout)
+		if err == nil {
+			_, err = html.Parse(htmlContent)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("email template %s.html: %w", name, err)
+		}
+
+		text, err := texttemplate.New(name).Option("missingkey=error").Funcs(placeholderEmailFuncs).Parse(textLayout)
+		if err == nil {
+			_, err = text.Parse(textContent)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("email template %s.txt: %w", name, err)
+		}
+
+		templates[name] = emailTemplate{html: html, text: text}
+	}
+
+	return &emailTemplateSet{templates: templates, locales: locales}, nil
+}
+
+// loadLocaleBundles reads locales/<locale>.json for every embedded locale.
+// The default locale is required since it backs every missing key.
+func loadLocaleBundles(embedded fs.FS, read func(string) (string, error)) (map[string]localeBundle, error) {
+	files, err := fs.Glob(embedded, "locales/*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	locales := make(map[string]localeBundle)
+	for _, file := range files {
+		locale := strings.TrimSuffix(filepath.Base(file), ".json")
+
+		content, err := read(file)
+		if err != nil {
+			return nil, fmt.Errorf("email locale %s: %w", locale, err)
+		}
+
+		var bundle localeBundle
+		if err := json.Unmarshal([]byte(content), &bundle); err != nil {
+			return nil, fmt.Errorf("email locale %s: %w", locale, err)
+		}
+		locales[locale] = bundle
+	}
+
+	if _, ok := locales[DefaultEmailLocale]; !ok {
+		return nil, fmt.Errorf("email locale %s is missing", DefaultEmailLocale)
+	}
+	return locales, nil
+}
+
+func getEmailTemplates() (*emailTemplateSet, error) {
+	emailTemplatesMu.Lock()
+	set := emailTemplates
+	emailTemplatesMu.Unlock()
+
+	if set != nil {
+		return set, nil
+	}
+	if err := InitEmailTemplates(); err != nil {
+		return nil, err
+	}
+	return getEmailTemplates()
+}
+
+// resolveLocale picks the bundle for a locale such as "de" or "de-AT",
+// falling back to the language and then to the default locale.
+func (s *emailTemplateSet) resolveLocale(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if _, ok := s.locales[locale]; ok {
+		return locale
+	}
+	if lang, _, found := strings.Cut(locale, "-"); found {
+		if _, ok := s.locales[lang]; ok {
+			return lang
+		}
+	}
+	return DefaultEmailLocale
+}
+
+// funcs returns the template functions bound to a locale.
+func (s *emailTemplateSet) funcs(locale string) map[string]any {
+	translate := func(key string, args ...any) (string, error) {
+		format, ok := s.locales[locale][key]
+		if !ok {
+			format, ok = s.locales[DefaultEmailLocale][key]
+		}
+		if !ok {
+			return "", fmt.Errorf("missing email message %q", key)
+		}
+		if len(args) == 0 {
+			return format, nil
+		}
+		return fmt.Sprintf(format, args...), nil
+	}
+
+	return map[string]any{
+		"t": translate,
+		"duration": func(d time.Duration) string {
+			key, n := "duration.minute", int(d.Round(time.Minute)/time.Minute)
+			if d >= time.Hour && d%time.Hour == 0 {
+				key, n = "duration.hour", int(d/time.Hour)
+			}
+			if n != 1 {
+				key += "s"
+			}
+			text, _ := translate(key, n)
+			return text
+		},
+		"locale": func() string { return locale },
+	}
+}
+
+// RenderEmail renders the named template in the given locale into a message
+// with a subject and both plain-text and HTML bodies; the caller fills in
+// To. Unknown locales fall back to DefaultEmailLocale. Templates are loaded
+// on first use if InitEmailTemplates was not called.
+func RenderEmail(name, locale string, data any) (EmailMessage, error) {
+	set, err := getEmailTemplates()
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	return set.render(name, locale, data)
+}
+
+func (s *emailTemplateSet) render(name, locale string, data any) (EmailMessage, error) {
+	t, ok := s.templates[name]
+	if !ok {
+		return EmailMessage{}, fmt.Errorf("%w %q", ErrUnknownEmailTemplate, name)
+	}
+
+	funcs := s.funcs(s.resolveLocale(locale))
+	textTmpl, err := t.text.Clone()
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	htmlTmpl, err := t.html.Clone()
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	textTmpl.Funcs(funcs)
+	htmlTmpl.Funcs(funcs)
+
+	var subject, text, html bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return EmailMessage{}, fmt.Errorf("render email template %s: %w", name, err)
+	}
+	if err := textTmpl.ExecuteTemplate(&text, "layout", data); err != nil {
+		return EmailMessage{}, fmt.Errorf("render email template %s: %w", name, err)
+	}
+	if err := htmlTmpl.ExecuteTemplate(&html, "layout", data); err != nil {
+		return EmailMessage{}, fmt.Errorf("render email template %s: %w", name, err)
+	}
+
+	return EmailMessage{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// emailPreviewData is sample data for each template, used by PreviewEmail.
+var emailPreviewData = map[string]any{
+	EmailTemplateWelcome:              WelcomeEmail{Username: "jane", VerifyURL: "https://app.example.com/verify-email?token=preview"},
+	EmailTemplateVerifyEmail:          VerifyEmail{VerifyURL: "https://app.example.com/verify-email?token=preview", ExpiresIn: 24 * time.Hour},
+	EmailTemplatePasswordReset:        PasswordResetEmail{ResetURL: "https://app.example.com/reset-password?token=preview", ExpiresIn: time.Hour},
+	EmailTemplatePasswordChanged:      PasswordChangedEmail{IPAddress: "203.0.113.7", ChangedAt: "Fri, 16 Oct 2026 09:30:00 UTC", SecureURL: "https://app.example.com/secure-account?token=preview"},
+	EmailTemplateOAuthLinked:          OAuthLinkEmail{Provider: "GitHub", IPAddress: "203.0.113.7", ChangedAt: "Fri, 16 Oct 2026 09:30:00 UTC"},
+	EmailTemplateOAuthUnlinked:        OAuthLinkEmail{Provider: "Google", IPAddress: "203.0.113.7", ChangedAt: "Fri, 16 Oct 2026 09:30:00 UTC"},
+	EmailTemplateAccountLocked:        AccountLockedEmail{UnlockURL: "https://app.example.com/unlock-account?token=preview", LockedUntil: "Fri, 16 Oct 2026 10:00:00 UTC"},
+	EmailTemplateLoginChallenge:       LoginChallengeEmail{IPAddress: "203.0.113.7", Code: "482913", ExpiresIn: 10 * time.Minute},
+	EmailTemplatePasswordSetChallenge: PasswordSetChallengeEmail{IPAddress: "203.0.113.7", Code: "482913", ExpiresIn: 10 * time.Minute},
+	EmailTemplateInvitation:           InvitationEmail{InviterName: "jane", InviteURL: "https://app.example.com/register?invite=preview", ExpiresIn: 7 * 24 * time.Hour},
+	EmailTemplateImpossibleTravel: ImpossibleTravelEmail{
+		Location:         "Tokyo, Japan",
+		PreviousLocation: "Berlin, Germany",
+		IPAddress:        "203.0.113.7",
+		Code:             "482913",
+		ExpiresIn:        10 * time.Minute,
+	},
+}
+
+// EmailTemplatePreviews lists the templates PreviewEmail can render and the
+// available locales.
+func EmailTemplatePreviews() (templates, locales []string) {
+	for name := range emailPreviewData {
+		templates = append(templates, name)
+	}
+	if set, err := getEmailTemplates(); err == nil {
+		for locale := range set.locales {
+			locales = append(locales, locale)
+		}
+	}
+	slices.Sort(templates)
+	slices.Sort(locales)
+	return templates, locales
+}
+
+// PreviewEmail renders a template with sample data. Templates are reloaded
+// from disk on every call, so edits in EMAIL_TEMPLATE_DIR show up without a
+// restart.
+func PreviewEmail(name, locale string) (EmailMessage, error) {
+	data, ok := emailPreviewData[name]
+	if !ok {
+		return EmailMessage{}, fmt.Errorf("%w %q", ErrUnknownEmailTemplate, name)
+	}
+
+	set, err := loadEmailTemplates(os.Getenv("EMAIL_TEMPLATE_DIR"))
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	return set.render(name, locale, data)
+}
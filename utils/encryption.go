@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encryptedValuePrefix marks values produced by EncryptValue. Stored values
+// without it are treated as legacy plaintext so existing rows keep working
+// until they are rewritten.
+const encryptedValuePrefix = "enc"
+
+// ErrEncryptionKeyMissing is returned when encryption is attempted without a
+// configured key.
+var ErrEncryptionKeyMissing = errors.New("TOKEN_ENCRYPTION_KEY is not configured")
+
+// keyring holds the AES-256-GCM keys used for data at rest. New values are
+// always sealed with the current key; any known key id can be opened, which
+// allows keys to be rotated without re-encrypting every row at once.
+type keyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+var (
+	encryptionKeys     *keyring
+	encryptionEnvelope *envelope
+	encryptionErr      error
+	encryptionOnce     sync.Once
+)
+
+// InitEncryption configures encryption at rest. When ENCRYPTION_PROVIDER
+// names a key management service, new values are envelope-encrypted with
+// data keys wrapped by that service. Otherwise the local keyring is used:
+// TOKEN_ENCRYPTION_KEY (base64-encoded 32-byte key), TOKEN_ENCRYPTION_KEY_ID
+// (default "v1") and TOKEN_ENCRYPTION_PREVIOUS_KEYS, a comma-separated list
+// of "<id>:<base64 key>" pairs kept for decrypting older values. The local
+// keyring is always loaded so values written before switching to a provider
+// stay readable. A missing key is not an error here; encryption calls fail
+// until one is configured.
+func InitEncryption() error {
+	encryptionOnce.Do(func() {
+		encryptionKeys, encryptionErr = loadKeyring()
+		if encryptionErr != nil {
+			return
+		}
+
+		var provider EncryptionProvider
+		provider, encryptionErr = NewEncryptionProvider()
+		if encryptionErr == nil && provider != nil {
+			encryptionEnvelope = newEnvelope(provider)
+		}
+	})
+	return encryptionErr
+}
+
+func loadKeyring() (*keyring, error) {
+	kr := &keyring{keys: make(map[string]cipher.AEAD)}
+
+	current := os.Getenv("TOKEN_ENCRYPTION_KEY")
+	if current == "" {
+		return kr, nil
+	}
+
+	kr.currentID = os.Getenv("TOKEN_ENCRYPTION_KEY_ID")
+	if kr.currentID == "" {
+		kr.currentID = "v1"
+	}
+	if err := kr.add(kr.currentID, current); err != nil {
+		return nil, fmt.Errorf("TOKEN_ENCRYPTION_KEY: %w", err)
+	}
+
+	if previous := os.Getenv("TOKEN_ENCRYPTION_PREVIOUS_KEYS"); previous != "" {
+		for _, entry := range strings.Split(previous, ",") {
+			id, key, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || id == "" {
+				return nil, fmt.Errorf("TOKEN_ENCRYPTION_PREVIOUS_KEYS: expected <id>:<base64 key>")
+			}
+			if err := kr.add(id, key); err != nil {
+				return nil, fmt.Errorf("TOKEN_ENCRYPTION_PREVIOUS_KEYS[%s]: %w", id, err)
+			}
+		}
+	}
+
+	return kr, nil
+}
+
+func (kr *keyring) add(id, encoded string) error {
+	if strings.Contains(id, ":") {
+		return errors.New("key id must not contain ':'")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decode key: %w", err)
+	}
+	if len(key) != 32 {
+		return fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	kr.keys[id] = aead
+	return nil
+}
+
+func getKeyring() (*keyring, error) {
+	if err := InitEncryption(); err != nil {
+		return nil, err
+	}
+	return encryptionKeys, nil
+}
+
+// EncryptValue seals plaintext with AES-256-GCM. With an EncryptionProvider
+// configured the result is an envelope ("env:<provider>:<wrapped data
+// key>:<ciphertext>"); otherwise it is sealed under the current local key as
+// "enc:<key id>:<base64(nonce|ciphertext)>".
+func EncryptValue(plaintext string) (string, error) {
+	kr, err := getKeyring()
+	if err != nil {
+		return "", err
+	}
+
+	if encryptionEnvelope != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return encryptionEnvelope.seal(ctx, plaintext)
+	}
+
+	if kr.currentID == "" {
+		return "", ErrEncryptionKeyMissing
+	}
+
+	aead := kr.keys[kr.currentID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return fmt.Sprintf("%s:%s:%s", encryptedValuePrefix, kr.currentID,
+		base64.RawURLEncoding.EncodeToString(sealed)), nil
+}
+
+// DecryptValue opens a value produced by EncryptValue. Values without a
+// known prefix are returned unchanged as legacy plaintext.
+func DecryptValue(value string) (string, error) {
+	prefix, rest, ok := strings.Cut(value, ":")
+	if !ok || (prefix != encryptedValuePrefix && prefix != envelopeValuePrefix) {
+		return value, nil
+	}
+
+	if prefix == envelopeValuePrefix {
+		if err := InitEncryption(); err != nil {
+			return "", err
+		}
+		if encryptionEnvelope == nil {
+			return "", errors.New("value is envelope-encrypted but no ENCRYPTION_PROVIDER is configured")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return encryptionEnvelope.open(ctx, rest)
+	}
+
+	id, payload, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	kr, err := getKeyring()
+	if err != nil {
+		return "", err
+	}
+	aead, ok := kr.keys[id]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key id %q", id)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted value: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+
+	return string(plaintext), nil
+}
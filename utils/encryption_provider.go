@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// envelopeValuePrefix marks values sealed with a data key wrapped by an
+// EncryptionProvider.
+const envelopeValuePrefix = "env"
+
+// EncryptionProvider wraps data keys with an external key management service
+// so that the key-encryption key never leaves the service. Implementations
+// should be safe for concurrent use by multiple goroutines.
+type EncryptionProvider interface {
+	// Name identifies the provider in stored values (e.g. "awskms").
+	Name() string
+	// GenerateDataKey returns a fresh 32-byte data key and its wrapped form.
+	GenerateDataKey(ctx context.Context) (plaintext, wrapped []byte, err error)
+	// DecryptDataKey unwraps a data key previously returned by GenerateDataKey.
+	DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// NewEncryptionProvider builds an EncryptionProvider from ENCRYPTION_PROVIDER
+// ("awskms" or "vault"). It returns nil when no provider is configured, in
+// which case values are sealed with the local TOKEN_ENCRYPTION_KEY.
+func NewEncryptionProvider() (EncryptionProvider, error) {
+	switch strings.ToLower(os.Getenv("ENCRYPTION_PROVIDER")) {
+	case "", "local":
+		return nil, nil
+	case "awskms":
+		return NewAWSKMSProvider()
+	case "vault":
+		return NewVaultTransitProvider()
+	default:
+		return nil, fmt.Errorf("unsupported ENCRYPTION_PROVIDER %q", os.Getenv("ENCRYPTION_PROVIDER"))
+	}
+}
+
+// envelope seals values with data keys obtained from an EncryptionProvider.
+// A data key is reused for dataKeyTTL to avoid a KMS round-trip per value,
+// and unwrapped keys are cached so reads do not hit the provider every time.
+type envelope struct {
+	provider   EncryptionProvider
+	dataKeyTTL time.Duration
+
+	mu        sync.Mutex
+	current   *dataKey
+	unwrapped map[string]cipher.AEAD
+}
+
+type dataKey struct {
+	aead      cipher.AEAD
+	wrapped   string
+	expiresAt time.Time
+}
+
+// maxCachedDataKeys bounds the unwrapped data key cache.
+const maxCachedDataKeys = 1024
+
+func newEnvelope(provider EncryptionProvider) *envelope {
+	ttl := time.Hour
+	if v, err := time.ParseDuration(os.Getenv("ENCRYPTION_DATA_KEY_TTL")); err == nil && v > 0 {
+		ttl = v
+	}
+
+	return &envelope{
+		provider:   provider,
+		dataKeyTTL: ttl,
+		unwrapped:  make(map[string]cipher.AEAD),
+	}
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (e *envelope) dataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.current != nil && time.Now().Before(e.current.expiresAt) {
+		return e.current, nil
+	}
+
+	plaintext, wrapped, err := e.provider.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s generate data key: %w", e.provider.Name(), err)
+	}
+
+	aead, err := newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	e.current = &dataKey{
+		aead:      aead,
+		wrapped:   base64.RawURLEncoding.EncodeToString(wrapped),
+		expiresAt: time.Now().Add(e.dataKeyTTL),
+	}
+	return e.current, nil
+}
+
+// seal returns "env:<provider>:<wrapped data key>:<base64(nonce|ciphertext)>".
+func (e *envelope) seal(ctx context.Context, plaintext string) (string, error) {
+	key, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := key.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return fmt.Sprintf("%s:%s:%s:%s", envelopeValuePrefix, e.provider.Name(), key.wrapped,
+		base64.RawURLEncoding.EncodeToString(sealed)), nil
+}
+
+// open decrypts the remainder of a value after the "env:" prefix.
+func (e *envelope) open(ctx context.Context, rest string) (string, error) {
+	parts := strings.SplitN(rest, ":", 3)
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	name, wrapped, payload := parts[0], parts[1], parts[2]
+
+	if name != e.provider.Name() {
+		return "", fmt.Errorf("value was encrypted with provider %q but %q is configured", name, e.provider.Name())
+	}
+
+	aead, err := e.unwrap(ctx, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("decode encrypted value: %w", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value: %w", err)
+	}
+
+	return string(plaintext), nil
+}
+
+func (e *envelope) unwrap(ctx context.Context, wrapped string) (cipher.AEAD, error) {
+	e.mu.Lock()
+	aead, ok := e.unwrapped[wrapped]
+	e.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("decode data key: %w", err)
+	}
+
+	plaintext, err := e.provider.DecryptDataKey(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s decrypt data key: %w", e.provider.Name(), err)
+	}
+
+	aead, err = newAEAD(plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	if len(e.unwrapped) >= maxCachedDataKeys {
+		e.unwrapped = make(map[string]cipher.AEAD)
+	}
+	e.unwrapped[wrapped] = aead
+	e.mu.Unlock()
+
+	return aead, nil
+}
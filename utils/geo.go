@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GeoLocation is the approximate location of an IP address.
+type GeoLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Country   string  `json:"country,omitempty"`
+	City      string  `json:"city,omitempty"`
+}
+
+// GeoLocator resolves the location of a client. Implementations should be
+// safe for concurrent use by multiple goroutines.
+type GeoLocator interface {
+	// Locate returns the location for ip. header returns request headers,
+	// for locators that rely on an edge proxy. A nil location with a nil
+	// error means the location is unknown.
+	Locate(ctx context.Context, ip string, header func(string) string) (*GeoLocation, error)
+}
+
+// NewGeoLocator builds a GeoLocator from GEO_SOURCE:
+//   - "headers": read CDN visitor-location headers (Cloudflare's
+//     cf-iplatitude / cf-iplongitude / cf-ipcountry / cf-ipcity by default,
+//     overridable with GEO_HEADER_LATITUDE, GEO_HEADER_LONGITUDE,
+//     GEO_HEADER_COUNTRY and GEO_HEADER_CITY)
+//   - "http": query GEOIP_URL, a URL template where "%s" is replaced by the
+//     IP (e.g. https://ipapi.co/%s/json/)
+//
+// It returns nil when GEO_SOURCE is empty.
+func NewGeoLocator() (GeoLocator, error) {
+	switch strings.ToLower(os.Getenv("GEO_SOURCE")) {
+	case "":
+		return nil, nil
+	case "headers":
+		return &HeaderGeoLocator{
+			LatitudeHeader:  envOr("GEO_HEADER_LATITUDE", "cf-iplatitude"),
+			LongitudeHeader: envOr("GEO_HEADER_LONGITUDE", "cf-iplongitude"),
+			CountryHeader:   envOr("GEO_HEADER_COUNTRY", "cf-ipcountry"),
+			CityHeader:      envOr("GEO_HEADER_CITY", "cf-ipcity"),
+		}, nil
+	case "http":
+		template := os.Getenv("GEOIP_URL")
+		if !strings.Contains(template, "%s") {
+			return nil, errors.New("GEOIP_URL must be a URL template containing %s")
+		}
+		return &HTTPGeoLocator{
+			URLTemplate: template,
+			client:      &http.Client{Timeout: 3 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported GEO_SOURCE %q", os.Getenv("GEO_SOURCE"))
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// HeaderGeoLocator reads the location from headers set by an edge proxy.
+type HeaderGeoLocator struct {
+	LatitudeHeader  string
+	LongitudeHeader string
+	CountryHeader   string
+	CityHeader      string
+}
+
+// Locate implements GeoLocator.
+func (l *HeaderGeoLocator) Locate(_ context.Context, _ string, header func(string) string) (*GeoLocation, error) {
+	lat, errLat := strconv.ParseFloat(header(l.LatitudeHeader), 64)
+	lon, errLon := strconv.ParseFloat(header(l.LongitudeHeader), 64)
+	if errLat != nil || errLon != nil {
+		return nil, nil
+	}
+
+	return &GeoLocation{
+		Latitude:  lat,
+		Longitude: lon,
+		Country:   header(l.CountryHeader),
+		City:      header(l.CityHeader),
+	}, nil
+}
+
+// HTTPGeoLocator looks up the location with a GeoIP HTTP API returning JSON.
+// Both "latitude"/"longitude" and "lat"/"lon" field names are understood.
+type HTTPGeoLocator struct {
+	URLTemplate string
+	client      *http.Client
+}
+
+// Locate implements GeoLocator.
+func (l *HTTPGeoLocator) Locate(ctx context.Context, ip string, _ func(string) string) (*GeoLocation, error) {
+	endpoint := fmt.Sprintf(l.URLTemplate, url.PathEscape(ip))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("geoip lookup: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("geoip lookup returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Latitude    *float64 `json:"latitude"`
+		Longitude   *float64 `json:"longitude"`
+		Lat         *float64 `json:"lat"`
+		Lon         *float64 `json:"lon"`
+		Country     string   `json:"country"`
+		CountryName string   `json:"country_name"`
+		City        string   `json:"city"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode geoip response: %w", err)
+	}
+
+	if body.Latitude == nil {
+		body.Latitude, body.Longitude = body.Lat, body.Lon
+	}
+	if body.Latitude == nil || body.Longitude == nil {
+		return nil, nil
+	}
+
+	country := body.Country
+	if country == "" {
+		country = body.CountryName
+	}
+
+	return &GeoLocation{
+		Latitude:  *body.Latitude,
+		Longitude: *body.Longitude,
+		Country:   country,
+		City:      body.City,
+	}, nil
+}
+
+// DistanceKm returns the great-circle distance between two locations.
+func DistanceKm(a, b GeoLocation) float64 {
+	const earthRadiusKm = 6371.0
+
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := (b.Latitude - a.Latitude) * math.Pi / 180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"api/database/models"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed messages/*.json
+var embeddedMessages embed.FS
+
+// messageCatalog holds the translations of API messages for each non-English
+// locale. Keys are the English messages; "%s" in a key matches any text,
+// which is substituted into the translation in the same order.
+var messageCatalog map[models.Locale]localeMessages
+
+type localeMessages struct {
+	exact    map[string]string
+	patterns []messagePattern
+}
+
+type messagePattern struct {
+	match       *regexp.Regexp
+	translation string
+}
+
+// InitMessages loads the embedded API message translations.
+func InitMessages() error {
+	files, err := fs.Glob(embeddedMessages, "messages/*.json")
+	if err != nil {
+		return err
+	}
+
+	catalog := make(map[models.Locale]localeMessages)
+	for _, file := range files {
+		data, err := embeddedMessages.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		bundle := localeMessages{exact: make(map[string]string)}
+		for message, translation := range messages {
+			if !strings.Contains(message, "%s") {
+				bundle.exact[message] = translation
+				continue
+			}
+			expr := strings.ReplaceAll(regexp.QuoteMeta(message), "%s", "(.+)")
+			bundle.patterns = append(bundle.patterns, messagePattern{regexp.MustCompile("^" + expr + "$"), translation})
+		}
+		catalog[models.Locale(strings.TrimSuffix(path.Base(file), ".json"))] = bundle
+	}
+
+	messageCatalog = catalog
+	return nil
+}
+
+// TranslateMessage returns message in the given locale. Messages without a
+// translation, including all messages in English, are returned unchanged.
+func TranslateMessage(locale models.Locale, message string) string {
+	bundle, ok := messageCatalog[locale]
+	if !ok {
+		return message
+	}
+	if translation, ok := bundle.exact[message]; ok {
+		return translation
+	}
+	for _, pattern := range bundle.patterns {
+		if groups := pattern.match.FindStringSubmatch(message); groups != nil {
+			args := make([]any, len(groups)-1)
+			for i, group := range groups[1:] {
+				args[i] = group
+			}
+			return fmt.Sprintf(pattern.translation, args...)
+		}
+	}
+	return message
+}
+
+// NegotiateLocale picks the supported locale that best matches an
+// Accept-Language header, defaulting to English. Region subtags are ignored,
+// so "de-AT" selects German.
+func NegotiateLocale(acceptLanguage string) models.Locale {
+	best, bestQuality := models.LocaleEN, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = v
+			}
+		}
+
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if locale := models.Locale(lang); quality > bestQuality && slices.Contains(models.SupportedLocales, locale) {
+			best, bestQuality = locale, quality
+		}
+	}
+	return best
+}
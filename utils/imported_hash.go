@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// firebaseHashPrefix marks password hashes imported from Firebase
+// Authentication. The stored form is "firebase-scrypt:<base64 salt>:<base64
+// hash>"; the project-wide parameters come from the environment.
+const firebaseHashPrefix = "firebase-scrypt"
+
+// firebaseScrypt holds the hash parameters of the Firebase project users
+// were exported from. signerKey is nil when no parameters are configured.
+var firebaseScrypt struct {
+	signerKey     []byte
+	saltSeparator []byte
+	rounds        int
+	memCost       int
+}
+
+// InitImportedHashes loads the parameters needed to verify password hashes
+// imported from other auth systems. For Firebase these are the values shown
+// under Authentication > Users > Password hash parameters:
+// FIREBASE_HASH_SIGNER_KEY and FIREBASE_HASH_SALT_SEPARATOR (base64),
+// FIREBASE_HASH_ROUNDS and FIREBASE_HASH_MEM_COST.
+func InitImportedHashes() error {
+	firebaseScrypt.signerKey = nil
+
+	signerKey := os.Getenv("FIREBASE_HASH_SIGNER_KEY")
+	if signerKey == "" {
+		return nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(signerKey)
+	if err != nil {
+		return fmt.Errorf("FIREBASE_HASH_SIGNER_KEY: %w", err)
+	}
+	separator, err := base64.StdEncoding.DecodeString(os.Getenv("FIREBASE_HASH_SALT_SEPARATOR"))
+	if err != nil {
+		return fmt.Errorf("FIREBASE_HASH_SALT_SEPARATOR: %w", err)
+	}
+	rounds, err := strconv.Atoi(os.Getenv("FIREBASE_HASH_ROUNDS"))
+	if err != nil || rounds < 1 || rounds > 8 {
+		return errors.New("FIREBASE_HASH_ROUNDS must be between 1 and 8")
+	}
+	memCost, err := strconv.Atoi(os.Getenv("FIREBASE_HASH_MEM_COST"))
+	if err != nil || memCost < 1 || memCost > 14 {
+		return errors.New("FIREBASE_HASH_MEM_COST must be between 1 and 14")
+	}
+
+	firebaseScrypt.signerKey = key
+	firebaseScrypt.saltSeparator = separator
+	firebaseScrypt.rounds = rounds
+	firebaseScrypt.memCost = memCost
+	return nil
+}
+
+// FirebasePasswordHash converts the base64 passwordHash and salt of a
+// Firebase user export into the stored form. It fails when the Firebase
+// hash parameters are not configured, as such hashes could never match.
+func FirebasePasswordHash(hash, salt string) (string, error) {
+	if firebaseScrypt.signerKey == nil {
+		return "", errors.New("firebase hash parameters are not configured")
+	}
+	if _, err := base64.StdEncoding.DecodeString(hash); err != nil {
+		return "", errors.New("firebase passwordHash is not valid base64")
+	}
+	if _, err := base64.StdEncoding.DecodeString(salt); err != nil {
+		return "", errors.New("firebase salt is not valid base64")
+	}
+	return firebaseHashPrefix + ":" + salt + ":" + hash, nil
+}
+
+// isForeignHash reports whether hash was imported in a format other than
+// bcrypt and must be replaced on the next successful login.
+func isForeignHash(hash string) bool {
+	return strings.HasPrefix(hash, firebaseHashPrefix+":")
+}
+
+// compareForeignHash verifies password against an imported non-bcrypt hash.
+func compareForeignHash(password, hash string) bool {
+	rest, _ := strings.CutPrefix(hash, firebaseHashPrefix+":")
+	salt, expected, ok := strings.Cut(rest, ":")
+	if !ok || firebaseScrypt.signerKey == nil {
+		return false
+	}
+	return compareFirebaseScrypt(password, salt, expected)
+}
+
+// compareFirebaseScrypt implements Firebase's modified scrypt: the scrypt
+// key derived from the password and salt encrypts the project signer key
+// with AES-256-CTR, and the ciphertext is the password hash.
+func compareFirebaseScrypt(password, encodedSalt, encodedHash string) bool {
+	salt, err := base64.StdEncoding.DecodeString(encodedSalt)
+	if err != nil {
+		return false
+	}
+	expected, err := base64.StdEncoding.DecodeString(encodedHash)
+	if err != nil {
+		return false
+	}
+
+	release := acquireHashSlot()
+	defer release()
+
+	combined := append(append([]byte{}, salt...), firebaseScrypt.saltSeparator...)
+	derived, err := scrypt.Key([]byte(password), combined, 1<<firebaseScrypt.memCost, firebaseScrypt.rounds, 1, 32)
+	if err != nil {
+		return false
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return false
+	}
+	actual := make([]byte, len(firebaseScrypt.signerKey))
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(actual, firebaseScrypt.signerKey)
+
+	return subtle.ConstantTimeCompare(actual, expected) == 1
+}
@@ -1,33 +1,63 @@
 package utils
 
 import (
-	"os"
+	"api/config"
+	"api/database/models"
+	"api/tokenauth"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
-type JWTClaims struct {
-	Subject uint `json:"sub"`
-	jwt.RegisteredClaims
+// JWTClaims are the claims of the access tokens issued here. They are
+// defined in tokenauth so other services can read them without this package.
+type JWTClaims = tokenauth.Claims
+
+// GetSignedKey issues an unscoped five-minute access token for a login
+// session, carrying the user's tenant and locale. It returns the token's JTI
+// and the signed token.
+func GetSignedKey(user *models.User) (string, string, error) {
+	return signAccessToken(user.ID, user.TenantID, user.Locale, nil, 5*time.Minute)
+}
+
+// GetScopedSignedKey issues an access token limited to scopes, e.g. for
+// personal access tokens or client-credentials grants. A nil scopes issues an
+// unscoped token.
+func GetScopedSignedKey(id, tenantID uint, scopes []string, ttl time.Duration) (string, string, error) {
+	return signAccessToken(id, tenantID, "", scopes, ttl)
 }
 
-func GetSignedKey(id uint) (string, string, error) {
+func signAccessToken(id, tenantID uint, locale models.Locale, scopes []string, ttl time.Duration) (string, string, error) {
 	jti := uuid.New()
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, JWTClaims{
 		Subject: id,
+		Tenant:  tenantID,
+		Scope:   strings.Join(scopes, " "),
+		Locale:  string(locale),
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			Issuer:    "auth.justfossa.lol",
 			Audience:  []string{"auth-api"},
 			ID:        jti.String(),
 		},
 	})
 
-	t, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	t, err := token.SignedString([]byte(config.Get().JWTSecret))
 
 	return jti.String(), t, err
 }
+
+// ParseAccessToken verifies an access token's signature and expiry and
+// returns its claims. It accepts the same tokens as the JWT middleware; the
+// caller still has to check that the token's session is not revoked.
+func ParseAccessToken(tokenString string) (*JWTClaims, error) {
+	token, err := tokenauth.New([]byte(config.Get().JWTSecret), nil).Parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return token.Claims.(*JWTClaims), nil
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"api/database/models"
+	"os"
+)
+
+// LegalDocuments describes the current terms of service and privacy policy.
+// An empty version means the document is not tracked.
+type LegalDocuments struct {
+	TermsVersion   string `json:"terms_version,omitempty"`
+	TermsURL       string `json:"terms_url,omitempty"`
+	PrivacyVersion string `json:"privacy_version,omitempty"`
+	PrivacyURL     string `json:"privacy_url,omitempty"`
+}
+
+// CurrentLegalDocuments reads TERMS_VERSION, TERMS_URL, PRIVACY_VERSION and
+// PRIVACY_URL.
+func CurrentLegalDocuments() LegalDocuments {
+	return LegalDocuments{
+		TermsVersion:   os.Getenv("TERMS_VERSION"),
+		TermsURL:       os.Getenv("TERMS_URL"),
+		PrivacyVersion: os.Getenv("PRIVACY_VERSION"),
+		PrivacyURL:     os.Getenv("PRIVACY_URL"),
+	}
+}
+
+// Tracked reports whether any document version is configured.
+func (d LegalDocuments) Tracked() bool {
+	return d.TermsVersion != "" || d.PrivacyVersion != ""
+}
+
+// AcceptedBy reports whether the user has accepted the current version of
+// every tracked document.
+func (d LegalDocuments) AcceptedBy(user *models.User) bool {
+	if d.TermsVersion != "" && user.TermsVersion != d.TermsVersion {
+		return false
+	}
+	if d.PrivacyVersion != "" && user.PrivacyVersion != d.PrivacyVersion {
+		return false
+	}
+	return true
+}
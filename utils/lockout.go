@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// LockoutPolicy controls how accounts are temporarily locked after repeated
+// failed logins. Once Threshold consecutive failures are reached the account
+// is locked for BaseDuration, doubling with every further failure up to
+// MaxDuration. With UnlockByEmail, locked users are emailed a link that lifts
+// the lockout early.
+type LockoutPolicy struct {
+	Threshold     int      `json:"threshold"`
+	BaseDuration  Duration `json:"base_duration"`
+	MaxDuration   Duration `json:"max_duration"`
+	UnlockByEmail bool     `json:"unlock_by_email"`
+}
+
+// NewLockoutPolicy builds a LockoutPolicy from environment variables:
+// LOCKOUT_THRESHOLD (default 5), LOCKOUT_BASE_DURATION (default 1m),
+// LOCKOUT_MAX_DURATION (default 24h) and LOCKOUT_UNLOCK_EMAIL (default true).
+// A threshold of 0 disables lockout.
+func NewLockoutPolicy() LockoutPolicy {
+	policy := LockoutPolicy{
+		Threshold:     5,
+		BaseDuration:  Duration(time.Minute),
+		MaxDuration:   Duration(24 * time.Hour),
+		UnlockByEmail: true,
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("LOCKOUT_THRESHOLD")); err == nil && v >= 0 {
+		policy.Threshold = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOCKOUT_BASE_DURATION")); err == nil && v > 0 {
+		policy.BaseDuration = Duration(v)
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOCKOUT_MAX_DURATION")); err == nil && v > 0 {
+		policy.MaxDuration = Duration(v)
+	}
+	if v, err := strconv.ParseBool(os.Getenv("LOCKOUT_UNLOCK_EMAIL")); err == nil {
+		policy.UnlockByEmail = v
+	}
+
+	return policy
+}
+
+// Enabled reports whether lockout is active.
+func (p LockoutPolicy) Enabled() bool {
+	return p.Threshold > 0
+}
+
+// LockDuration returns how long an account should be locked after the given
+// number of consecutive failures, or 0 if the threshold is not reached.
+func (p LockoutPolicy) LockDuration(failures int) time.Duration {
+	if !p.Enabled() || failures < p.Threshold {
+		return 0
+	}
+
+	d, limit := time.Duration(p.BaseDuration), time.Duration(p.MaxDuration)
+	for i := p.Threshold; i < failures; i++ {
+		d *= 2
+		if d >= limit {
+			return limit
+		}
+	}
+
+	if d > limit {
+		return limit
+	}
+	return d
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"api/config"
 	"api/database/models"
 	"context"
 	"crypto/rand"
@@ -10,6 +11,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
@@ -24,12 +26,16 @@ type OAuthConfig struct {
 
 var OAuthConfigs *OAuthConfig
 
+// oauthConfigsMu guards OAuthConfigs, which InitOAuth replaces when rotated
+// client secrets are loaded.
+var oauthConfigsMu sync.RWMutex
+
 // Initialize OAuth configurations
 func InitOAuth() {
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:5000"
-	}
+	baseURL := config.Get().BaseURL
+
+	oauthConfigsMu.Lock()
+	defer oauthConfigsMu.Unlock()
 
 	OAuthConfigs = &OAuthConfig{
 		GoogleConfig: &oauth2.Config{
@@ -51,6 +57,9 @@ func InitOAuth() {
 
 // GetOAuthConfig returns the OAuth config for a specific provider
 func GetOAuthConfig(provider models.OAuthProvider) (*oauth2.Config, error) {
+	oauthConfigsMu.RLock()
+	defer oauthConfigsMu.RUnlock()
+
 	if OAuthConfigs == nil {
 		return nil, errors.New("OAuth not initialized")
 	}
@@ -215,24 +224,46 @@ func fetchGitHubPrimaryEmail(client *http.Client) (string, error) {
 	return "", errors.New("no verified email found in GitHub account")
 }
 
-// EncryptToken encrypts OAuth tokens for secure storage
+// EncryptToken encrypts OAuth tokens for secure storage using AES-256-GCM
 func EncryptToken(token string) (string, error) {
 	if token == "" {
 		return "", nil
 	}
-	// TODO: Implement proper encryption (AES-256-GCM)
-	// For now, return as-is - in production, encrypt with a key from environment
-	return token, nil
+	return EncryptValue(token)
 }
 
-// DecryptToken decrypts OAuth tokens from storage
+// DecryptToken decrypts OAuth tokens from storage. Tokens stored before
+// encryption was enabled are returned as-is.
 func DecryptToken(encryptedToken string) (string, error) {
 	if encryptedToken == "" {
 		return "", nil
 	}
-	// TODO: Implement proper decryption
-	// For now, return as-is - in production, decrypt with the same key
-	return encryptedToken, nil
+	return DecryptValue(encryptedToken)
+}
+
+// OAuthTokenFromAccount rebuilds a usable oauth2.Token from the encrypted
+// credentials stored on an OAuth account
+func OAuthTokenFromAccount(account *models.OAuthAccount) (*oauth2.Token, error) {
+	accessToken, err := DecryptToken(account.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt access token: %w", err)
+	}
+
+	refreshToken, err := DecryptToken(account.RefreshToken)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt refresh token: %w", err)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+	}
+	if account.TokenExpiry != nil {
+		token.Expiry = *account.TokenExpiry
+	}
+
+	return token, nil
 }
 
 // ValidateOAuthState validates the OAuth state parameter for CSRF protection
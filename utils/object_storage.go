@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ObjectStorage stores publicly readable files such as avatars.
+type ObjectStorage interface {
+	// Put stores data under key and returns its public URL.
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	// Delete removes the object under key. Deleting a missing key succeeds.
+	Delete(ctx context.Context, key string) error
+}
+
+// NewObjectStorage selects the storage backend from STORAGE_PROVIDER. Only
+// "s3" is supported; when unset nil is returned and uploads are disabled.
+func NewObjectStorage() (ObjectStorage, error) {
+	switch provider := strings.ToLower(os.Getenv("STORAGE_PROVIDER")); provider {
+	case "":
+		return nil, nil
+	case "s3":
+		return NewS3Storage()
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_PROVIDER %q (supported: s3)", provider)
+	}
+}
+
+// S3Storage stores objects in an S3-compatible bucket (AWS S3, MinIO,
+// Cloudflare R2, ...) using path-style requests signed with SigV4.
+type S3Storage struct {
+	endpoint  string // Bucket URL that objects are written to
+	publicURL string // Base URL objects are served from
+	region    string
+	creds     awsCredentials
+	client    *http.Client
+}
+
+// NewS3Storage builds an S3Storage from environment variables: S3_BUCKET,
+// AWS_REGION (or S3_REGION), AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// optionally AWS_SESSION_TOKEN, S3_ENDPOINT for S3-compatible services and
+// S3_PUBLIC_URL when objects are served through a CDN.
+func NewS3Storage() (*S3Storage, error) {
+	bucket := os.Getenv("S3_BUCKET")
+	if bucket == "" {
+		return nil, errors.New("S3_BUCKET is required for the s3 storage provider")
+	}
+
+	s := &S3Storage{
+		region: os.Getenv("S3_REGION"),
+		creds: awsCredentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+
+	if s.region == "" {
+		s.region = os.Getenv("AWS_REGION")
+	}
+	if s.region == "" {
+		return nil, errors.New("AWS_REGION or S3_REGION is required for the s3 storage provider")
+	}
+	if s.creds.accessKey == "" || s.creds.secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the s3 storage provider")
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.region)
+	}
+	s.endpoint = strings.TrimSuffix(endpoint, "/") + "/" + url.PathEscape(bucket)
+
+	s.publicURL = strings.TrimSuffix(os.Getenv("S3_PUBLIC_URL"), "/")
+	if s.publicURL == "" {
+		s.publicURL = s.endpoint
+	}
+
+	return s, nil
+}
+
+// Put implements ObjectStorage.
+func (s *S3Storage) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(s.endpoint, key), bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("build s3 request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if err := s.do(req, data); err != nil {
+		return "", err
+	}
+	return s.objectURL(s.publicURL, key), nil
+}
+
+// Delete implements ObjectStorage.
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(s.endpoint, key), nil)
+	if err != nil {
+		return fmt.Errorf("build s3 request: %w", err)
+	}
+	return s.do(req, nil)
+}
+
+// objectURL joins base and key, escaping each path segment of the key.
+func (s *S3Storage) objectURL(base, key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return base + "/" + strings.Join(segments, "/")
+}
+
+// do signs and sends the request, turning non-2xx responses into errors.
+func (s *S3Storage) do(req *http.Request, payload []byte) error {
+	req.Header.Set("X-Amz-Content-Sha256", sha256Hex(payload))
+	signAWSRequest(req, payload, time.Now().UTC(), s.creds, s.region, "s3")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("s3: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("s3 returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that was not produced by
+// Cursor.Encode.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is a position in a list ordered newest first by a timestamp and
+// then by ID. It identifies the last item of a page; the next page starts
+// right after it, so rows inserted meanwhile do not shift the results.
+type Cursor struct {
+	Time time.Time
+	ID   uint
+}
+
+type cursorJSON struct {
+	Time time.Time `json:"t"`
+	ID   uint      `json:"id"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(cursorJSON{Time: c.Time.UTC(), ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeCursor parses a cursor produced by Cursor.Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	var c cursorJSON
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID == 0 || c.Time.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return Cursor{Time: c.Time, ID: c.ID}, nil
+}
+
+// PageInfo is the pagination metadata of a list response, returned in
+// Response.Meta. NextCursor is set when HasMore is true.
+type PageInfo struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
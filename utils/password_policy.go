@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbutton23/zxcvbn-go"
+)
+
+// bcryptMaxBytes is the longest input bcrypt accepts.
+const bcryptMaxBytes = 72
+
+// PasswordPolicy describes the strength requirements for user passwords.
+type PasswordPolicy struct {
+	MinLength     int  `json:"min_length"`     // minimum number of characters
+	MaxLength     int  `json:"max_length"`     // maximum number of bytes (bcrypt caps input at 72)
+	RequireUpper  bool `json:"require_upper"`  // at least one upper-case letter
+	RequireLower  bool `json:"require_lower"`  // at least one lower-case letter
+	RequireDigit  bool `json:"require_digit"`  // at least one digit
+	RequireSymbol bool `json:"require_symbol"` // at least one punctuation or symbol character
+	MinScore      int  `json:"min_score"`      // minimum zxcvbn score (0-4); 0 disables the check
+}
+
+// NewPasswordPolicy builds a PasswordPolicy from environment variables:
+// PASSWORD_MIN_LENGTH (default 8), PASSWORD_MAX_LENGTH (default 72),
+// PASSWORD_REQUIRE_UPPER, PASSWORD_REQUIRE_LOWER, PASSWORD_REQUIRE_DIGIT,
+// PASSWORD_REQUIRE_SYMBOL (default false) and PASSWORD_MIN_SCORE (default 2).
+func NewPasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength: 8,
+		MaxLength: bcryptMaxBytes,
+		MinScore:  2,
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil && v > 0 {
+		policy.MinLength = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH")); err == nil && v > 0 && v <= bcryptMaxBytes {
+		policy.MaxLength = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_SCORE")); err == nil && v >= 0 && v <= 4 {
+		policy.MinScore = v
+	}
+	policy.RequireUpper, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_UPPER"))
+	policy.RequireLower, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_LOWER"))
+	policy.RequireDigit, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_DIGIT"))
+	policy.RequireSymbol, _ = strconv.ParseBool(os.Getenv("PASSWORD_REQUIRE_SYMBOL"))
+
+	return policy
+}
+
+// Validate checks the password against the policy. userInputs (username,
+// email, ...) are penalised by the zxcvbn estimator so passwords derived from
+// them score lower. The returned error message is safe to show to clients.
+func (p PasswordPolicy) Validate(password string, userInputs ...string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("Password must be at least %d characters long", p.MinLength)
+	}
+	if len(password) > p.MaxLength {
+		return fmt.Errorf("Password must not exceed %d bytes", p.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		return errors.New("Password must contain an upper-case letter")
+	}
+	if p.RequireLower && !hasLower {
+		return errors.New("Password must contain a lower-case letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		return errors.New("Password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return errors.New("Password must contain a symbol")
+	}
+
+	if p.MinScore > 0 {
+		if zxcvbn.PasswordStrength(password, userInputs).Score < p.MinScore {
+			return errors.New("Password is too weak. Avoid common words, names and patterns")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pepperedHashPrefix marks password hashes whose input was peppered. The
+// stored form is "pepper:<id>:<bcrypt hash>" so peppers can be rotated.
+const pepperedHashPrefix = "pepper"
+
+// peppers holds the server-side secrets mixed into password hashes. New
+// hashes use currentID; hashes made with any known id can still be verified.
+var peppers struct {
+	currentID string
+	keys      map[string][]byte
+}
+
+// InitPepper loads the password pepper from environment variables:
+// PASSWORD_PEPPER (base64, at least 32 bytes), PASSWORD_PEPPER_ID (default
+// "v1") and PASSWORD_PREVIOUS_PEPPERS, a comma-separated list of
+// "<id>:<base64 pepper>" pairs kept to verify older hashes. With
+// PASSWORD_PEPPER_KMS=true the values are data keys wrapped by the
+// configured ENCRYPTION_PROVIDER and are unwrapped once at startup. Without
+// PASSWORD_PEPPER, passwords are hashed without a pepper.
+func InitPepper() error {
+	peppers.currentID = ""
+	peppers.keys = make(map[string][]byte)
+
+	current := os.Getenv("PASSWORD_PEPPER")
+	if current == "" {
+		return nil
+	}
+
+	var provider EncryptionProvider
+	if useKMS, _ := strconv.ParseBool(os.Getenv("PASSWORD_PEPPER_KMS")); useKMS {
+		var err error
+		provider, err = NewEncryptionProvider()
+		if err != nil {
+			return err
+		}
+		if provider == nil {
+			return errors.New("PASSWORD_PEPPER_KMS requires ENCRYPTION_PROVIDER")
+		}
+	}
+
+	id := os.Getenv("PASSWORD_PEPPER_ID")
+	if id == "" {
+		id = "v1"
+	}
+	if err := addPepper(provider, id, current); err != nil {
+		return fmt.Errorf("PASSWORD_PEPPER: %w", err)
+	}
+	peppers.currentID = id
+
+	if previous := os.Getenv("PASSWORD_PREVIOUS_PEPPERS"); previous != "" {
+		for _, entry := range strings.Split(previous, ",") {
+			id, value, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok || id == "" {
+				return errors.New("PASSWORD_PREVIOUS_PEPPERS: expected <id>:<base64 pepper>")
+			}
+			if err := addPepper(provider, id, value); err != nil {
+				return fmt.Errorf("PASSWORD_PREVIOUS_PEPPERS[%s]: %w", id, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func addPepper(provider EncryptionProvider, id, encoded string) error {
+	if strings.Contains(id, ":") {
+		return errors.New("pepper id must not contain ':'")
+	}
+
+	pepper, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decode pepper: %w", err)
+	}
+
+	if provider != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if pepper, err = provider.DecryptDataKey(ctx, pepper); err != nil {
+			return fmt.Errorf("unwrap pepper: %w", err)
+		}
+	}
+
+	if len(pepper) < 32 {
+		return errors.New("pepper must be at least 32 bytes")
+	}
+
+	peppers.keys[id] = pepper
+	return nil
+}
+
+// pepperPassword mixes the pepper into the password with HMAC-SHA256. The
+// base64 digest is 43 bytes, well within bcrypt's 72-byte input limit.
+func pepperPassword(pepper []byte, password string) []byte {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+	return []byte(base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+}
+
+// splitPepperedHash separates a stored hash into its pepper id and bcrypt
+// hash. Unpeppered hashes are returned with an empty id.
+func splitPepperedHash(stored string) (id, hash string) {
+	rest, ok := strings.CutPrefix(stored, pepperedHashPrefix+":")
+	if !ok {
+		return "", stored
+	}
+	id, hash, _ = strings.Cut(rest, ":")
+	return id, hash
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var (
+	testPepperV1 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	testPepperV2 = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+)
+
+// usePepper configures the pepper from env for the rest of the test.
+func usePepper(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	t.Setenv("BCRYPT_COST", "4")
+	for _, key := range []string{"PASSWORD_PEPPER", "PASSWORD_PEPPER_ID", "PASSWORD_PREVIOUS_PEPPERS", "PASSWORD_PEPPER_KMS"} {
+		t.Setenv(key, env[key])
+	}
+	if err := InitPepper(); err != nil {
+		t.Fatalf("InitPepper: %v", err)
+	}
+	t.Cleanup(func() {
+		peppers.currentID = ""
+		peppers.keys = nil
+	})
+}
+
+func TestPepperRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		prefix string
+	}{
+		{"unpeppered", nil, "$2"},
+		{"default id", map[string]string{"PASSWORD_PEPPER": testPepperV1}, "pepper:v1:$2"},
+		{"custom id", map[string]string{"PASSWORD_PEPPER": testPepperV2, "PASSWORD_PEPPER_ID": "2024"}, "pepper:2024:$2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePepper(t, tt.env)
+
+			hash, err := HashPassword("hunter2-but-longer")
+			if err != nil {
+				t.Fatalf("HashPassword: %v", err)
+			}
+			if !strings.HasPrefix(hash, tt.prefix) {
+				t.Errorf("hash %q does not start with %q", hash, tt.prefix)
+			}
+			if !ComparePassword("hunter2-but-longer", hash) {
+				t.Error("password does not match its hash")
+			}
+			if ComparePassword("hunter3-but-longer", hash) {
+				t.Error("wrong password matches")
+			}
+			if PasswordNeedsRehash(hash) {
+				t.Error("fresh hash needs a rehash")
+			}
+		})
+	}
+}
+
+func TestPepperRotation(t *testing.T) {
+	usePepper(t, map[string]string{"PASSWORD_PEPPER": testPepperV1})
+	v1Hash, err := HashPassword("hunter2-but-longer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	usePepper(t, nil)
+	plainHash, err := HashPassword("hunter2-but-longer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		hash    string
+		matches bool
+		rehash  bool
+	}{
+		{"unpeppered hash after enabling a pepper", map[string]string{"PASSWORD_PEPPER": testPepperV1}, plainHash, true, true},
+		{"previous pepper", map[string]string{"PASSWORD_PEPPER": testPepperV2, "PASSWORD_PEPPER_ID": "v2", "PASSWORD_PREVIOUS_PEPPERS": "v1:" + testPepperV1}, v1Hash, true, true},
+		{"dropped pepper", map[string]string{"PASSWORD_PEPPER": testPepperV2, "PASSWORD_PEPPER_ID": "v2"}, v1Hash, false, true},
+		{"pepper with a reused id", map[string]string{"PASSWORD_PEPPER": testPepperV2}, v1Hash, false, false},
+		{"pepper disabled", nil, v1Hash, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usePepper(t, tt.env)
+
+			if got := ComparePassword("hunter2-but-longer", tt.hash); got != tt.matches {
+				t.Errorf("ComparePassword = %t, want %t", got, tt.matches)
+			}
+			if got := PasswordNeedsRehash(tt.hash); got != tt.rehash {
+				t.Errorf("PasswordNeedsRehash = %t, want %t", got, tt.rehash)
+			}
+		})
+	}
+}
+
+func TestInitPepperErrors(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString([]byte("too short"))
+
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"not base64", map[string]string{"PASSWORD_PEPPER": "not base64!"}},
+		{"short pepper", map[string]string{"PASSWORD_PEPPER": short}},
+		{"id with colon", map[string]string{"PASSWORD_PEPPER": testPepperV1, "PASSWORD_PEPPER_ID": "a:b"}},
+		{"previous without id", map[string]string{"PASSWORD_PEPPER": testPepperV1, "PASSWORD_PREVIOUS_PEPPERS": testPepperV2}},
+		{"short previous", map[string]string{"PASSWORD_PEPPER": testPepperV1, "PASSWORD_PREVIOUS_PEPPERS": "v0:" + short}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			t.Cleanup(func() {
+				peppers.currentID = ""
+				peppers.keys = nil
+			})
+			if err := InitPepper(); err == nil {
+				t.Error("InitPepper succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm/schema"
+)
+
+// piiConfig controls protection of personal data at rest. When encrypt is set,
+// fields tagged `serializer:pii` are encrypted with EncryptValue. blindIndexKey
+// keys the HMAC used to look encrypted emails up without decrypting them.
+var piiConfig struct {
+	encrypt       bool
+	blindIndexKey []byte
+}
+
+func init() {
+	schema.RegisterSerializer("pii", PIISerializer{})
+}
+
+// InitPII configures PII protection from environment variables:
+// EMAIL_ENCRYPTION=true encrypts user emails at rest and
+// EMAIL_BLIND_INDEX_KEY (base64, at least 32 bytes) keys the email blind
+// index. Encryption requires the blind index key, since encrypted emails
+// can no longer be queried directly.
+func InitPII() error {
+	piiConfig.encrypt, _ = strconv.ParseBool(os.Getenv("EMAIL_ENCRYPTION"))
+	piiConfig.blindIndexKey = nil
+
+	if encoded := os.Getenv("EMAIL_BLIND_INDEX_KEY"); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("decode EMAIL_BLIND_INDEX_KEY: %w", err)
+		}
+		if len(key) < 32 {
+			return errors.New("EMAIL_BLIND_INDEX_KEY must be at least 32 bytes")
+		}
+		piiConfig.blindIndexKey = key
+	}
+
+	if piiConfig.encrypt && piiConfig.blindIndexKey == nil {
+		return errors.New("EMAIL_ENCRYPTION requires EMAIL_BLIND_INDEX_KEY")
+	}
+	return nil
+}
+
+// EmailEncryptionEnabled reports whether emails are encrypted at rest.
+func EmailEncryptionEnabled() bool {
+	return piiConfig.encrypt
+}
+
+// EmailBlindIndexEnabled reports whether a blind index key is configured.
+func EmailBlindIndexEnabled() bool {
+	return piiConfig.blindIndexKey != nil
+}
+
+// EmailBlindIndex returns the keyed HMAC-SHA256 of the normalized email, or
+// nil when no blind index key is configured.
+func EmailBlindIndex(email string) *string {
+	if piiConfig.blindIndexKey == nil {
+		return nil
+	}
+
+	mac := hmac.New(sha256.New, piiConfig.blindIndexKey)
+	mac.Write([]byte(NormalizeEmail(email)))
+	index := hex.EncodeToString(mac.Sum(nil))
+	return &index
+}
+
+// EncryptPII returns value as the pii serializer stores it: encrypted when PII
+// encryption is enabled, unchanged otherwise. Map updates bypass serializers,
+// so they must store PII columns through it.
+func EncryptPII(value string) (string, error) {
+	if value == "" || !piiConfig.encrypt {
+		return value, nil
+	}
+	return EncryptValue(value)
+}
+
+// PIISerializer is a GORM serializer for string fields holding personal data.
+// Values are encrypted on write when PII encryption is enabled, and encrypted
+// values are always decrypted on read; plaintext rows are returned unchanged.
+type PIISerializer struct{}
+
+// Scan implements schema.SerializerInterface.
+func (PIISerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("pii: unsupported value type %T for %s", dbValue, field.Name)
+	}
+
+	if value != "" {
+		plaintext, err := DecryptValue(value)
+		if err != nil {
+			return fmt.Errorf("pii: decrypt %s: %w", field.Name, err)
+		}
+		value = plaintext
+	}
+
+	field.ReflectValueOf(ctx, dst).SetString(value)
+	return nil
+}
+
+// Value implements schema.SerializerValuerInterface.
+func (PIISerializer) Value(_ context.Context, _ *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, _ := fieldValue.(string)
+	return EncryptPII(value)
+}
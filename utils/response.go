@@ -1,8 +1,62 @@
 package utils
 
+import "encoding/json"
+
 type Response struct {
 	Success bool   `json:"success"`
 	Code    uint   `json:"code"`
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
+	Meta    any    `json:"meta,omitempty"` // Pagination metadata (*PageInfo) of list responses
+}
+
+// ReasonError is a client error carrying a machine-readable reason. The error
+// handler returns the reason (and any details) in the response data so
+// clients can branch on it rather than on the message.
+type ReasonError struct {
+	Code    int
+	Reason  string
+	Message string
+	Details map[string]any
+}
+
+// NewReasonError creates a ReasonError.
+func NewReasonError(code int, reason, message string) *ReasonError {
+	return &ReasonError{Code: code, Reason: reason, Message: message}
+}
+
+func (e *ReasonError) Error() string {
+	return e.Message
+}
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. Extensions are written as
+// additional top-level members; they never replace the standard ones.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]any
+}
+
+// MarshalJSON flattens the extension members into the problem object.
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]any, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
 }
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// RiskPolicy weighs the built-in login risk rules and sets the scores at
+// which a risky login needs a CAPTCHA or is refused. The score requiring an
+// emailed code is part of StepUpPolicy. A weight or threshold of 0 disables
+// it.
+type RiskPolicy struct {
+	Enabled          bool     `json:"enabled"`
+	CaptchaScore     int      `json:"captcha_score"`
+	DenyScore        int      `json:"deny_score"`
+	NewDeviceScore   int      `json:"new_device_score"`
+	NewLocationScore int      `json:"new_location_score"`
+	VelocityScore    int      `json:"velocity_score"`
+	VelocityFailures int      `json:"velocity_failures"` // failed logins within VelocityWindow before VelocityScore applies
+	VelocityWindow   Duration `json:"velocity_window"`
+	TorScore         int      `json:"tor_score"`
+	VPNScore         int      `json:"vpn_score"`
+}
+
+// NewRiskPolicy builds a RiskPolicy from environment variables: RISK_SCORING
+// (default false), RISK_CAPTCHA_SCORE (default 30), RISK_DENY_SCORE (default
+// 80), RISK_NEW_DEVICE_SCORE (default 20), RISK_NEW_LOCATION_SCORE (default
+// 20), RISK_VELOCITY_SCORE (default 30), RISK_VELOCITY_FAILURES (default 3),
+// RISK_VELOCITY_WINDOW (default 1h), RISK_TOR_SCORE (default 50) and
+// RISK_VPN_SCORE (default 25).
+func NewRiskPolicy() RiskPolicy {
+	policy := RiskPolicy{
+		CaptchaScore:     30,
+		DenyScore:        80,
+		NewDeviceScore:   20,
+		NewLocationScore: 20,
+		VelocityScore:    30,
+		VelocityFailures: 3,
+		VelocityWindow:   Duration(time.Hour),
+		TorScore:         50,
+		VPNScore:         25,
+	}
+
+	if v, err := strconv.ParseBool(os.Getenv("RISK_SCORING")); err == nil {
+		policy.Enabled = v
+	}
+	for key, field := range map[string]*int{
+		"RISK_CAPTCHA_SCORE":      &policy.CaptchaScore,
+		"RISK_DENY_SCORE":         &policy.DenyScore,
+		"RISK_NEW_DEVICE_SCORE":   &policy.NewDeviceScore,
+		"RISK_NEW_LOCATION_SCORE": &policy.NewLocationScore,
+		"RISK_VELOCITY_SCORE":     &policy.VelocityScore,
+		"RISK_VELOCITY_FAILURES":  &policy.VelocityFailures,
+		"RISK_TOR_SCORE":          &policy.TorScore,
+		"RISK_VPN_SCORE":          &policy.VPNScore,
+	} {
+		if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v >= 0 {
+			*field = v
+		}
+	}
+	if v, err := time.ParseDuration(os.Getenv("RISK_VELOCITY_WINDOW")); err == nil && v > 0 {
+		policy.VelocityWindow = Duration(v)
+	}
+
+	return policy
+}
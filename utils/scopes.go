@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes that personal access tokens and API clients can be granted. Routes
+// require them with middleware.RequireScope. Login tokens are unscoped and
+// pass every scope check.
+const (
+	ScopeProfileRead  = "profile:read"  // Read the user's profile and preferences
+	ScopeProfileWrite = "profile:write" // Change the user's profile, metadata, avatar and preferences
+	ScopeActivityRead = "activity:read" // Read the user's sessions, activity and login history
+	ScopeUsersRead    = "users:read"    // Admins: read the users of the tenant
+	ScopeUsersWrite   = "users:write"   // Admins: manage the users of the tenant
+)
+
+// KnownScopes lists every scope, in the order they are documented.
+var KnownScopes = []string{ScopeProfileRead, ScopeProfileWrite, ScopeActivityRead, ScopeUsersRead, ScopeUsersWrite}
+
+// AdminScopes are the scopes only admins can grant, since their routes
+// require the admin role.
+var AdminScopes = []string{ScopeUsersRead, ScopeUsersWrite}
+
+// ParseScopes checks that scopes is a non-empty list of known scopes and
+// returns it sorted and without duplicates.
+func ParseScopes(scopes []string) ([]string, error) {
+	parsed := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(KnownScopes, scope) {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		parsed = append(parsed, scope)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("at least one scope is required")
+	}
+	slices.Sort(parsed)
+	return slices.Compact(parsed), nil
+}
+
+// ScopeSubset reports whether every scope in scopes is in the
+// space-separated granted list.
+func ScopeSubset(scopes []string, granted string) bool {
+	grantedScopes := strings.Fields(granted)
+	for _, scope := range scopes {
+		if !slices.Contains(grantedScopes, scope) {
+			return false
+		}
+	}
+	return true
+}
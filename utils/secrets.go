@@ -0,0 +1,272 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SecretsProvider fetches settings from a secrets manager, keyed by the env
+// var they replace, e.g. JWT_SECRET, DB_URI or GOOGLE_CLIENT_SECRET.
+type SecretsProvider interface {
+	Name() string
+	FetchSecrets(ctx context.Context) (map[string]string, error)
+}
+
+// NewSecretsProvider builds the provider selected by SECRETS_PROVIDER
+// (vault or awssecretsmanager). It returns nil when none is configured.
+func NewSecretsProvider() (SecretsProvider, error) {
+	switch provider := strings.ToLower(os.Getenv("SECRETS_PROVIDER")); provider {
+	case "":
+		return nil, nil
+	case "vault":
+		return NewVaultSecrets()
+	case "awssecretsmanager":
+		return NewAWSSecretsManager()
+	default:
+		return nil, fmt.Errorf("unsupported SECRETS_PROVIDER %q", provider)
+	}
+}
+
+// LoadSecrets fetches the provider's secrets and sets them as env vars,
+// overriding values from the environment, .env and config files. It returns
+// the names it set.
+func LoadSecrets(ctx context.Context, provider SecretsProvider) ([]string, error) {
+	secrets, err := provider.FetchSecrets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: %w", err)
+	}
+
+	keys := make([]string, 0, len(secrets))
+	for key, value := range secrets {
+		if err := os.Setenv(key, value); err != nil {
+			return nil, fmt.Errorf("secrets: set %s: %w", key, err)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// WatchSecrets fetches the provider's secrets every interval until ctx is
+// done. Values that changed are set as env vars and their names passed to
+// onChange. Failed fetches are logged and retried at the next interval.
+func WatchSecrets(ctx context.Context, provider SecretsProvider, interval time.Duration, onChange func(changed []string)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		secrets, err := provider.FetchSecrets(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("secrets: refresh from %s failed: %v", provider.Name(), err)
+			}
+			continue
+		}
+
+		var changed []string
+		for key, value := range secrets {
+			if current, ok := os.LookupEnv(key); ok && current == value {
+				continue
+			}
+			os.Setenv(key, value)
+			changed = append(changed, key)
+		}
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			onChange(changed)
+		}
+	}
+}
+
+// secretValues converts a decoded secret, whose values may be numbers or
+// booleans, to env var values.
+func secretValues(data map[string]any) map[string]string {
+	values := make(map[string]string, len(data))
+	for key, value := range data {
+		if s, ok := value.(string); ok {
+			values[key] = s
+		} else {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return values
+}
+
+// VaultSecrets implements SecretsProvider by reading one secret from a
+// HashiCorp Vault KV version 2 secrets engine.
+type VaultSecrets struct {
+	addr   string
+	token  string
+	mount  string
+	path   string
+	client *http.Client
+}
+
+// NewVaultSecrets builds a VaultSecrets from environment variables:
+// VAULT_ADDR, VAULT_TOKEN, VAULT_SECRETS_PATH and optionally
+// VAULT_SECRETS_MOUNT (default "secret").
+func NewVaultSecrets() (*VaultSecrets, error) {
+	p := &VaultSecrets{
+		addr:   strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		token:  os.Getenv("VAULT_TOKEN"),
+		mount:  strings.Trim(os.Getenv("VAULT_SECRETS_MOUNT"), "/"),
+		path:   strings.Trim(os.Getenv("VAULT_SECRETS_PATH"), "/"),
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if p.addr == "" || p.token == "" || p.path == "" {
+		return nil, errors.New("VAULT_ADDR, VAULT_TOKEN and VAULT_SECRETS_PATH are required for the vault secrets provider")
+	}
+	if p.mount == "" {
+		p.mount = "secret"
+	}
+
+	return p, nil
+}
+
+// Name implements SecretsProvider.
+func (p *VaultSecrets) Name() string {
+	return "vault"
+}
+
+// FetchSecrets implements SecretsProvider.
+func (p *VaultSecrets) FetchSecrets(ctx context.Context) (map[string]string, error) {
+	path := fmt.Sprintf("/v1/%s/data/%s", p.mount, p.path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.addr+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: read response: %w", path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault %s returned status %d: %s", path, resp.StatusCode, body)
+	}
+
+	var out struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("vault %s: decode response: %w", path, err)
+	}
+	return secretValues(out.Data.Data), nil
+}
+
+// AWSSecretsManager implements SecretsProvider by reading one secret, stored
+// as a JSON object, from AWS Secrets Manager. Requests are signed with SigV4
+// using static credentials from the standard AWS_* environment variables.
+type AWSSecretsManager struct {
+	secretID     string
+	region       string
+	endpoint     string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+}
+
+// NewAWSSecretsManager builds an AWSSecretsManager from environment
+// variables: AWS_SECRETS_MANAGER_SECRET_ID, AWS_REGION, AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and optionally AWS_SESSION_TOKEN and
+// AWS_SECRETS_MANAGER_ENDPOINT.
+func NewAWSSecretsManager() (*AWSSecretsManager, error) {
+	p := &AWSSecretsManager{
+		secretID:     os.Getenv("AWS_SECRETS_MANAGER_SECRET_ID"),
+		region:       os.Getenv("AWS_REGION"),
+		endpoint:     os.Getenv("AWS_SECRETS_MANAGER_ENDPOINT"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if p.secretID == "" || p.region == "" {
+		return nil, errors.New("AWS_SECRETS_MANAGER_SECRET_ID and AWS_REGION are required for the awssecretsmanager provider")
+	}
+	if p.accessKey == "" || p.secretKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required for the awssecretsmanager provider")
+	}
+	if p.endpoint == "" {
+		p.endpoint = fmt.Sprintf("https://secretsmanager.%s.amazonaws.com/", p.region)
+	}
+
+	return p, nil
+}
+
+// Name implements SecretsProvider.
+func (p *AWSSecretsManager) Name() string {
+	return "awssecretsmanager"
+}
+
+// FetchSecrets implements SecretsProvider.
+func (p *AWSSecretsManager) FetchSecrets(ctx context.Context) (map[string]string, error) {
+	payload, err := json.Marshal(map[string]any{"SecretId": p.secretID})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	creds := awsCredentials{accessKey: p.accessKey, secretKey: p.secretKey, sessionToken: p.sessionToken}
+	signAWSRequest(req, payload, time.Now().UTC(), creds, p.region, "secretsmanager")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("secretsmanager GetSecretValue: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("secretsmanager GetSecretValue: read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secretsmanager GetSecretValue returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("secretsmanager GetSecretValue: decode response: %w", err)
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal([]byte(out.SecretString), &data); err != nil {
+		return nil, errors.New("secretsmanager: secret must be a JSON object of setting names to values")
+	}
+	return secretValues(data), nil
+}
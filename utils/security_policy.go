@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// SecurityPolicy groups the tunable security settings enforced by the auth
+// handlers. Defaults come from environment variables; admins can override
+// them at runtime through the security policy API.
+type SecurityPolicy struct {
+	Lockout            LockoutPolicy  `json:"lockout"`
+	Password           PasswordPolicy `json:"password"`
+	Session            SessionPolicy  `json:"session"`
+	StepUp             StepUpPolicy   `json:"step_up"`
+	Risk               RiskPolicy     `json:"risk"`
+	ImpossibleTravel   TravelPolicy   `json:"impossible_travel"`
+	CredentialStuffing StuffingPolicy `json:"credential_stuffing"`
+}
+
+// NewSecurityPolicy builds the configured default policy from environment
+// variables.
+func NewSecurityPolicy() SecurityPolicy {
+	return SecurityPolicy{
+		Lockout:            NewLockoutPolicy(),
+		Password:           NewPasswordPolicy(),
+		Session:            NewSessionPolicy(),
+		StepUp:             NewStepUpPolicy(),
+		Risk:               NewRiskPolicy(),
+		ImpossibleTravel:   NewTravelPolicy(),
+		CredentialStuffing: NewStuffingPolicy(),
+	}
+}
+
+// Validate checks that the settings are usable. The returned error message is
+// safe to show to clients.
+func (p SecurityPolicy) Validate() error {
+	switch {
+	case p.Lockout.Threshold < 0:
+		return errors.New("lockout.threshold must not be negative")
+	case p.Lockout.BaseDuration <= 0:
+		return errors.New("lockout.base_duration must be positive")
+	case p.Lockout.MaxDuration < p.Lockout.BaseDuration:
+		return errors.New("lockout.max_duration must not be shorter than lockout.base_duration")
+	case p.Password.MinLength <= 0:
+		return errors.New("password.min_length must be positive")
+	case p.Password.MaxLength > bcryptMaxBytes:
+		return fmt.Errorf("password.max_length must not exceed %d", bcryptMaxBytes)
+	case p.Password.MaxLength < p.Password.MinLength:
+		return errors.New("password.max_length must not be less than password.min_length")
+	case p.Password.MinScore < 0 || p.Password.MinScore > 4:
+		return errors.New("password.min_score must be between 0 and 4")
+	case p.Session.TTL <= 0:
+		return errors.New("session.ttl must be positive")
+	case p.Session.MaxPerUser < 0:
+		return errors.New("session.max_per_user must not be negative")
+	case p.StepUp.RiskScore < 0:
+		return errors.New("step_up.risk_score must not be negative")
+	case p.StepUp.ChallengeTTL <= 0:
+		return errors.New("step_up.challenge_ttl must be positive")
+	case p.StepUp.RecentLogin < 0:
+		return errors.New("step_up.recent_login must not be negative")
+	case p.Risk.CaptchaScore < 0 || p.Risk.DenyScore < 0:
+		return errors.New("risk.captcha_score and risk.deny_score must not be negative")
+	case p.Risk.NewDeviceScore < 0 || p.Risk.NewLocationScore < 0 || p.Risk.VelocityScore < 0 || p.Risk.TorScore < 0 || p.Risk.VPNScore < 0:
+		return errors.New("risk rule scores must not be negative")
+	case p.Risk.VelocityFailures < 0:
+		return errors.New("risk.velocity_failures must not be negative")
+	case p.Risk.VelocityWindow <= 0:
+		return errors.New("risk.velocity_window must be positive")
+	case p.ImpossibleTravel.MaxSpeedKmh <= 0:
+		return errors.New("impossible_travel.max_speed_kmh must be positive")
+	case p.ImpossibleTravel.MinDistanceKm < 0:
+		return errors.New("impossible_travel.min_distance_km must not be negative")
+	case p.CredentialStuffing.Window <= 0:
+		return errors.New("credential_stuffing.window must be positive")
+	case p.CredentialStuffing.CaptchaAccounts < 0 || p.CredentialStuffing.BlockAccounts < 0 || p.CredentialStuffing.ASNAccounts < 0:
+		return errors.New("credential_stuffing account thresholds must not be negative")
+	case p.CredentialStuffing.BlockDuration <= 0:
+		return errors.New("credential_stuffing.block_duration must be positive")
+	}
+	return nil
+}
+
+// Duration is a time.Duration that is encoded in JSON as a Go duration
+// string such as "15m" or "24h".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Invalid values are reported as
+// a *json.UnmarshalTypeError, like other type mismatches.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &json.UnmarshalTypeError{Value: string(data), Type: reflect.TypeFor[Duration]()}
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: strconv.Quote(s), Type: reflect.TypeFor[Duration]()}
+	}
+	*d = Duration(v)
+	return nil
+}
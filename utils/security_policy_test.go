@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSecurityPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(p *SecurityPolicy)
+		valid  bool
+	}{
+		{"defaults", func(p *SecurityPolicy) {}, true},
+		{"lockout disabled", func(p *SecurityPolicy) { p.Lockout.Threshold = 0 }, true},
+		{"negative lockout threshold", func(p *SecurityPolicy) { p.Lockout.Threshold = -1 }, false},
+		{"zero lockout duration", func(p *SecurityPolicy) { p.Lockout.BaseDuration = 0 }, false},
+		{"max lockout below base", func(p *SecurityPolicy) { p.Lockout.MaxDuration = p.Lockout.BaseDuration - 1 }, false},
+		{"zero password length", func(p *SecurityPolicy) { p.Password.MinLength = 0 }, false},
+		{"password longer than bcrypt input", func(p *SecurityPolicy) { p.Password.MaxLength = bcryptMaxBytes + 1 }, false},
+		{"max password below min", func(p *SecurityPolicy) { p.Password.MaxLength = p.Password.MinLength - 1 }, false},
+		{"password score above 4", func(p *SecurityPolicy) { p.Password.MinScore = 5 }, false},
+		{"zero session ttl", func(p *SecurityPolicy) { p.Session.TTL = 0 }, false},
+		{"negative session cap", func(p *SecurityPolicy) { p.Session.MaxPerUser = -1 }, false},
+		{"negative step-up score", func(p *SecurityPolicy) { p.StepUp.RiskScore = -1 }, false},
+		{"zero step-up ttl", func(p *SecurityPolicy) { p.StepUp.ChallengeTTL = 0 }, false},
+		{"zero recent login", func(p *SecurityPolicy) { p.StepUp.RecentLogin = 0 }, true},
+		{"negative recent login", func(p *SecurityPolicy) { p.StepUp.RecentLogin = -1 }, false},
+		{"negative deny score", func(p *SecurityPolicy) { p.Risk.DenyScore = -1 }, false},
+		{"negative rule score", func(p *SecurityPolicy) { p.Risk.TorScore = -1 }, false},
+		{"negative velocity failures", func(p *SecurityPolicy) { p.Risk.VelocityFailures = -1 }, false},
+		{"zero velocity window", func(p *SecurityPolicy) { p.Risk.VelocityWindow = 0 }, false},
+		{"zero travel speed", func(p *SecurityPolicy) { p.ImpossibleTravel.MaxSpeedKmh = 0 }, false},
+		{"negative travel distance", func(p *SecurityPolicy) { p.ImpossibleTravel.MinDistanceKm = -1 }, false},
+		{"zero stuffing window", func(p *SecurityPolicy) { p.CredentialStuffing.Window = 0 }, false},
+		{"negative stuffing threshold", func(p *SecurityPolicy) { p.CredentialStuffing.ASNAccounts = -1 }, false},
+		{"zero stuffing block", func(p *SecurityPolicy) { p.CredentialStuffing.BlockDuration = 0 }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewSecurityPolicy()
+			tt.modify(&policy)
+
+			err := policy.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Validate succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		json  string
+		want  Duration
+		valid bool
+	}{
+		{`"15m"`, Duration(15 * time.Minute), true},
+		{`"1h30m"`, Duration(90 * time.Minute), true},
+		{`"0s"`, 0, true},
+		{`"15 minutes"`, 0, false},
+		{`900`, 0, false},
+		{`null`, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var got Duration
+			err := json.Unmarshal([]byte(tt.json), &got)
+			if !tt.valid {
+				if _, ok := err.(*json.UnmarshalTypeError); !ok {
+					t.Errorf("got error %v, want a *json.UnmarshalTypeError", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %v, %v; want %v", time.Duration(got), err, time.Duration(tt.want))
+			}
+
+			encoded, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var again Duration
+			if err := json.Unmarshal(encoded, &again); err != nil || again != got {
+				t.Errorf("round trip through %s gave %v, %v", encoded, time.Duration(again), err)
+			}
+		})
+	}
+}
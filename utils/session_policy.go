@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// SessionPolicy controls the lifetime of refresh-token sessions and how many
+// a user may hold at once. When MaxPerUser is reached, signing in again
+// revokes the user's oldest sessions.
+type SessionPolicy struct {
+	TTL        Duration `json:"ttl"`
+	MaxPerUser int      `json:"max_per_user"` // 0 means unlimited
+}
+
+// NewSessionPolicy builds a SessionPolicy from environment variables:
+// SESSION_TTL (default 720h) and SESSION_MAX_PER_USER (default 0).
+func NewSessionPolicy() SessionPolicy {
+	policy := SessionPolicy{
+		TTL: Duration(30 * 24 * time.Hour),
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil && v > 0 {
+		policy.TTL = Duration(v)
+	}
+	if v, err := strconv.Atoi(os.Getenv("SESSION_MAX_PER_USER")); err == nil && v >= 0 {
+		policy.MaxPerUser = v
+	}
+
+	return policy
+}
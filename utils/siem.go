@@ -0,0 +1,387 @@
+package utils
+
+import (
+	"api/database/models"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// siemBatchSize caps how many queued events are sent in one HTTP request.
+const siemBatchSize = 100
+
+// SIEMSink delivers formatted events, one per line, to an external system.
+type SIEMSink interface {
+	Send(ctx context.Context, lines [][]byte) error
+}
+
+// SIEMStreamer formats audit events and streams them to a SIEMSink from a
+// background goroutine, so auditing never blocks a request. Events are
+// dropped when the queue is full.
+type SIEMStreamer struct {
+	format string // "json" or "cef"
+	sink   SIEMSink
+	queue  chan models.AuditLog
+
+	mu     sync.RWMutex // Guards closed against Publish racing Close
+	closed bool
+	done   chan struct{} // Closed once the queue is drained
+}
+
+// NewSIEMStreamer builds a streamer from environment variables: SIEM_SINK
+// (syslog or http), SIEM_FORMAT (json or cef, default json) and
+// SIEM_QUEUE_SIZE (default 1000). The syslog sink reads SIEM_SYSLOG_ADDR as
+// udp://, tcp:// or tls://host:port; the http sink posts to SIEM_HTTP_URL
+// with the optional SIEM_HTTP_AUTHORIZATION header. It returns nil when
+// SIEM_SINK is not set.
+func NewSIEMStreamer() (*SIEMStreamer, error) {
+	kind := strings.ToLower(os.Getenv("SIEM_SINK"))
+	if kind == "" {
+		return nil, nil
+	}
+
+	format := strings.ToLower(os.Getenv("SIEM_FORMAT"))
+	switch format {
+	case "":
+		format = "json"
+	case "json", "cef":
+	default:
+		return nil, fmt.Errorf("unsupported SIEM_FORMAT %q", format)
+	}
+
+	streamer := &SIEMStreamer{format: format}
+
+	switch kind {
+	case "syslog":
+		addr := os.Getenv("SIEM_SYSLOG_ADDR")
+		if addr == "" {
+			return nil, errors.New("SIEM_SYSLOG_ADDR is not configured")
+		}
+		s, err := NewSyslogSink(addr)
+		if err != nil {
+			return nil, err
+		}
+		streamer.sink = s
+	case "http":
+		endpoint := os.Getenv("SIEM_HTTP_URL")
+		if endpoint == "" {
+			return nil, errors.New("SIEM_HTTP_URL is not configured")
+		}
+		streamer.sink = NewHTTPSink(endpoint, os.Getenv("SIEM_HTTP_AUTHORIZATION"), streamer.contentType())
+	default:
+		return nil, fmt.Errorf("unsupported SIEM_SINK %q", kind)
+	}
+
+	size := 1000
+	if v, err := strconv.Atoi(os.Getenv("SIEM_QUEUE_SIZE")); err == nil && v > 0 {
+		size = v
+	}
+
+	streamer.queue = make(chan models.AuditLog, size)
+	streamer.done = make(chan struct{})
+	go streamer.run()
+	return streamer, nil
+}
+
+// Publish queues an event for delivery without blocking.
+func (s *SIEMStreamer) Publish(entry models.AuditLog) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		log.Printf("siem: closed, dropping %s event", entry.Event)
+		return
+	}
+	select {
+	case s.queue <- entry:
+	default:
+		log.Printf("siem: queue full, dropping %s event", entry.Event)
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are sent or
+// ctx is done.
+func (s *SIEMStreamer) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("siem: queued events not all sent: %w", ctx.Err())
+	}
+}
+
+func (s *SIEMStreamer) run() {
+	defer close(s.done)
+	if sink, ok := s.sink.(*SyslogSink); ok {
+		defer sink.close()
+	}
+
+	for entry := range s.queue {
+		batch := [][]byte{s.formatEvent(entry)}
+
+		// Drain whatever else is already queued into the same batch
+	drain:
+		for len(batch) < siemBatchSize {
+			select {
+			case next := <-s.queue:
+				batch = append(batch, s.formatEvent(next))
+			default:
+				break drain
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := s.sink.Send(ctx, batch); err != nil {
+			log.Printf("siem: failed to deliver %d events: %v", len(batch), err)
+		}
+		cancel()
+	}
+}
+
+func (s *SIEMStreamer) contentType() string {
+	if s.format == "cef" {
+		return "text/plain"
+	}
+	return "application/x-ndjson"
+}
+
+func (s *SIEMStreamer) formatEvent(entry models.AuditLog) []byte {
+	if s.format == "cef" {
+		return FormatCEF(entry)
+	}
+	return FormatJSONLine(entry)
+}
+
+// FormatJSONLine encodes an audit event as a single JSON object.
+func FormatJSONLine(entry models.AuditLog) []byte {
+	event := struct {
+		Timestamp time.Time         `json:"timestamp"`
+		Source    string            `json:"source"`
+		Event     models.AuditEvent `json:"event"`
+		Severity  int               `json:"severity"`
+		UserID    *uint             `json:"user_id,omitempty"`
+		ActorID   *uint             `json:"actor_id,omitempty"`
+		IPAddress string            `json:"ip_address,omitempty"`
+		UserAgent string            `json:"user_agent,omitempty"`
+		Metadata  json.RawMessage   `json:"metadata,omitempty"`
+	}{
+		Timestamp: entry.CreatedAt.UTC(),
+		Source:    "go-auth",
+		Event:     entry.Event,
+		Severity:  eventSeverity(entry.Event),
+		UserID:    entry.UserID,
+		ActorID:   entry.ActorID,
+		IPAddress: entry.IPAddress,
+		UserAgent: entry.UserAgent,
+	}
+	if json.Valid([]byte(entry.Metadata)) {
+		event.Metadata = json.RawMessage(entry.Metadata)
+	}
+
+	line, _ := json.Marshal(event)
+	return line
+}
+
+// FormatCEF encodes an audit event in ArcSight Common Event Format.
+func FormatCEF(entry models.AuditLog) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|Asuna Labs|go-auth|1.0|%s|%s|%d|",
+		cefHeader(string(entry.Event)), cefHeader(string(entry.Event)), eventSeverity(entry.Event))
+
+	ext := []string{"rt=" + strconv.FormatInt(entry.CreatedAt.UnixMilli(), 10)}
+	if entry.IPAddress != "" {
+		ext = append(ext, "src="+cefValue(entry.IPAddress))
+	}
+	if entry.ActorID != nil {
+		ext = append(ext, "suid="+strconv.FormatUint(uint64(*entry.ActorID), 10))
+	}
+	if entry.UserID != nil {
+		ext = append(ext, "duid="+strconv.FormatUint(uint64(*entry.UserID), 10))
+	}
+	if entry.UserAgent != "" {
+		ext = append(ext, "requestClientApplication="+cefValue(entry.UserAgent))
+	}
+	if entry.Metadata != "" && entry.Metadata != "{}" {
+		ext = append(ext, "msg="+cefValue(entry.Metadata))
+	}
+	b.WriteString(strings.Join(ext, " "))
+
+	return []byte(b.String())
+}
+
+func cefHeader(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ").Replace(s)
+}
+
+func cefValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
+
+// eventSeverity maps an audit event to a CEF severity (0-10).
+func eventSeverity(event models.AuditEvent) int {
+	switch event {
+	case models.AuditEventCredentialStuffingBlocked,
+		models.AuditEventImpossibleTravel,
+		models.AuditEventTLSFingerprintMismatch:
+		return 8
+	case models.AuditEventCredentialStuffingSuspected,
+		models.AuditEventLoginRisk,
+		models.AuditEventRegistrationBotSuspected,
+		models.AuditEventUserBanned,
+		models.AuditEventUserSuspended:
+		return 6
+	case models.AuditEventUserRoleChanged,
+		models.AuditEventSecurityPolicyUpdated,
+		models.AuditEventSecurityPolicyReset:
+		return 5
+	default:
+		return 3
+	}
+}
+
+// SyslogSink writes RFC 5424 messages over UDP, TCP or TLS. Stream
+// connections are newline-framed and re-established after a write error.
+type SyslogSink struct {
+	network  string
+	addr     string
+	useTLS   bool
+	hostname string
+	conn     net.Conn
+}
+
+// NewSyslogSink parses an address such as udp://siem.internal:514 or
+// tls://siem.internal:6514.
+func NewSyslogSink(rawURL string) (*SyslogSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid SIEM_SYSLOG_ADDR %q", rawURL)
+	}
+
+	sink := &SyslogSink{addr: u.Host}
+	switch u.Scheme {
+	case "udp", "tcp":
+		sink.network = u.Scheme
+	case "tls":
+		sink.network, sink.useTLS = "tcp", true
+	default:
+		return nil, fmt.Errorf("unsupported syslog scheme %q", u.Scheme)
+	}
+
+	sink.hostname, _ = os.Hostname()
+	if sink.hostname == "" {
+		sink.hostname = "-"
+	}
+	return sink, nil
+}
+
+// Send implements SIEMSink. It is only called from the streamer goroutine.
+func (s *SyslogSink) Send(ctx context.Context, lines [][]byte) error {
+	for _, line := range lines {
+		if err := s.write(ctx, line); err != nil {
+			// Reconnect once in case the server dropped an idle connection
+			s.close()
+			if err := s.write(ctx, line); err != nil {
+				s.close()
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *SyslogSink) write(ctx context.Context, line []byte) error {
+	if s.conn == nil {
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		var err error
+		if s.useTLS {
+			s.conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, s.network, s.addr)
+		} else {
+			s.conn, err = dialer.DialContext(ctx, s.network, s.addr)
+		}
+		if err != nil {
+			return fmt.Errorf("syslog dial: %w", err)
+		}
+	}
+
+	// Facility authpriv (10), severity notice (5)
+	msg := fmt.Sprintf("<%d>1 %s %s go-auth - - - %s\n",
+		10*8+5, time.Now().UTC().Format(time.RFC3339), s.hostname, line)
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *SyslogSink) close() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// HTTPSink posts newline-delimited events to a collector such as a Splunk
+// HEC raw endpoint or Logstash http input.
+type HTTPSink struct {
+	endpoint      string
+	authorization string
+	contentType   string
+	client        *http.Client
+}
+
+// NewHTTPSink returns a sink posting to endpoint with the given content type.
+// authorization, if set, is sent as the Authorization header (e.g.
+// "Splunk <token>").
+func NewHTTPSink(endpoint, authorization, contentType string) *HTTPSink {
+	return &HTTPSink{
+		endpoint:      endpoint,
+		authorization: authorization,
+		contentType:   contentType,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send implements SIEMSink.
+func (s *HTTPSink) Send(ctx context.Context, lines [][]byte) error {
+	body := bytes.Join(lines, []byte("\n"))
+	body = append(body, '\n')
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build siem request: %w", err)
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("siem post: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("siem endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
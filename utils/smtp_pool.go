@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"time"
+)
+
+// smtpPool keeps authenticated SMTP connections open between messages so
+// bulk sends skip the dial, STARTTLS and AUTH round trips. At most size
+// connections are open at once; callers wait for a free one.
+type smtpPool struct {
+	slots       chan struct{}  // One token per open or dialing connection
+	idle        chan *smtpConn // Connections ready for the next message
+	maxMessages int            // Messages per connection before it is recycled; 0 is unlimited
+	idleTimeout time.Duration  // Idle connections older than this are closed instead of reused
+}
+
+// smtpConn is one authenticated connection to the SMTP server.
+type smtpConn struct {
+	client   *smtp.Client
+	conn     net.Conn
+	messages int
+	lastUsed time.Time
+}
+
+// newSMTPPoolFromEnv reads SMTP_POOL_SIZE (default 0, which dials per
+// message), SMTP_POOL_MAX_MESSAGES (default 100) and SMTP_POOL_IDLE_TIMEOUT
+// (default 30s; most servers drop idle clients after a minute or so).
+func newSMTPPoolFromEnv() *smtpPool {
+	size, err := strconv.Atoi(os.Getenv("SMTP_POOL_SIZE"))
+	if err != nil || size <= 0 {
+		return nil
+	}
+
+	p := &smtpPool{
+		slots:       make(chan struct{}, size),
+		idle:        make(chan *smtpConn, size),
+		maxMessages: 100,
+		idleTimeout: 30 * time.Second,
+	}
+	if v, err := strconv.Atoi(os.Getenv("SMTP_POOL_MAX_MESSAGES")); err == nil && v >= 0 {
+		p.maxMessages = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("SMTP_POOL_IDLE_TIMEOUT")); err == nil && v > 0 {
+		p.idleTimeout = v
+	}
+	return p
+}
+
+// acquire returns a connection ready for a new message: a healthy idle one
+// from the pool if possible, otherwise a freshly dialed one.
+func (s *SMTPClient) acquire(ctx context.Context) (*smtpConn, error) {
+	if s.pool == nil {
+		return s.dial(ctx)
+	}
+
+	for {
+		select {
+		case c := <-s.pool.idle:
+			if c.healthy(ctx, s.pool.idleTimeout) {
+				return c, nil
+			}
+			c.close()
+			<-s.pool.slots
+		case s.pool.slots <- struct{}{}:
+			c, err := s.dial(ctx)
+			if err != nil {
+				<-s.pool.slots
+				return nil, err
+			}
+			return c, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release hands a connection back after a send. Connections that failed or
+// reached maxMessages are closed; without a pool every connection is.
+func (s *SMTPClient) release(c *smtpConn, sendErr error) {
+	if s.pool == nil {
+		c.quit()
+		return
+	}
+
+	c.messages++
+	c.lastUsed = time.Now()
+	if sendErr != nil || (s.pool.maxMessages > 0 && c.messages >= s.pool.maxMessages) {
+		c.quit()
+		<-s.pool.slots
+		return
+	}
+	s.pool.idle <- c
+}
+
+// dial connects to the server, upgrades to TLS and authenticates.
+func (s *SMTPClient) dial(ctx context.Context) (*smtpConn, error) {
+	var conn net.Conn
+	var err error
+	d := net.Dialer{Timeout: s.timeout}
+
+	if s.useTLS {
+		// implicit TLS (port 465)
+		tlsConfig := &tls.Config{ServerName: s.host}
+		conn, err = tls.DialWithDialer(&d, "tcp", s.addr, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("tls dial: %w", err)
+		}
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", s.addr)
+		if err != nil {
+			return nil, fmt.Errorf("dial smtp: %w", err)
+		}
+	}
+
+	// Bound the handshake; send sets a fresh deadline per message
+	conn.SetDeadline(connDeadline(ctx, s.timeout))
+
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp client: %w", err)
+	}
+
+	// If using STARTTLS (typically port 587), upgrade the connection.
+	if !s.useTLS {
+		tlsConfig := &tls.Config{ServerName: s.host}
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(tlsConfig); err != nil {
+				c.Close()
+				return nil, fmt.Errorf("starttls: %w", err)
+			}
+		}
+	}
+
+	// Authenticate if credentials are present
+	if s.email != "" && s.password != "" {
+		if err = c.Auth(s.auth); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	return &smtpConn{client: c, conn: conn, lastUsed: time.Now()}, nil
+}
+
+// send runs one mail transaction on the connection.
+func (c *smtpConn) send(ctx context.Context, from string, to []string, data []byte) error {
+	c.conn.SetDeadline(connDeadline(ctx, 30*time.Second))
+
+	if err := c.client.Mail(from); err != nil {
+		return fmt.Errorf("mail from: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := c.client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("rcpt %s: %w", rcpt, err)
+		}
+	}
+
+	w, err := c.client.Data()
+	if err != nil {
+		return fmt.Errorf("data: %w", err)
+	}
+	if _, err = w.Write(data); err != nil {
+		w.Close()
+		return fmt.Errorf("write message: %w", err)
+	}
+	if err = w.Close(); err != nil {
+		return fmt.Errorf("close write: %w", err)
+	}
+	return nil
+}
+
+// healthy reports whether an idle connection can take another message. The
+// server must still answer RSET, which also clears any leftover transaction.
+func (c *smtpConn) healthy(ctx context.Context, idleTimeout time.Duration) bool {
+	if time.Since(c.lastUsed) > idleTimeout {
+		return false
+	}
+	c.conn.SetDeadline(connDeadline(ctx, 5*time.Second))
+	return c.client.Reset() == nil
+}
+
+// quit ends the session politely, falling back to dropping the connection.
+func (c *smtpConn) quit() {
+	c.conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if c.client.Quit() != nil {
+		c.close()
+	}
+}
+
+func (c *smtpConn) close() {
+	c.client.Close()
+}
+
+// connDeadline returns the context deadline, or now+fallback without one.
+func connDeadline(ctx context.Context, fallback time.Duration) time.Time {
+	if deadline, ok := ctx.Deadline(); ok {
+		return deadline
+	}
+	return time.Now().Add(fallback)
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// StepUpPolicy controls when a login whose password was accepted must also
+// be completed with a one-time code emailed to the user. One code satisfies
+// every check that asks for it during the same login. Sensitive account
+// changes ask for a code too unless the session signed in recently.
+type StepUpPolicy struct {
+	RiskScore        int      `json:"risk_score"`         // require a code at or above this risk score; 0 disables
+	ImpossibleTravel bool     `json:"impossible_travel"`  // require a code for impossible-travel logins
+	RequireForAdmins bool     `json:"require_for_admins"` // require a code for every password login of an admin
+	ChallengeTTL     Duration `json:"challenge_ttl"`      // validity of an emailed code
+	RecentLogin      Duration `json:"recent_login"`       // sessions this young make sensitive changes without a code; 0 always asks
+}
+
+// NewStepUpPolicy builds a StepUpPolicy from environment variables:
+// RISK_CHALLENGE_SCORE (default 50), GEO_STEP_UP (default false),
+// STEP_UP_REQUIRE_ADMINS (default false), GEO_STEP_UP_TTL (default 10m) and
+// STEP_UP_RECENT_LOGIN (default 10m).
+func NewStepUpPolicy() StepUpPolicy {
+	policy := StepUpPolicy{
+		RiskScore:    50,
+		ChallengeTTL: Duration(10 * time.Minute),
+		RecentLogin:  Duration(10 * time.Minute),
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("RISK_CHALLENGE_SCORE")); err == nil && v >= 0 {
+		policy.RiskScore = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("GEO_STEP_UP")); err == nil {
+		policy.ImpossibleTravel = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("STEP_UP_REQUIRE_ADMINS")); err == nil {
+		policy.RequireForAdmins = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("GEO_STEP_UP_TTL")); err == nil && v > 0 {
+		policy.ChallengeTTL = Duration(v)
+	}
+	if v, err := time.ParseDuration(os.Getenv("STEP_UP_RECENT_LOGIN")); err == nil && v >= 0 {
+		policy.RecentLogin = Duration(v)
+	}
+
+	return policy
+}
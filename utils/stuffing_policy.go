@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// StuffingPolicy sets the credential-stuffing thresholds. A burst is the
+// number of distinct accounts with failed logins from one IP or ASN within
+// Window. A threshold of 0 disables its action.
+type StuffingPolicy struct {
+	Window          Duration `json:"window"`
+	CaptchaAccounts int      `json:"captcha_accounts"` // accounts per IP before a CAPTCHA is required
+	BlockAccounts   int      `json:"block_accounts"`   // accounts per IP before the IP is blocked
+	ASNAccounts     int      `json:"asn_accounts"`     // accounts per ASN before a CAPTCHA is required
+	BlockDuration   Duration `json:"block_duration"`
+}
+
+// NewStuffingPolicy builds a StuffingPolicy from environment variables:
+// STUFFING_WINDOW (default 10m), STUFFING_CAPTCHA_ACCOUNTS (default 5),
+// STUFFING_BLOCK_ACCOUNTS (default 20), STUFFING_ASN_ACCOUNTS (default 50)
+// and STUFFING_BLOCK_DURATION (default 1h).
+func NewStuffingPolicy() StuffingPolicy {
+	policy := StuffingPolicy{
+		Window:          Duration(10 * time.Minute),
+		CaptchaAccounts: 5,
+		BlockAccounts:   20,
+		ASNAccounts:     50,
+		BlockDuration:   Duration(time.Hour),
+	}
+
+	if v, err := time.ParseDuration(os.Getenv("STUFFING_WINDOW")); err == nil && v > 0 {
+		policy.Window = Duration(v)
+	}
+	if v, err := strconv.Atoi(os.Getenv("STUFFING_CAPTCHA_ACCOUNTS")); err == nil && v >= 0 {
+		policy.CaptchaAccounts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("STUFFING_BLOCK_ACCOUNTS")); err == nil && v >= 0 {
+		policy.BlockAccounts = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("STUFFING_ASN_ACCOUNTS")); err == nil && v >= 0 {
+		policy.ASNAccounts = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("STUFFING_BLOCK_DURATION")); err == nil && v > 0 {
+		policy.BlockDuration = Duration(v)
+	}
+
+	return policy
+}
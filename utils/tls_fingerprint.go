@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// tlsFingerprints maps the remote address of each live TLS connection to the
+// JA3 fingerprint of its ClientHello. Entries are removed when the connection
+// closes.
+var tlsFingerprints sync.Map
+
+// ListenTLS listens on addr and terminates TLS with the given certificate,
+// recording the JA3 fingerprint of every client so handlers can read it with
+// TLSFingerprint.
+func ListenTLS(addr, certFile, keyFile string) (net.Listener, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+
+	return listenTLS(addr, &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	})
+}
+
+// listenTLS listens on addr and terminates TLS with config, recording client
+// fingerprints.
+func listenTLS(addr string, config *tls.Config) (net.Listener, error) {
+	config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		tlsFingerprints.Store(hello.Conn.RemoteAddr().String(), JA3(hello))
+		return nil, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(fingerprintListener{ln}, config), nil
+}
+
+// TLSFingerprint returns the JA3 fingerprint recorded for the connection from
+// remoteAddr, or "" when the connection did not go through ListenTLS.
+func TLSFingerprint(remoteAddr net.Addr) string {
+	if remoteAddr == nil {
+		return ""
+	}
+	if fingerprint, ok := tlsFingerprints.Load(remoteAddr.String()); ok {
+		return fingerprint.(string)
+	}
+	return ""
+}
+
+// JA3 returns the MD5 JA3 fingerprint of a ClientHello: the TLS version,
+// cipher suites, extensions, curves and point formats, with GREASE values
+// removed. Extensions are sorted because browsers such as Chrome randomize
+// their order on every connection. ClientHelloInfo does not expose the legacy
+// version field, so it is derived from the highest supported version, capped
+// at TLS 1.2 as on the wire.
+func JA3(hello *tls.ClientHelloInfo) string {
+	var version uint16
+	for _, v := range hello.SupportedVersions {
+		if !isGREASE(v) && v > version {
+			version = v
+		}
+	}
+	if version > tls.VersionTLS12 {
+		version = tls.VersionTLS12
+	}
+
+	extensions := slices.Clone(hello.Extensions)
+	slices.Sort(extensions)
+
+	curves := make([]uint16, len(hello.SupportedCurves))
+	for i, curve := range hello.SupportedCurves {
+		curves[i] = uint16(curve)
+	}
+	points := make([]uint16, len(hello.SupportedPoints))
+	for i, point := range hello.SupportedPoints {
+		points[i] = uint16(point)
+	}
+
+	raw := strings.Join([]string{
+		strconv.Itoa(int(version)),
+		joinJA3Values(hello.CipherSuites),
+		joinJA3Values(extensions),
+		joinJA3Values(curves),
+		joinJA3Values(points),
+	}, ",")
+
+	sum := md5.Sum([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func joinJA3Values(values []uint16) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		if !isGREASE(v) {
+			parts = append(parts, strconv.Itoa(int(v)))
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// isGREASE reports whether v is a reserved GREASE value (RFC 8701), which
+// clients randomize and JA3 ignores.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+// fingerprintListener wraps accepted connections so their fingerprint is
+// forgotten when they close.
+type fingerprintListener struct {
+	net.Listener
+}
+
+func (l fingerprintListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &fingerprintConn{Conn: conn}, nil
+}
+
+type fingerprintConn struct {
+	net.Conn
+	once sync.Once
+}
+
+func (c *fingerprintConn) Close() error {
+	c.once.Do(func() { tlsFingerprints.Delete(c.RemoteAddr().String()) })
+	return c.Conn.Close()
+}
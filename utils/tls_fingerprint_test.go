@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/hex"
+	"testing"
+)
+
+func TestIsGREASE(t *testing.T) {
+	tests := []struct {
+		value uint16
+		want  bool
+	}{
+		{0x0a0a, true},
+		{0x1a1a, true},
+		{0xfafa, true},
+		{0x0a1a, false},
+		{0x1a0a, false},
+		{0x0b0b, false},
+		{tls.TLS_AES_128_GCM_SHA256, false},
+		{0, false},
+	}
+
+	for _, tt := range tests {
+		if got := isGREASE(tt.value); got != tt.want {
+			t.Errorf("isGREASE(%#04x) = %t, want %t", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestJA3(t *testing.T) {
+	hello := func(versions, suites, extensions []uint16, curves []tls.CurveID, points []uint8) *tls.ClientHelloInfo {
+		return &tls.ClientHelloInfo{
+			SupportedVersions: versions,
+			CipherSuites:      suites,
+			Extensions:        extensions,
+			SupportedCurves:   curves,
+			SupportedPoints:   points,
+		}
+	}
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	base := hello(
+		[]uint16{tls.VersionTLS13, tls.VersionTLS12},
+		[]uint16{4865, 4866},
+		[]uint16{0, 10, 11},
+		[]tls.CurveID{tls.X25519, tls.CurveP256},
+		[]uint8{0},
+	)
+
+	tests := []struct {
+		name  string
+		hello *tls.ClientHelloInfo
+		want  string
+	}{
+		{
+			name:  "TLS 1.3 is reported as 1.2",
+			hello: base,
+			want:  md5Hex("771,4865-4866,0-10-11,29-23,0"),
+		},
+		{
+			name:  "GREASE values are removed",
+			hello: hello([]uint16{0x0a0a, tls.VersionTLS13, tls.VersionTLS12}, []uint16{0x1a1a, 4865, 4866}, []uint16{0x2a2a, 0, 10, 11}, []tls.CurveID{0x3a3a, tls.X25519, tls.CurveP256}, []uint8{0}),
+			want:  md5Hex("771,4865-4866,0-10-11,29-23,0"),
+		},
+		{
+			name:  "extension order is ignored",
+			hello: hello([]uint16{tls.VersionTLS13, tls.VersionTLS12}, []uint16{4865, 4866}, []uint16{11, 0, 10}, []tls.CurveID{tls.X25519, tls.CurveP256}, []uint8{0}),
+			want:  md5Hex("771,4865-4866,0-10-11,29-23,0"),
+		},
+		{
+			name:  "cipher suite order is kept",
+			hello: hello([]uint16{tls.VersionTLS13, tls.VersionTLS12}, []uint16{4866, 4865}, []uint16{0, 10, 11}, []tls.CurveID{tls.X25519, tls.CurveP256}, []uint8{0}),
+			want:  md5Hex("771,4866-4865,0-10-11,29-23,0"),
+		},
+		{
+			name:  "TLS 1.1 client",
+			hello: hello([]uint16{tls.VersionTLS11, tls.VersionTLS10}, []uint16{47}, nil, nil, nil),
+			want:  md5Hex("770,47,,,"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JA3(tt.hello); got != tt.want {
+				t.Errorf("JA3 = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("extensions are not sorted in place", func(t *testing.T) {
+		extensions := []uint16{11, 0, 10}
+		JA3(hello(nil, nil, extensions, nil, nil))
+		if extensions[0] != 11 || extensions[1] != 0 || extensions[2] != 10 {
+			t.Errorf("ClientHello extensions reordered to %v", extensions)
+		}
+	})
+}
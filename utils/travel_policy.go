@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+)
+
+// TravelPolicy sets when the distance between a login and the user's
+// previous one counts as impossible travel. GeoIP is imprecise, so jumps
+// shorter than MinDistanceKm are ignored whatever the implied speed.
+type TravelPolicy struct {
+	MaxSpeedKmh   int `json:"max_speed_kmh"`
+	MinDistanceKm int `json:"min_distance_km"`
+}
+
+// NewTravelPolicy builds a TravelPolicy from environment variables:
+// GEO_MAX_SPEED_KMH (default 1000) and GEO_MIN_DISTANCE_KM (default 500).
+func NewTravelPolicy() TravelPolicy {
+	policy := TravelPolicy{
+		MaxSpeedKmh:   1000,
+		MinDistanceKm: 500,
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("GEO_MAX_SPEED_KMH")); err == nil && v > 0 {
+		policy.MaxSpeedKmh = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("GEO_MIN_DISTANCE_KM")); err == nil && v >= 0 {
+		policy.MinDistanceKm = v
+	}
+
+	return policy
+}
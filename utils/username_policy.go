@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultReservedUsernames cannot be registered because they could be used to
+// impersonate staff or collide with routes and system addresses.
+var defaultReservedUsernames = []string{
+	"admin", "administrator", "root", "superuser", "system", "sysadmin",
+	"support", "help", "helpdesk", "security", "abuse", "staff",
+	"moderator", "mod", "official", "team", "api", "www", "mail",
+	"email", "postmaster", "webmaster", "hostmaster", "noreply",
+	"no-reply", "info", "billing", "me", "null", "undefined", "anonymous",
+}
+
+// defaultUsernamePattern allows letters, digits, "_", "." and "-", starting
+// with a letter or digit.
+const defaultUsernamePattern = `^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`
+
+// UsernamePolicy describes how usernames are normalized and validated.
+type UsernamePolicy struct {
+	MinLength int            // minimum number of characters
+	MaxLength int            // maximum number of characters
+	Lowercase bool           // store usernames lower-cased
+	Pattern   *regexp.Regexp // allowed characters, applied after normalization
+	Reserved  map[string]struct{}
+}
+
+// NewUsernamePolicy builds a UsernamePolicy from environment variables:
+// USERNAME_MIN_LENGTH (default 3), USERNAME_MAX_LENGTH (default 32),
+// USERNAME_LOWERCASE (default true), USERNAME_PATTERN (default letters,
+// digits, "_", "." and "-") and USERNAME_RESERVED, a comma-separated list
+// added to the built-in reserved names.
+func NewUsernamePolicy() UsernamePolicy {
+	policy := UsernamePolicy{
+		MinLength: 3,
+		MaxLength: 32,
+		Lowercase: true,
+		Pattern:   regexp.MustCompile(defaultUsernamePattern),
+		Reserved:  make(map[string]struct{}),
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("USERNAME_MIN_LENGTH")); err == nil && v > 0 {
+		policy.MinLength = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("USERNAME_MAX_LENGTH")); err == nil && v > 0 && v <= 255 {
+		policy.MaxLength = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("USERNAME_LOWERCASE")); err == nil {
+		policy.Lowercase = v
+	}
+	if v := os.Getenv("USERNAME_PATTERN"); v != "" {
+		if re, err := regexp.Compile(v); err == nil {
+			policy.Pattern = re
+		} else {
+			log.Printf("ignoring invalid USERNAME_PATTERN: %v", err)
+		}
+	}
+
+	reserved := append(defaultReservedUsernames, strings.Split(os.Getenv("USERNAME_RESERVED"), ",")...)
+	for _, name := range reserved {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			policy.Reserved[name] = struct{}{}
+		}
+	}
+
+	return policy
+}
+
+// Normalize trims the username and lower-cases it if the policy says so.
+func (p UsernamePolicy) Normalize(username string) string {
+	username = strings.TrimSpace(username)
+	if p.Lowercase {
+		username = strings.ToLower(username)
+	}
+	return username
+}
+
+// IsReserved reports whether the username is reserved, ignoring case.
+func (p UsernamePolicy) IsReserved(username string) bool {
+	_, ok := p.Reserved[strings.ToLower(username)]
+	return ok
+}
+
+// Validate checks an already normalized username against the policy. The
+// returned error message is safe to show to clients.
+func (p UsernamePolicy) Validate(username string) error {
+	length := utf8.RuneCountInString(username)
+	if length < p.MinLength {
+		return fmt.Errorf("Username must be at least %d characters long", p.MinLength)
+	}
+	if length > p.MaxLength {
+		return fmt.Errorf("Username must be at most %d characters long", p.MaxLength)
+	}
+	if !p.Pattern.MatchString(username) {
+		return errors.New("Username contains invalid characters")
+	}
+	if p.IsReserved(username) {
+		return errors.New("This username is reserved")
+	}
+	return nil
+}
+
+// Sanitize derives a valid username from free-form input such as an OAuth
+// display name or email local part. Characters outside [a-zA-Z0-9_.-] become
+// "_" and the result is truncated to leave room for a uniqueness suffix. It
+// returns "" if no valid username can be derived.
+func (p UsernamePolicy) Sanitize(input string) string {
+	var b strings.Builder
+	for _, r := range p.Normalize(input) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	username := strings.Trim(b.String(), "_.-")
+	if limit := p.MaxLength - 5; limit > 0 && len(username) > limit {
+		username = strings.TrimRight(username[:limit], "_.-")
+	}
+
+	if p.Validate(username) != nil {
+		return ""
+	}
+	return username
+}
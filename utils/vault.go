@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// VaultTransitProvider implements EncryptionProvider using the HashiCorp
+// Vault Transit secrets engine.
+type VaultTransitProvider struct {
+	addr    string
+	token   string
+	mount   string
+	keyName string
+	client  *http.Client
+}
+
+// NewVaultTransitProvider builds a VaultTransitProvider from environment
+// variables: VAULT_ADDR, VAULT_TOKEN, VAULT_TRANSIT_KEY and optionally
+// VAULT_TRANSIT_MOUNT (default "transit").
+func NewVaultTransitProvider() (*VaultTransitProvider, error) {
+	p := &VaultTransitProvider{
+		addr:    strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		token:   os.Getenv("VAULT_TOKEN"),
+		mount:   strings.Trim(os.Getenv("VAULT_TRANSIT_MOUNT"), "/"),
+		keyName: os.Getenv("VAULT_TRANSIT_KEY"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if p.addr == "" || p.token == "" || p.keyName == "" {
+		return nil, errors.New("VAULT_ADDR, VAULT_TOKEN and VAULT_TRANSIT_KEY are required for the vault provider")
+	}
+	if p.mount == "" {
+		p.mount = "transit"
+	}
+
+	return p, nil
+}
+
+// Name implements EncryptionProvider.
+func (p *VaultTransitProvider) Name() string {
+	return "vault"
+}
+
+// GenerateDataKey implements EncryptionProvider.
+func (p *VaultTransitProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	var out struct {
+		Data struct {
+			Plaintext  string `json:"plaintext"`
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+
+	path := fmt.Sprintf("/v1/%s/datakey/plaintext/%s", p.mount, p.keyName)
+	if err := p.call(ctx, path, map[string]any{"bits": 256}, &out); err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(out.Data.Plaintext)
+	if err != nil {
+		return nil, nil, fmt.Errorf("vault datakey: decode plaintext: %w", err)
+	}
+
+	// Vault ciphertexts are opaque strings ("vault:v1:..."); store them as-is.
+	return plaintext, []byte(out.Data.Ciphertext), nil
+}
+
+// DecryptDataKey implements EncryptionProvider.
+func (p *VaultTransitProvider) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var out struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+
+	path := fmt.Sprintf("/v1/%s/decrypt/%s", p.mount, p.keyName)
+	if err := p.call(ctx, path, map[string]any{"ciphertext": string(wrapped)}, &out); err != nil {
+		return nil, err
+	}
+
+	plaintext, err := base64.StdEncoding.DecodeString(out.Data.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("vault decrypt: decode plaintext: %w", err)
+	}
+	return plaintext, nil
+}
+
+func (p *VaultTransitProvider) call(ctx context.Context, path string, in any, out any) error {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.addr+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", p.token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("vault %s: read response: %w", path, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("vault %s returned status %d: %s", path, resp.StatusCode, body)
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("vault %s: decode response: %w", path, err)
+	}
+	return nil
+}